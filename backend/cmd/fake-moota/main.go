@@ -0,0 +1,74 @@
+// Command fake-moota pushes a signed bank mutation to a locally running
+// server, the way the Moota mutation checker would. Use it to try the
+// webhook and the donation matching without a real bank account:
+//
+//	go run ./cmd/fake-moota -amount 100123
+//
+// Sending the same -id twice exercises duplicate delivery.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+	"masjid-baiturrahim-backend/internal/services"
+)
+
+func main() {
+	url := flag.String("url", "http://localhost:8080/api/v1/webhooks/mutations", "webhook endpoint")
+	secret := flag.String("secret", os.Getenv("MUTATION_WEBHOOK_SECRET"), "shared webhook secret")
+	id := flag.String("id", strconv.FormatInt(time.Now().UnixNano(), 36), "mutation ID")
+	bank := flag.String("bank", "bca", "bank type")
+	amount := flag.String("amount", "", "amount in rupiah, e.g. 100123")
+	kind := flag.String("type", "CR", "CR for incoming, DB for outgoing")
+	description := flag.String("description", "TRSF E-BANKING CR HAMBA ALLAH", "mutation description")
+	at := flag.String("date", time.Now().Format("2006-01-02 15:04:05"), "transaction time")
+	badSignature := flag.Bool("bad-signature", false, "send a wrong signature")
+	flag.Parse()
+
+	if *amount == "" || *secret == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	body, err := json.Marshal([]services.WebhookMutation{{
+		MutationID:    *id,
+		BankType:      *bank,
+		AccountNumber: "1234567890",
+		Date:          *at,
+		Description:   *description,
+		Type:          *kind,
+		Amount:        json.Number(*amount),
+	}})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	signature := services.SignMutationPayload(*secret, body)
+	if *badSignature {
+		signature = services.SignMutationPayload(*secret+"x", body)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, *url, bytes.NewReader(body))
+	if err != nil {
+		log.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(services.MutationSignatureHeader, signature)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	reply, _ := io.ReadAll(resp.Body)
+	fmt.Printf("mutation %s: %s\n%s\n", *id, resp.Status, reply)
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"masjid-baiturrahim-backend/internal/handlers"
+	"masjid-baiturrahim-backend/internal/middleware"
+	"masjid-baiturrahim-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// registerRoutes mounts the API on r. The database has to be in the context
+// as "db" for the auth middleware.
+func registerRoutes(r *gin.Engine, h *handlers.Handler) {
+	// API v1 routes
+	v1 := r.Group("/api/v1")
+	{
+		// Public routes
+		public := v1.Group("")
+		{
+			// Auth (public)
+			auth := public.Group("/auth")
+			{
+				auth.POST("/login", h.Login)
+				auth.POST("/refresh", h.Refresh)
+				auth.POST("/forgot-password", h.ForgotPassword)
+				auth.POST("/reset-password", h.ResetPassword)
+			}
+
+			// Public endpoints
+			public.GET("/mosque", h.GetMosqueInfo)
+			public.GET("/structure", h.GetStructures)
+			public.GET("/prayer-times", h.GetPrayerTimesByDate)
+			public.GET("/prayer-times/month", h.GetPrayerTimesByMonth)
+			public.GET("/content", h.GetContentSections)
+			public.GET("/events", h.GetEvents)
+			public.GET("/events/:slug", h.GetEventBySlug)
+			public.GET("/announcements", h.GetAnnouncements)
+			public.POST("/donations", h.CreateDonation)
+			public.GET("/donations/:code/status", h.GetDonationStatus)
+			public.GET("/donations/wall", h.GetDonorWall)
+			public.POST("/donations/:code/proof", h.UploadDonationProof)
+			public.GET("/payment-methods", h.GetPaymentMethods)
+			public.GET("/campaigns", h.GetCampaigns)
+			public.GET("/campaigns/:slug", h.GetCampaignBySlug)
+			public.GET("/campaigns/:slug/updates", h.GetCampaignUpdates)
+			public.GET("/reports/weekly", h.GetWeeklyReport)
+			public.GET("/reports/weekly/pdf", h.GetWeeklyReportPDF)
+			public.GET("/zakat/rates", h.GetZakatRates)
+			public.POST("/zakat/calculate/maal", h.CalculateZakatMaal)
+			public.POST("/zakat/calculate/profesi", h.CalculateZakatProfesi)
+			public.POST("/zakat/calculate/fitrah", h.CalculateZakatFitrah)
+			public.GET("/qurban/types", h.GetQurbanTypes)
+			public.POST("/qurban/register", h.RegisterQurban)
+			public.GET("/wakaf/summary", h.GetWakafSummary)
+			public.POST("/donor/login", h.DonorLogin)
+			public.POST("/donor/login/verify", h.DonorVerify)
+
+			// Bank mutations pushed by the mutation checker, signed with a shared secret
+			public.POST("/webhooks/mutations", h.ReceiveMutationWebhook)
+
+			// Payment notifications from the virtual account provider
+			public.POST("/payments/va/notify", h.ReceiveVirtualAccountPayment)
+		}
+
+		// Donor routes (require a donor login link token)
+		donor := v1.Group("/donor")
+		donor.Use(middleware.DonorAuthRequired())
+		{
+			donor.GET("/me", h.GetDonorMe)
+			donor.GET("/donations", h.GetDonorMyDonations)
+			donor.GET("/pledges", h.GetDonorMyPledges)
+		}
+
+		// Protected routes (require authentication). Users who have to replace
+		// a temporary password can only see their account, log out and change it.
+		protected := v1.Group("")
+		protected.Use(middleware.AuthRequired())
+		{
+			protected.GET("/auth/me", h.GetMe)
+			protected.GET("/auth/permissions", middleware.PasswordChangeRequired(), h.GetMyPermissions)
+			protected.POST("/auth/logout", h.Logout)
+			protected.POST("/auth/logout-all", h.LogoutAll)
+			protected.PUT("/auth/password", h.ChangePassword)
+		}
+
+		// Admin routes (require authentication, a changed password and a
+		// permission per route)
+		admin := v1.Group("/admin")
+		admin.Use(middleware.AuthRequired(), middleware.PasswordChangeRequired())
+		{
+			// Every route needs a permission of the caller's role, see models.RolePermissions
+			can := middleware.RequirePermission
+
+			// Mosque Info
+			admin.PUT("/mosque", can(models.PermMosqueEdit), h.UpdateMosqueInfo)
+
+			// Structure
+			admin.GET("/structure", can(models.PermMosqueEdit), h.GetStructures)
+			admin.POST("/structure", can(models.PermMosqueEdit), h.CreateStructure)
+			admin.PUT("/structure/:id", can(models.PermMosqueEdit), h.UpdateStructure)
+			admin.DELETE("/structure/:id", can(models.PermMosqueEdit), h.DeleteStructure)
+			admin.PUT("/structure/reorder", can(models.PermMosqueEdit), h.ReorderStructures)
+
+			// Prayer Times
+			admin.POST("/prayer-times", can(models.PermMosqueEdit), h.CreatePrayerTimes)
+			admin.POST("/prayer-times/bulk", can(models.PermMosqueEdit), h.BulkCreatePrayerTimes)
+			admin.PUT("/prayer-times/:id", can(models.PermMosqueEdit), h.UpdatePrayerTimes)
+			admin.DELETE("/prayer-times/:id", can(models.PermMosqueEdit), h.DeletePrayerTimes)
+			admin.POST("/prayer-times/generate", can(models.PermMosqueEdit), h.GeneratePrayerTimes)
+
+			// Content
+			admin.GET("/content", can(models.PermContentEdit), h.GetContentSections)
+			admin.GET("/content/:id", can(models.PermContentEdit), h.GetContentSection)
+			admin.PUT("/content/:id", can(models.PermContentEdit), h.UpdateContentSection)
+			admin.PUT("/content/reorder", can(models.PermContentEdit), h.ReorderContentSections)
+			admin.PUT("/content/:id/toggle", can(models.PermContentEdit), h.ToggleContentSection)
+
+			// Events
+			admin.GET("/events", can(models.PermContentEdit), h.GetEvents)
+			admin.POST("/events", can(models.PermContentEdit), h.CreateEvent)
+			admin.PUT("/events/:id", can(models.PermContentEdit), h.UpdateEvent)
+			admin.DELETE("/events/:id", can(models.PermContentEdit), h.DeleteEvent)
+
+			// Announcements
+			admin.GET("/announcements", can(models.PermContentEdit), h.GetAnnouncements)
+			admin.POST("/announcements", can(models.PermContentEdit), h.CreateAnnouncement)
+			admin.PUT("/announcements/:id", can(models.PermContentEdit), h.UpdateAnnouncement)
+			admin.DELETE("/announcements/:id", can(models.PermContentEdit), h.DeleteAnnouncement)
+
+			// Donations
+			admin.GET("/donations", can(models.PermDonationsView), h.GetDonations)
+			admin.GET("/donations/:id", can(models.PermDonationsView), h.GetDonation)
+			admin.PUT("/donations/:id/confirm", can(models.PermDonationsConfirm), h.ConfirmDonation)
+			admin.PUT("/donations/:id/reject", can(models.PermDonationsManage), h.RejectDonation)
+			admin.PUT("/donations/:id/cancel", can(models.PermDonationsManage), h.CancelDonation)
+			admin.PUT("/donations/:id/expire", can(models.PermDonationsManage), h.ExpireDonation)
+			admin.PUT("/donations/:id/refund", can(models.PermDonationsConfirm), h.RefundDonation)
+			admin.PUT("/donations/:id/wakaf-asset", can(models.PermDonationsManage), h.LinkWakafDonation)
+			admin.GET("/donations/stats", can(models.PermDonationsView), h.GetDonationStats)
+			admin.GET("/donations/export", can(models.PermDonationsView), h.ExportDonations)
+			admin.GET("/donations/:id/proof", can(models.PermDonationsView), h.GetDonationProof)
+
+			// Bank reconciliation
+			admin.POST("/reconciliation/imports", can(models.PermDonationsConfirm), h.ImportBankStatement)
+			admin.GET("/reconciliation/imports", can(models.PermDonationsView), h.GetBankStatementImports)
+			admin.GET("/reconciliation/mutations", can(models.PermDonationsView), h.GetBankMutations)
+			admin.GET("/reconciliation/queue", can(models.PermDonationsView), h.GetReconciliationQueue)
+			admin.PUT("/reconciliation/mutations/:id/confirm", can(models.PermDonationsConfirm), h.ConfirmBankMutation)
+			admin.PUT("/reconciliation/mutations/:id/ignore", can(models.PermDonationsConfirm), h.IgnoreBankMutation)
+
+			// Approvals
+			admin.GET("/approval-threshold", can(models.PermFinanceView), h.GetApprovalThreshold)
+			admin.PUT("/approval-threshold", can(models.PermFinanceApprove), h.RequestApprovalThreshold)
+			admin.GET("/approvals", can(models.PermFinanceView), h.GetApprovals)
+			admin.GET("/approvals/:id", can(models.PermFinanceView), h.GetApproval)
+			admin.POST("/approvals/:id/approve", can(models.PermFinanceApprove), h.ApproveApproval)
+			admin.POST("/approvals/:id/reject", can(models.PermFinanceApprove), h.RejectApproval)
+			admin.POST("/approvals/:id/cancel", can(models.PermFinanceView), h.CancelApproval)
+
+			// Divisions and fund requests (pengajuan dana)
+			admin.GET("/divisions", can(models.PermFundRequestsSubmit), h.GetDivisions)
+			admin.POST("/divisions", can(models.PermFinanceManage), h.CreateDivision)
+			admin.PUT("/divisions/:id", can(models.PermFinanceManage), h.UpdateDivision)
+			admin.DELETE("/divisions/:id", can(models.PermFinanceManage), h.DeleteDivision)
+			admin.GET("/divisions/:id/budget", can(models.PermFundRequestsSubmit), h.GetDivisionBudget)
+			admin.PUT("/divisions/:id/budget", can(models.PermFinanceApprove), h.SetDivisionBudget)
+			admin.GET("/fund-requests", can(models.PermFundRequestsSubmit), h.GetFundRequests)
+			admin.POST("/fund-requests", can(models.PermFundRequestsSubmit), h.CreateFundRequest)
+			admin.GET("/fund-requests/outstanding", can(models.PermFinanceView), h.GetOutstandingAdvances)
+			admin.GET("/fund-requests/:id", can(models.PermFundRequestsSubmit), h.GetFundRequest)
+			admin.PUT("/fund-requests/:id", can(models.PermFundRequestsSubmit), h.UpdateFundRequest)
+			admin.POST("/fund-requests/:id/approve", can(models.PermFinanceApprove), h.ApproveFundRequest)
+			admin.POST("/fund-requests/:id/reject", can(models.PermFinanceApprove), h.RejectFundRequest)
+			admin.POST("/fund-requests/:id/cancel", can(models.PermFundRequestsSubmit), h.CancelFundRequest)
+			admin.POST("/fund-requests/:id/disburse", can(models.PermFinanceApprove), h.DisburseFundRequest)
+			admin.POST("/fund-requests/:id/receipts", can(models.PermFundRequestsSubmit), h.UploadFundRequestReceipt)
+			admin.GET("/fund-requests/:id/receipts/:index", can(models.PermFundRequestsSubmit), h.GetFundRequestReceipt)
+			admin.DELETE("/fund-requests/:id/receipts/:index", can(models.PermFundRequestsSubmit), h.DeleteFundRequestReceipt)
+			admin.POST("/fund-requests/:id/report", can(models.PermFundRequestsSubmit), h.ReportFundRequest)
+
+			// Annual budget (RAPB)
+			admin.GET("/budgets", can(models.PermFinanceView), h.GetBudgetPlans)
+			admin.POST("/budgets", can(models.PermFinanceApprove), h.CreateBudgetPlan)
+			admin.GET("/budgets/:id", can(models.PermFinanceView), h.GetBudgetPlan)
+			admin.PUT("/budgets/:id", can(models.PermFinanceApprove), h.UpdateBudgetPlan)
+			admin.DELETE("/budgets/:id", can(models.PermFinanceApprove), h.DeleteBudgetPlan)
+			admin.PUT("/budgets/:id/lines", can(models.PermFinanceApprove), h.UpdateBudgetLines)
+			admin.POST("/budgets/:id/approve", can(models.PermFinanceApprove), h.ApproveBudgetPlan)
+			admin.POST("/budgets/:id/reopen", can(models.PermFinanceApprove), h.ReopenBudgetPlan)
+			admin.GET("/budgets/:id/report", can(models.PermFinanceView), h.GetBudgetReport)
+			admin.GET("/budgets/:id/report/xlsx", can(models.PermFinanceView), h.GetBudgetReportXLSX)
+
+			// Campaigns
+			admin.GET("/campaigns", can(models.PermContentEdit), h.GetCampaigns)
+			admin.POST("/campaigns", can(models.PermContentEdit), h.CreateCampaign)
+			admin.PUT("/campaigns/:id", can(models.PermContentEdit), h.UpdateCampaign)
+			admin.DELETE("/campaigns/:id", can(models.PermContentEdit), h.DeleteCampaign)
+			admin.POST("/campaigns/:id/updates", can(models.PermContentEdit), h.CreateCampaignUpdate)
+			admin.PUT("/campaigns/:id/updates/:updateId", can(models.PermContentEdit), h.UpdateCampaignUpdate)
+			admin.DELETE("/campaigns/:id/updates/:updateId", can(models.PermContentEdit), h.DeleteCampaignUpdate)
+
+			// Ledger
+			admin.GET("/ledger/accounts", can(models.PermFinanceView), h.GetLedgerAccounts)
+			admin.POST("/ledger/accounts", can(models.PermFinanceManage), h.CreateLedgerAccount)
+			admin.PUT("/ledger/accounts/:id", can(models.PermFinanceManage), h.UpdateLedgerAccount)
+			admin.DELETE("/ledger/accounts/:id", can(models.PermFinanceManage), h.DeleteLedgerAccount)
+			admin.GET("/ledger/categories", can(models.PermFinanceView), h.GetLedgerCategories)
+			admin.POST("/ledger/categories", can(models.PermFinanceManage), h.CreateLedgerCategory)
+			admin.PUT("/ledger/categories/:id", can(models.PermFinanceManage), h.UpdateLedgerCategory)
+			admin.DELETE("/ledger/categories/:id", can(models.PermFinanceManage), h.DeleteLedgerCategory)
+			admin.GET("/ledger/entries", can(models.PermFinanceView), h.GetLedgerEntries)
+			admin.POST("/ledger/entries", can(models.PermFinanceManage), h.CreateLedgerEntry)
+			admin.GET("/ledger/entries/:id", can(models.PermFinanceView), h.GetLedgerEntry)
+			admin.PUT("/ledger/entries/:id", can(models.PermFinanceManage), h.UpdateLedgerEntry)
+			admin.DELETE("/ledger/entries/:id", can(models.PermFinanceManage), h.DeleteLedgerEntry)
+			admin.POST("/ledger/entries/:id/attachments", can(models.PermFinanceManage), h.UploadLedgerAttachment)
+			admin.GET("/ledger/entries/:id/attachments/:index", can(models.PermFinanceView), h.GetLedgerAttachment)
+			admin.DELETE("/ledger/entries/:id/attachments/:index", can(models.PermFinanceManage), h.DeleteLedgerAttachment)
+			admin.POST("/ledger/sync-donations", can(models.PermFinanceManage), h.SyncDonationsToLedger)
+
+			// Zakat
+			admin.GET("/zakat/muzakki", can(models.PermZakatManage), h.GetMuzakkiList)
+			admin.POST("/zakat/muzakki", can(models.PermZakatManage), h.CreateMuzakki)
+			admin.GET("/zakat/muzakki/:id", can(models.PermZakatManage), h.GetMuzakki)
+			admin.PUT("/zakat/muzakki/:id", can(models.PermZakatManage), h.UpdateMuzakki)
+			admin.GET("/zakat/payments", can(models.PermZakatManage), h.GetZakatPayments)
+			admin.POST("/zakat/payments", can(models.PermZakatManage), h.RecordZakatPayment)
+			admin.GET("/zakat/summary", can(models.PermZakatManage), h.GetZakatSummary)
+
+			// Mustahik and distributions
+			admin.GET("/mustahik", can(models.PermZakatManage), h.GetMustahikList)
+			admin.POST("/mustahik", can(models.PermZakatManage), h.CreateMustahik)
+			admin.GET("/mustahik/:id", can(models.PermZakatManage), h.GetMustahik)
+			admin.PUT("/mustahik/:id", can(models.PermZakatManage), h.UpdateMustahik)
+			admin.DELETE("/mustahik/:id", can(models.PermZakatManage), h.DeleteMustahik)
+			admin.PUT("/mustahik/:id/verify", can(models.PermZakatManage), h.VerifyMustahik)
+			admin.GET("/distributions", can(models.PermZakatManage), h.GetDistributions)
+			admin.POST("/distributions", can(models.PermZakatManage), h.CreateDistribution)
+			admin.DELETE("/distributions/:id", can(models.PermZakatManage), h.DeleteDistribution)
+			admin.GET("/distributions/funds", can(models.PermZakatManage), h.GetFundBalances)
+			admin.GET("/distributions/report", can(models.PermZakatManage), h.GetDistributionReport)
+
+			// Qurban
+			admin.GET("/qurban/types", can(models.PermQurbanManage), h.GetQurbanTypes)
+			admin.POST("/qurban/types", can(models.PermQurbanManage), h.CreateQurbanType)
+			admin.PUT("/qurban/types/:id", can(models.PermQurbanManage), h.UpdateQurbanType)
+			admin.GET("/qurban/shares", can(models.PermQurbanManage), h.GetQurbanShares)
+			admin.POST("/qurban/shares", can(models.PermQurbanManage), h.RegisterQurban)
+			admin.GET("/qurban/animals", can(models.PermQurbanManage), h.GetQurbanAnimals)
+			admin.PUT("/qurban/animals/:id", can(models.PermQurbanManage), h.UpdateQurbanAnimal)
+			admin.PUT("/qurban/animals/:id/status", can(models.PermQurbanManage), h.UpdateQurbanAnimalStatus)
+			admin.GET("/qurban/coupons", can(models.PermQurbanManage), h.GetQurbanCoupons)
+			admin.POST("/qurban/coupons", can(models.PermQurbanManage), h.GenerateQurbanCoupons)
+			admin.PUT("/qurban/coupons/:code/redeem", can(models.PermQurbanManage), h.RedeemQurbanCoupon)
+			admin.GET("/qurban/coupons/:code/qr", can(models.PermQurbanManage), h.GetQurbanCouponQR)
+
+			// Donors
+			admin.GET("/donors", can(models.PermDonationsView), h.GetDonors)
+			admin.GET("/donors/duplicates", can(models.PermDonationsView), h.GetDuplicateDonors)
+			admin.POST("/donors/link-donations", can(models.PermDonationsManage), h.LinkDonationsToDonors)
+			admin.GET("/donors/:id", can(models.PermDonationsView), h.GetDonor)
+			admin.PUT("/donors/:id", can(models.PermDonationsManage), h.UpdateDonor)
+			admin.POST("/donors/:id/merge", can(models.PermDonationsManage), h.MergeDonors)
+
+			// Pledges
+			admin.GET("/pledges", can(models.PermDonationsView), h.GetPledges)
+			admin.POST("/pledges", can(models.PermDonationsManage), h.CreatePledge)
+			admin.GET("/pledges/fulfilment", can(models.PermDonationsView), h.GetPledgeFulfilment)
+			admin.POST("/pledges/run", can(models.PermDonationsManage), h.RunPledgeSchedule)
+			admin.GET("/pledges/:id", can(models.PermDonationsView), h.GetPledge)
+			admin.PUT("/pledges/:id", can(models.PermDonationsManage), h.UpdatePledge)
+			admin.PUT("/pledges/:id/status", can(models.PermDonationsManage), h.UpdatePledgeStatus)
+
+			// Wakaf
+			admin.GET("/wakaf/nazhir", can(models.PermWakafManage), h.GetNazhirs)
+			admin.POST("/wakaf/nazhir", can(models.PermWakafManage), h.CreateNazhir)
+			admin.PUT("/wakaf/nazhir/:id", can(models.PermWakafManage), h.UpdateNazhir)
+			admin.DELETE("/wakaf/nazhir/:id", can(models.PermWakafManage), h.DeleteNazhir)
+			admin.GET("/wakaf/assets", can(models.PermWakafManage), h.GetWakafAssets)
+			admin.POST("/wakaf/assets", can(models.PermWakafManage), h.CreateWakafAsset)
+			admin.GET("/wakaf/assets/:id", can(models.PermWakafManage), h.GetWakafAsset)
+			admin.PUT("/wakaf/assets/:id", can(models.PermWakafManage), h.UpdateWakafAsset)
+			admin.DELETE("/wakaf/assets/:id", can(models.PermWakafManage), h.DeleteWakafAsset)
+			admin.POST("/wakaf/assets/:id/documents", can(models.PermWakafManage), h.UploadWakafDocument)
+			admin.GET("/wakaf/assets/:id/documents/:index", can(models.PermWakafManage), h.GetWakafDocument)
+			admin.DELETE("/wakaf/assets/:id/documents/:index", can(models.PermWakafManage), h.DeleteWakafDocument)
+			admin.POST("/wakaf/assets/:id/yields", can(models.PermWakafManage), h.CreateWakafYield)
+			admin.DELETE("/wakaf/assets/:id/yields/:yieldId", can(models.PermWakafManage), h.DeleteWakafYield)
+
+			// Kotak amal
+			admin.GET("/kotak-amal/boxes", can(models.PermCharityBoxCount), h.GetCharityBoxes)
+			admin.POST("/kotak-amal/boxes", can(models.PermCharityBoxCount), h.CreateCharityBox)
+			admin.PUT("/kotak-amal/boxes/:id", can(models.PermCharityBoxCount), h.UpdateCharityBox)
+			admin.DELETE("/kotak-amal/boxes/:id", can(models.PermCharityBoxCount), h.DeleteCharityBox)
+			admin.GET("/kotak-amal/sessions", can(models.PermCharityBoxCount), h.GetCharityCounts)
+			admin.POST("/kotak-amal/sessions", can(models.PermCharityBoxCount), h.CreateCharityCount)
+			admin.GET("/kotak-amal/sessions/:id", can(models.PermCharityBoxCount), h.GetCharityCount)
+			admin.PUT("/kotak-amal/sessions/:id/counts", can(models.PermCharityBoxCount), h.UpdateCharityCountLines)
+			admin.POST("/kotak-amal/sessions/:id/sign", can(models.PermCharityBoxCount), h.SignCharityCount)
+			admin.POST("/kotak-amal/sessions/:id/recount", can(models.PermCharityBoxCount), h.RecountCharityCount)
+			admin.DELETE("/kotak-amal/sessions/:id", can(models.PermCharityBoxCount), h.DeleteCharityCount)
+
+			// Payment Methods
+			admin.GET("/payment-methods", can(models.PermSettingsManage), h.GetPaymentMethods)
+			admin.POST("/payment-methods", can(models.PermSettingsManage), h.CreatePaymentMethod)
+			admin.PUT("/payment-methods/:id", can(models.PermSettingsManage), h.UpdatePaymentMethod)
+			admin.DELETE("/payment-methods/:id", can(models.PermSettingsManage), h.DeletePaymentMethod)
+			admin.PUT("/payment-methods/reorder", can(models.PermSettingsManage), h.ReorderPaymentMethods)
+
+			// Upload
+			admin.POST("/upload", can(models.PermContentEdit), h.UploadImage)
+			admin.DELETE("/upload", can(models.PermContentEdit), h.DeleteImage)
+
+			// Settings
+			admin.GET("/settings", can(models.PermSettingsManage), h.GetSettings)
+			admin.PUT("/settings/:key", can(models.PermSettingsManage), h.UpdateSetting)
+
+			// Users
+			admin.GET("/users", can(models.PermUsersManage), h.GetUsers)
+			admin.POST("/users", can(models.PermUsersManage), h.CreateUser)
+			admin.PUT("/users/:id", can(models.PermUsersManage), h.UpdateUser)
+			admin.DELETE("/users/:id", can(models.PermUsersManage), h.DeleteUser)
+			admin.POST("/users/:id/reset-password", can(models.PermUsersManage), h.ResetUserPassword)
+		}
+	}
+
+	// Uploaded images and generated QRIS codes
+	r.Static("/uploads", "./uploads")
+
+	// Health check
+	r.GET("/health", func(c *gin.Context) {
+		c.JSON(200, gin.H{"status": "ok", "service": "masjid-baiturrahim-api"})
+	})
+}
@@ -0,0 +1,461 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"masjid-baiturrahim-backend/config"
+	"masjid-baiturrahim-backend/internal/handlers"
+	"masjid-baiturrahim-backend/internal/models"
+	"masjid-baiturrahim-backend/internal/services"
+	"masjid-baiturrahim-backend/internal/testutil"
+	"masjid-baiturrahim-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// adminRoutes is every admin route with the permission it needs. A route
+// added to registerRoutes has to be listed here too.
+var adminRoutes = []struct {
+	method     string
+	path       string
+	permission models.Permission
+}{
+	{"PUT", "/api/v1/admin/mosque", models.PermMosqueEdit},
+	{"GET", "/api/v1/admin/structure", models.PermMosqueEdit},
+	{"POST", "/api/v1/admin/structure", models.PermMosqueEdit},
+	{"PUT", "/api/v1/admin/structure/:id", models.PermMosqueEdit},
+	{"DELETE", "/api/v1/admin/structure/:id", models.PermMosqueEdit},
+	{"PUT", "/api/v1/admin/structure/reorder", models.PermMosqueEdit},
+	{"POST", "/api/v1/admin/prayer-times", models.PermMosqueEdit},
+	{"POST", "/api/v1/admin/prayer-times/bulk", models.PermMosqueEdit},
+	{"PUT", "/api/v1/admin/prayer-times/:id", models.PermMosqueEdit},
+	{"DELETE", "/api/v1/admin/prayer-times/:id", models.PermMosqueEdit},
+	{"POST", "/api/v1/admin/prayer-times/generate", models.PermMosqueEdit},
+	{"GET", "/api/v1/admin/content", models.PermContentEdit},
+	{"GET", "/api/v1/admin/content/:id", models.PermContentEdit},
+	{"PUT", "/api/v1/admin/content/:id", models.PermContentEdit},
+	{"PUT", "/api/v1/admin/content/reorder", models.PermContentEdit},
+	{"PUT", "/api/v1/admin/content/:id/toggle", models.PermContentEdit},
+	{"GET", "/api/v1/admin/events", models.PermContentEdit},
+	{"POST", "/api/v1/admin/events", models.PermContentEdit},
+	{"PUT", "/api/v1/admin/events/:id", models.PermContentEdit},
+	{"DELETE", "/api/v1/admin/events/:id", models.PermContentEdit},
+	{"GET", "/api/v1/admin/announcements", models.PermContentEdit},
+	{"POST", "/api/v1/admin/announcements", models.PermContentEdit},
+	{"PUT", "/api/v1/admin/announcements/:id", models.PermContentEdit},
+	{"DELETE", "/api/v1/admin/announcements/:id", models.PermContentEdit},
+	{"GET", "/api/v1/admin/donations", models.PermDonationsView},
+	{"GET", "/api/v1/admin/donations/:id", models.PermDonationsView},
+	{"PUT", "/api/v1/admin/donations/:id/confirm", models.PermDonationsConfirm},
+	{"PUT", "/api/v1/admin/donations/:id/reject", models.PermDonationsManage},
+	{"PUT", "/api/v1/admin/donations/:id/cancel", models.PermDonationsManage},
+	{"PUT", "/api/v1/admin/donations/:id/expire", models.PermDonationsManage},
+	{"PUT", "/api/v1/admin/donations/:id/refund", models.PermDonationsConfirm},
+	{"PUT", "/api/v1/admin/donations/:id/wakaf-asset", models.PermDonationsManage},
+	{"GET", "/api/v1/admin/donations/stats", models.PermDonationsView},
+	{"GET", "/api/v1/admin/donations/export", models.PermDonationsView},
+	{"GET", "/api/v1/admin/donations/:id/proof", models.PermDonationsView},
+	{"POST", "/api/v1/admin/reconciliation/imports", models.PermDonationsConfirm},
+	{"GET", "/api/v1/admin/reconciliation/imports", models.PermDonationsView},
+	{"GET", "/api/v1/admin/reconciliation/mutations", models.PermDonationsView},
+	{"GET", "/api/v1/admin/reconciliation/queue", models.PermDonationsView},
+	{"PUT", "/api/v1/admin/reconciliation/mutations/:id/confirm", models.PermDonationsConfirm},
+	{"PUT", "/api/v1/admin/reconciliation/mutations/:id/ignore", models.PermDonationsConfirm},
+	{"GET", "/api/v1/admin/approval-threshold", models.PermFinanceView},
+	{"PUT", "/api/v1/admin/approval-threshold", models.PermFinanceApprove},
+	{"GET", "/api/v1/admin/approvals", models.PermFinanceView},
+	{"GET", "/api/v1/admin/approvals/:id", models.PermFinanceView},
+	{"POST", "/api/v1/admin/approvals/:id/approve", models.PermFinanceApprove},
+	{"POST", "/api/v1/admin/approvals/:id/reject", models.PermFinanceApprove},
+	{"POST", "/api/v1/admin/approvals/:id/cancel", models.PermFinanceView},
+	{"GET", "/api/v1/admin/divisions", models.PermFundRequestsSubmit},
+	{"POST", "/api/v1/admin/divisions", models.PermFinanceManage},
+	{"PUT", "/api/v1/admin/divisions/:id", models.PermFinanceManage},
+	{"DELETE", "/api/v1/admin/divisions/:id", models.PermFinanceManage},
+	{"GET", "/api/v1/admin/divisions/:id/budget", models.PermFundRequestsSubmit},
+	{"PUT", "/api/v1/admin/divisions/:id/budget", models.PermFinanceApprove},
+	{"GET", "/api/v1/admin/fund-requests", models.PermFundRequestsSubmit},
+	{"POST", "/api/v1/admin/fund-requests", models.PermFundRequestsSubmit},
+	{"GET", "/api/v1/admin/fund-requests/outstanding", models.PermFinanceView},
+	{"GET", "/api/v1/admin/fund-requests/:id", models.PermFundRequestsSubmit},
+	{"PUT", "/api/v1/admin/fund-requests/:id", models.PermFundRequestsSubmit},
+	{"POST", "/api/v1/admin/fund-requests/:id/approve", models.PermFinanceApprove},
+	{"POST", "/api/v1/admin/fund-requests/:id/reject", models.PermFinanceApprove},
+	{"POST", "/api/v1/admin/fund-requests/:id/cancel", models.PermFundRequestsSubmit},
+	{"POST", "/api/v1/admin/fund-requests/:id/disburse", models.PermFinanceApprove},
+	{"POST", "/api/v1/admin/fund-requests/:id/receipts", models.PermFundRequestsSubmit},
+	{"GET", "/api/v1/admin/fund-requests/:id/receipts/:index", models.PermFundRequestsSubmit},
+	{"DELETE", "/api/v1/admin/fund-requests/:id/receipts/:index", models.PermFundRequestsSubmit},
+	{"POST", "/api/v1/admin/fund-requests/:id/report", models.PermFundRequestsSubmit},
+	{"GET", "/api/v1/admin/budgets", models.PermFinanceView},
+	{"POST", "/api/v1/admin/budgets", models.PermFinanceApprove},
+	{"GET", "/api/v1/admin/budgets/:id", models.PermFinanceView},
+	{"PUT", "/api/v1/admin/budgets/:id", models.PermFinanceApprove},
+	{"DELETE", "/api/v1/admin/budgets/:id", models.PermFinanceApprove},
+	{"PUT", "/api/v1/admin/budgets/:id/lines", models.PermFinanceApprove},
+	{"POST", "/api/v1/admin/budgets/:id/approve", models.PermFinanceApprove},
+	{"POST", "/api/v1/admin/budgets/:id/reopen", models.PermFinanceApprove},
+	{"GET", "/api/v1/admin/budgets/:id/report", models.PermFinanceView},
+	{"GET", "/api/v1/admin/budgets/:id/report/xlsx", models.PermFinanceView},
+	{"GET", "/api/v1/admin/campaigns", models.PermContentEdit},
+	{"POST", "/api/v1/admin/campaigns", models.PermContentEdit},
+	{"PUT", "/api/v1/admin/campaigns/:id", models.PermContentEdit},
+	{"DELETE", "/api/v1/admin/campaigns/:id", models.PermContentEdit},
+	{"POST", "/api/v1/admin/campaigns/:id/updates", models.PermContentEdit},
+	{"PUT", "/api/v1/admin/campaigns/:id/updates/:updateId", models.PermContentEdit},
+	{"DELETE", "/api/v1/admin/campaigns/:id/updates/:updateId", models.PermContentEdit},
+	{"GET", "/api/v1/admin/ledger/accounts", models.PermFinanceView},
+	{"POST", "/api/v1/admin/ledger/accounts", models.PermFinanceManage},
+	{"PUT", "/api/v1/admin/ledger/accounts/:id", models.PermFinanceManage},
+	{"DELETE", "/api/v1/admin/ledger/accounts/:id", models.PermFinanceManage},
+	{"GET", "/api/v1/admin/ledger/categories", models.PermFinanceView},
+	{"POST", "/api/v1/admin/ledger/categories", models.PermFinanceManage},
+	{"PUT", "/api/v1/admin/ledger/categories/:id", models.PermFinanceManage},
+	{"DELETE", "/api/v1/admin/ledger/categories/:id", models.PermFinanceManage},
+	{"GET", "/api/v1/admin/ledger/entries", models.PermFinanceView},
+	{"POST", "/api/v1/admin/ledger/entries", models.PermFinanceManage},
+	{"GET", "/api/v1/admin/ledger/entries/:id", models.PermFinanceView},
+	{"PUT", "/api/v1/admin/ledger/entries/:id", models.PermFinanceManage},
+	{"DELETE", "/api/v1/admin/ledger/entries/:id", models.PermFinanceManage},
+	{"POST", "/api/v1/admin/ledger/entries/:id/attachments", models.PermFinanceManage},
+	{"GET", "/api/v1/admin/ledger/entries/:id/attachments/:index", models.PermFinanceView},
+	{"DELETE", "/api/v1/admin/ledger/entries/:id/attachments/:index", models.PermFinanceManage},
+	{"POST", "/api/v1/admin/ledger/sync-donations", models.PermFinanceManage},
+	{"GET", "/api/v1/admin/zakat/muzakki", models.PermZakatManage},
+	{"POST", "/api/v1/admin/zakat/muzakki", models.PermZakatManage},
+	{"GET", "/api/v1/admin/zakat/muzakki/:id", models.PermZakatManage},
+	{"PUT", "/api/v1/admin/zakat/muzakki/:id", models.PermZakatManage},
+	{"GET", "/api/v1/admin/zakat/payments", models.PermZakatManage},
+	{"POST", "/api/v1/admin/zakat/payments", models.PermZakatManage},
+	{"GET", "/api/v1/admin/zakat/summary", models.PermZakatManage},
+	{"GET", "/api/v1/admin/mustahik", models.PermZakatManage},
+	{"POST", "/api/v1/admin/mustahik", models.PermZakatManage},
+	{"GET", "/api/v1/admin/mustahik/:id", models.PermZakatManage},
+	{"PUT", "/api/v1/admin/mustahik/:id", models.PermZakatManage},
+	{"DELETE", "/api/v1/admin/mustahik/:id", models.PermZakatManage},
+	{"PUT", "/api/v1/admin/mustahik/:id/verify", models.PermZakatManage},
+	{"GET", "/api/v1/admin/distributions", models.PermZakatManage},
+	{"POST", "/api/v1/admin/distributions", models.PermZakatManage},
+	{"DELETE", "/api/v1/admin/distributions/:id", models.PermZakatManage},
+	{"GET", "/api/v1/admin/distributions/funds", models.PermZakatManage},
+	{"GET", "/api/v1/admin/distributions/report", models.PermZakatManage},
+	{"GET", "/api/v1/admin/qurban/types", models.PermQurbanManage},
+	{"POST", "/api/v1/admin/qurban/types", models.PermQurbanManage},
+	{"PUT", "/api/v1/admin/qurban/types/:id", models.PermQurbanManage},
+	{"GET", "/api/v1/admin/qurban/shares", models.PermQurbanManage},
+	{"POST", "/api/v1/admin/qurban/shares", models.PermQurbanManage},
+	{"GET", "/api/v1/admin/qurban/animals", models.PermQurbanManage},
+	{"PUT", "/api/v1/admin/qurban/animals/:id", models.PermQurbanManage},
+	{"PUT", "/api/v1/admin/qurban/animals/:id/status", models.PermQurbanManage},
+	{"GET", "/api/v1/admin/qurban/coupons", models.PermQurbanManage},
+	{"POST", "/api/v1/admin/qurban/coupons", models.PermQurbanManage},
+	{"PUT", "/api/v1/admin/qurban/coupons/:code/redeem", models.PermQurbanManage},
+	{"GET", "/api/v1/admin/qurban/coupons/:code/qr", models.PermQurbanManage},
+	{"GET", "/api/v1/admin/donors", models.PermDonationsView},
+	{"GET", "/api/v1/admin/donors/duplicates", models.PermDonationsView},
+	{"POST", "/api/v1/admin/donors/link-donations", models.PermDonationsManage},
+	{"GET", "/api/v1/admin/donors/:id", models.PermDonationsView},
+	{"PUT", "/api/v1/admin/donors/:id", models.PermDonationsManage},
+	{"POST", "/api/v1/admin/donors/:id/merge", models.PermDonationsManage},
+	{"GET", "/api/v1/admin/pledges", models.PermDonationsView},
+	{"POST", "/api/v1/admin/pledges", models.PermDonationsManage},
+	{"GET", "/api/v1/admin/pledges/fulfilment", models.PermDonationsView},
+	{"POST", "/api/v1/admin/pledges/run", models.PermDonationsManage},
+	{"GET", "/api/v1/admin/pledges/:id", models.PermDonationsView},
+	{"PUT", "/api/v1/admin/pledges/:id", models.PermDonationsManage},
+	{"PUT", "/api/v1/admin/pledges/:id/status", models.PermDonationsManage},
+	{"GET", "/api/v1/admin/wakaf/nazhir", models.PermWakafManage},
+	{"POST", "/api/v1/admin/wakaf/nazhir", models.PermWakafManage},
+	{"PUT", "/api/v1/admin/wakaf/nazhir/:id", models.PermWakafManage},
+	{"DELETE", "/api/v1/admin/wakaf/nazhir/:id", models.PermWakafManage},
+	{"GET", "/api/v1/admin/wakaf/assets", models.PermWakafManage},
+	{"POST", "/api/v1/admin/wakaf/assets", models.PermWakafManage},
+	{"GET", "/api/v1/admin/wakaf/assets/:id", models.PermWakafManage},
+	{"PUT", "/api/v1/admin/wakaf/assets/:id", models.PermWakafManage},
+	{"DELETE", "/api/v1/admin/wakaf/assets/:id", models.PermWakafManage},
+	{"POST", "/api/v1/admin/wakaf/assets/:id/documents", models.PermWakafManage},
+	{"GET", "/api/v1/admin/wakaf/assets/:id/documents/:index", models.PermWakafManage},
+	{"DELETE", "/api/v1/admin/wakaf/assets/:id/documents/:index", models.PermWakafManage},
+	{"POST", "/api/v1/admin/wakaf/assets/:id/yields", models.PermWakafManage},
+	{"DELETE", "/api/v1/admin/wakaf/assets/:id/yields/:yieldId", models.PermWakafManage},
+	{"GET", "/api/v1/admin/kotak-amal/boxes", models.PermCharityBoxCount},
+	{"POST", "/api/v1/admin/kotak-amal/boxes", models.PermCharityBoxCount},
+	{"PUT", "/api/v1/admin/kotak-amal/boxes/:id", models.PermCharityBoxCount},
+	{"DELETE", "/api/v1/admin/kotak-amal/boxes/:id", models.PermCharityBoxCount},
+	{"GET", "/api/v1/admin/kotak-amal/sessions", models.PermCharityBoxCount},
+	{"POST", "/api/v1/admin/kotak-amal/sessions", models.PermCharityBoxCount},
+	{"GET", "/api/v1/admin/kotak-amal/sessions/:id", models.PermCharityBoxCount},
+	{"PUT", "/api/v1/admin/kotak-amal/sessions/:id/counts", models.PermCharityBoxCount},
+	{"POST", "/api/v1/admin/kotak-amal/sessions/:id/sign", models.PermCharityBoxCount},
+	{"POST", "/api/v1/admin/kotak-amal/sessions/:id/recount", models.PermCharityBoxCount},
+	{"DELETE", "/api/v1/admin/kotak-amal/sessions/:id", models.PermCharityBoxCount},
+	{"GET", "/api/v1/admin/payment-methods", models.PermSettingsManage},
+	{"POST", "/api/v1/admin/payment-methods", models.PermSettingsManage},
+	{"PUT", "/api/v1/admin/payment-methods/:id", models.PermSettingsManage},
+	{"DELETE", "/api/v1/admin/payment-methods/:id", models.PermSettingsManage},
+	{"PUT", "/api/v1/admin/payment-methods/reorder", models.PermSettingsManage},
+	{"POST", "/api/v1/admin/upload", models.PermContentEdit},
+	{"DELETE", "/api/v1/admin/upload", models.PermContentEdit},
+	{"GET", "/api/v1/admin/settings", models.PermSettingsManage},
+	{"PUT", "/api/v1/admin/settings/:key", models.PermSettingsManage},
+	{"GET", "/api/v1/admin/users", models.PermUsersManage},
+	{"POST", "/api/v1/admin/users", models.PermUsersManage},
+	{"PUT", "/api/v1/admin/users/:id", models.PermUsersManage},
+	{"DELETE", "/api/v1/admin/users/:id", models.PermUsersManage},
+	{"POST", "/api/v1/admin/users/:id/reset-password", models.PermUsersManage},
+}
+
+// rolePermissions is what each role may do, written out on its own so a
+// change to models.RolePermissions shows up here.
+var rolePermissions = map[models.UserRole][]models.Permission{
+	models.RoleSuperAdmin: {
+		models.PermMosqueEdit, models.PermContentEdit,
+		models.PermDonationsView, models.PermDonationsManage, models.PermDonationsConfirm,
+		models.PermFinanceView, models.PermFinanceManage, models.PermFundRequestsSubmit,
+		models.PermZakatManage, models.PermQurbanManage, models.PermWakafManage, models.PermCharityBoxCount,
+		models.PermSettingsManage, models.PermUsersManage,
+	},
+	models.RoleAdmin: {
+		models.PermMosqueEdit, models.PermContentEdit,
+		models.PermDonationsView, models.PermDonationsManage, models.PermDonationsConfirm,
+		models.PermFinanceView, models.PermFinanceManage, models.PermFundRequestsSubmit,
+		models.PermZakatManage, models.PermQurbanManage, models.PermWakafManage, models.PermCharityBoxCount,
+		models.PermSettingsManage, models.PermUsersManage,
+	},
+	models.RoleEditor: {
+		models.PermMosqueEdit, models.PermContentEdit, models.PermFundRequestsSubmit,
+	},
+	models.RoleTreasurer: {
+		models.PermDonationsView, models.PermDonationsManage, models.PermDonationsConfirm,
+		models.PermFinanceView, models.PermFinanceManage, models.PermFinanceApprove, models.PermFundRequestsSubmit,
+		models.PermZakatManage, models.PermWakafManage, models.PermCharityBoxCount,
+	},
+}
+
+var pathParam = regexp.MustCompile(`:[A-Za-z]+`)
+
+func newTestRouter(t *testing.T) (*gin.Engine, *gorm.DB, map[models.UserRole]string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	db := testutil.NewDB(t)
+
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.Use(func(c *gin.Context) {
+		c.Set("db", db)
+		c.Next()
+	})
+	registerRoutes(r, handlers.New(db))
+
+	tokens := make(map[models.UserRole]string)
+	for role := range models.RolePermissions {
+		user := models.User{
+			Username:     string(role),
+			Email:        string(role) + "@example.com",
+			PasswordHash: "-",
+			FullName:     string(role),
+			Role:         role,
+			IsActive:     true,
+		}
+		if err := db.Create(&user).Error; err != nil {
+			t.Fatal(err)
+		}
+		tokens[role] = login(t, db, &user)
+	}
+	return r, db, tokens
+}
+
+// login starts a session for user and returns its access token.
+func login(t *testing.T, db *gorm.DB, user *models.User) string {
+	t.Helper()
+	session, _, err := services.StartSession(db, user.ID, "test", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := utils.GenerateAccessToken(user.ID, session.ID, user.Email, string(user.Role), config.Load().JWTSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func request(r http.Handler, method, path, token string) int {
+	return requestJSON(r, method, path, token, "")
+}
+
+func requestJSON(r http.Handler, method, path, token, body string) int {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestAdminRoutePermissions(t *testing.T) {
+	r, _, tokens := newTestRouter(t)
+
+	listed := make(map[string]bool)
+	for _, route := range adminRoutes {
+		listed[route.method+" "+route.path] = true
+	}
+	registered := 0
+	for _, route := range r.Routes() {
+		if len(route.Path) < len("/api/v1/admin/") || route.Path[:len("/api/v1/admin/")] != "/api/v1/admin/" {
+			continue
+		}
+		registered++
+		if !listed[route.Method+" "+route.Path] {
+			t.Errorf("%s %s is not in adminRoutes", route.Method, route.Path)
+		}
+	}
+	if registered != len(adminRoutes) {
+		t.Errorf("%d admin routes registered, %d listed", registered, len(adminRoutes))
+	}
+
+	for role, perms := range rolePermissions {
+		if len(models.RolePermissions[role]) != len(perms) {
+			t.Errorf("%s has %d permissions, want %d", role, len(models.RolePermissions[role]), len(perms))
+		}
+		granted := make(map[models.Permission]bool)
+		for _, p := range perms {
+			granted[p] = true
+		}
+
+		for _, route := range adminRoutes {
+			path := pathParam.ReplaceAllStringFunc(route.path, func(string) string { return uuid.NewString() })
+			code := request(r, route.method, path, tokens[role])
+			name := fmt.Sprintf("%s: %s %s", role, route.method, route.path)
+			switch {
+			case code == http.StatusUnauthorized:
+				t.Errorf("%s: status 401, the token was not accepted", name)
+			case granted[route.permission] && code == http.StatusForbidden:
+				t.Errorf("%s: status 403, want it allowed with %s", name, route.permission)
+			case !granted[route.permission] && code != http.StatusForbidden:
+				t.Errorf("%s: status %d, want 403 without %s", name, code, route.permission)
+			}
+		}
+	}
+}
+
+func TestProtectedRoutesNeedLogin(t *testing.T) {
+	r, _, tokens := newTestRouter(t)
+
+	for _, route := range adminRoutes[:5] {
+		if code := request(r, route.method, route.path, ""); code != http.StatusUnauthorized {
+			t.Errorf("%s %s without a token: status %d, want 401", route.method, route.path, code)
+		}
+	}
+
+	// Every role can see itself and its permissions
+	for role, token := range tokens {
+		for _, path := range []string{"/api/v1/auth/me", "/api/v1/auth/permissions"} {
+			if code := request(r, http.MethodGet, path, token); code != http.StatusOK {
+				t.Errorf("%s: GET %s status %d, want 200", role, path, code)
+			}
+		}
+	}
+}
+
+func TestPasswordChangeRequired(t *testing.T) {
+	r, db, _ := newTestRouter(t)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("temporary-password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{
+		Username:           "new-treasurer",
+		Email:              "new-treasurer@example.com",
+		PasswordHash:       string(hash),
+		FullName:           "New Treasurer",
+		Role:               models.RoleTreasurer,
+		IsActive:           true,
+		MustChangePassword: true,
+	}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	token := login(t, db, &user)
+
+	for _, route := range []struct{ method, path string }{
+		{http.MethodGet, "/api/v1/admin/donations"},
+		{http.MethodGet, "/api/v1/admin/ledger/entries"},
+		{http.MethodGet, "/api/v1/auth/permissions"},
+	} {
+		if code := request(r, route.method, route.path, token); code != http.StatusForbidden {
+			t.Errorf("%s %s before changing the password: status %d, want 403", route.method, route.path, code)
+		}
+	}
+	if code := request(r, http.MethodGet, "/api/v1/auth/me", token); code != http.StatusOK {
+		t.Errorf("GET /auth/me before changing the password: status %d, want 200", code)
+	}
+
+	body := `{"current_password": "temporary-password", "new_password": "a-better-password"}`
+	if code := requestJSON(r, http.MethodPut, "/api/v1/auth/password", token, body); code != http.StatusOK {
+		t.Fatalf("changing the password: status %d, want 200", code)
+	}
+
+	// Changing the password ends the session, so log in again
+	token = login(t, db, &user)
+	if code := request(r, http.MethodGet, "/api/v1/admin/donations", token); code != http.StatusOK {
+		t.Errorf("GET /admin/donations after changing the password: status %d, want 200", code)
+	}
+
+	// A user who can't be looked up isn't waved through
+	if err := db.Delete(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if code := request(r, http.MethodGet, "/api/v1/admin/donations", token); code != http.StatusUnauthorized {
+		t.Errorf("GET /admin/donations for a deleted user: status %d, want 401", code)
+	}
+}
+
+func TestLogoutEndsSession(t *testing.T) {
+	r, db, tokens := newTestRouter(t)
+
+	var user models.User
+	db.First(&user, "role = ?", models.RoleEditor)
+	session, refresh, err := services.StartSession(db, user.ID, "test", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := utils.GenerateAccessToken(user.ID, session.ID, user.Email, string(user.Role), config.Load().JWTSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code := request(r, http.MethodGet, "/api/v1/auth/me", token); code != http.StatusOK {
+		t.Fatalf("before logout: status = %d, want 200", code)
+	}
+	if code := request(r, http.MethodPost, "/api/v1/auth/logout", token); code != http.StatusOK {
+		t.Fatalf("logout: status = %d, want 200", code)
+	}
+
+	// The access token hasn't expired, but its session has
+	if code := request(r, http.MethodGet, "/api/v1/auth/me", token); code != http.StatusUnauthorized {
+		t.Errorf("access token after logout: status = %d, want 401", code)
+	}
+	if code := request(r, http.MethodPost, "/api/v1/auth/logout", token); code != http.StatusUnauthorized {
+		t.Errorf("second logout: status = %d, want 401", code)
+	}
+	if code := requestJSON(r, http.MethodPost, "/api/v1/auth/refresh", "", `{"refresh_token":"`+refresh+`"}`); code != http.StatusUnauthorized {
+		t.Errorf("refresh token after logout: status = %d, want 401", code)
+	}
+
+	// The user's other session is untouched
+	if code := request(r, http.MethodGet, "/api/v1/auth/me", tokens[models.RoleEditor]); code != http.StatusOK {
+		t.Errorf("other session after logout: status = %d, want 200", code)
+	}
+}
@@ -1,6 +1,8 @@
 package database
 
 import (
+	"fmt"
+	"log"
 	"masjid-baiturrahim-backend/internal/models"
 
 	"golang.org/x/crypto/bcrypt"
@@ -8,7 +10,11 @@ import (
 )
 
 func Migrate(db *gorm.DB) error {
-	return db.AutoMigrate(
+	if err := migrateMoneyColumns(db); err != nil {
+		return err
+	}
+
+	if err := db.AutoMigrate(
 		&models.User{},
 		&models.MosqueInfo{},
 		&models.OrganizationStructure{},
@@ -17,9 +23,138 @@ func Migrate(db *gorm.DB) error {
 		&models.Event{},
 		&models.Announcement{},
 		&models.Donation{},
+		&models.DonationHistory{},
 		&models.PaymentMethod{},
 		&models.Setting{},
-	)
+		&models.BankStatementImport{},
+		&models.BankMutation{},
+		&models.Campaign{},
+		&models.CampaignUpdate{},
+		&models.LedgerAccount{},
+		&models.LedgerCategory{},
+		&models.LedgerEntry{},
+		&models.Muzakki{},
+		&models.ZakatPayment{},
+		&models.Mustahik{},
+		&models.Distribution{},
+		&models.QurbanAnimalType{},
+		&models.QurbanAnimal{},
+		&models.QurbanShare{},
+		&models.QurbanCoupon{},
+		&models.Donor{},
+		&models.DonorLoginToken{},
+		&models.Pledge{},
+		&models.PledgeInstallment{},
+		&models.Nazhir{},
+		&models.WakafAsset{},
+		&models.WakafYield{},
+		&models.CharityBox{},
+		&models.CharityCount{},
+		&models.CharityCountLine{},
+		&models.CharityCountSignoff{},
+		&models.Approval{},
+		&models.Division{},
+		&models.DivisionBudget{},
+		&models.FundRequest{},
+		&models.BudgetPlan{},
+		&models.BudgetLine{},
+		&models.AuthSession{},
+		&models.RefreshToken{},
+		&models.PasswordResetToken{},
+	); err != nil {
+		return err
+	}
+
+	// Donations created before unique codes existed are transferred at face value
+	if err := db.Model(&models.Donation{}).
+		Where("transfer_amount = 0").
+		Update("transfer_amount", gorm.Expr("amount")).Error; err != nil {
+		return err
+	}
+
+	// Donations used to be booked without their unique code, so the cash
+	// book fell short of the bank statement
+	if err := db.Exec(`UPDATE ledger_entries SET amount = donations.transfer_amount
+		FROM donations
+		WHERE ledger_entries.donation_id = donations.id
+			AND ledger_entries.source IN (?, ?)
+			AND ledger_entries.amount = donations.amount
+			AND donations.transfer_amount <> donations.amount`,
+		models.LedgerSourceDonation, models.LedgerSourceDonationRefund).Error; err != nil {
+		return err
+	}
+
+	return flagDefaultAdminPassword(db)
+}
+
+// The account SeedDefaultAdmin creates. Its password is in this file, so it
+// is no secret.
+const (
+	defaultAdminUsername = "admin"
+	defaultAdminEmail    = "admin@masjidbaiturrahim.com"
+	defaultAdminPassword = "admin123"
+)
+
+// flagDefaultAdminPassword makes the seeded admin change its password if it
+// still has the default one. Admins seeded before the flag existed could
+// otherwise keep using it indefinitely.
+func flagDefaultAdminPassword(db *gorm.DB) error {
+	var admins []models.User
+	if err := db.Where("(username = ? OR email = ?) AND must_change_password = ?", defaultAdminUsername, defaultAdminEmail, false).
+		Find(&admins).Error; err != nil {
+		return err
+	}
+	for _, admin := range admins {
+		if bcrypt.CompareHashAndPassword([]byte(admin.PasswordHash), []byte(defaultAdminPassword)) != nil {
+			continue
+		}
+		if err := db.Model(&models.User{}).Where("id = ?", admin.ID).Update("must_change_password", true).Error; err != nil {
+			return err
+		}
+		log.Printf("User %s still has the default password, it has to be changed before the account can be used", admin.Username)
+	}
+	return nil
+}
+
+// moneyColumns were decimal(15,2) rupiah before amounts became models.Money.
+var moneyColumns = []struct{ table, column string }{
+	{"donations", "amount"},
+	{"donations", "transfer_amount"},
+	{"bank_mutations", "amount"},
+	{"bank_mutations", "balance"},
+	{"campaigns", "target_amount"},
+}
+
+// migrateMoneyColumns converts decimal rupiah columns to bigint minor units
+// (amount * 100) before AutoMigrate sees them, so existing values, including
+// their sen, are kept exactly. Columns already converted are skipped.
+func migrateMoneyColumns(db *gorm.DB) error {
+	// Only Postgres databases can predate minor units
+	if db.Dialector.Name() != "postgres" {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, mc := range moneyColumns {
+			var dataType string
+			err := tx.Raw(`SELECT data_type FROM information_schema.columns
+				WHERE table_schema = current_schema() AND table_name = ? AND column_name = ?`, mc.table, mc.column).
+				Scan(&dataType).Error
+			if err != nil {
+				return err
+			}
+			if dataType != "numeric" {
+				continue
+			}
+
+			if err := tx.Exec(fmt.Sprintf(
+				`ALTER TABLE %q ALTER COLUMN %q TYPE bigint USING ROUND(%q * 100)::bigint`,
+				mc.table, mc.column, mc.column,
+			)).Error; err != nil {
+				return fmt.Errorf("failed to migrate %s.%s to minor units: %w", mc.table, mc.column, err)
+			}
+		}
+		return nil
+	})
 }
 
 func SeedDefaultAdmin(db *gorm.DB) error {
@@ -31,19 +166,20 @@ func SeedDefaultAdmin(db *gorm.DB) error {
 		return nil
 	}
 
-	// Hash password: "admin123"
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("admin123"), 12)
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(defaultAdminPassword), 12)
 	if err != nil {
 		return err
 	}
 
 	adminUser := models.User{
-		Username:     "admin",
-		Email:        "admin@masjidbaiturrahim.com",
+		Username:     defaultAdminUsername,
+		Email:        defaultAdminEmail,
 		PasswordHash: string(hashedPassword),
 		FullName:     "Administrator",
 		Role:         models.RoleAdmin,
 		IsActive:     true,
+		// The password is public, so it has to be replaced after the first login
+		MustChangePassword: true,
 	}
 
 	if err := db.Create(&adminUser).Error; err != nil {
@@ -52,3 +188,57 @@ func SeedDefaultAdmin(db *gorm.DB) error {
 
 	return nil
 }
+
+// SeedLedgerDefaults creates the usual accounts and categories of the cash
+// book the first time it runs. Each donation category gets an income category
+// so confirmed donations can be posted automatically; ones added in later
+// releases are created on the next start.
+func SeedLedgerDefaults(db *gorm.DB) error {
+	var count int64
+	db.Model(&models.LedgerAccount{}).Count(&count)
+	if count == 0 {
+		accounts := []models.LedgerAccount{
+			{Name: "Kas Tunai", Type: models.LedgerAccountCash, IsDefault: true, IsActive: true},
+			{Name: "Bank BSI", Type: models.LedgerAccountBank, IsActive: true},
+			{Name: "QRIS", Type: models.LedgerAccountQRIS, IsActive: true},
+		}
+		if err := db.Create(&accounts).Error; err != nil {
+			return err
+		}
+	}
+
+	donationCategory := func(c models.DonationCategory) *models.DonationCategory { return &c }
+	donationCategories := []models.LedgerCategory{
+		{Code: "infaq", Name: "Infaq", Type: models.LedgerIncome, DonationCategory: donationCategory(models.DonationCategoryInfaq)},
+		{Code: "sedekah", Name: "Sedekah", Type: models.LedgerIncome, DonationCategory: donationCategory(models.DonationCategorySedekah)},
+		{Code: "zakat", Name: "Zakat", Type: models.LedgerIncome, DonationCategory: donationCategory(models.DonationCategoryZakat)},
+		{Code: "wakaf", Name: "Wakaf", Type: models.LedgerIncome, DonationCategory: donationCategory(models.DonationCategoryWakaf)},
+		{Code: "operasional", Name: "Donasi Operasional", Type: models.LedgerIncome, DonationCategory: donationCategory(models.DonationCategoryOperasional)},
+		{Code: "qurban", Name: "Qurban", Type: models.LedgerIncome, DonationCategory: donationCategory(models.DonationCategoryQurban)},
+	}
+
+	db.Model(&models.LedgerCategory{}).Count(&count)
+	if count > 0 {
+		for _, category := range donationCategories {
+			category.IsActive = true
+			if err := db.Where("donation_category = ?", *category.DonationCategory).FirstOrCreate(&category).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	categories := append(donationCategories, []models.LedgerCategory{
+		{Code: "pendapatan-lain", Name: "Pendapatan Lain-lain", Type: models.LedgerIncome},
+		{Code: "listrik-air", Name: "Listrik dan Air", Type: models.LedgerExpense},
+		{Code: "gaji-marbot", Name: "Gaji Marbot", Type: models.LedgerExpense},
+		{Code: "honor-kajian", Name: "Honor Kajian", Type: models.LedgerExpense},
+		{Code: "pemeliharaan", Name: "Pemeliharaan Masjid", Type: models.LedgerExpense},
+		{Code: "operasional-lain", Name: "Operasional Lain-lain", Type: models.LedgerExpense},
+		{Code: "pengembalian-donasi", Name: "Pengembalian Donasi", Type: models.LedgerExpense},
+	}...)
+	for i := range categories {
+		categories[i].IsActive = true
+	}
+	return db.Create(&categories).Error
+}
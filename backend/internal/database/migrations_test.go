@@ -0,0 +1,58 @@
+package database_test
+
+import (
+	"testing"
+	"masjid-baiturrahim-backend/internal/database"
+	"masjid-baiturrahim-backend/internal/models"
+	"masjid-baiturrahim-backend/internal/testutil"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestMigrateFlagsDefaultAdminPassword(t *testing.T) {
+	db := testutil.NewDB(t)
+
+	user := func(username, email, password string) *models.User {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u := &models.User{
+			Username:     username,
+			Email:        email,
+			PasswordHash: string(hash),
+			FullName:     username,
+			Role:         models.RoleAdmin,
+			IsActive:     true,
+		}
+		if err := db.Create(u).Error; err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+	// Seeded before the flag existed and never changed
+	seeded := user("admin", "admin@masjidbaiturrahim.com", "admin123")
+	changed := user("admin-renamed", "admin@example.com", "a-better-password")
+	other := user("someone", "someone@example.com", "admin123")
+
+	if err := database.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		user *models.User
+		want bool
+	}{
+		{seeded, true},
+		{changed, false},
+		{other, false}, // not the seeded account, so not checked
+	} {
+		var got models.User
+		if err := db.First(&got, "id = ?", tt.user.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		if got.MustChangePassword != tt.want {
+			t.Errorf("%s: must_change_password = %v, want %v", tt.user.Username, got.MustChangePassword, tt.want)
+		}
+	}
+}
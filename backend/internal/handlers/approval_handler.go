@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"masjid-baiturrahim-backend/internal/models"
+	"masjid-baiturrahim-backend/internal/services"
+	"masjid-baiturrahim-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ApprovalDecisionRequest struct {
+	Note string `json:"note"`
+}
+
+func approvalError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "Approval not found")
+	case errors.Is(err, services.ErrSelfApproval), errors.Is(err, services.ErrApprovalNotYours):
+		utils.ErrorResponse(c, http.StatusForbidden, err.Error())
+	case errors.Is(err, services.ErrApprovalPending), errors.Is(err, services.ErrApprovalDecided),
+		errors.Is(err, services.ErrInvalidTransition), errors.Is(err, services.ErrLedgerEntryGenerated),
+		errors.Is(err, services.ErrMustahikNotVerified), errors.Is(err, services.ErrInsufficientFunds),
+		errors.Is(err, services.ErrInsufficientRice):
+		utils.ErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, services.ErrApprovalNoteNeeded), errors.Is(err, services.ErrApprovalExpense),
+		errors.Is(err, services.ErrNoLedgerAccount), errors.Is(err, services.ErrNoLedgerCategory),
+		errors.Is(err, services.ErrDistributionAccount), errors.Is(err, services.ErrApprovalThreshold):
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to process approval")
+	}
+}
+
+// requestApproval queues a request made above the approval threshold and
+// lets the treasurers know. The caller gets 202 with the pending approval.
+func (h *Handler) requestApproval(c *gin.Context, request func() (*models.Approval, error)) {
+	approval, err := request()
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			utils.ErrorResponse(c, http.StatusNotFound, "Not found")
+		default:
+			approvalError(c, err)
+		}
+		return
+	}
+	h.approvalRequested(c, approval)
+}
+
+// approvalRequested lets the treasurers know about an approval a service
+// queued and answers 202 with it.
+func (h *Handler) approvalRequested(c *gin.Context, approval *models.Approval) {
+	services.NotifyApprovalRequested(h.DB, h.Notifier, approval)
+	utils.SuccessResponse(c, http.StatusAccepted, approval,
+		"Amount is above "+services.ApprovalThreshold(h.DB).Format()+", waiting for a treasurer's approval")
+}
+
+// GetApprovalThreshold returns the amount above which approvals are needed.
+func (h *Handler) GetApprovalThreshold(c *gin.Context) {
+	utils.SuccessResponse(c, http.StatusOK, gin.H{"threshold": services.ApprovalThreshold(h.DB)}, "")
+}
+
+// RequestApprovalThreshold queues a change of the threshold for another
+// treasurer to approve.
+func (h *Handler) RequestApprovalThreshold(c *gin.Context) {
+	var req struct {
+		Threshold models.Money `json:"threshold" binding:"required"`
+		Note      string       `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	approval, err := services.RequestApprovalThreshold(h.DB, req.Threshold, userID.(uuid.UUID), req.Note)
+	if err != nil {
+		approvalError(c, err)
+		return
+	}
+
+	services.NotifyApprovalRequested(h.DB, h.Notifier, approval)
+	utils.SuccessResponse(c, http.StatusAccepted, approval, "Waiting for another treasurer's approval")
+}
+
+// GetApprovals lists approvals, pending ones by default.
+func (h *Handler) GetApprovals(c *gin.Context) {
+	page, limit := utils.GetPaginationParams(c)
+	offset := utils.GetOffset(page, limit)
+
+	var approvals []models.Approval
+	var total int64
+
+	query := h.DB.Model(&models.Approval{}).Where("status = ?", c.DefaultQuery("status", string(models.ApprovalPending)))
+	if kind := c.Query("kind"); kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	if c.Query("mine") == "true" {
+		userID, _ := c.Get("userID")
+		query = query.Where("requested_by = ?", userID)
+	}
+
+	query.Count(&total)
+	query.Preload("Requester").Preload("Decider").
+		Order("created_at ASC").
+		Offset(offset).
+		Limit(limit).
+		Find(&approvals)
+
+	utils.PaginatedSuccessResponse(c, approvals, page, limit, total)
+}
+
+// GetApproval returns an approval with the donation or current ledger entry
+// it concerns.
+func (h *Handler) GetApproval(c *gin.Context) {
+	var approval models.Approval
+	if err := h.DB.Preload("Requester").Preload("Decider").First(&approval, "id = ?", c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Approval not found")
+		return
+	}
+
+	result := gin.H{"approval": approval}
+	switch approval.Kind {
+	case models.ApprovalDonationConfirmation:
+		var donation models.Donation
+		if h.DB.Preload("PaymentMethod").First(&donation, "id = ?", approval.SubjectID).Error == nil {
+			result["donation"] = donation
+		}
+	case models.ApprovalExpense:
+		var entry models.LedgerEntry
+		if h.DB.Preload("Account").Preload("Category").First(&entry, "id = ?", approval.SubjectID).Error == nil {
+			result["current_entry"] = entry
+		}
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, result, "")
+}
+
+func (h *Handler) ApproveApproval(c *gin.Context) {
+	h.decideApproval(c, services.ApproveApproval, "Approved successfully")
+}
+
+func (h *Handler) RejectApproval(c *gin.Context) {
+	h.decideApproval(c, services.RejectApproval, "Rejected successfully")
+}
+
+func (h *Handler) decideApproval(c *gin.Context, decide func(*gorm.DB, uuid.UUID, uuid.UUID, string) (*models.Approval, error), message string) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Approval not found")
+		return
+	}
+
+	var req ApprovalDecisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	approval, err := decide(h.DB, id, userID.(uuid.UUID), req.Note)
+	if err != nil {
+		approvalError(c, err)
+		return
+	}
+
+	services.NotifyApprovalDecided(h.DB, h.Notifier, approval)
+	utils.SuccessResponse(c, http.StatusOK, approval, message)
+}
+
+// CancelApproval lets the requester withdraw a request still waiting.
+func (h *Handler) CancelApproval(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Approval not found")
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	approval, err := services.CancelApproval(h.DB, id, userID.(uuid.UUID))
+	if err != nil {
+		approvalError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, approval, "Request withdrawn successfully")
+}
@@ -1,13 +1,17 @@
 package handlers
 
 import (
+	"errors"
+	"log"
 	"net/http"
 	"time"
 	"masjid-baiturrahim-backend/config"
 	"masjid-baiturrahim-backend/internal/models"
+	"masjid-baiturrahim-backend/internal/services"
 	"masjid-baiturrahim-backend/internal/utils"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
@@ -43,16 +47,15 @@ func (h *Handler) Login(c *gin.Context) {
 		return
 	}
 
-	cfg := config.Load()
-	accessToken, err := utils.GenerateAccessToken(user.ID, user.Email, string(user.Role), cfg.JWTSecret)
+	session, refreshToken, err := services.StartSession(h.DB, user.ID, c.Request.UserAgent(), c.ClientIP())
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate access token")
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate refresh token")
 		return
 	}
 
-	refreshToken, err := utils.GenerateRefreshToken(user.ID, user.Email, string(user.Role), cfg.JWTSecret)
+	accessToken, err := utils.GenerateAccessToken(user.ID, session.ID, user.Email, string(user.Role), config.Load().JWTSecret)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate refresh token")
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate access token")
 		return
 	}
 
@@ -71,10 +74,13 @@ func (h *Handler) Login(c *gin.Context) {
 			"full_name": user.FullName,
 			"role":      user.Role,
 			"avatar_url": user.AvatarURL,
+			"must_change_password": user.MustChangePassword,
 		},
 	}, "Login successful")
 }
 
+// Refresh exchanges a refresh token for a new access token and the next
+// refresh token of the session; the presented token stops working.
 func (h *Handler) Refresh(c *gin.Context) {
 	var req RefreshTokenRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -82,50 +88,62 @@ func (h *Handler) Refresh(c *gin.Context) {
 		return
 	}
 
-	cfg := config.Load()
-	claims, err := utils.ParseToken(req.RefreshToken, cfg.JWTSecret)
+	session, refreshToken, err := services.RotateRefreshToken(h.DB, req.RefreshToken)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid refresh token")
-		return
-	}
-
-	if claims.TokenType != utils.TokenTypeRefresh {
-		utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid token type")
+		switch {
+		case errors.Is(err, services.ErrRefreshTokenInvalid), errors.Is(err, services.ErrRefreshTokenReused),
+			errors.Is(err, services.ErrSessionRevoked):
+			utils.ErrorResponse(c, http.StatusUnauthorized, err.Error())
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to refresh token")
+		}
 		return
 	}
 
 	// Verify user still exists and is active
 	var user models.User
-	if err := h.DB.Where("id = ? AND is_active = ?", claims.UserID, true).First(&user).Error; err != nil {
+	if err := h.DB.Where("id = ? AND is_active = ?", session.UserID, true).First(&user).Error; err != nil {
+		services.RevokeSession(h.DB, session.ID, services.RevokeUserInactive)
 		utils.ErrorResponse(c, http.StatusUnauthorized, "User not found or inactive")
 		return
 	}
 
-	// Generate new tokens
-	accessToken, err := utils.GenerateAccessToken(user.ID, user.Email, string(user.Role), cfg.JWTSecret)
+	accessToken, err := utils.GenerateAccessToken(user.ID, session.ID, user.Email, string(user.Role), config.Load().JWTSecret)
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate access token")
 		return
 	}
 
-	refreshToken, err := utils.GenerateRefreshToken(user.ID, user.Email, string(user.Role), cfg.JWTSecret)
-	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate refresh token")
-		return
-	}
-
 	utils.SuccessResponse(c, http.StatusOK, gin.H{
 		"access_token":  accessToken,
 		"refresh_token": refreshToken,
 	}, "Token refreshed successfully")
 }
 
+// Logout ends the current session: its refresh token and access tokens stop
+// working.
 func (h *Handler) Logout(c *gin.Context) {
-	// In a stateless JWT system, logout is handled client-side by removing the token
-	// For enhanced security, you could implement a token blacklist in Redis
+	sessionID, _ := c.Get("sessionID")
+	if err := services.RevokeSession(h.DB, sessionID.(uuid.UUID), services.RevokeLogout); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to log out")
+		return
+	}
+
 	utils.SuccessResponse(c, http.StatusOK, nil, "Logout successful")
 }
 
+// LogoutAll ends every session of the current user, e.g. after losing a
+// device.
+func (h *Handler) LogoutAll(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	if err := services.RevokeUserSessions(h.DB, userID.(uuid.UUID), services.RevokeLogoutAll); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to log out")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, nil, "Logged out of all sessions")
+}
+
 func (h *Handler) GetMe(c *gin.Context) {
 	userID, exists := c.Get("userID")
 	if !exists {
@@ -148,7 +166,115 @@ func (h *Handler) GetMe(c *gin.Context) {
 		"avatar_url": user.AvatarURL,
 		"is_active":  user.IsActive,
 		"last_login_at": user.LastLoginAt,
+		"must_change_password": user.MustChangePassword,
 		"created_at": user.CreatedAt,
 	}, "")
 }
 
+// GetMyPermissions lists what the caller's role may do, so the dashboard can
+// hide actions that would be refused.
+func (h *Handler) GetMyPermissions(c *gin.Context) {
+	role, _ := c.Get("userRole")
+	userRole := models.UserRole(role.(string))
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{
+		"role":        userRole,
+		"permissions": userRole.Permissions(),
+	}, "")
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// ChangePassword sets a new password for the caller. Every session, this one
+// included, is ended; the response carries tokens of a fresh session so the
+// caller stays logged in.
+func (h *Handler) ChangePassword(c *gin.Context) {
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	if err := services.ChangePassword(h.DB, userID.(uuid.UUID), req.CurrentPassword, req.NewPassword); err != nil {
+		passwordError(c, err)
+		return
+	}
+
+	var user models.User
+	if err := h.DB.First(&user, "id = ?", userID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	session, refreshToken, err := services.StartSession(h.DB, user.ID, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate refresh token")
+		return
+	}
+	accessToken, err := utils.GenerateAccessToken(user.ID, session.ID, user.Email, string(user.Role), config.Load().JWTSecret)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate access token")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
+	}, "Password changed; other sessions have been logged out")
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+func (h *Handler) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Answer the same whether or not the email is known
+	if err := services.RequestPasswordReset(h.DB, h.Mailer, req.Email, config.Load().FrontendURL); err != nil {
+		log.Printf("password reset link for %s: %v", req.Email, err)
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, nil, "If this email belongs to an account, a reset link has been sent")
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+func (h *Handler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := services.ResetPassword(h.DB, req.Token, req.NewPassword); err != nil {
+		passwordError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, nil, "Password has been reset, please log in")
+}
+
+func passwordError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrPasswordIncorrect), errors.Is(err, services.ErrPasswordTooShort),
+		errors.Is(err, services.ErrPasswordUnchanged):
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrPasswordResetInvalid):
+		utils.ErrorResponse(c, http.StatusUnauthorized, err.Error())
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update password")
+	}
+}
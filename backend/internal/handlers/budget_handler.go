@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+	"masjid-baiturrahim-backend/internal/services"
+	"masjid-baiturrahim-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func (h *Handler) GetBudgetPlans(c *gin.Context) {
+	var plans []models.BudgetPlan
+	h.DB.Order("fiscal_year DESC").Find(&plans)
+
+	utils.SuccessResponse(c, http.StatusOK, plans, "")
+}
+
+func (h *Handler) GetBudgetPlan(c *gin.Context) {
+	var plan models.BudgetPlan
+	if err := h.DB.Preload("Lines.Category").First(&plan, "id = ?", c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Budget plan not found")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, plan, "")
+}
+
+type BudgetPlanRequest struct {
+	FiscalYear int                        `json:"fiscal_year" binding:"required,min=2000,max=2100"`
+	Title      string                     `json:"title"`
+	Notes      string                     `json:"notes"`
+	Lines      []services.BudgetLineInput `json:"lines" binding:"dive"`
+}
+
+func (h *Handler) CreateBudgetPlan(c *gin.Context) {
+	var req BudgetPlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var count int64
+	h.DB.Model(&models.BudgetPlan{}).Where("fiscal_year = ?", req.FiscalYear).Count(&count)
+	if count > 0 {
+		utils.ErrorResponse(c, http.StatusConflict, "A budget plan for this fiscal year already exists")
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	plan := models.BudgetPlan{
+		FiscalYear: req.FiscalYear,
+		Title:      req.Title,
+		Notes:      req.Notes,
+		Status:     models.BudgetPlanDraft,
+		CreatedBy:  userID.(uuid.UUID),
+	}
+	if plan.Title == "" {
+		plan.Title = fmt.Sprintf("RAPB Tahun %d", req.FiscalYear)
+	}
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&plan).Error; err != nil {
+			return err
+		}
+		_, err := services.SaveBudgetLines(tx, plan.ID, req.Lines)
+		return err
+	})
+	if err != nil {
+		budgetError(c, err, "Failed to create budget plan")
+		return
+	}
+
+	h.DB.Preload("Lines.Category").First(&plan, "id = ?", plan.ID)
+	utils.SuccessResponse(c, http.StatusCreated, plan, "Budget plan created successfully")
+}
+
+// UpdateBudgetPlan changes the title and notes of a plan. Lines are saved
+// separately with UpdateBudgetLines.
+func (h *Handler) UpdateBudgetPlan(c *gin.Context) {
+	var plan models.BudgetPlan
+	if err := h.DB.First(&plan, "id = ?", c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Budget plan not found")
+		return
+	}
+
+	var req struct {
+		Title string `json:"title" binding:"required"`
+		Notes string `json:"notes"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	plan.Title = req.Title
+	plan.Notes = req.Notes
+	if err := h.DB.Save(&plan).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update budget plan")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, plan, "Budget plan updated successfully")
+}
+
+func (h *Handler) UpdateBudgetLines(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Budget plan not found")
+		return
+	}
+
+	var req struct {
+		Lines []services.BudgetLineInput `json:"lines" binding:"dive"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if _, err := services.SaveBudgetLines(h.DB, id, req.Lines); err != nil {
+		budgetError(c, err, "Failed to save budget lines")
+		return
+	}
+
+	var plan models.BudgetPlan
+	h.DB.Preload("Lines.Category").First(&plan, "id = ?", id)
+	utils.SuccessResponse(c, http.StatusOK, plan, "Budget lines saved successfully")
+}
+
+// ApproveBudgetPlan records that the takmir adopted the plan; its lines are
+// frozen from then on.
+func (h *Handler) ApproveBudgetPlan(c *gin.Context) {
+	var plan models.BudgetPlan
+	if err := h.DB.First(&plan, "id = ?", c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Budget plan not found")
+		return
+	}
+	if plan.Status == models.BudgetPlanApproved {
+		utils.ErrorResponse(c, http.StatusConflict, "Budget plan is already approved")
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	approvedBy := userID.(uuid.UUID)
+	now := time.Now()
+	plan.Status = models.BudgetPlanApproved
+	plan.ApprovedBy = &approvedBy
+	plan.ApprovedAt = &now
+	if err := h.DB.Save(&plan).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to approve budget plan")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, plan, "Budget plan approved successfully")
+}
+
+// ReopenBudgetPlan puts an approved plan back into draft, e.g. for a
+// mid-year revision.
+func (h *Handler) ReopenBudgetPlan(c *gin.Context) {
+	var plan models.BudgetPlan
+	if err := h.DB.First(&plan, "id = ?", c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Budget plan not found")
+		return
+	}
+	if plan.Status != models.BudgetPlanApproved {
+		utils.ErrorResponse(c, http.StatusConflict, "Budget plan is not approved")
+		return
+	}
+
+	plan.Status = models.BudgetPlanDraft
+	plan.ApprovedBy = nil
+	plan.ApprovedAt = nil
+	if err := h.DB.Save(&plan).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to reopen budget plan")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, plan, "Budget plan reopened")
+}
+
+func (h *Handler) DeleteBudgetPlan(c *gin.Context) {
+	var plan models.BudgetPlan
+	if err := h.DB.First(&plan, "id = ?", c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Budget plan not found")
+		return
+	}
+	if plan.Status == models.BudgetPlanApproved {
+		utils.ErrorResponse(c, http.StatusConflict, services.ErrBudgetPlanApproved.Error())
+		return
+	}
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("plan_id = ?", plan.ID).Delete(&models.BudgetLine{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&plan).Error
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete budget plan")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, nil, "Budget plan deleted successfully")
+}
+
+func (h *Handler) budgetReport(c *gin.Context) (*services.BudgetReport, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Budget plan not found")
+		return nil, false
+	}
+
+	report, err := services.GetBudgetReport(h.DB, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.ErrorResponse(c, http.StatusNotFound, "Budget plan not found")
+		} else {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to build budget report")
+		}
+		return nil, false
+	}
+	return report, true
+}
+
+// GetBudgetReport compares the plan with confirmed donations and recorded
+// expenses, with a monthly burn chart.
+func (h *Handler) GetBudgetReport(c *gin.Context) {
+	report, ok := h.budgetReport(c)
+	if !ok {
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, report, "")
+}
+
+// GetBudgetReportXLSX serves the budget report as a spreadsheet for the
+// annual meeting.
+func (h *Handler) GetBudgetReportXLSX(c *gin.Context) {
+	report, ok := h.budgetReport(c)
+	if !ok {
+		return
+	}
+
+	var mosque models.MosqueInfo
+	h.DB.First(&mosque)
+
+	xlsx, err := services.RenderBudgetReportXLSX(report, mosque.Name)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to render budget report")
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename="+services.BudgetReportFilename(report))
+	c.Data(http.StatusOK, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", xlsx)
+}
+
+func budgetError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "Budget plan not found")
+	case errors.Is(err, services.ErrBudgetPlanApproved):
+		utils.ErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, services.ErrBudgetLineCategory),
+		errors.Is(err, services.ErrBudgetLineDuplicate),
+		errors.Is(err, services.ErrBudgetLineAmount):
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, fallback)
+	}
+}
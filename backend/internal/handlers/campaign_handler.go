@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+	"masjid-baiturrahim-backend/internal/services"
+	"masjid-baiturrahim-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const recentCampaignDonorsLimit = 10
+
+type CampaignWithProgress struct {
+	models.Campaign
+	Progress services.CampaignProgress `json:"progress"`
+}
+
+type CampaignDetail struct {
+	CampaignWithProgress
+	RecentDonors []services.DonorWallEntry `json:"recent_donors"`
+	Updates      []models.CampaignUpdate   `json:"updates"`
+}
+
+func (h *Handler) GetCampaigns(c *gin.Context) {
+	page, limit := utils.GetPaginationParams(c)
+	offset := utils.GetOffset(page, limit)
+
+	var campaigns []models.Campaign
+	var total int64
+
+	query := h.DB.Model(&models.Campaign{})
+
+	// Public visitors only see running and finished campaigns
+	if _, isAdmin := c.Get("userID"); !isAdmin {
+		query = query.Where("status IN ?", []models.CampaignStatus{models.CampaignStatusActive, models.CampaignStatusCompleted})
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	query.Count(&total)
+	query.Order("created_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&campaigns)
+
+	progress, err := services.GetCampaignsProgress(h.DB, campaigns)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to get campaign progress")
+		return
+	}
+
+	result := make([]CampaignWithProgress, len(campaigns))
+	for i, campaign := range campaigns {
+		result[i] = CampaignWithProgress{Campaign: campaign, Progress: progress[campaign.ID]}
+	}
+
+	utils.PaginatedSuccessResponse(c, result, page, limit, total)
+}
+
+func (h *Handler) GetCampaignBySlug(c *gin.Context) {
+	var campaign models.Campaign
+	if err := h.DB.Where("slug = ? AND status <> ?", c.Param("slug"), models.CampaignStatusDraft).First(&campaign).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Campaign not found")
+		return
+	}
+
+	progress, err := services.GetCampaignsProgress(h.DB, []models.Campaign{campaign})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to get campaign progress")
+		return
+	}
+
+	donors, err := services.GetRecentCampaignDonors(h.DB, campaign.ID, recentCampaignDonorsLimit)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to get campaign donors")
+		return
+	}
+
+	var updates []models.CampaignUpdate
+	h.DB.Where("campaign_id = ? AND published_at <= ?", campaign.ID, time.Now()).
+		Order("published_at DESC").
+		Find(&updates)
+
+	utils.SuccessResponse(c, http.StatusOK, CampaignDetail{
+		CampaignWithProgress: CampaignWithProgress{Campaign: campaign, Progress: progress[campaign.ID]},
+		RecentDonors:         donors,
+		Updates:              updates,
+	}, "")
+}
+
+func (h *Handler) GetCampaignUpdates(c *gin.Context) {
+	var campaign models.Campaign
+	if err := h.DB.Where("slug = ? AND status <> ?", c.Param("slug"), models.CampaignStatusDraft).First(&campaign).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Campaign not found")
+		return
+	}
+
+	var updates []models.CampaignUpdate
+	h.DB.Preload("Creator").
+		Where("campaign_id = ? AND published_at <= ?", campaign.ID, time.Now()).
+		Order("published_at DESC").
+		Find(&updates)
+
+	utils.SuccessResponse(c, http.StatusOK, updates, "")
+}
+
+func (h *Handler) CreateCampaign(c *gin.Context) {
+	var campaign models.Campaign
+	if err := c.ShouldBindJSON(&campaign); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if campaign.Title == "" || campaign.TargetAmount <= 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Title and a positive target amount are required")
+		return
+	}
+	if campaign.Slug == "" {
+		campaign.Slug = utils.Slugify(campaign.Title)
+	}
+
+	userID, _ := c.Get("userID")
+	campaign.CreatedBy = userID.(uuid.UUID)
+
+	if err := h.DB.Create(&campaign).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Campaign slug already exists")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, campaign, "Campaign created successfully")
+}
+
+func (h *Handler) UpdateCampaign(c *gin.Context) {
+	id := c.Param("id")
+	var campaign models.Campaign
+
+	if err := h.DB.First(&campaign, "id = ?", id).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Campaign not found")
+		return
+	}
+
+	if err := c.ShouldBindJSON(&campaign); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.DB.Save(&campaign).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update campaign")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, campaign, "Campaign updated successfully")
+}
+
+func (h *Handler) DeleteCampaign(c *gin.Context) {
+	id := c.Param("id")
+	if err := h.DB.Delete(&models.Campaign{}, "id = ?", id).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete campaign")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, nil, "Campaign deleted successfully")
+}
+
+func (h *Handler) CreateCampaignUpdate(c *gin.Context) {
+	var campaign models.Campaign
+	if err := h.DB.First(&campaign, "id = ?", c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Campaign not found")
+		return
+	}
+
+	var update models.CampaignUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	update.CampaignID = campaign.ID
+	update.CreatedBy = userID.(uuid.UUID)
+
+	if err := h.DB.Create(&update).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create campaign update")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, update, "Campaign update created successfully")
+}
+
+func (h *Handler) UpdateCampaignUpdate(c *gin.Context) {
+	var update models.CampaignUpdate
+	if err := h.DB.First(&update, "id = ? AND campaign_id = ?", c.Param("updateId"), c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Campaign update not found")
+		return
+	}
+
+	campaignID := update.CampaignID
+	if err := c.ShouldBindJSON(&update); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	update.CampaignID = campaignID
+
+	if err := h.DB.Save(&update).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update campaign update")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, update, "Campaign update updated successfully")
+}
+
+func (h *Handler) DeleteCampaignUpdate(c *gin.Context) {
+	if err := h.DB.Delete(&models.CampaignUpdate{}, "id = ? AND campaign_id = ?", c.Param("updateId"), c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete campaign update")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, nil, "Campaign update deleted successfully")
+}
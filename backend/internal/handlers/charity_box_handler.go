@@ -0,0 +1,322 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+	"masjid-baiturrahim-backend/internal/services"
+	"masjid-baiturrahim-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Charity boxes
+
+func (h *Handler) GetCharityBoxes(c *gin.Context) {
+	var boxes []models.CharityBox
+	query := h.DB.Order("code ASC")
+	if c.Query("active") == "true" {
+		query = query.Where("is_active = ?", true)
+	}
+	query.Find(&boxes)
+
+	utils.SuccessResponse(c, http.StatusOK, boxes, "")
+}
+
+func (h *Handler) CreateCharityBox(c *gin.Context) {
+	var box models.CharityBox
+	if err := c.ShouldBindJSON(&box); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if box.Code == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Code is required")
+		return
+	}
+
+	var count int64
+	h.DB.Model(&models.CharityBox{}).Where("code = ?", box.Code).Count(&count)
+	if count > 0 {
+		utils.ErrorResponse(c, http.StatusConflict, "A charity box with this code already exists")
+		return
+	}
+
+	if err := h.DB.Create(&box).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create charity box")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, box, "Charity box created successfully")
+}
+
+func (h *Handler) UpdateCharityBox(c *gin.Context) {
+	var box models.CharityBox
+	if err := h.DB.First(&box, "id = ?", c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Charity box not found")
+		return
+	}
+
+	id := box.ID
+	if err := c.ShouldBindJSON(&box); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	box.ID = id
+	if box.Code == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Code is required")
+		return
+	}
+
+	var count int64
+	h.DB.Model(&models.CharityBox{}).Where("code = ? AND id <> ?", box.Code, box.ID).Count(&count)
+	if count > 0 {
+		utils.ErrorResponse(c, http.StatusConflict, "A charity box with this code already exists")
+		return
+	}
+
+	if err := h.DB.Save(&box).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update charity box")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, box, "Charity box updated successfully")
+}
+
+func (h *Handler) DeleteCharityBox(c *gin.Context) {
+	var count int64
+	h.DB.Model(&models.CharityCountLine{}).Where("box_id = ?", c.Param("id")).Count(&count)
+	if count > 0 {
+		utils.ErrorResponse(c, http.StatusConflict, "Charity box has been counted before, deactivate it instead")
+		return
+	}
+
+	result := h.DB.Delete(&models.CharityBox{}, "id = ?", c.Param("id"))
+	if result.Error != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete charity box")
+		return
+	}
+	if result.RowsAffected == 0 {
+		utils.ErrorResponse(c, http.StatusNotFound, "Charity box not found")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, nil, "Charity box deleted successfully")
+}
+
+// Counting sessions
+
+type CharityCountRequest struct {
+	CountDate string                     `json:"count_date"` // YYYY-MM-DD, defaults to today
+	Category  models.DonationCategory    `json:"category"`   // defaults to infaq
+	Notes     string                     `json:"notes"`
+	Boxes     []services.CharityBoxCount `json:"boxes"`
+}
+
+type CharityRecountRequest struct {
+	CountDate   string                     `json:"count_date"` // defaults to the date of the recounted session
+	Notes       string                     `json:"notes"`
+	RecountNote string                     `json:"recount_note" binding:"required"`
+	Boxes       []services.CharityBoxCount `json:"boxes"`
+}
+
+type CharityCountLinesRequest struct {
+	Boxes []services.CharityBoxCount `json:"boxes"`
+}
+
+func charityCountError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "Charity count not found")
+	case errors.Is(err, services.ErrCharityCountLocked), errors.Is(err, services.ErrCharityCountPosted),
+		errors.Is(err, services.ErrCharityCountSuperseded), errors.Is(err, services.ErrCharityCountSigned):
+		utils.ErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, services.ErrCharityCountEmpty), errors.Is(err, services.ErrCharityDenomination),
+		errors.Is(err, services.ErrCharityQuantity), errors.Is(err, services.ErrCharityBoxUnknown),
+		errors.Is(err, services.ErrCharityBoxTwice), errors.Is(err, services.ErrCharityCategory),
+		errors.Is(err, services.ErrCharityRecountNote), errors.Is(err, services.ErrNoLedgerAccount),
+		errors.Is(err, services.ErrNoLedgerCategory):
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to save charity count")
+	}
+}
+
+func (h *Handler) GetCharityCounts(c *gin.Context) {
+	page, limit := utils.GetPaginationParams(c)
+	offset := utils.GetOffset(page, limit)
+
+	var counts []models.CharityCount
+	var total int64
+
+	query := h.DB.Model(&models.CharityCount{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if from := c.Query("from"); from != "" {
+		query = query.Where("count_date >= ?", from)
+	}
+	if to := c.Query("to"); to != "" {
+		query = query.Where("count_date <= ?", to)
+	}
+
+	query.Count(&total)
+	query.Preload("Signoffs.User").
+		Order("count_date DESC, created_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&counts)
+
+	utils.PaginatedSuccessResponse(c, counts, page, limit, total)
+}
+
+func (h *Handler) GetCharityCount(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Charity count not found")
+		return
+	}
+
+	detail, err := services.GetCharityCount(h.DB, id)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Charity count not found")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, detail, "")
+}
+
+func (h *Handler) CreateCharityCount(c *gin.Context) {
+	var req CharityCountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	count := models.CharityCount{Category: req.Category, Notes: req.Notes}
+	if req.CountDate != "" {
+		date, err := time.Parse("2006-01-02", req.CountDate)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid count_date. Use YYYY-MM-DD")
+			return
+		}
+		count.CountDate = date
+	}
+
+	userID, _ := c.Get("userID")
+	if err := services.CreateCharityCount(h.DB, &count, req.Boxes, userID.(uuid.UUID)); err != nil {
+		charityCountError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, count, "Charity count created successfully")
+}
+
+// UpdateCharityCountLines replaces what was counted in a session that no
+// witness has signed yet.
+func (h *Handler) UpdateCharityCountLines(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Charity count not found")
+		return
+	}
+
+	var req CharityCountLinesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	count, err := services.UpdateCharityCountLines(h.DB, id, req.Boxes)
+	if err != nil {
+		charityCountError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, count, "Charity count updated successfully")
+}
+
+// SignCharityCount records the current user as a witness. The count is
+// posted as a confirmed donation once enough witnesses have signed.
+func (h *Handler) SignCharityCount(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Charity count not found")
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	count, err := services.SignCharityCount(h.DB, id, userID.(uuid.UUID))
+	if err != nil {
+		charityCountError(c, err)
+		return
+	}
+
+	message := "Charity count signed successfully"
+	if count.Status == models.CharityCountPosted {
+		message = "Charity count signed and posted successfully"
+	}
+	utils.SuccessResponse(c, http.StatusOK, count, message)
+}
+
+// RecountCharityCount starts a recount that supersedes the session. The
+// original values are kept; the recount posts in its place once signed.
+func (h *Handler) RecountCharityCount(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Charity count not found")
+		return
+	}
+
+	var req CharityRecountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var original models.CharityCount
+	if err := h.DB.First(&original, "id = ?", id).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Charity count not found")
+		return
+	}
+
+	count := models.CharityCount{
+		Category:    original.Category,
+		Notes:       req.Notes,
+		RecountOf:   &original.ID,
+		RecountNote: req.RecountNote,
+	}
+	if req.CountDate != "" {
+		date, err := time.Parse("2006-01-02", req.CountDate)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid count_date. Use YYYY-MM-DD")
+			return
+		}
+		count.CountDate = date
+	}
+
+	userID, _ := c.Get("userID")
+	if err := services.CreateCharityCount(h.DB, &count, req.Boxes, userID.(uuid.UUID)); err != nil {
+		charityCountError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, count, "Recount created successfully")
+}
+
+func (h *Handler) DeleteCharityCount(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Charity count not found")
+		return
+	}
+
+	if err := services.DeleteCharityCount(h.DB, id); err != nil {
+		charityCountError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, nil, "Charity count deleted successfully")
+}
@@ -1,7 +1,14 @@
 package handlers
 
 import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 	"masjid-baiturrahim-backend/internal/models"
 	"masjid-baiturrahim-backend/internal/services"
@@ -9,26 +16,205 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
+type CreateDonationRequest struct {
+	models.Donation
+	UseUniqueCode     bool   `json:"use_unique_code"`
+	UseVirtualAccount bool   `json:"use_virtual_account"`
+	VABank            string `json:"va_bank"` // bank of the virtual account, e.g. bsi
+}
+
 func (h *Handler) CreateDonation(c *gin.Context) {
-	var donation models.Donation
-	if err := c.ShouldBindJSON(&donation); err != nil {
+	var req CreateDonationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	donation := req.Donation
+
+	if err := services.ValidateDonationAmount(donation.Amount); err != nil {
 		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	// Generate donation code
 	donation.DonationCode = services.GenerateDonationCode()
+	donation.Status = models.DonationStatusPending
+	donation.ProofURL = nil
+	donation.ProofUploadedAt = nil
+	donation.ConfirmedBy = nil
+	donation.ConfirmedAt = nil
+	donation.DonorID = nil // resolved from the contact details below
+	// Nested records in the body would be created along with the donation
+	donation.ID = uuid.Nil
+	donation.Donor = nil
+	donation.PaymentMethod = models.PaymentMethod{}
+	donation.Confirmer = models.User{}
+	donation.History = nil
+	donation.UniqueCode = 0
+	donation.TransferAmount = donation.Amount
+	donation.QRISPayload = nil
+	donation.QRISImageURL = nil
+	donation.VANumber = nil
+	donation.VABankCode = nil
+	donation.VAExpiresAt = nil
+
+	if req.UseVirtualAccount && h.VirtualAccounts == nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Virtual accounts are not available")
+		return
+	}
+
+	// Anonymous donors are listed as "Hamba Allah", so a display name would
+	// only leak who they are
+	if donation.DisplayName != nil {
+		name := strings.TrimSpace(*donation.DisplayName)
+		donation.DisplayName = &name
+		if name == "" || donation.IsAnonymous {
+			donation.DisplayName = nil
+		} else if len([]rune(name)) > 100 {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Display name must be at most 100 characters")
+			return
+		}
+	}
 
-	if err := h.DB.Create(&donation).Error; err != nil {
+	donation.Campaign = nil
+	if donation.CampaignID != nil {
+		var campaign models.Campaign
+		if err := h.DB.First(&campaign, "id = ?", donation.CampaignID).Error; err != nil || !campaign.AcceptsDonations(time.Now()) {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Campaign is not accepting donations")
+			return
+		}
+	}
+
+	donation.WakafAsset = nil
+	if donation.WakafAssetID != nil {
+		if err := services.CheckWakafDonation(h.DB, donation.Category, *donation.WakafAssetID, true); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				utils.ErrorResponse(c, http.StatusBadRequest, "Wakaf asset not found")
+				return
+			}
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	// The donor uses this token with the donation code to check the status
+	// and upload proof of transfer; only its hash is stored
+	accessToken, err := utils.GenerateRandomToken(24)
+	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create donation")
 		return
 	}
+	donation.AccessTokenHash = utils.HashToken(accessToken)
+
+	var method *models.PaymentMethod
+	if donation.PaymentMethodID != nil {
+		method = &models.PaymentMethod{}
+		if err := h.DB.First(method, "id = ? AND is_active = ?", donation.PaymentMethodID, true).Error; err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid payment method")
+			return
+		}
+	}
+
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		// A unique suffix (e.g. Rp100.037) lets bank mutations be matched to
+		// this donation by amount alone
+		if req.UseUniqueCode {
+			code, err := services.AssignUniqueCode(tx, donation.Amount)
+			if err != nil {
+				return err
+			}
+			donation.UniqueCode = code
+			donation.TransferAmount = donation.Amount + models.NewMoney(int64(code))
+		}
 
+		if err := services.AttachDonor(tx, &donation); err != nil {
+			return err
+		}
+		return tx.Create(&donation).Error
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrNoUniqueCodeAvailable) {
+			utils.ErrorResponse(c, http.StatusConflict, "No unique code available for this amount, please try another amount")
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create donation")
+		return
+	}
+
+	// The QR image is written and the virtual account opened once the
+	// donation is saved, so neither holds the unique code lock. If either
+	// fails the donation is taken back, which frees its unique code.
+	if err := h.issuePaymentDetails(&donation, method, req); err != nil {
+		if donation.QRISImageURL != nil {
+			services.RemoveQRISImage(donation.DonationCode)
+		}
+		if err := h.DB.Delete(&models.Donation{}, "id = ?", donation.ID).Error; err != nil {
+			log.Printf("Failed to remove donation %s after its payment details failed: %v", donation.DonationCode, err)
+		}
+		if donation.VANumber != nil {
+			log.Printf("Virtual account %s was opened for donation %s, which was not saved", *donation.VANumber, donation.DonationCode)
+		}
+		if errors.Is(err, services.ErrVirtualAccountBank) {
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create donation")
+		return
+	}
+
+	donation.AccessToken = accessToken
 	utils.SuccessResponse(c, http.StatusCreated, donation, "Donation submitted successfully")
 }
 
+// issuePaymentDetails embeds the amount and donation code into the
+// merchant's QRIS, so the donor doesn't have to type the amount and the
+// transfer is traceable, and opens a virtual account that only receives this
+// donation. Both are then stored on the saved donation.
+func (h *Handler) issuePaymentDetails(donation *models.Donation, method *models.PaymentMethod, req CreateDonationRequest) error {
+	if method != nil && method.Type == models.PaymentTypeQRIS && method.QRISPayload != nil && *method.QRISPayload != "" {
+		payload, err := services.GenerateDynamicQRIS(*method.QRISPayload, donation.TransferAmount, donation.DonationCode)
+		if err != nil {
+			return err
+		}
+		imageURL, err := services.SaveQRISImage(payload, donation.DonationCode)
+		if err != nil {
+			return err
+		}
+		donation.QRISPayload = &payload
+		donation.QRISImageURL = &imageURL
+	}
+
+	if req.UseVirtualAccount {
+		va, err := h.VirtualAccounts.CreateVirtualAccount(services.VirtualAccountRequest{
+			DonationCode: donation.DonationCode,
+			Name:         donation.DonorName,
+			Amount:       donation.TransferAmount,
+			BankCode:     req.VABank,
+			ExpiresAt:    time.Now().Add(h.VAExpiry),
+		})
+		if err != nil {
+			return err
+		}
+		donation.VANumber = &va.Number
+		donation.VABankCode = &va.BankCode
+		donation.VAExpiresAt = &va.ExpiresAt
+	}
+
+	if donation.QRISPayload == nil && donation.VANumber == nil {
+		return nil
+	}
+	return h.DB.Model(donation).Updates(map[string]interface{}{
+		"qris_payload":   donation.QRISPayload,
+		"qris_image_url": donation.QRISImageURL,
+		"va_number":      donation.VANumber,
+		"va_bank_code":   donation.VABankCode,
+		"va_expires_at":  donation.VAExpiresAt,
+	}).Error
+}
+
 func (h *Handler) GetDonations(c *gin.Context) {
 	page, limit := utils.GetPaginationParams(c)
 	offset := utils.GetOffset(page, limit)
@@ -36,15 +222,32 @@ func (h *Handler) GetDonations(c *gin.Context) {
 	var donations []models.Donation
 	var total int64
 
-	query := h.DB.Model(&models.Donation{})
+	query := filterDonations(c, h.DB.Model(&models.Donation{}))
+
+	query.Count(&total)
+	// Pending donations with a receipt attached are the ones ready to check
+	query.Preload("PaymentMethod").Preload("Confirmer").
+		Order("CASE WHEN status = 'pending' AND proof_url IS NOT NULL THEN 0 ELSE 1 END").
+		Order("created_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&donations)
+
+	utils.PaginatedSuccessResponse(c, donations, page, limit, total)
+}
 
-	// Filters
+// filterDonations applies the donation list filters shared by the admin list
+// and the export.
+func filterDonations(c *gin.Context, query *gorm.DB) *gorm.DB {
 	if status := c.Query("status"); status != "" {
 		query = query.Where("status = ?", status)
 	}
 	if category := c.Query("category"); category != "" {
 		query = query.Where("category = ?", category)
 	}
+	if campaignID := c.Query("campaign_id"); campaignID != "" {
+		query = query.Where("campaign_id = ?", campaignID)
+	}
 	if from := c.Query("from"); from != "" {
 		if date, err := time.Parse("2006-01-02", from); err == nil {
 			query = query.Where("created_at >= ?", date)
@@ -55,44 +258,155 @@ func (h *Handler) GetDonations(c *gin.Context) {
 			query = query.Where("created_at <= ?", date.Add(24*time.Hour))
 		}
 	}
+	if c.Query("has_proof") == "true" {
+		query = query.Where("proof_url IS NOT NULL")
+	}
+	return query
+}
 
-	query.Count(&total)
-	query.Preload("PaymentMethod").Preload("Confirmer").
-		Order("created_at DESC").
-		Offset(offset).
-		Limit(limit).
-		Find(&donations)
+type DonationTransitionRequest struct {
+	Reason string `json:"reason"`
+}
 
-	utils.PaginatedSuccessResponse(c, donations, page, limit, total)
+func (h *Handler) GetDonation(c *gin.Context) {
+	var donation models.Donation
+	err := h.DB.Preload("PaymentMethod").Preload("Confirmer").Preload("Campaign").
+		Preload("History", func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at ASC")
+		}).
+		Preload("History.Actor").
+		First(&donation, "id = ?", c.Param("id")).Error
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Donation not found")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, donation, "")
 }
 
 func (h *Handler) ConfirmDonation(c *gin.Context) {
-	id := c.Param("id")
-	var donation models.Donation
+	h.transitionDonation(c, models.DonationStatusConfirmed, "Donation confirmed successfully")
+}
 
-	if err := h.DB.First(&donation, "id = ?", id).Error; err != nil {
-		utils.ErrorResponse(c, http.StatusNotFound, "Donation not found")
+func (h *Handler) RejectDonation(c *gin.Context) {
+	h.transitionDonation(c, models.DonationStatusRejected, "Donation rejected successfully")
+}
+
+func (h *Handler) CancelDonation(c *gin.Context) {
+	h.transitionDonation(c, models.DonationStatusCancelled, "Donation cancelled successfully")
+}
+
+func (h *Handler) ExpireDonation(c *gin.Context) {
+	h.transitionDonation(c, models.DonationStatusExpired, "Donation expired successfully")
+}
+
+func (h *Handler) RefundDonation(c *gin.Context) {
+	h.transitionDonation(c, models.DonationStatusRefunded, "Donation refunded successfully")
+}
+
+func (h *Handler) transitionDonation(c *gin.Context, to models.DonationStatus, message string) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid donation ID")
+		return
+	}
+
+	var req DonationTransitionRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Every manual change says why, including ones sent for approval
+	if strings.TrimSpace(req.Reason) == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, services.ErrReasonRequired.Error())
 		return
 	}
 
 	userID, _ := c.Get("userID")
-	userUUID := userID.(uuid.UUID)
-	now := time.Now()
-	donation.Status = models.DonationStatusConfirmed
-	donation.ConfirmedBy = &userUUID
-	donation.ConfirmedAt = &now
+	actorID := userID.(uuid.UUID)
 
-	if err := h.DB.Save(&donation).Error; err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to confirm donation")
+	// Large confirmations wait for a treasurer other than the one asking
+	if to == models.DonationStatusConfirmed {
+		var donation models.Donation
+		if err := h.DB.Select("id", "amount").First(&donation, "id = ?", id).Error; err != nil {
+			utils.ErrorResponse(c, http.StatusNotFound, "Donation not found")
+			return
+		}
+		if services.NeedsApproval(h.DB, donation.Amount) {
+			h.requestApproval(c, func() (*models.Approval, error) {
+				return services.RequestDonationConfirmation(h.DB, id, actorID, req.Reason)
+			})
+			return
+		}
+	}
+
+	donation, err := services.TransitionDonation(h.DB, id, to, actorID, req.Reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			utils.ErrorResponse(c, http.StatusNotFound, "Donation not found")
+		case errors.Is(err, services.ErrInvalidTransition):
+			utils.ErrorResponse(c, http.StatusConflict, err.Error())
+		case errors.Is(err, services.ErrReasonRequired), errors.Is(err, services.ErrNoLedgerAccount),
+			errors.Is(err, services.ErrNoLedgerCategory):
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update donation status")
+		}
 		return
 	}
 
-	utils.SuccessResponse(c, http.StatusOK, donation, "Donation confirmed successfully")
+	utils.SuccessResponse(c, http.StatusOK, donation, message)
 }
 
+// GetDonationStats reports donations created between from and to (default:
+// the last 12 months) grouped by group_by, optionally compared with the same
+// range a year earlier (compare=true).
 func (h *Handler) GetDonationStats(c *gin.Context) {
-	stats, err := services.GetDonationStats(h.DB)
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	q := services.DonationStatsQuery{
+		From:    time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).AddDate(0, -11, 0),
+		To:      today,
+		GroupBy: services.StatsGrouping(c.DefaultQuery("group_by", string(services.StatsByMonth))),
+	}
+
+	if s := c.Query("from"); s != "" {
+		date, err := time.ParseInLocation("2006-01-02", s, now.Location())
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid from date. Use YYYY-MM-DD")
+			return
+		}
+		q.From = date
+	}
+	if s := c.Query("to"); s != "" {
+		date, err := time.ParseInLocation("2006-01-02", s, now.Location())
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid to date. Use YYYY-MM-DD")
+			return
+		}
+		q.To = date
+	}
+	if q.To.Before(q.From) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "to must not be before from")
+		return
+	}
+	if s := c.Query("compare"); s != "" {
+		compare, err := strconv.ParseBool(s)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "compare must be true or false")
+			return
+		}
+		q.Compare = compare
+	}
+
+	stats, err := services.GetDonationStats(h.DB, q)
 	if err != nil {
+		if errors.Is(err, services.ErrInvalidStatsGrouping) || errors.Is(err, services.ErrStatsRangeTooLong) {
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to get donation statistics")
 		return
 	}
@@ -101,8 +415,223 @@ func (h *Handler) GetDonationStats(c *gin.Context) {
 }
 
 func (h *Handler) ExportDonations(c *gin.Context) {
-	_ = c.DefaultQuery("format", "excel") // TODO: Use format when implementing export
-	// TODO: Implement export functionality
-	utils.ErrorResponse(c, http.StatusNotImplemented, "Export functionality not yet implemented")
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Unsupported export format")
+		return
+	}
+
+	var donations []models.Donation
+	if err := filterDonations(c, h.DB.Model(&models.Donation{})).
+		Preload("PaymentMethod").
+		Order("created_at ASC").
+		Find(&donations).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to export donations")
+		return
+	}
+
+	filename := fmt.Sprintf("donations-%s.csv", time.Now().Format("20060102-150405"))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	// Amounts are written as exact decimals so spreadsheet totals match ours
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{
+		"donation_code", "created_at", "donor_name", "donor_email", "donor_phone", "category",
+		"payment_method", "amount", "unique_code", "transfer_amount", "status", "confirmed_at",
+	})
+	for _, d := range donations {
+		var paymentMethod, confirmedAt string
+		if d.PaymentMethodID != nil {
+			paymentMethod = d.PaymentMethod.Name
+		}
+		if d.ConfirmedAt != nil {
+			confirmedAt = d.ConfirmedAt.Format(time.RFC3339)
+		}
+		w.Write([]string{
+			d.DonationCode,
+			d.CreatedAt.Format(time.RFC3339),
+			d.DonorName,
+			utils.StringValue(d.DonorEmail),
+			utils.StringValue(d.DonorPhone),
+			string(d.Category),
+			paymentMethod,
+			d.Amount.String(),
+			strconv.Itoa(d.UniqueCode),
+			d.TransferAmount.String(),
+			string(d.Status),
+			confirmedAt,
+		})
+	}
+	w.Flush()
+}
+
+
+const MaxProofSize = 5 * 1024 * 1024 // 5MB
+
+// DonationStatusView is what a donor sees when looking up their donation.
+type DonationStatusView struct {
+	DonationCode    string                  `json:"donation_code"`
+	DonorName       string                  `json:"donor_name"`
+	Amount          models.Money            `json:"amount"`
+	TransferAmount  models.Money            `json:"transfer_amount"`
+	Category        models.DonationCategory `json:"category"`
+	Status          models.DonationStatus   `json:"status"`
+	PaymentMethod   *string                 `json:"payment_method,omitempty"`
+	QRISImageURL    *string                 `json:"qris_image_url,omitempty"`
+	VANumber        *string                 `json:"va_number,omitempty"`
+	VABankCode      *string                 `json:"va_bank_code,omitempty"`
+	VAExpiresAt     *time.Time              `json:"va_expires_at,omitempty"`
+	HasProof        bool                    `json:"has_proof"`
+	ProofUploadedAt *time.Time              `json:"proof_uploaded_at,omitempty"`
+	CreatedAt       time.Time               `json:"created_at"`
+	ConfirmedAt     *time.Time              `json:"confirmed_at,omitempty"`
+}
+
+func newDonationStatusView(d models.Donation) DonationStatusView {
+	view := DonationStatusView{
+		DonationCode:    d.DonationCode,
+		DonorName:       d.DonorName,
+		Amount:          d.Amount,
+		TransferAmount:  d.TransferAmount,
+		Category:        d.Category,
+		Status:          d.Status,
+		QRISImageURL:    d.QRISImageURL,
+		VANumber:        d.VANumber,
+		VABankCode:      d.VABankCode,
+		VAExpiresAt:     d.VAExpiresAt,
+		HasProof:        d.ProofURL != nil,
+		ProofUploadedAt: d.ProofUploadedAt,
+		CreatedAt:       d.CreatedAt,
+		ConfirmedAt:     d.ConfirmedAt,
+	}
+	if d.PaymentMethodID != nil {
+		view.PaymentMethod = &d.PaymentMethod.Name
+	}
+	return view
+}
+
+// findDonationForDonor loads a donation by its code, authorised by the access
+// token handed out at creation. The token is only read from the
+// X-Donation-Token header; in a URL it would end up in access logs and
+// Referer headers.
+func (h *Handler) findDonationForDonor(c *gin.Context) (*models.Donation, bool) {
+	token := c.GetHeader("X-Donation-Token")
+
+	var donation models.Donation
+	err := h.DB.Preload("PaymentMethod").Where("donation_code = ?", c.Param("code")).First(&donation).Error
+	if err != nil || token == "" || donation.AccessTokenHash == "" || !utils.TokenMatchesHash(token, donation.AccessTokenHash) {
+		utils.ErrorResponse(c, http.StatusNotFound, "Donation not found")
+		return nil, false
+	}
+	return &donation, true
+}
+
+func (h *Handler) GetDonationStatus(c *gin.Context) {
+	donation, ok := h.findDonationForDonor(c)
+	if !ok {
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, newDonationStatusView(*donation), "")
+}
+
+func (h *Handler) UploadDonationProof(c *gin.Context) {
+	donation, ok := h.findDonationForDonor(c)
+	if !ok {
+		return
+	}
+
+	if donation.Status != models.DonationStatusPending {
+		utils.ErrorResponse(c, http.StatusConflict, "Proof can only be uploaded for pending donations")
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "No file provided")
+		return
+	}
+
+	key, err := services.SavePrivateFile("proofs", file, MaxProofSize, services.DocumentContentTypes)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrFileTooLarge):
+			utils.ErrorResponse(c, http.StatusBadRequest, "File size exceeds 5MB limit")
+		case errors.Is(err, services.ErrFileTypeInvalid):
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid file type. Only JPG, PNG, WebP or PDF are allowed")
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to save file")
+		}
+		return
+	}
+
+	// Replace an earlier receipt rather than keeping orphaned files around
+	previous := donation.ProofURL
+	now := time.Now()
+	if err := h.DB.Model(donation).Updates(map[string]interface{}{
+		"proof_url":         key,
+		"proof_uploaded_at": now,
+	}).Error; err != nil {
+		os.Remove(services.PrivateFilePath(key))
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to save proof of transfer")
+		return
+	}
+	if previous != nil && !strings.HasPrefix(*previous, "/") && !strings.HasPrefix(*previous, "http") {
+		os.Remove(services.PrivateFilePath(*previous))
+	}
+
+	donation.ProofURL = &key
+	donation.ProofUploadedAt = &now
+	utils.SuccessResponse(c, http.StatusOK, newDonationStatusView(*donation), "Proof of transfer uploaded successfully")
+}
+
+// GetDonationProof streams a donation's privately stored receipt to admins.
+func (h *Handler) GetDonationProof(c *gin.Context) {
+	var donation models.Donation
+	if err := h.DB.First(&donation, "id = ?", c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Donation not found")
+		return
+	}
+	if donation.ProofURL == nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Donation has no proof of transfer")
+		return
+	}
+
+	// Receipts attached before private storage existed are plain URLs
+	if strings.HasPrefix(*donation.ProofURL, "/") || strings.HasPrefix(*donation.ProofURL, "http") {
+		c.Redirect(http.StatusFound, *donation.ProofURL)
+		return
+	}
+
+	c.File(services.PrivateFilePath(*donation.ProofURL))
 }
 
+// GetDonorWall lists confirmed donations publicly, optionally for one
+// category or campaign (by slug). Names are masked unless the donor chose a
+// display name, and donors who asked to stay hidden are never listed.
+func (h *Handler) GetDonorWall(c *gin.Context) {
+	page, limit := utils.GetPaginationParams(c)
+	offset := utils.GetOffset(page, limit)
+
+	var filter services.DonorWallFilter
+	if category := c.Query("category"); category != "" {
+		filter.Category = models.DonationCategory(category)
+	}
+	if slug := c.Query("campaign"); slug != "" {
+		var campaign models.Campaign
+		if err := h.DB.Where("slug = ? AND status <> ?", slug, models.CampaignStatusDraft).First(&campaign).Error; err != nil {
+			utils.ErrorResponse(c, http.StatusNotFound, "Campaign not found")
+			return
+		}
+		filter.CampaignID = &campaign.ID
+	}
+
+	entries, total, err := services.GetDonorWall(h.DB, filter, offset, limit)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch donor wall")
+		return
+	}
+
+	utils.PaginatedSuccessResponse(c, entries, page, limit, total)
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+	"masjid-baiturrahim-backend/internal/services"
+	"masjid-baiturrahim-backend/internal/testutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The EMVCo specification's sample payload made static (tag 01 is 11, no
+// amount), with its CRC recomputed.
+const staticQRIS = "00020101021129300012D156000000000510A93FO3230Q31280012D15600000001030812345678520441115802CN5914BEST TRANSPORT6007BEIJING64200002ZH0104最佳运输0202北京53031565502016233030412340603***0708A60086670902ME91320016A01122334499887707081234567863043E69"
+
+// unavailableProvider fails to open virtual accounts, like a provider that
+// is down.
+type unavailableProvider struct {
+	services.FakeVirtualAccountProvider
+}
+
+func (unavailableProvider) CreateVirtualAccount(services.VirtualAccountRequest) (*services.VirtualAccount, error) {
+	return nil, errors.New("provider unavailable")
+}
+
+func TestCreateDonationPaymentDetails(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// QR images are written under ./uploads
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	db := testutil.NewDB(t)
+	payload := staticQRIS
+	method := models.PaymentMethod{Name: "QRIS Masjid", Type: models.PaymentTypeQRIS, QRISPayload: &payload, IsActive: true}
+	if err := db.Create(&method).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	create := func(provider services.VirtualAccountProvider, body map[string]interface{}) (int, models.Donation) {
+		t.Helper()
+		h := &Handler{DB: db, VirtualAccounts: provider, VAExpiry: time.Hour}
+		router := gin.New()
+		router.POST("/donations", h.CreateDonation)
+
+		body["donor_name"] = "Hamba Allah"
+		body["amount"] = 100000
+		body["category"] = models.DonationCategoryInfaq
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, "/donations", bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var resp struct {
+			Data models.Donation `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp.Data
+	}
+	donations := func() int64 {
+		var n int64
+		db.Model(&models.Donation{}).Count(&n)
+		return n
+	}
+
+	t.Run("QRIS and virtual account", func(t *testing.T) {
+		code, donation := create(services.FakeVirtualAccountProvider{Secret: "va-secret"}, map[string]interface{}{
+			"payment_method_id":   method.ID,
+			"use_unique_code":     true,
+			"use_virtual_account": true,
+		})
+		if code != http.StatusCreated {
+			t.Fatalf("status = %d, want 201", code)
+		}
+
+		var stored models.Donation
+		if err := db.First(&stored, "id = ?", donation.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		if stored.QRISPayload == nil || services.ValidateQRIS(*stored.QRISPayload) != nil || stored.QRISImageURL == nil {
+			t.Errorf("stored QRIS = %v, image %v", stored.QRISPayload, stored.QRISImageURL)
+		}
+		if stored.VANumber == nil || stored.VABankCode == nil || stored.VAExpiresAt == nil {
+			t.Errorf("stored virtual account = %v, %v, %v", stored.VANumber, stored.VABankCode, stored.VAExpiresAt)
+		}
+		if stored.UniqueCode == 0 || stored.TransferAmount != stored.Amount+models.NewMoney(int64(stored.UniqueCode)) {
+			t.Errorf("unique code %d, transfer amount %s", stored.UniqueCode, stored.TransferAmount.Format())
+		}
+		if _, err := os.Stat(filepath.Join("uploads", "qris", stored.DonationCode+".png")); err != nil {
+			t.Errorf("QR image: %v", err)
+		}
+	})
+
+	t.Run("provider failure", func(t *testing.T) {
+		before := donations()
+		code, _ := create(unavailableProvider{}, map[string]interface{}{
+			"payment_method_id":   method.ID,
+			"use_unique_code":     true,
+			"use_virtual_account": true,
+		})
+		if code != http.StatusInternalServerError {
+			t.Errorf("status = %d, want 500", code)
+		}
+		if after := donations(); after != before {
+			t.Errorf("%d donations left behind", after-before)
+		}
+		images, _ := filepath.Glob(filepath.Join("uploads", "qris", "*.png"))
+		if len(images) != 1 {
+			t.Errorf("%d QR images, want only the first donation's", len(images))
+		}
+	})
+
+	t.Run("unsupported bank", func(t *testing.T) {
+		before := donations()
+		code, _ := create(services.FakeVirtualAccountProvider{Secret: "va-secret"}, map[string]interface{}{
+			"use_virtual_account": true,
+			"va_bank":             "bri",
+		})
+		if code != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400", code)
+		}
+		if after := donations(); after != before {
+			t.Errorf("%d donations left behind", after-before)
+		}
+	})
+}
@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"masjid-baiturrahim-backend/config"
+	"masjid-baiturrahim-backend/internal/models"
+	"masjid-baiturrahim-backend/internal/services"
+	"masjid-baiturrahim-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Donors (admin)
+
+func (h *Handler) GetDonors(c *gin.Context) {
+	page, limit := utils.GetPaginationParams(c)
+	offset := utils.GetOffset(page, limit)
+
+	donors, total, err := services.ListDonors(h.DB, c.Query("q"), c.Query("sort"), offset, limit)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch donors")
+		return
+	}
+
+	utils.PaginatedSuccessResponse(c, donors, page, limit, total)
+}
+
+func (h *Handler) GetDonor(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid donor ID")
+		return
+	}
+
+	summary, err := services.GetDonorSummary(h.DB, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.ErrorResponse(c, http.StatusNotFound, "Donor not found")
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch donor")
+		return
+	}
+
+	var donations []models.Donation
+	h.DB.Where("donor_id = ?", id).Order("created_at DESC").Find(&donations)
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{
+		"summary":   summary,
+		"donations": donations,
+	}, "")
+}
+
+type UpdateDonorRequest struct {
+	Name  string  `json:"name" binding:"required"`
+	Phone *string `json:"phone"`
+	Email *string `json:"email"`
+	Notes string  `json:"notes"`
+}
+
+func (h *Handler) UpdateDonor(c *gin.Context) {
+	var donor models.Donor
+	if err := h.DB.First(&donor, "id = ?", c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Donor not found")
+		return
+	}
+
+	var req UpdateDonorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	donor.Name = strings.TrimSpace(req.Name)
+	donor.Notes = req.Notes
+	donor.Phone = nil
+	if req.Phone != nil && *req.Phone != "" {
+		phone := utils.NormalizePhone(*req.Phone)
+		if phone == "" {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid phone number")
+			return
+		}
+		donor.Phone = &phone
+	}
+	donor.Email = nil
+	if req.Email != nil && *req.Email != "" {
+		email := utils.NormalizeEmail(*req.Email)
+		donor.Email = &email
+	}
+
+	if err := h.DB.Save(&donor).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusConflict, "Another donor already has this phone or email, merge them instead")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, donor, "Donor updated successfully")
+}
+
+func (h *Handler) GetDuplicateDonors(c *gin.Context) {
+	groups, err := services.FindDuplicateDonors(h.DB)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to find duplicate donors")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, groups, "")
+}
+
+type MergeDonorsRequest struct {
+	SourceIDs []uuid.UUID `json:"source_ids" binding:"required"`
+}
+
+func (h *Handler) MergeDonors(c *gin.Context) {
+	targetID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid donor ID")
+		return
+	}
+
+	var req MergeDonorsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	donor, err := services.MergeDonors(h.DB, targetID, req.SourceIDs)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrDonorMergeSelf), errors.Is(err, services.ErrDonorMergeSources):
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			utils.ErrorResponse(c, http.StatusNotFound, "Donor not found")
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to merge donors")
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, donor, "Donors merged successfully")
+}
+
+func (h *Handler) LinkDonationsToDonors(c *gin.Context) {
+	linked, err := services.LinkDonationsToDonors(h.DB)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to link donations: "+err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{"linked": linked}, "Donations linked to donors")
+}
+
+// Donor self-service
+
+type DonorLoginRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+func (h *Handler) DonorLogin(c *gin.Context) {
+	var req DonorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Answer the same whether or not the email is known
+	if err := services.RequestDonorLogin(h.DB, h.Mailer, req.Email, config.Load().FrontendURL); err != nil {
+		log.Printf("donor login link for %s: %v", req.Email, err)
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, nil, "If this email belongs to a donor, a login link has been sent")
+}
+
+type DonorVerifyRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+func (h *Handler) DonorVerify(c *gin.Context) {
+	var req DonorVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	donor, err := services.VerifyDonorLogin(h.DB, req.Token)
+	if err != nil {
+		if errors.Is(err, services.ErrDonorLoginInvalid) {
+			utils.ErrorResponse(c, http.StatusUnauthorized, err.Error())
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to verify login link")
+		return
+	}
+
+	email := ""
+	if donor.Email != nil {
+		email = *donor.Email
+	}
+	token, err := utils.GenerateDonorToken(donor.ID, email, config.Load().JWTSecret)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{
+		"token": token,
+		"donor": donor,
+	}, "Login successful")
+}
+
+func (h *Handler) GetDonorMe(c *gin.Context) {
+	donorID, _ := c.Get("donorID")
+
+	summary, err := services.GetDonorSummary(h.DB, donorID.(uuid.UUID))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Donor not found")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, summary, "")
+}
+
+func (h *Handler) GetDonorMyDonations(c *gin.Context) {
+	donorID, _ := c.Get("donorID")
+	page, limit := utils.GetPaginationParams(c)
+	offset := utils.GetOffset(page, limit)
+
+	var donations []models.Donation
+	var total int64
+
+	query := h.DB.Model(&models.Donation{}).Where("donor_id = ?", donorID)
+	if category := c.Query("category"); category != "" {
+		query = query.Where("category = ?", category)
+	}
+
+	query.Count(&total)
+	query.Preload("Campaign").
+		Order("created_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&donations)
+
+	utils.PaginatedSuccessResponse(c, donations, page, limit, total)
+}
@@ -0,0 +1,585 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+	"masjid-baiturrahim-backend/internal/services"
+	"masjid-baiturrahim-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Divisions
+
+func (h *Handler) GetDivisions(c *gin.Context) {
+	var divisions []models.Division
+	query := h.DB.Preload("Budgets", func(db *gorm.DB) *gorm.DB {
+		return db.Order("year DESC")
+	}).Preload("HeadUser").Order("name ASC")
+	if c.Query("active") == "true" {
+		query = query.Where("is_active = ?", true)
+	}
+	query.Find(&divisions)
+
+	utils.SuccessResponse(c, http.StatusOK, divisions, "")
+}
+
+type DivisionRequest struct {
+	Name       string     `json:"name" binding:"required"`
+	HeadUserID *uuid.UUID `json:"head_user_id"`
+	IsActive   *bool      `json:"is_active"`
+}
+
+func (h *Handler) CreateDivision(c *gin.Context) {
+	var req DivisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	division := models.Division{Name: req.Name, HeadUserID: req.HeadUserID, IsActive: true}
+	var count int64
+	h.DB.Model(&models.Division{}).Where("name = ?", division.Name).Count(&count)
+	if count > 0 {
+		utils.ErrorResponse(c, http.StatusConflict, "A division with this name already exists")
+		return
+	}
+
+	if err := h.DB.Create(&division).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create division")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, division, "Division created successfully")
+}
+
+func (h *Handler) UpdateDivision(c *gin.Context) {
+	var division models.Division
+	if err := h.DB.First(&division, "id = ?", c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Division not found")
+		return
+	}
+
+	var req DivisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var count int64
+	h.DB.Model(&models.Division{}).Where("name = ? AND id <> ?", req.Name, division.ID).Count(&count)
+	if count > 0 {
+		utils.ErrorResponse(c, http.StatusConflict, "A division with this name already exists")
+		return
+	}
+
+	division.Name = req.Name
+	division.HeadUserID = req.HeadUserID
+	if req.IsActive != nil {
+		division.IsActive = *req.IsActive
+	}
+	if err := h.DB.Omit("Budgets", "HeadUser").Save(&division).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update division")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, division, "Division updated successfully")
+}
+
+func (h *Handler) DeleteDivision(c *gin.Context) {
+	var count int64
+	h.DB.Model(&models.FundRequest{}).Where("division_id = ?", c.Param("id")).Count(&count)
+	if count > 0 {
+		utils.ErrorResponse(c, http.StatusConflict, "Division has fund requests, deactivate it instead")
+		return
+	}
+
+	result := h.DB.Delete(&models.Division{}, "id = ?", c.Param("id"))
+	if result.Error != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete division")
+		return
+	}
+	if result.RowsAffected == 0 {
+		utils.ErrorResponse(c, http.StatusNotFound, "Division not found")
+		return
+	}
+	h.DB.Where("division_id = ?", c.Param("id")).Delete(&models.DivisionBudget{})
+
+	utils.SuccessResponse(c, http.StatusOK, nil, "Division deleted successfully")
+}
+
+type DivisionBudgetRequest struct {
+	Year   int          `json:"year" binding:"required,min=2000,max=2100"`
+	Amount models.Money `json:"amount"`
+	Notes  string       `json:"notes"`
+}
+
+// SetDivisionBudget sets a division's budget for a year, replacing any
+// earlier figure.
+func (h *Handler) SetDivisionBudget(c *gin.Context) {
+	var division models.Division
+	if err := h.DB.First(&division, "id = ?", c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Division not found")
+		return
+	}
+
+	var req DivisionBudgetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Amount < 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Budget can't be negative")
+		return
+	}
+
+	budget := models.DivisionBudget{DivisionID: division.ID, Year: req.Year}
+	if err := h.DB.Where(budget).Assign(models.DivisionBudget{Amount: req.Amount, Notes: req.Notes}).
+		FirstOrCreate(&budget).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to save budget")
+		return
+	}
+
+	status, err := services.GetDivisionBudgetStatus(h.DB, division.ID, req.Year)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to get budget status")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, status, "Budget saved successfully")
+}
+
+func (h *Handler) GetDivisionBudget(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Division not found")
+		return
+	}
+	year, err := strconv.Atoi(c.DefaultQuery("year", strconv.Itoa(time.Now().Year())))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid year")
+		return
+	}
+
+	status, err := services.GetDivisionBudgetStatus(h.DB, id, year)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to get budget status")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, status, "")
+}
+
+// Fund requests
+
+type FundRequestRequest struct {
+	DivisionID  uuid.UUID    `json:"division_id" binding:"required"`
+	EventID     *uuid.UUID   `json:"event_id"`
+	Title       string       `json:"title" binding:"required"`
+	Description string       `json:"description"`
+	Amount      models.Money `json:"amount" binding:"required"`
+	NeededBy    *string      `json:"needed_by"` // YYYY-MM-DD
+}
+
+func (r FundRequestRequest) apply(fr *models.FundRequest) error {
+	neededBy, err := parseOptionalDate(r.NeededBy, "needed_by")
+	if err != nil {
+		return err
+	}
+
+	fr.DivisionID = r.DivisionID
+	fr.EventID = r.EventID
+	fr.Title = r.Title
+	fr.Description = r.Description
+	fr.Amount = r.Amount
+	fr.NeededBy = neededBy
+	return nil
+}
+
+type FundRequestDecisionRequest struct {
+	ApprovedAmount models.Money `json:"approved_amount"` // defaults to the requested amount
+	Note           string       `json:"note"`
+}
+
+type FundRequestDisburseRequest struct {
+	AccountID  uuid.UUID `json:"account_id" binding:"required"`
+	CategoryID uuid.UUID `json:"category_id" binding:"required"`
+}
+
+type FundRequestReportRequest struct {
+	SpentAmount models.Money `json:"spent_amount"`
+	Note        string       `json:"note"`
+}
+
+func fundRequestError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "Fund request not found")
+	case errors.Is(err, services.ErrFundSelfApproval), errors.Is(err, services.ErrFundRequestNotYours),
+		errors.Is(err, services.ErrFundReportNotAllowed), errors.Is(err, services.ErrFundOverspend):
+		utils.ErrorResponse(c, http.StatusForbidden, err.Error())
+	case errors.Is(err, services.ErrFundRequestStatus), errors.Is(err, services.ErrOverBudget),
+		errors.Is(err, services.ErrNoDivisionBudget):
+		utils.ErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, services.ErrFundRequestInvalid), errors.Is(err, services.ErrFundApprovedAmount),
+		errors.Is(err, services.ErrFundNoteRequired), errors.Is(err, services.ErrFundDivisionInactive),
+		errors.Is(err, services.ErrFundEventNotFound), errors.Is(err, services.ErrFundSpentAmount),
+		errors.Is(err, services.ErrFundReceiptsRequired), errors.Is(err, services.ErrFundDisburseAccount):
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to process fund request")
+	}
+}
+
+func (h *Handler) GetFundRequests(c *gin.Context) {
+	page, limit := utils.GetPaginationParams(c)
+	offset := utils.GetOffset(page, limit)
+
+	var requests []models.FundRequest
+	var total int64
+
+	query := h.DB.Model(&models.FundRequest{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if divisionID := c.Query("division_id"); divisionID != "" {
+		query = query.Where("division_id = ?", divisionID)
+	}
+	if eventID := c.Query("event_id"); eventID != "" {
+		query = query.Where("event_id = ?", eventID)
+	}
+	if year := c.Query("year"); year != "" {
+		query = query.Where("year = ?", year)
+	}
+
+	query.Count(&total)
+	query.Preload("Division").Preload("Event").Preload("Requester").
+		Order("created_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&requests)
+
+	utils.PaginatedSuccessResponse(c, requests, page, limit, total)
+}
+
+// GetFundRequest returns a request together with where its division's
+// budget stands.
+func (h *Handler) GetFundRequest(c *gin.Context) {
+	var request models.FundRequest
+	if err := h.DB.Preload("Division").Preload("Event").Preload("Requester").
+		First(&request, "id = ?", c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Fund request not found")
+		return
+	}
+
+	budget, err := services.GetDivisionBudgetStatus(h.DB, request.DivisionID, request.Year)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to get budget status")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{
+		"request": request,
+		"budget":  budget,
+	}, "")
+}
+
+func (h *Handler) CreateFundRequest(c *gin.Context) {
+	var req FundRequestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var request models.FundRequest
+	if err := req.apply(&request); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	if err := services.SubmitFundRequest(h.DB, &request, userID.(uuid.UUID)); err != nil {
+		fundRequestError(c, err)
+		return
+	}
+	services.NotifyFundRequestSubmitted(h.DB, h.Notifier, &request)
+
+	budget, err := services.GetDivisionBudgetStatus(h.DB, request.DivisionID, request.Year)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to get budget status")
+		return
+	}
+
+	message := "Fund request submitted successfully"
+	if !budget.HasBudget || request.Amount > budget.Remaining {
+		message = "Fund request submitted, but it exceeds the division's remaining budget"
+	}
+	utils.SuccessResponse(c, http.StatusCreated, gin.H{
+		"request": request,
+		"budget":  budget,
+	}, message)
+}
+
+func (h *Handler) UpdateFundRequest(c *gin.Context) {
+	var request models.FundRequest
+	if err := h.DB.First(&request, "id = ?", c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Fund request not found")
+		return
+	}
+
+	var req FundRequestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := req.apply(&request); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	if err := services.UpdateFundRequest(h.DB, &request, userID.(uuid.UUID)); err != nil {
+		fundRequestError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, request, "Fund request updated successfully")
+}
+
+func (h *Handler) ApproveFundRequest(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Fund request not found")
+		return
+	}
+
+	var req FundRequestDecisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	request, err := services.ApproveFundRequest(h.DB, id, userID.(uuid.UUID), req.ApprovedAmount, req.Note)
+	if err != nil {
+		fundRequestError(c, err)
+		return
+	}
+	services.NotifyFundRequestUpdated(h.DB, h.Notifier, request)
+
+	utils.SuccessResponse(c, http.StatusOK, request, "Fund request approved successfully")
+}
+
+func (h *Handler) RejectFundRequest(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Fund request not found")
+		return
+	}
+
+	var req FundRequestDecisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	request, err := services.RejectFundRequest(h.DB, id, userID.(uuid.UUID), req.Note)
+	if err != nil {
+		fundRequestError(c, err)
+		return
+	}
+	services.NotifyFundRequestUpdated(h.DB, h.Notifier, request)
+
+	utils.SuccessResponse(c, http.StatusOK, request, "Fund request rejected successfully")
+}
+
+func (h *Handler) CancelFundRequest(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Fund request not found")
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	request, err := services.CancelFundRequest(h.DB, id, userID.(uuid.UUID))
+	if err != nil {
+		fundRequestError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, request, "Fund request cancelled successfully")
+}
+
+func (h *Handler) DisburseFundRequest(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Fund request not found")
+		return
+	}
+
+	var req FundRequestDisburseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	request, err := services.DisburseFundRequest(h.DB, id, userID.(uuid.UUID), req.AccountID, req.CategoryID)
+	if err != nil {
+		fundRequestError(c, err)
+		return
+	}
+	services.NotifyFundRequestUpdated(h.DB, h.Notifier, request)
+
+	utils.SuccessResponse(c, http.StatusOK, request, "Fund request disbursed successfully")
+}
+
+// ReportFundRequest settles a disbursed request with the amount actually
+// spent; receipts must be uploaded first.
+func (h *Handler) ReportFundRequest(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Fund request not found")
+		return
+	}
+
+	var req FundRequestReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	role, _ := c.Get("userRole")
+	request, err := services.SettleFundRequest(h.DB, id, userID.(uuid.UUID), models.UserRole(role.(string)), req.SpentAmount, req.Note)
+	if err != nil {
+		fundRequestError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, request, "Fund request settled successfully")
+}
+
+// UploadFundRequestReceipt adds a receipt (nota/kuitansi) to a disbursed
+// request.
+func (h *Handler) UploadFundRequestReceipt(c *gin.Context) {
+	var request models.FundRequest
+	if err := h.DB.First(&request, "id = ?", c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Fund request not found")
+		return
+	}
+	if !h.canReportFundRequest(c, &request) {
+		return
+	}
+	if request.Status != models.FundRequestDisbursed {
+		utils.ErrorResponse(c, http.StatusConflict, "Receipts can only be added to disbursed requests")
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "No file provided")
+		return
+	}
+
+	key, err := services.SavePrivateFile("fund-requests", file, MaxLedgerAttachmentSize, services.DocumentContentTypes)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrFileTooLarge):
+			utils.ErrorResponse(c, http.StatusBadRequest, "File size exceeds 5MB limit")
+		case errors.Is(err, services.ErrFileTypeInvalid):
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid file type. Only JPG, PNG, WebP or PDF are allowed")
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to save file")
+		}
+		return
+	}
+
+	request.Receipts = append(request.Receipts, key)
+	if err := h.DB.Model(&request).Update("receipts", request.Receipts).Error; err != nil {
+		os.Remove(services.PrivateFilePath(key))
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to save receipt")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, request, "Receipt uploaded successfully")
+}
+
+// GetFundRequestReceipt streams the receipt at the given index of a request.
+func (h *Handler) GetFundRequestReceipt(c *gin.Context) {
+	var request models.FundRequest
+	if err := h.DB.First(&request, "id = ?", c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Fund request not found")
+		return
+	}
+
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil || index < 0 || index >= len(request.Receipts) {
+		utils.ErrorResponse(c, http.StatusNotFound, "Receipt not found")
+		return
+	}
+
+	c.File(services.PrivateFilePath(request.Receipts[index]))
+}
+
+func (h *Handler) DeleteFundRequestReceipt(c *gin.Context) {
+	var request models.FundRequest
+	if err := h.DB.First(&request, "id = ?", c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Fund request not found")
+		return
+	}
+	if !h.canReportFundRequest(c, &request) {
+		return
+	}
+	if request.Status != models.FundRequestDisbursed {
+		utils.ErrorResponse(c, http.StatusConflict, "Receipts of a settled request are kept")
+		return
+	}
+
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil || index < 0 || index >= len(request.Receipts) {
+		utils.ErrorResponse(c, http.StatusNotFound, "Receipt not found")
+		return
+	}
+
+	key := request.Receipts[index]
+	request.Receipts = append(request.Receipts[:index], request.Receipts[index+1:]...)
+	if err := h.DB.Model(&request).Update("receipts", request.Receipts).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to remove receipt")
+		return
+	}
+	os.Remove(services.PrivateFilePath(key))
+
+	utils.SuccessResponse(c, http.StatusOK, request, "Receipt removed successfully")
+}
+
+// canReportFundRequest answers 403 unless the caller may change the
+// request's receipts.
+func (h *Handler) canReportFundRequest(c *gin.Context, request *models.FundRequest) bool {
+	userID, _ := c.Get("userID")
+	role, _ := c.Get("userRole")
+	if !services.CanReportFundRequest(request, userID.(uuid.UUID), models.UserRole(role.(string))) {
+		utils.ErrorResponse(c, http.StatusForbidden, services.ErrFundReportNotAllowed.Error())
+		return false
+	}
+	return true
+}
+
+// GetOutstandingAdvances reports money paid out to divisions that hasn't
+// been accounted for with receipts yet.
+func (h *Handler) GetOutstandingAdvances(c *gin.Context) {
+	report, err := services.GetOutstandingAdvances(h.DB, time.Now())
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to get outstanding advances")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, report, "")
+}
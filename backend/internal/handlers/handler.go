@@ -1,12 +1,36 @@
 package handlers
 
-import "gorm.io/gorm"
+import (
+	"time"
+	"masjid-baiturrahim-backend/config"
+	"masjid-baiturrahim-backend/internal/services"
+
+	"gorm.io/gorm"
+)
 
 type Handler struct {
-	DB *gorm.DB
+	DB       *gorm.DB
+	Mailer   services.Mailer
+	Notifier services.Notifier
+
+	// Key for verifying bank mutation webhooks
+	MutationWebhookSecret string
+
+	// Issues virtual accounts for donations; nil when disabled
+	VirtualAccounts services.VirtualAccountProvider
+	VAExpiry        time.Duration
 }
 
 func New(db *gorm.DB) *Handler {
-	return &Handler{DB: db}
-}
+	cfg := config.Load()
+	mailer := services.NewMailer(cfg)
+	return &Handler{
+		DB:       db,
+		Mailer:   mailer,
+		Notifier: services.MailNotifier{Mailer: mailer},
 
+		MutationWebhookSecret: cfg.MutationWebhookSecret,
+		VirtualAccounts:       services.NewVirtualAccountProvider(cfg),
+		VAExpiry:              cfg.VAExpiry,
+	}
+}
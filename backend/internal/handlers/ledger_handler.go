@@ -0,0 +1,497 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+	"masjid-baiturrahim-backend/internal/services"
+	"masjid-baiturrahim-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const MaxLedgerAttachmentSize = 5 * 1024 * 1024 // 5MB
+
+// Accounts
+
+func (h *Handler) GetLedgerAccounts(c *gin.Context) {
+	var asOf *time.Time
+	if s := c.Query("as_of"); s != "" {
+		date, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid as_of date. Use YYYY-MM-DD")
+			return
+		}
+		asOf = &date
+	}
+
+	balances, err := services.GetAccountBalances(h.DB, asOf)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to get account balances")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, balances, "")
+}
+
+func (h *Handler) CreateLedgerAccount(c *gin.Context) {
+	var account models.LedgerAccount
+	if err := c.ShouldBindJSON(&account); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if account.Name == "" || account.Type == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Name and type are required")
+		return
+	}
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if account.IsDefault {
+			if err := tx.Model(&models.LedgerAccount{}).Where("is_default = ?", true).Update("is_default", false).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(&account).Error
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Payment method is already linked to another account")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, account, "Account created successfully")
+}
+
+func (h *Handler) UpdateLedgerAccount(c *gin.Context) {
+	id := c.Param("id")
+	var account models.LedgerAccount
+
+	if err := h.DB.First(&account, "id = ?", id).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Account not found")
+		return
+	}
+
+	if err := c.ShouldBindJSON(&account); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Only one account receives donations without a linked payment method
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if account.IsDefault {
+			if err := tx.Model(&models.LedgerAccount{}).Where("is_default = ? AND id <> ?", true, account.ID).Update("is_default", false).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Save(&account).Error
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update account")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, account, "Account updated successfully")
+}
+
+func (h *Handler) DeleteLedgerAccount(c *gin.Context) {
+	id := c.Param("id")
+
+	var count int64
+	h.DB.Model(&models.LedgerEntry{}).Where("account_id = ?", id).Count(&count)
+	if count > 0 {
+		utils.ErrorResponse(c, http.StatusConflict, "Account has entries; deactivate it instead")
+		return
+	}
+
+	if err := h.DB.Delete(&models.LedgerAccount{}, "id = ?", id).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete account")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, nil, "Account deleted successfully")
+}
+
+// Categories
+
+func (h *Handler) GetLedgerCategories(c *gin.Context) {
+	var categories []models.LedgerCategory
+	query := h.DB.Model(&models.LedgerCategory{})
+	if entryType := c.Query("type"); entryType != "" {
+		query = query.Where("type = ?", entryType)
+	}
+	if c.Query("active") == "true" {
+		query = query.Where("is_active = ?", true)
+	}
+
+	query.Order("type ASC, name ASC").Find(&categories)
+	utils.SuccessResponse(c, http.StatusOK, categories, "")
+}
+
+func (h *Handler) CreateLedgerCategory(c *gin.Context) {
+	var category models.LedgerCategory
+	if err := c.ShouldBindJSON(&category); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if category.Name == "" || (category.Type != models.LedgerIncome && category.Type != models.LedgerExpense) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Name and a type of income or expense are required")
+		return
+	}
+	if category.DonationCategory != nil && category.Type != models.LedgerIncome {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Only income categories can receive donations")
+		return
+	}
+	if category.Code == "" {
+		category.Code = utils.Slugify(category.Name)
+	}
+
+	if err := h.DB.Create(&category).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Category code or donation category already in use")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, category, "Category created successfully")
+}
+
+func (h *Handler) UpdateLedgerCategory(c *gin.Context) {
+	id := c.Param("id")
+	var category models.LedgerCategory
+
+	if err := h.DB.First(&category, "id = ?", id).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Category not found")
+		return
+	}
+
+	// The type can't change once entries are booked against the category
+	entryType := category.Type
+	if err := c.ShouldBindJSON(&category); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	category.Type = entryType
+	if category.DonationCategory != nil && category.Type != models.LedgerIncome {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Only income categories can receive donations")
+		return
+	}
+
+	if err := h.DB.Save(&category).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Category code or donation category already in use")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, category, "Category updated successfully")
+}
+
+func (h *Handler) DeleteLedgerCategory(c *gin.Context) {
+	id := c.Param("id")
+
+	var count int64
+	h.DB.Model(&models.LedgerEntry{}).Where("category_id = ?", id).Count(&count)
+	if count > 0 {
+		utils.ErrorResponse(c, http.StatusConflict, "Category has entries; deactivate it instead")
+		return
+	}
+
+	if err := h.DB.Delete(&models.LedgerCategory{}, "id = ?", id).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete category")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, nil, "Category deleted successfully")
+}
+
+// Entries
+
+type LedgerEntryRequest struct {
+	AccountID   uuid.UUID    `json:"account_id" binding:"required"`
+	CategoryID  uuid.UUID    `json:"category_id" binding:"required"`
+	Type        string       `json:"type" binding:"required,oneof=income expense"`
+	Amount      models.Money `json:"amount" binding:"required"`
+	EntryDate   string       `json:"entry_date" binding:"required"` // YYYY-MM-DD
+	Description string       `json:"description" binding:"required"`
+	Reference   *string      `json:"reference"`
+	Note        string       `json:"note"` // for the treasurer, when the expense needs approval
+}
+
+func (r LedgerEntryRequest) apply(entry *models.LedgerEntry) error {
+	date, err := time.Parse("2006-01-02", r.EntryDate)
+	if err != nil {
+		return errors.New("invalid entry_date. Use YYYY-MM-DD")
+	}
+
+	entry.AccountID = r.AccountID
+	entry.CategoryID = r.CategoryID
+	entry.Type = models.LedgerEntryType(r.Type)
+	entry.Amount = r.Amount
+	entry.EntryDate = date
+	entry.Description = r.Description
+	entry.Reference = r.Reference
+	return nil
+}
+
+func (h *Handler) GetLedgerEntries(c *gin.Context) {
+	page, limit := utils.GetPaginationParams(c)
+	offset := utils.GetOffset(page, limit)
+
+	var filter services.LedgerEntryFilter
+	if accountID, err := uuid.Parse(c.Query("account_id")); err == nil {
+		filter.AccountID = &accountID
+	}
+	if categoryID, err := uuid.Parse(c.Query("category_id")); err == nil {
+		filter.CategoryID = &categoryID
+	}
+	filter.Type = models.LedgerEntryType(c.Query("type"))
+	if date, err := time.Parse("2006-01-02", c.Query("from")); err == nil {
+		filter.From = &date
+	}
+	if date, err := time.Parse("2006-01-02", c.Query("to")); err == nil {
+		filter.To = &date
+	}
+
+	entries, total, err := services.ListLedgerEntries(h.DB, filter, offset, limit)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to get ledger entries")
+		return
+	}
+
+	utils.PaginatedSuccessResponse(c, entries, page, limit, total)
+}
+
+func (h *Handler) GetLedgerEntry(c *gin.Context) {
+	var entry models.LedgerEntry
+	if err := h.DB.Preload("Account").Preload("Category").Preload("Creator").
+		First(&entry, "id = ?", c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Entry not found")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, entry, "")
+}
+
+func (h *Handler) CreateLedgerEntry(c *gin.Context) {
+	var req LedgerEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var entry models.LedgerEntry
+	if err := req.apply(&entry); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := services.ValidateLedgerEntry(h.DB, &entry); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	creator := userID.(uuid.UUID)
+	if entry.Type == models.LedgerExpense && services.NeedsApproval(h.DB, entry.Amount) {
+		h.requestApproval(c, func() (*models.Approval, error) {
+			return services.RequestExpense(h.DB, &entry, creator, req.Note)
+		})
+		return
+	}
+	entry.Source = models.LedgerSourceManual
+	entry.CreatedBy = &creator
+
+	if err := h.DB.Create(&entry).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create entry")
+		return
+	}
+
+	h.DB.Preload("Account").Preload("Category").First(&entry, "id = ?", entry.ID)
+	utils.SuccessResponse(c, http.StatusCreated, entry, "Entry created successfully")
+}
+
+// findManualEntry loads an entry that may be changed by hand; entries posted
+// from donations follow the donation and are corrected through it instead.
+func (h *Handler) findManualEntry(c *gin.Context) (*models.LedgerEntry, bool) {
+	var entry models.LedgerEntry
+	if err := h.DB.First(&entry, "id = ?", c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Entry not found")
+		return nil, false
+	}
+	if entry.Source != models.LedgerSourceManual {
+		utils.ErrorResponse(c, http.StatusBadRequest, services.ErrLedgerEntryGenerated.Error())
+		return nil, false
+	}
+	return &entry, true
+}
+
+func (h *Handler) UpdateLedgerEntry(c *gin.Context) {
+	entry, ok := h.findManualEntry(c)
+	if !ok {
+		return
+	}
+
+	var req LedgerEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	// Changing a large expense needs approval too, whatever it becomes
+	wasLargeExpense := entry.Type == models.LedgerExpense && services.NeedsApproval(h.DB, entry.Amount)
+	if err := req.apply(entry); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := services.ValidateLedgerEntry(h.DB, entry); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if wasLargeExpense || entry.Type == models.LedgerExpense && services.NeedsApproval(h.DB, entry.Amount) {
+		userID, _ := c.Get("userID")
+		h.requestApproval(c, func() (*models.Approval, error) {
+			return services.RequestExpense(h.DB, entry, userID.(uuid.UUID), req.Note)
+		})
+		return
+	}
+
+	if err := h.DB.Omit("Account", "Category", "Creator").Save(entry).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update entry")
+		return
+	}
+
+	h.DB.Preload("Account").Preload("Category").First(entry, "id = ?", entry.ID)
+	utils.SuccessResponse(c, http.StatusOK, entry, "Entry updated successfully")
+}
+
+func (h *Handler) DeleteLedgerEntry(c *gin.Context) {
+	entry, ok := h.findManualEntry(c)
+	if !ok {
+		return
+	}
+
+	if entry.Type == models.LedgerExpense && services.NeedsApproval(h.DB, entry.Amount) {
+		var req struct {
+			Note string `json:"note"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		userID, _ := c.Get("userID")
+		h.requestApproval(c, func() (*models.Approval, error) {
+			return services.RequestExpenseDeletion(h.DB, entry, userID.(uuid.UUID), req.Note)
+		})
+		return
+	}
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := services.CancelPendingApprovals(tx, entry.ID, "Entri dihapus"); err != nil {
+			return err
+		}
+		return tx.Delete(entry).Error
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete entry")
+		return
+	}
+	for _, key := range entry.Attachments {
+		os.Remove(services.PrivateFilePath(key))
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, nil, "Entry deleted successfully")
+}
+
+// UploadLedgerAttachment adds a receipt or invoice to an entry.
+func (h *Handler) UploadLedgerAttachment(c *gin.Context) {
+	var entry models.LedgerEntry
+	if err := h.DB.First(&entry, "id = ?", c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Entry not found")
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "No file provided")
+		return
+	}
+
+	key, err := services.SavePrivateFile("ledger", file, MaxLedgerAttachmentSize, services.DocumentContentTypes)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrFileTooLarge):
+			utils.ErrorResponse(c, http.StatusBadRequest, "File size exceeds 5MB limit")
+		case errors.Is(err, services.ErrFileTypeInvalid):
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid file type. Only JPG, PNG, WebP or PDF are allowed")
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to save file")
+		}
+		return
+	}
+
+	entry.Attachments = append(entry.Attachments, key)
+	if err := h.DB.Model(&entry).Update("attachments", entry.Attachments).Error; err != nil {
+		os.Remove(services.PrivateFilePath(key))
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to save attachment")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, entry, "Attachment uploaded successfully")
+}
+
+// GetLedgerAttachment streams the attachment at the given index of an entry.
+func (h *Handler) GetLedgerAttachment(c *gin.Context) {
+	var entry models.LedgerEntry
+	if err := h.DB.First(&entry, "id = ?", c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Entry not found")
+		return
+	}
+
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil || index < 0 || index >= len(entry.Attachments) {
+		utils.ErrorResponse(c, http.StatusNotFound, "Attachment not found")
+		return
+	}
+
+	c.File(services.PrivateFilePath(entry.Attachments[index]))
+}
+
+func (h *Handler) DeleteLedgerAttachment(c *gin.Context) {
+	var entry models.LedgerEntry
+	if err := h.DB.First(&entry, "id = ?", c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Entry not found")
+		return
+	}
+
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil || index < 0 || index >= len(entry.Attachments) {
+		utils.ErrorResponse(c, http.StatusNotFound, "Attachment not found")
+		return
+	}
+
+	key := entry.Attachments[index]
+	entry.Attachments = append(entry.Attachments[:index], entry.Attachments[index+1:]...)
+	if err := h.DB.Model(&entry).Update("attachments", entry.Attachments).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to remove attachment")
+		return
+	}
+	os.Remove(services.PrivateFilePath(key))
+
+	utils.SuccessResponse(c, http.StatusOK, entry, "Attachment removed successfully")
+}
+
+// SyncDonationsToLedger posts confirmed donations that predate the ledger.
+func (h *Handler) SyncDonationsToLedger(c *gin.Context) {
+	posted, err := services.SyncDonationsToLedger(h.DB)
+	if err != nil {
+		if errors.Is(err, services.ErrNoLedgerAccount) || errors.Is(err, services.ErrNoLedgerCategory) {
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to sync donations")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{"posted": posted}, fmt.Sprintf("%d donations posted to the ledger", posted))
+}
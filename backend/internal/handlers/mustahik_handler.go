@@ -0,0 +1,336 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+	"masjid-baiturrahim-backend/internal/services"
+	"masjid-baiturrahim-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Mustahik
+
+func (h *Handler) GetMustahikList(c *gin.Context) {
+	page, limit := utils.GetPaginationParams(c)
+	offset := utils.GetOffset(page, limit)
+
+	var mustahik []models.Mustahik
+	var total int64
+
+	query := h.DB.Model(&models.Mustahik{})
+	if asnaf := c.Query("asnaf"); asnaf != "" {
+		query = query.Where("asnaf = ?", asnaf)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("verification_status = ?", status)
+	}
+	if q := c.Query("q"); q != "" {
+		query = query.Where("name ILIKE ? OR nik = ?", "%"+q+"%", q)
+	}
+
+	query.Count(&total)
+	query.Order("name ASC").
+		Offset(offset).
+		Limit(limit).
+		Find(&mustahik)
+
+	utils.PaginatedSuccessResponse(c, mustahik, page, limit, total)
+}
+
+func (h *Handler) GetMustahik(c *gin.Context) {
+	var mustahik models.Mustahik
+	if err := h.DB.Preload("Verifier").First(&mustahik, "id = ?", c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Mustahik not found")
+		return
+	}
+
+	var distributions []models.Distribution
+	h.DB.Where("mustahik_id = ?", mustahik.ID).Order("distributed_at DESC").Find(&distributions)
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{
+		"mustahik":      mustahik,
+		"distributions": distributions,
+	}, "")
+}
+
+func (h *Handler) CreateMustahik(c *gin.Context) {
+	var mustahik models.Mustahik
+	if err := c.ShouldBindJSON(&mustahik); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if mustahik.Name == "" || !mustahik.Asnaf.Valid() {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Name and a valid asnaf are required")
+		return
+	}
+
+	// New registrations always start unverified
+	mustahik.VerificationStatus = models.VerificationPending
+	mustahik.VerifiedBy = nil
+	mustahik.VerifiedAt = nil
+
+	if err := h.DB.Create(&mustahik).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "A mustahik with this NIK is already registered")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, mustahik, "Mustahik registered successfully")
+}
+
+func (h *Handler) UpdateMustahik(c *gin.Context) {
+	var mustahik models.Mustahik
+	if err := h.DB.First(&mustahik, "id = ?", c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Mustahik not found")
+		return
+	}
+
+	verification := mustahik.VerificationStatus
+	verifiedBy, verifiedAt := mustahik.VerifiedBy, mustahik.VerifiedAt
+	if err := c.ShouldBindJSON(&mustahik); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !mustahik.Asnaf.Valid() {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid asnaf")
+		return
+	}
+	// Verification is changed through its own endpoint only
+	mustahik.VerificationStatus = verification
+	mustahik.VerifiedBy, mustahik.VerifiedAt = verifiedBy, verifiedAt
+
+	if err := h.DB.Omit("Verifier").Save(&mustahik).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "A mustahik with this NIK is already registered")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, mustahik, "Mustahik updated successfully")
+}
+
+func (h *Handler) DeleteMustahik(c *gin.Context) {
+	if err := h.DB.Delete(&models.Mustahik{}, "id = ?", c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete mustahik")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, nil, "Mustahik deleted successfully")
+}
+
+func (h *Handler) VerifyMustahik(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid mustahik ID")
+		return
+	}
+
+	var req struct {
+		Status models.VerificationStatus `json:"status" binding:"required"`
+		Notes  string                    `json:"notes"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	mustahik, err := services.VerifyMustahik(h.DB, id, req.Status, req.Notes, userID.(uuid.UUID))
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			utils.ErrorResponse(c, http.StatusNotFound, "Mustahik not found")
+		case errors.Is(err, services.ErrInvalidVerification):
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to verify mustahik")
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, mustahik, "Mustahik verification updated")
+}
+
+// Distributions
+
+type DistributionRequest struct {
+	MustahikID     uuid.UUID               `json:"mustahik_id" binding:"required"`
+	SourceCategory models.DonationCategory `json:"source_category" binding:"required"`
+	Amount         models.Money            `json:"amount"`
+	RiceKg         float64                 `json:"rice_kg"`
+	Description    string                  `json:"description"`
+	DistributedAt  string                  `json:"distributed_at"` // YYYY-MM-DD, defaults to today
+	AccountID      *uuid.UUID              `json:"account_id"`
+	Note           string                  `json:"note"` // for the treasurer, when the distribution needs approval
+}
+
+func (h *Handler) GetDistributions(c *gin.Context) {
+	page, limit := utils.GetPaginationParams(c)
+	offset := utils.GetOffset(page, limit)
+
+	var distributions []models.Distribution
+	var total int64
+
+	query := h.DB.Model(&models.Distribution{})
+	if category := c.Query("category"); category != "" {
+		query = query.Where("source_category = ?", category)
+	}
+	if asnaf := c.Query("asnaf"); asnaf != "" {
+		query = query.Where("asnaf = ?", asnaf)
+	}
+	if mustahikID := c.Query("mustahik_id"); mustahikID != "" {
+		query = query.Where("mustahik_id = ?", mustahikID)
+	}
+	if from := c.Query("from"); from != "" {
+		query = query.Where("distributed_at >= ?", from)
+	}
+	if to := c.Query("to"); to != "" {
+		query = query.Where("distributed_at <= ?", to)
+	}
+
+	query.Count(&total)
+	query.Preload("Mustahik").
+		Order("distributed_at DESC, created_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&distributions)
+
+	utils.PaginatedSuccessResponse(c, distributions, page, limit, total)
+}
+
+func (h *Handler) CreateDistribution(c *gin.Context) {
+	var req DistributionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	distribution := models.Distribution{
+		MustahikID:     req.MustahikID,
+		SourceCategory: req.SourceCategory,
+		Amount:         req.Amount,
+		RiceKg:         req.RiceKg,
+		Description:    req.Description,
+		AccountID:      req.AccountID,
+	}
+	if req.DistributedAt != "" {
+		date, err := time.Parse("2006-01-02", req.DistributedAt)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid distributed_at. Use YYYY-MM-DD")
+			return
+		}
+		distribution.DistributedAt = date
+	}
+
+	userID, _ := c.Get("userID")
+	actorID := userID.(uuid.UUID)
+
+	// Large payouts wait for a treasurer other than the one recording them
+	if services.NeedsApproval(h.DB, distribution.Amount) {
+		approval, err := services.RequestDistribution(h.DB, &distribution, actorID, req.Note)
+		if err != nil {
+			distributionError(c, err)
+			return
+		}
+		h.approvalRequested(c, approval)
+		return
+	}
+
+	if err := services.RecordDistribution(h.DB, &distribution, actorID); err != nil {
+		distributionError(c, err)
+		return
+	}
+
+	h.DB.Preload("Mustahik").First(&distribution, "id = ?", distribution.ID)
+	utils.SuccessResponse(c, http.StatusCreated, distribution, "Distribution recorded successfully")
+}
+
+func distributionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrDistributionAccount):
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "Mustahik not found")
+	case errors.Is(err, services.ErrMustahikNotVerified), errors.Is(err, services.ErrInsufficientFunds),
+		errors.Is(err, services.ErrInsufficientRice), errors.Is(err, services.ErrApprovalPending):
+		utils.ErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, services.ErrZakatNegative), errors.Is(err, services.ErrDistributionEmpty),
+		errors.Is(err, services.ErrDistributionRice):
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to record distribution")
+	}
+}
+
+func (h *Handler) DeleteDistribution(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid distribution ID")
+		return
+	}
+
+	if err := services.DeleteDistribution(h.DB, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.ErrorResponse(c, http.StatusNotFound, "Distribution not found")
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete distribution")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, nil, "Distribution deleted successfully")
+}
+
+func (h *Handler) GetFundBalances(c *gin.Context) {
+	balances := make([]*services.FundBalance, 0, len(models.AllDonationCategories))
+	for _, category := range models.AllDonationCategories {
+		balance, err := services.GetFundBalance(h.DB, category)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to get fund balances")
+			return
+		}
+		balances = append(balances, balance)
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, balances, "")
+}
+
+// GetDistributionReport reports collected versus distributed funds between
+// from and to (default: the current year).
+func (h *Handler) GetDistributionReport(c *gin.Context) {
+	now := time.Now()
+	from := time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location())
+	to := time.Date(now.Year(), 12, 31, 0, 0, 0, 0, now.Location())
+
+	if s := c.Query("from"); s != "" {
+		date, err := time.ParseInLocation("2006-01-02", s, now.Location())
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid from date. Use YYYY-MM-DD")
+			return
+		}
+		from = date
+	}
+	if s := c.Query("to"); s != "" {
+		date, err := time.ParseInLocation("2006-01-02", s, now.Location())
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid to date. Use YYYY-MM-DD")
+			return
+		}
+		to = date
+	}
+	if to.Before(from) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "to must not be before from")
+		return
+	}
+
+	report, err := services.GetDistributionReport(h.DB, from, to)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to build distribution report")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, report, "")
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"masjid-baiturrahim-backend/internal/services"
+	"masjid-baiturrahim-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const MaxMutationWebhookSize = 1024 * 1024 // 1MB
+
+// ReceiveMutationWebhook ingests bank mutations pushed by the mutation-checker
+// service. Credits are matched to pending donations and wait in the
+// reconciliation queue for confirmation like imported statements.
+func (h *Handler) ReceiveMutationWebhook(c *gin.Context) {
+	if h.MutationWebhookSecret == "" {
+		utils.ErrorResponse(c, http.StatusNotFound, "Mutation webhook is not enabled")
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, MaxMutationWebhookSize+1))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to read request body")
+		return
+	}
+	if len(body) > MaxMutationWebhookSize {
+		utils.ErrorResponse(c, http.StatusRequestEntityTooLarge, "Payload too large")
+		return
+	}
+	if !services.VerifyMutationSignature(h.MutationWebhookSecret, body, c.GetHeader(services.MutationSignatureHeader)) {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid signature")
+		return
+	}
+
+	result, err := services.IngestMutationWebhook(h.DB, body)
+	if err != nil {
+		if errors.Is(err, services.ErrMutationPayload) {
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to store mutations")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, result, "Mutations received")
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+	"masjid-baiturrahim-backend/internal/services"
+	"masjid-baiturrahim-backend/internal/testutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testWebhookSecret = "webhook-secret"
+
+func postMutationWebhook(t *testing.T, router http.Handler, body []byte, signature string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/mutations", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if signature != "" {
+		req.Header.Set(services.MutationSignatureHeader, signature)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestReceiveMutationWebhook(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testutil.NewDB(t)
+	h := &Handler{DB: db, MutationWebhookSecret: testWebhookSecret}
+	router := gin.New()
+	router.POST("/webhooks/mutations", h.ReceiveMutationWebhook)
+
+	donation := models.Donation{
+		DonationCode:   "DON-20261019-WHK01",
+		DonorName:      "Hamba Allah",
+		Amount:         models.NewMoney(150000),
+		UniqueCode:     37,
+		TransferAmount: models.NewMoney(150037),
+		Category:       models.DonationCategoryInfaq,
+		Status:         models.DonationStatusPending,
+	}
+	if err := db.Create(&donation).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := json.Marshal([]services.WebhookMutation{{
+		MutationID:    "mut-1",
+		BankType:      "bsi",
+		AccountNumber: "7123456789",
+		Date:          time.Now().Format("2006-01-02 15:04:05"),
+		Description:   "TRF DARI HAMBA ALLAH",
+		Type:          "CR",
+		Amount:        "150037",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := services.SignMutationPayload(testWebhookSecret, body)
+
+	countMutations := func() int64 {
+		var n int64
+		db.Model(&models.BankMutation{}).Count(&n)
+		return n
+	}
+	readResult := func(w *httptest.ResponseRecorder) services.MutationWebhookResult {
+		var resp struct {
+			Data services.MutationWebhookResult `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode response: %v (%s)", err, w.Body.String())
+		}
+		return resp.Data
+	}
+
+	t.Run("bad signature", func(t *testing.T) {
+		for name, sig := range map[string]string{
+			"missing":    "",
+			"wrong key":  services.SignMutationPayload("another-secret", body),
+			"other body": services.SignMutationPayload(testWebhookSecret, append([]byte(" "), body...)),
+		} {
+			if w := postMutationWebhook(t, router, body, sig); w.Code != http.StatusUnauthorized {
+				t.Errorf("%s: status = %d, want 401 (%s)", name, w.Code, w.Body.String())
+			}
+		}
+		if n := countMutations(); n != 0 {
+			t.Errorf("%d mutations stored from unsigned requests", n)
+		}
+	})
+
+	t.Run("valid signature", func(t *testing.T) {
+		w := postMutationWebhook(t, router, body, signature)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200 (%s)", w.Code, w.Body.String())
+		}
+		if got := readResult(w); got != (services.MutationWebhookResult{Received: 1, Matched: 1}) {
+			t.Errorf("result = %+v, want 1 received and matched", got)
+		}
+
+		var mutation models.BankMutation
+		if err := db.First(&mutation, "external_id = ?", "mut-1").Error; err != nil {
+			t.Fatal(err)
+		}
+		if mutation.MatchStatus != models.MutationMatched || mutation.DonationID == nil || *mutation.DonationID != donation.ID {
+			t.Errorf("mutation is %s for %v, want matched to %s", mutation.MatchStatus, mutation.DonationID, donation.ID)
+		}
+		if mutation.Source != models.MutationSourceWebhook || mutation.Bank != models.BankBSI || mutation.Amount != models.NewMoney(150037) {
+			t.Errorf("mutation stored as %s %s %s", mutation.Source, mutation.Bank, mutation.Amount.Format())
+		}
+	})
+
+	t.Run("duplicate fingerprint", func(t *testing.T) {
+		// The checker retries until it gets a 200, with the same mutation_id
+		w := postMutationWebhook(t, router, body, signature)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200 (%s)", w.Code, w.Body.String())
+		}
+		if got := readResult(w); got != (services.MutationWebhookResult{Received: 1, Duplicates: 1}) {
+			t.Errorf("result = %+v, want 1 received and duplicate", got)
+		}
+		if n := countMutations(); n != 1 {
+			t.Errorf("%d mutations stored, want 1", n)
+		}
+	})
+
+	t.Run("disabled without a secret", func(t *testing.T) {
+		disabled := gin.New()
+		disabled.POST("/webhooks/mutations", (&Handler{DB: db}).ReceiveMutationWebhook)
+		if w := postMutationWebhook(t, disabled, body, services.SignMutationPayload("", body)); w.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want 404", w.Code)
+		}
+	})
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"net/http"
 	"masjid-baiturrahim-backend/internal/models"
+	"masjid-baiturrahim-backend/internal/services"
 	"masjid-baiturrahim-backend/internal/utils"
 
 	"github.com/gin-gonic/gin"
@@ -34,6 +35,11 @@ func (h *Handler) CreatePaymentMethod(c *gin.Context) {
 		return
 	}
 
+	if !validQRISPayload(method) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid QRIS payload")
+		return
+	}
+
 	if err := h.DB.Create(&method).Error; err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create payment method")
 		return
@@ -56,6 +62,11 @@ func (h *Handler) UpdatePaymentMethod(c *gin.Context) {
 		return
 	}
 
+	if !validQRISPayload(method) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid QRIS payload")
+		return
+	}
+
 	if err := h.DB.Save(&method).Error; err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update payment method")
 		return
@@ -90,3 +101,9 @@ func (h *Handler) ReorderPaymentMethods(c *gin.Context) {
 	utils.SuccessResponse(c, http.StatusOK, nil, "Payment methods reordered successfully")
 }
 
+func validQRISPayload(method models.PaymentMethod) bool {
+	if method.QRISPayload == nil || *method.QRISPayload == "" {
+		return true
+	}
+	return services.ValidateQRIS(*method.QRISPayload) == nil
+}
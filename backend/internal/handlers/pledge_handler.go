@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+	"masjid-baiturrahim-backend/internal/services"
+	"masjid-baiturrahim-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type PledgeRequest struct {
+	// Either an existing donor or contact details to find or create one;
+	// ignored on update
+	DonorID    *uuid.UUID `json:"donor_id"`
+	DonorName  string     `json:"donor_name"`
+	DonorEmail *string    `json:"donor_email"`
+	DonorPhone *string    `json:"donor_phone"`
+
+	Amount     models.Money            `json:"amount" binding:"required"`
+	Category   models.DonationCategory `json:"category" binding:"required"`
+	CampaignID *uuid.UUID              `json:"campaign_id"`
+	Frequency  models.PledgeFrequency  `json:"frequency"`
+	DayOfMonth int                     `json:"day_of_month" binding:"required"`
+	StartDate  string                  `json:"start_date" binding:"required"` // YYYY-MM-DD
+	EndDate    *string                 `json:"end_date"`
+	Notes      string                  `json:"notes"`
+}
+
+// apply copies the schedule fields of the request onto pledge.
+func (req *PledgeRequest) apply(pledge *models.Pledge) error {
+	start, err := time.Parse("2006-01-02", req.StartDate)
+	if err != nil {
+		return errors.New("Invalid start date. Use YYYY-MM-DD")
+	}
+	var end *time.Time
+	if req.EndDate != nil && *req.EndDate != "" {
+		date, err := time.Parse("2006-01-02", *req.EndDate)
+		if err != nil {
+			return errors.New("Invalid end date. Use YYYY-MM-DD")
+		}
+		end = &date
+	}
+
+	pledge.Amount = req.Amount
+	pledge.Category = req.Category
+	pledge.CampaignID = req.CampaignID
+	pledge.Frequency = req.Frequency
+	if pledge.Frequency == "" {
+		pledge.Frequency = models.PledgeMonthly
+	}
+	pledge.DayOfMonth = req.DayOfMonth
+	pledge.StartDate = start
+	pledge.EndDate = end
+	pledge.Notes = req.Notes
+	return nil
+}
+
+func pledgeErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrPledgeInvalid), errors.Is(err, services.ErrPledgeFrequency),
+		errors.Is(err, services.ErrPledgeDay), errors.Is(err, services.ErrPledgeDates),
+		errors.Is(err, services.ErrPledgeStatus),
+		errors.Is(err, services.ErrDonationAmountRange), errors.Is(err, services.ErrDonationAmountFraction):
+		return http.StatusBadRequest
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+func (h *Handler) GetPledges(c *gin.Context) {
+	page, limit := utils.GetPaginationParams(c)
+	offset := utils.GetOffset(page, limit)
+
+	var pledges []models.Pledge
+	var total int64
+
+	query := h.DB.Model(&models.Pledge{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if donorID := c.Query("donor_id"); donorID != "" {
+		query = query.Where("donor_id = ?", donorID)
+	}
+
+	query.Count(&total)
+	query.Preload("Donor").
+		Order("created_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&pledges)
+
+	utils.PaginatedSuccessResponse(c, pledges, page, limit, total)
+}
+
+func (h *Handler) GetPledge(c *gin.Context) {
+	var pledge models.Pledge
+	if err := h.DB.Preload("Donor").Preload("Campaign").
+		Preload("Installments", func(db *gorm.DB) *gorm.DB {
+			return db.Order("due_date DESC")
+		}).
+		Preload("Installments.Donation").
+		First(&pledge, "id = ?", c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Pledge not found")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, pledge, "")
+}
+
+func (h *Handler) CreatePledge(c *gin.Context) {
+	var req PledgeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	createdBy := userID.(uuid.UUID)
+
+	pledge := models.Pledge{Status: models.PledgeActive, CreatedBy: &createdBy}
+	if err := req.apply(&pledge); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	// Never schedule installments from before the pledge was recorded
+	pledge.ScheduleFrom = time.Now()
+	if pledge.StartDate.After(pledge.ScheduleFrom) {
+		pledge.ScheduleFrom = pledge.StartDate
+	}
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if req.DonorID != nil {
+			var donor models.Donor
+			if err := tx.First(&donor, "id = ?", req.DonorID).Error; err != nil {
+				return err
+			}
+			pledge.DonorID = donor.ID
+		} else {
+			donor, err := services.ResolveDonor(tx, req.DonorName, req.DonorEmail, req.DonorPhone)
+			if err != nil {
+				return err
+			}
+			if donor == nil {
+				return services.ErrPledgeInvalid
+			}
+			pledge.DonorID = donor.ID
+		}
+
+		if err := services.ValidatePledge(&pledge); err != nil {
+			return err
+		}
+		return tx.Create(&pledge).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Donor not found")
+			return
+		}
+		status := pledgeErrorStatus(err)
+		if status == http.StatusInternalServerError {
+			utils.ErrorResponse(c, status, "Failed to create pledge")
+			return
+		}
+		utils.ErrorResponse(c, status, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, pledge, "Pledge created successfully")
+}
+
+// UpdatePledge changes the schedule of a pledge. Installments already created
+// keep their amount and due date.
+func (h *Handler) UpdatePledge(c *gin.Context) {
+	var pledge models.Pledge
+	if err := h.DB.First(&pledge, "id = ?", c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Pledge not found")
+		return
+	}
+
+	var req PledgeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := req.apply(&pledge); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := services.ValidatePledge(&pledge); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.DB.Omit("Donor", "Campaign", "Installments").Save(&pledge).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update pledge")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, pledge, "Pledge updated successfully")
+}
+
+type PledgeStatusRequest struct {
+	Status models.PledgeStatus `json:"status" binding:"required"`
+}
+
+func (h *Handler) UpdatePledgeStatus(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid pledge ID")
+		return
+	}
+
+	var req PledgeStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	pledge, err := services.SetPledgeStatus(h.DB, id, req.Status, userID.(uuid.UUID))
+	if err != nil {
+		status := pledgeErrorStatus(err)
+		switch status {
+		case http.StatusNotFound:
+			utils.ErrorResponse(c, status, "Pledge not found")
+		case http.StatusBadRequest:
+			utils.ErrorResponse(c, status, err.Error())
+		default:
+			utils.ErrorResponse(c, status, "Failed to update pledge status")
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, pledge, "Pledge status updated successfully")
+}
+
+// RunPledgeSchedule runs the hourly pledge job immediately.
+func (h *Handler) RunPledgeSchedule(c *gin.Context) {
+	result, err := services.RunPledgeSchedule(h.DB, h.Notifier, time.Now())
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to run pledge schedule: "+err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, result, "Pledge schedule completed")
+}
+
+// GetPledgeFulfilment reports installments paid versus due between from and
+// to (default: the current year).
+func (h *Handler) GetPledgeFulfilment(c *gin.Context) {
+	now := time.Now()
+	from := time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location())
+	to := time.Date(now.Year(), 12, 31, 0, 0, 0, 0, now.Location())
+
+	if s := c.Query("from"); s != "" {
+		date, err := time.ParseInLocation("2006-01-02", s, now.Location())
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid from date. Use YYYY-MM-DD")
+			return
+		}
+		from = date
+	}
+	if s := c.Query("to"); s != "" {
+		date, err := time.ParseInLocation("2006-01-02", s, now.Location())
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid to date. Use YYYY-MM-DD")
+			return
+		}
+		to = date
+	}
+	if to.Before(from) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "to must not be before from")
+		return
+	}
+
+	report, err := services.GetPledgeFulfilment(h.DB, from, to)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to build pledge fulfilment report")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, report, "")
+}
+
+// GetDonorMyPledges lists the signed-in donor's pledges and their installments.
+func (h *Handler) GetDonorMyPledges(c *gin.Context) {
+	donorID, _ := c.Get("donorID")
+
+	var pledges []models.Pledge
+	if err := h.DB.Preload("Campaign").
+		Preload("Installments", func(db *gorm.DB) *gorm.DB {
+			return db.Order("due_date DESC")
+		}).
+		Where("donor_id = ?", donorID).
+		Order("created_at DESC").
+		Find(&pledges).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch pledges")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, pledges, "")
+}
@@ -0,0 +1,402 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+	"masjid-baiturrahim-backend/internal/services"
+	"masjid-baiturrahim-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// qurbanYear reads ?year, defaulting to the current year.
+func qurbanYear(c *gin.Context) (int, bool) {
+	year := time.Now().Year()
+	if s := c.Query("year"); s != "" {
+		parsed, err := strconv.Atoi(s)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid year")
+			return 0, false
+		}
+		year = parsed
+	}
+	return year, true
+}
+
+// Animal types
+
+// GetQurbanTypes lists the animals on offer with their share availability.
+// Visitors only see active types.
+func (h *Handler) GetQurbanTypes(c *gin.Context) {
+	year, ok := qurbanYear(c)
+	if !ok {
+		return
+	}
+
+	_, isAdmin := c.Get("userID")
+	types, err := services.GetQurbanAvailability(h.DB, year, !isAdmin)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to get qurban types")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, types, "")
+}
+
+func (h *Handler) CreateQurbanType(c *gin.Context) {
+	var animalType models.QurbanAnimalType
+	if err := c.ShouldBindJSON(&animalType); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if animalType.Year == 0 {
+		animalType.Year = time.Now().Year()
+	}
+	if animalType.SharesPerAnimal == 0 {
+		animalType.SharesPerAnimal = 1
+	}
+	if err := services.ValidateQurbanType(&animalType); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.DB.Create(&animalType).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create qurban type")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, animalType, "Qurban type created successfully")
+}
+
+func (h *Handler) UpdateQurbanType(c *gin.Context) {
+	var animalType models.QurbanAnimalType
+	if err := h.DB.First(&animalType, "id = ?", c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Qurban type not found")
+		return
+	}
+
+	// Shares already sold fix how an animal is split
+	sharesPerAnimal := animalType.SharesPerAnimal
+	if err := c.ShouldBindJSON(&animalType); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	var animals int64
+	h.DB.Model(&models.QurbanAnimal{}).Where("type_id = ?", animalType.ID).Count(&animals)
+	if animals > 0 && animalType.SharesPerAnimal != sharesPerAnimal {
+		utils.ErrorResponse(c, http.StatusConflict, "Shares per animal can't change once shares are registered")
+		return
+	}
+	if err := services.ValidateQurbanType(&animalType); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.DB.Save(&animalType).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update qurban type")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, animalType, "Qurban type updated successfully")
+}
+
+// Shohibul registration
+
+type QurbanRegistrationRequest struct {
+	TypeID          uuid.UUID  `json:"type_id" binding:"required"`
+	ShohibulName    string     `json:"shohibul_name" binding:"required"`
+	OnBehalfOf      string     `json:"on_behalf_of"`
+	Phone           *string    `json:"phone"`
+	Email           *string    `json:"email"`
+	Address         *string    `json:"address"`
+	WantsMeat       bool       `json:"wants_meat"`
+	PaymentMethodID *uuid.UUID `json:"payment_method_id"`
+	UseUniqueCode   bool       `json:"use_unique_code"`
+}
+
+// RegisterQurban reserves a share for a shohibul and returns the pending
+// donation to pay, with the access token for checking its status and
+// uploading proof of transfer.
+func (h *Handler) RegisterQurban(c *gin.Context) {
+	var req QurbanRegistrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if req.PaymentMethodID != nil {
+		var method models.PaymentMethod
+		if err := h.DB.First(&method, "id = ? AND is_active = ?", req.PaymentMethodID, true).Error; err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid payment method")
+			return
+		}
+	}
+
+	accessToken, err := utils.GenerateRandomToken(24)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to register qurban")
+		return
+	}
+
+	share := models.QurbanShare{
+		ShohibulName: req.ShohibulName,
+		OnBehalfOf:   req.OnBehalfOf,
+		Phone:        req.Phone,
+		Address:      req.Address,
+		WantsMeat:    req.WantsMeat,
+	}
+	donation := models.Donation{
+		DonorName:       req.ShohibulName,
+		DonorEmail:      req.Email,
+		DonorPhone:      req.Phone,
+		PaymentMethodID: req.PaymentMethodID,
+		AccessTokenHash: utils.HashToken(accessToken),
+	}
+
+	if err := services.RegisterQurbanShare(h.DB, req.TypeID, &share, &donation, req.UseUniqueCode); err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			utils.ErrorResponse(c, http.StatusNotFound, "Qurban type not found")
+		case errors.Is(err, services.ErrQurbanTypeInactive), errors.Is(err, services.ErrQurbanSoldOut),
+			errors.Is(err, services.ErrNoUniqueCodeAvailable):
+			utils.ErrorResponse(c, http.StatusConflict, err.Error())
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to register qurban")
+		}
+		return
+	}
+
+	donation.AccessToken = accessToken
+	utils.SuccessResponse(c, http.StatusCreated, share, "Qurban registered successfully")
+}
+
+func (h *Handler) GetQurbanShares(c *gin.Context) {
+	year, ok := qurbanYear(c)
+	if !ok {
+		return
+	}
+	page, limit := utils.GetPaginationParams(c)
+	offset := utils.GetOffset(page, limit)
+
+	var shares []models.QurbanShare
+	var total int64
+
+	query := h.DB.Model(&models.QurbanShare{}).
+		Joins("JOIN qurban_animals ON qurban_animals.id = qurban_shares.animal_id").
+		Joins("JOIN donations ON donations.id = qurban_shares.donation_id").
+		Where("qurban_animals.year = ?", year)
+	if typeID := c.Query("type_id"); typeID != "" {
+		query = query.Where("qurban_animals.type_id = ?", typeID)
+	}
+	if status := c.Query("payment_status"); status != "" {
+		query = query.Where("donations.status = ?", status)
+	}
+	if q := c.Query("q"); q != "" {
+		query = query.Where("qurban_shares.shohibul_name ILIKE ? OR qurban_shares.on_behalf_of ILIKE ?", "%"+q+"%", "%"+q+"%")
+	}
+
+	query.Count(&total)
+	query.Preload("Animal.Type").Preload("Donation").
+		Order("qurban_animals.tag_number ASC, qurban_shares.share_number ASC").
+		Offset(offset).
+		Limit(limit).
+		Find(&shares)
+
+	utils.PaginatedSuccessResponse(c, shares, page, limit, total)
+}
+
+// Animals
+
+func (h *Handler) GetQurbanAnimals(c *gin.Context) {
+	year, ok := qurbanYear(c)
+	if !ok {
+		return
+	}
+
+	var animals []models.QurbanAnimal
+	query := h.DB.Where("year = ?", year)
+	if typeID := c.Query("type_id"); typeID != "" {
+		query = query.Where("type_id = ?", typeID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	query.Preload("Type").
+		Preload("Shares", func(db *gorm.DB) *gorm.DB {
+			return db.Order("share_number ASC")
+		}).
+		Preload("Shares.Donation").
+		Order("tag_number ASC").
+		Find(&animals)
+
+	utils.SuccessResponse(c, http.StatusOK, animals, "")
+}
+
+func (h *Handler) UpdateQurbanAnimal(c *gin.Context) {
+	var animal models.QurbanAnimal
+	if err := h.DB.First(&animal, "id = ?", c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Animal not found")
+		return
+	}
+
+	var req struct {
+		TagNumber *string  `json:"tag_number"`
+		WeightKg  *float64 `json:"weight_kg"`
+		Notes     *string  `json:"notes"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.TagNumber != nil && strings.TrimSpace(*req.TagNumber) != "" {
+		updates["tag_number"] = strings.TrimSpace(*req.TagNumber)
+	}
+	if req.WeightKg != nil {
+		updates["weight_kg"] = *req.WeightKg
+	}
+	if req.Notes != nil {
+		updates["notes"] = *req.Notes
+	}
+
+	if err := h.DB.Model(&animal).Updates(updates).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusConflict, "Tag number is already used this year")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, animal, "Animal updated successfully")
+}
+
+func (h *Handler) UpdateQurbanAnimalStatus(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid animal ID")
+		return
+	}
+
+	var req struct {
+		Status models.QurbanAnimalStatus `json:"status" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	animal, err := services.SetQurbanAnimalStatus(h.DB, id, req.Status)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			utils.ErrorResponse(c, http.StatusNotFound, "Animal not found")
+		case errors.Is(err, services.ErrQurbanStatus):
+			utils.ErrorResponse(c, http.StatusConflict, err.Error())
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update animal status")
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, animal, "Animal status updated")
+}
+
+// Meat coupons
+
+func (h *Handler) GetQurbanCoupons(c *gin.Context) {
+	year, ok := qurbanYear(c)
+	if !ok {
+		return
+	}
+	page, limit := utils.GetPaginationParams(c)
+	offset := utils.GetOffset(page, limit)
+
+	var coupons []models.QurbanCoupon
+	var total int64
+
+	query := h.DB.Model(&models.QurbanCoupon{}).Where("year = ?", year)
+	switch c.Query("redeemed") {
+	case "true":
+		query = query.Where("redeemed_at IS NOT NULL")
+	case "false":
+		query = query.Where("redeemed_at IS NULL")
+	}
+
+	query.Count(&total)
+	query.Order("created_at ASC, code ASC").
+		Offset(offset).
+		Limit(limit).
+		Find(&coupons)
+
+	utils.PaginatedSuccessResponse(c, coupons, page, limit, total)
+}
+
+func (h *Handler) GenerateQurbanCoupons(c *gin.Context) {
+	var req struct {
+		Year       int      `json:"year"`
+		Count      int      `json:"count"`
+		Recipients []string `json:"recipients"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Year == 0 {
+		req.Year = time.Now().Year()
+	}
+
+	coupons, err := services.GenerateQurbanCoupons(h.DB, req.Year, req.Count, req.Recipients)
+	if err != nil {
+		if errors.Is(err, services.ErrCouponCount) {
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate coupons")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, coupons, "Coupons generated successfully")
+}
+
+func (h *Handler) RedeemQurbanCoupon(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	code := strings.ToUpper(strings.TrimSpace(c.Param("code")))
+
+	coupon, err := services.RedeemQurbanCoupon(h.DB, code, userID.(uuid.UUID))
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			utils.ErrorResponse(c, http.StatusNotFound, "Coupon not found")
+		case errors.Is(err, services.ErrCouponRedeemed):
+			utils.ErrorResponse(c, http.StatusConflict, "Coupon was already redeemed at "+coupon.RedeemedAt.Format("15:04"))
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to redeem coupon")
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, coupon, "Coupon redeemed")
+}
+
+// GetQurbanCouponQR serves the QR code printed on a coupon.
+func (h *Handler) GetQurbanCouponQR(c *gin.Context) {
+	var coupon models.QurbanCoupon
+	if err := h.DB.First(&coupon, "code = ?", c.Param("code")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Coupon not found")
+		return
+	}
+
+	png, err := services.RenderCouponQR(coupon.Code)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to render QR code")
+		return
+	}
+
+	c.Data(http.StatusOK, "image/png", png)
+}
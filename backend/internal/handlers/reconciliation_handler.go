@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"masjid-baiturrahim-backend/internal/models"
+	"masjid-baiturrahim-backend/internal/services"
+	"masjid-baiturrahim-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const MaxStatementSize = 10 * 1024 * 1024 // 10MB
+
+type BankMutationWithCandidates struct {
+	models.BankMutation
+	Candidates []models.Donation `json:"candidates,omitempty"`
+}
+
+func (h *Handler) ImportBankStatement(c *gin.Context) {
+	bank := models.BankCode(c.PostForm("bank"))
+	if !services.IsSupportedBank(bank) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Unsupported bank. Use bca, bsi or mandiri")
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "No file provided")
+		return
+	}
+	if file.Size > MaxStatementSize {
+		utils.ErrorResponse(c, http.StatusBadRequest, "File size exceeds 10MB limit")
+		return
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to read file")
+		return
+	}
+	defer f.Close()
+
+	userID, _ := c.Get("userID")
+	imp, err := services.ImportBankStatement(h.DB, bank, file.Filename, f, userID.(uuid.UUID))
+	if err != nil {
+		if errors.Is(err, services.ErrStatementHeader) {
+			utils.ErrorResponse(c, http.StatusBadRequest, "File does not look like a "+string(bank)+" statement")
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to import bank statement")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, imp, "Bank statement imported successfully")
+}
+
+func (h *Handler) GetBankStatementImports(c *gin.Context) {
+	page, limit := utils.GetPaginationParams(c)
+	offset := utils.GetOffset(page, limit)
+
+	var imports []models.BankStatementImport
+	var total int64
+
+	query := h.DB.Model(&models.BankStatementImport{})
+	if bank := c.Query("bank"); bank != "" {
+		query = query.Where("bank = ?", bank)
+	}
+
+	query.Count(&total)
+	query.Preload("Importer").
+		Order("created_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&imports)
+
+	utils.PaginatedSuccessResponse(c, imports, page, limit, total)
+}
+
+func (h *Handler) GetBankMutations(c *gin.Context) {
+	page, limit := utils.GetPaginationParams(c)
+	offset := utils.GetOffset(page, limit)
+
+	var mutations []models.BankMutation
+	var total int64
+
+	query := h.DB.Model(&models.BankMutation{})
+
+	// Filters
+	if status := c.Query("status"); status != "" {
+		query = query.Where("match_status = ?", status)
+	}
+	if source := c.Query("source"); source != "" {
+		query = query.Where("source = ?", source)
+	}
+	if importID := c.Query("import_id"); importID != "" {
+		query = query.Where("import_id = ?", importID)
+	}
+	if mutationType := c.Query("type"); mutationType != "" {
+		query = query.Where("type = ?", mutationType)
+	}
+
+	query.Count(&total)
+	query.Preload("Donation").
+		Order("transaction_date DESC, created_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&mutations)
+
+	utils.PaginatedSuccessResponse(c, h.withCandidates(mutations), page, limit, total)
+}
+
+// GetReconciliationQueue lists credits that need a decision: proposed matches
+// awaiting confirmation and ambiguous ones with all of their candidates.
+func (h *Handler) GetReconciliationQueue(c *gin.Context) {
+	var mutations []models.BankMutation
+	h.DB.Preload("Donation").
+		Where("match_status IN ?", []models.MutationMatchStatus{models.MutationAmbiguous, models.MutationMatched}).
+		Order("match_status ASC, transaction_date ASC").
+		Find(&mutations)
+
+	utils.SuccessResponse(c, http.StatusOK, h.withCandidates(mutations), "")
+}
+
+func (h *Handler) withCandidates(mutations []models.BankMutation) []BankMutationWithCandidates {
+	result := make([]BankMutationWithCandidates, len(mutations))
+	for i, m := range mutations {
+		result[i] = BankMutationWithCandidates{BankMutation: m}
+		if len(m.CandidateIDs) > 0 {
+			h.DB.Where("id IN ?", []uuid.UUID(m.CandidateIDs)).
+				Order("created_at ASC").
+				Find(&result[i].Candidates)
+		}
+	}
+	return result
+}
+
+func (h *Handler) ConfirmBankMutation(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid mutation ID")
+		return
+	}
+
+	var req struct {
+		DonationID *uuid.UUID `json:"donation_id"`
+		// Required when the credit isn't exactly the donation's transfer amount
+		AmountOverrideReason string `json:"amount_override_reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	mutation, approval, err := services.ConfirmMutation(h.DB, id, req.DonationID, req.AmountOverrideReason, userID.(uuid.UUID))
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			utils.ErrorResponse(c, http.StatusNotFound, "Mutation not found")
+		case errors.Is(err, services.ErrMutationReviewed), errors.Is(err, services.ErrInvalidTransition),
+			errors.Is(err, services.ErrApprovalPending):
+			utils.ErrorResponse(c, http.StatusConflict, err.Error())
+		case errors.Is(err, services.ErrMutationNotCredit), errors.Is(err, services.ErrDonationNotCandidate),
+			errors.Is(err, services.ErrMutationNeedsDonation), errors.Is(err, services.ErrMutationAmountDiffers),
+			errors.Is(err, services.ErrNoLedgerAccount),
+			errors.Is(err, services.ErrNoLedgerCategory):
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to confirm mutation")
+		}
+		return
+	}
+
+	// Large donations wait for a treasurer other than the one confirming
+	if approval != nil {
+		h.approvalRequested(c, approval)
+		return
+	}
+
+	h.DB.Preload("Donation").First(mutation, "id = ?", mutation.ID)
+	utils.SuccessResponse(c, http.StatusOK, mutation, "Donation confirmed from bank mutation")
+}
+
+func (h *Handler) IgnoreBankMutation(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid mutation ID")
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	mutation, err := services.IgnoreMutation(h.DB, id, userID.(uuid.UUID))
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			utils.ErrorResponse(c, http.StatusNotFound, "Mutation not found")
+		case errors.Is(err, services.ErrMutationReviewed):
+			utils.ErrorResponse(c, http.StatusConflict, err.Error())
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to ignore mutation")
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, mutation, "Mutation ignored")
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+	"masjid-baiturrahim-backend/internal/services"
+	"masjid-baiturrahim-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// weeklyReport resolves the ?date query (any day; the report is for the Friday
+// on or before it, defaulting to the latest Friday) and builds the report.
+func (h *Handler) weeklyReport(c *gin.Context) (*services.WeeklyReport, bool) {
+	date := time.Now()
+	if s := c.Query("date"); s != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", s, time.Local)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid date. Use YYYY-MM-DD")
+			return nil, false
+		}
+		date = parsed
+	}
+
+	report, err := services.GetWeeklyReport(h.DB, services.ReportFriday(date))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to build weekly report")
+		return nil, false
+	}
+	return report, true
+}
+
+func (h *Handler) GetWeeklyReport(c *gin.Context) {
+	report, ok := h.weeklyReport(c)
+	if !ok {
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, report, "")
+}
+
+// GetWeeklyReportPDF serves the weekly report as a printable PDF.
+func (h *Handler) GetWeeklyReportPDF(c *gin.Context) {
+	report, ok := h.weeklyReport(c)
+	if !ok {
+		return
+	}
+
+	var mosque models.MosqueInfo
+	h.DB.First(&mosque)
+
+	pdf, err := services.RenderWeeklyReportPDF(report, mosque.Name)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to render weekly report")
+		return
+	}
+
+	c.Header("Content-Disposition", "inline; filename=laporan-kas-"+report.ReportDate+".pdf")
+	c.Data(http.StatusOK, "application/pdf", pdf)
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"net/http"
 	"masjid-baiturrahim-backend/internal/models"
+	"masjid-baiturrahim-backend/internal/services"
 	"masjid-baiturrahim-backend/internal/utils"
 
 	"github.com/gin-gonic/gin"
@@ -23,6 +24,10 @@ func (h *Handler) GetSettings(c *gin.Context) {
 
 func (h *Handler) UpdateSetting(c *gin.Context) {
 	key := c.Param("key")
+	if services.ProtectedSetting(key) {
+		utils.ErrorResponse(c, http.StatusForbidden, services.ErrSettingProtected.Error())
+		return
+	}
 	var setting models.Setting
 
 	if err := h.DB.Where("key = ?", key).First(&setting).Error; err != nil {
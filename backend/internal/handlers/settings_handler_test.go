@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"masjid-baiturrahim-backend/internal/models"
+	"masjid-baiturrahim-backend/internal/services"
+	"masjid-baiturrahim-backend/internal/testutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestUpdateSettingProtected(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := &Handler{DB: testutil.NewDB(t)}
+	router := gin.New()
+	router.PUT("/settings/:key", h.UpdateSetting)
+
+	put := func(key, value string) int {
+		req := httptest.NewRequest(http.MethodPut, "/settings/"+key, strings.NewReader(`{"value":"`+value+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := put(services.SettingApprovalThreshold, "0"); code != http.StatusForbidden {
+		t.Errorf("approval threshold: status = %d, want 403", code)
+	}
+	var count int64
+	h.DB.Model(&models.Setting{}).Where("key = ?", services.SettingApprovalThreshold).Count(&count)
+	if count != 0 {
+		t.Error("approval threshold was stored through the settings endpoint")
+	}
+
+	if code := put("site_name", "Masjid Baiturrahim"); code != http.StatusOK {
+		t.Errorf("other setting: status = %d, want 200", code)
+	}
+}
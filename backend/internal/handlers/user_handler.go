@@ -1,8 +1,12 @@
 package handlers
 
 import (
+	"errors"
+	"io"
 	"net/http"
+	"masjid-baiturrahim-backend/config"
 	"masjid-baiturrahim-backend/internal/models"
+	"masjid-baiturrahim-backend/internal/services"
 	"masjid-baiturrahim-backend/internal/utils"
 
 	"github.com/gin-gonic/gin"
@@ -48,6 +52,10 @@ func (h *Handler) CreateUser(c *gin.Context) {
 		return
 	}
 
+	if !h.canAssignRole(c, req.Role) {
+		return
+	}
+
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), 12)
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to hash password")
@@ -94,6 +102,14 @@ func (h *Handler) UpdateUser(c *gin.Context) {
 		return
 	}
 
+	// Only super admins may touch a super admin account or hand out the role
+	if user.Role == models.RoleSuperAdmin && !h.canAssignRole(c, user.Role) {
+		return
+	}
+	if req.Role != "" && !h.canAssignRole(c, req.Role) {
+		return
+	}
+
 	if req.Username != "" {
 		user.Username = req.Username
 	}
@@ -123,6 +139,15 @@ func (h *Handler) UpdateUser(c *gin.Context) {
 
 func (h *Handler) DeleteUser(c *gin.Context) {
 	id := c.Param("id")
+	var user models.User
+	if err := h.DB.First(&user, "id = ?", id).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+		return
+	}
+	if !h.canAssignRole(c, user.Role) {
+		return
+	}
+
 	if err := h.DB.Delete(&models.User{}, "id = ?", id).Error; err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete user")
 		return
@@ -131,3 +156,50 @@ func (h *Handler) DeleteUser(c *gin.Context) {
 	utils.SuccessResponse(c, http.StatusOK, nil, "User deleted successfully")
 }
 
+// ResetUserPassword lets an admin take back an account: with a temporary
+// password the user must pick a new one after logging in, without one they
+// get a reset link by email. Either way all their sessions end.
+func (h *Handler) ResetUserPassword(c *gin.Context) {
+	var user models.User
+	if err := h.DB.First(&user, "id = ?", c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+		return
+	}
+	if !h.canAssignRole(c, user.Role) {
+		return
+	}
+
+	var req struct {
+		TemporaryPassword string `json:"temporary_password"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := services.ForcePasswordReset(h.DB, h.Mailer, user.ID, req.TemporaryPassword, config.Load().FrontendURL); err != nil {
+		passwordError(c, err)
+		return
+	}
+
+	message := "Password reset link has been sent to " + user.Email
+	if req.TemporaryPassword != "" {
+		message = "Temporary password set; the user must change it after logging in"
+	}
+	utils.SuccessResponse(c, http.StatusOK, nil, message)
+}
+
+// canAssignRole checks that role exists and that the caller may give it to
+// someone: the super admin role is only handed out by super admins.
+func (h *Handler) canAssignRole(c *gin.Context, role models.UserRole) bool {
+	if !models.IsValidRole(role) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Unknown role: "+string(role))
+		return false
+	}
+	callerRole, _ := c.Get("userRole")
+	if role == models.RoleSuperAdmin && models.UserRole(callerRole.(string)) != models.RoleSuperAdmin {
+		utils.ErrorResponse(c, http.StatusForbidden, "Only a super admin can manage super admin accounts")
+		return false
+	}
+	return true
+}
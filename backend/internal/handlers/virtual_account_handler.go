@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"masjid-baiturrahim-backend/internal/services"
+	"masjid-baiturrahim-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const MaxPaymentNotificationSize = 64 * 1024 // 64KB
+
+// ReceiveVirtualAccountPayment handles the provider's notification that a
+// donation's virtual account was paid and confirms the donation.
+func (h *Handler) ReceiveVirtualAccountPayment(c *gin.Context) {
+	if h.VirtualAccounts == nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Virtual accounts are not enabled")
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, MaxPaymentNotificationSize+1))
+	if err != nil || len(body) > MaxPaymentNotificationSize {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to read request body")
+		return
+	}
+
+	payment, err := h.VirtualAccounts.ParsePaymentNotification(c.Request.Header, body)
+	if err != nil {
+		if errors.Is(err, services.ErrVirtualAccountSignature) {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid signature")
+			return
+		}
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	donation, err := services.ConfirmVirtualAccountPayment(h.DB, payment)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrVirtualAccountExpired):
+			// Stored for review, so the provider can stop retrying
+			log.Printf("Virtual account payment %s to %s for %s queued for review: %v", payment.PaymentID, payment.Number, donation.DonationCode, err)
+			utils.SuccessResponse(c, http.StatusAccepted, gin.H{
+				"donation_code": donation.DonationCode,
+				"status":        donation.Status,
+			}, "Payment received after the virtual account expired, queued for review")
+		case errors.Is(err, services.ErrVirtualAccountUnknown):
+			utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+		case errors.Is(err, services.ErrVirtualAccountNotPending), errors.Is(err, services.ErrVirtualAccountAmount):
+			// Money arrived for a donation we can't confirm; it has to be
+			// sorted out by hand
+			log.Printf("Virtual account payment %s to %s not applied: %v", payment.PaymentID, payment.Number, err)
+			utils.ErrorResponse(c, http.StatusConflict, err.Error())
+		case errors.Is(err, services.ErrNoLedgerAccount), errors.Is(err, services.ErrNoLedgerCategory):
+			utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to confirm donation")
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{
+		"donation_code": donation.DonationCode,
+		"status":        donation.Status,
+	}, "Payment received")
+}
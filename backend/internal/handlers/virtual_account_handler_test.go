@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+	"masjid-baiturrahim-backend/internal/services"
+	"masjid-baiturrahim-backend/internal/testutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newVirtualAccountRouter(t *testing.T, provider services.FakeVirtualAccountProvider) (*gin.Engine, *Handler) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	h := &Handler{
+		DB:              testutil.NewDB(t),
+		VirtualAccounts: provider,
+		VAExpiry:        time.Hour,
+	}
+	router := gin.New()
+	router.POST("/donations", h.CreateDonation)
+	router.POST("/payments/va/notify", h.ReceiveVirtualAccountPayment)
+	return router, h
+}
+
+// issueVirtualAccount creates a donation paid through a virtual account and
+// returns it as the donor sees it.
+func issueVirtualAccount(t *testing.T, router http.Handler, bank string, amount int64) (int, models.Donation) {
+	t.Helper()
+	body, _ := json.Marshal(map[string]interface{}{
+		"donor_name":          "Hamba Allah",
+		"amount":              amount,
+		"category":            models.DonationCategoryInfaq,
+		"use_virtual_account": true,
+		"va_bank":             bank,
+	})
+	req := httptest.NewRequest(http.MethodPost, "/donations", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var resp struct {
+		Data models.Donation `json:"data"`
+	}
+	if w.Code == http.StatusCreated {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode donation: %v (%s)", err, w.Body.String())
+		}
+	}
+	return w.Code, resp.Data
+}
+
+func notifyVirtualAccount(t *testing.T, router http.Handler, notice map[string]interface{}, signature string) *httptest.ResponseRecorder {
+	t.Helper()
+	body, _ := json.Marshal(notice)
+	req := httptest.NewRequest(http.MethodPost, "/payments/va/notify", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if signature != "" {
+		req.Header.Set("X-Callback-Signature", signature)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func signedNotice(p services.FakeVirtualAccountProvider, notice map[string]interface{}) string {
+	body, _ := json.Marshal(notice)
+	return p.SignNotification(body)
+}
+
+func TestVirtualAccountIssuance(t *testing.T) {
+	router, _ := newVirtualAccountRouter(t, services.FakeVirtualAccountProvider{Secret: "va-secret"})
+
+	tests := []struct {
+		bank   string
+		prefix string
+	}{
+		{"", services.FakeVirtualAccountPrefixes[string(models.BankBSI)]},
+		{"bca", services.FakeVirtualAccountPrefixes[string(models.BankBCA)]},
+		{"MANDIRI", services.FakeVirtualAccountPrefixes[string(models.BankMandiri)]},
+	}
+	for _, tt := range tests {
+		code, donation := issueVirtualAccount(t, router, tt.bank, 100000)
+		if code != http.StatusCreated {
+			t.Errorf("bank %q: status = %d, want 201", tt.bank, code)
+			continue
+		}
+		if donation.VANumber == nil || !strings.HasPrefix(*donation.VANumber, tt.prefix) || len(*donation.VANumber) != 16 {
+			t.Errorf("bank %q: va_number = %v, want 16 digits starting with %s", tt.bank, donation.VANumber, tt.prefix)
+		}
+		if donation.VAExpiresAt == nil || time.Until(*donation.VAExpiresAt) <= 50*time.Minute || time.Until(*donation.VAExpiresAt) > time.Hour {
+			t.Errorf("bank %q: va_expires_at = %v, want an hour from now", tt.bank, donation.VAExpiresAt)
+		}
+		if donation.Status != models.DonationStatusPending {
+			t.Errorf("bank %q: status = %s, want pending", tt.bank, donation.Status)
+		}
+	}
+
+	if code, _ := issueVirtualAccount(t, router, "bri", 100000); code != http.StatusBadRequest {
+		t.Errorf("unsupported bank: status = %d, want 400", code)
+	}
+
+	disabled := gin.New()
+	disabled.POST("/donations", (&Handler{DB: testutil.NewDB(t)}).CreateDonation)
+	if code, _ := issueVirtualAccount(t, disabled, "bsi", 100000); code != http.StatusBadRequest {
+		t.Errorf("without a provider: status = %d, want 400", code)
+	}
+}
+
+func TestVirtualAccountNotify(t *testing.T) {
+	provider := services.FakeVirtualAccountProvider{Secret: "va-secret"}
+	router, h := newVirtualAccountRouter(t, provider)
+
+	status := func(id interface{}) models.DonationStatus {
+		var d models.Donation
+		if err := h.DB.First(&d, "id = ?", id).Error; err != nil {
+			t.Fatal(err)
+		}
+		return d.Status
+	}
+
+	t.Run("signature", func(t *testing.T) {
+		_, donation := issueVirtualAccount(t, router, "bsi", 100000)
+		notice := map[string]interface{}{"va_number": *donation.VANumber, "amount": "100000", "payment_id": "p-sig"}
+
+		for name, sig := range map[string]string{
+			"missing":   "",
+			"wrong key": signedNotice(services.FakeVirtualAccountProvider{Secret: "other"}, notice),
+			"no key":    signedNotice(services.FakeVirtualAccountProvider{}, notice),
+		} {
+			if w := notifyVirtualAccount(t, router, notice, sig); w.Code != http.StatusUnauthorized {
+				t.Errorf("%s: status = %d, want 401 (%s)", name, w.Code, w.Body.String())
+			}
+		}
+		if got := status(donation.ID); got != models.DonationStatusPending {
+			t.Errorf("donation is %s after unsigned notifications, want pending", got)
+		}
+
+		// Without a secret nothing can be verified, so nothing is accepted
+		unsigned := gin.New()
+		unsigned.POST("/payments/va/notify", (&Handler{DB: h.DB, VirtualAccounts: services.FakeVirtualAccountProvider{}}).ReceiveVirtualAccountPayment)
+		if w := notifyVirtualAccount(t, unsigned, notice, signedNotice(services.FakeVirtualAccountProvider{}, notice)); w.Code != http.StatusUnauthorized {
+			t.Errorf("provider without a secret: status = %d, want 401 (%s)", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("paid", func(t *testing.T) {
+		_, donation := issueVirtualAccount(t, router, "bsi", 100000)
+		notice := map[string]interface{}{"va_number": *donation.VANumber, "amount": "100000", "payment_id": "p-paid"}
+		sig := signedNotice(provider, notice)
+
+		// The provider repeats the notification until it is acknowledged
+		for i := 0; i < 2; i++ {
+			w := notifyVirtualAccount(t, router, notice, sig)
+			if w.Code != http.StatusOK {
+				t.Fatalf("attempt %d: status = %d, want 200 (%s)", i+1, w.Code, w.Body.String())
+			}
+		}
+		if got := status(donation.ID); got != models.DonationStatusConfirmed {
+			t.Errorf("donation is %s, want confirmed", got)
+		}
+		var entries int64
+		h.DB.Model(&models.LedgerEntry{}).Where("donation_id = ?", donation.ID).Count(&entries)
+		if entries != 1 {
+			t.Errorf("%d ledger entries for the donation, want 1", entries)
+		}
+	})
+
+	t.Run("wrong amount", func(t *testing.T) {
+		_, donation := issueVirtualAccount(t, router, "bsi", 100000)
+		notice := map[string]interface{}{"va_number": *donation.VANumber, "amount": "90000", "payment_id": "p-short"}
+		if w := notifyVirtualAccount(t, router, notice, signedNotice(provider, notice)); w.Code != http.StatusConflict {
+			t.Errorf("status = %d, want 409 (%s)", w.Code, w.Body.String())
+		}
+		if got := status(donation.ID); got != models.DonationStatusPending {
+			t.Errorf("donation is %s, want pending", got)
+		}
+	})
+
+	t.Run("unknown account", func(t *testing.T) {
+		notice := map[string]interface{}{"va_number": "7001399999999999", "amount": "100000"}
+		if w := notifyVirtualAccount(t, router, notice, signedNotice(provider, notice)); w.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want 404 (%s)", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("paid after expiry", func(t *testing.T) {
+		_, donation := issueVirtualAccount(t, router, "bsi", 100000)
+		notice := map[string]interface{}{
+			"va_number":  *donation.VANumber,
+			"amount":     "100000",
+			"payment_id": "p-late",
+			"paid_at":    donation.VAExpiresAt.Add(time.Minute),
+		}
+		sig := signedNotice(provider, notice)
+
+		for i := 0; i < 2; i++ {
+			w := notifyVirtualAccount(t, router, notice, sig)
+			if w.Code != http.StatusAccepted {
+				t.Fatalf("attempt %d: status = %d, want 202 (%s)", i+1, w.Code, w.Body.String())
+			}
+		}
+		if got := status(donation.ID); got != models.DonationStatusPending {
+			t.Errorf("donation is %s, want it left pending", got)
+		}
+
+		var queued []models.BankMutation
+		h.DB.Where("source = ?", models.MutationSourceVirtualAccount).Find(&queued)
+		if len(queued) != 1 {
+			t.Fatalf("%d mutations queued, want 1", len(queued))
+		}
+		if m := queued[0]; m.MatchStatus != models.MutationUnmatched || m.Amount != models.NewMoney(100000) ||
+			m.ExternalID != "p-late" || !strings.Contains(m.Description, donation.DonationCode) {
+			t.Errorf("queued mutation = %s %s %q %q", m.MatchStatus, m.Amount.Format(), m.ExternalID, m.Description)
+		}
+	})
+}
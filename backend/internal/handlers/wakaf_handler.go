@@ -0,0 +1,548 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+	"masjid-baiturrahim-backend/internal/services"
+	"masjid-baiturrahim-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const MaxWakafDocumentSize = 10 * 1024 * 1024 // 10MB, certificates are often multi-page scans
+
+// Nazhir
+
+func (h *Handler) GetNazhirs(c *gin.Context) {
+	var nazhirs []models.Nazhir
+	query := h.DB.Order("name ASC")
+	if c.Query("active") == "true" {
+		query = query.Where("is_active = ?", true)
+	}
+	query.Find(&nazhirs)
+
+	utils.SuccessResponse(c, http.StatusOK, nazhirs, "")
+}
+
+func (h *Handler) CreateNazhir(c *gin.Context) {
+	var nazhir models.Nazhir
+	if err := c.ShouldBindJSON(&nazhir); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if nazhir.Name == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Name is required")
+		return
+	}
+
+	if err := h.DB.Create(&nazhir).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create nazhir")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, nazhir, "Nazhir created successfully")
+}
+
+func (h *Handler) UpdateNazhir(c *gin.Context) {
+	var nazhir models.Nazhir
+	if err := h.DB.First(&nazhir, "id = ?", c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Nazhir not found")
+		return
+	}
+
+	id := nazhir.ID
+	if err := c.ShouldBindJSON(&nazhir); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	nazhir.ID = id
+	if nazhir.Name == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Name is required")
+		return
+	}
+
+	if err := h.DB.Save(&nazhir).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update nazhir")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, nazhir, "Nazhir updated successfully")
+}
+
+func (h *Handler) DeleteNazhir(c *gin.Context) {
+	var count int64
+	h.DB.Model(&models.WakafAsset{}).Where("nazhir_id = ?", c.Param("id")).Count(&count)
+	if count > 0 {
+		utils.ErrorResponse(c, http.StatusConflict, "Nazhir still manages wakaf assets, reassign them or deactivate the nazhir instead")
+		return
+	}
+
+	result := h.DB.Delete(&models.Nazhir{}, "id = ?", c.Param("id"))
+	if result.Error != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete nazhir")
+		return
+	}
+	if result.RowsAffected == 0 {
+		utils.ErrorResponse(c, http.StatusNotFound, "Nazhir not found")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, nil, "Nazhir deleted successfully")
+}
+
+// Wakaf assets
+
+type WakafAssetRequest struct {
+	Name              string                  `json:"name" binding:"required"`
+	Type              models.WakafAssetType   `json:"type" binding:"required"`
+	Description       string                  `json:"description"`
+	Location          string                  `json:"location"`
+	AreaM2            *float64                `json:"area_m2"`
+	Quantity          int                     `json:"quantity"`
+	WakifName         string                  `json:"wakif_name"`
+	WakifDonorID      *uuid.UUID              `json:"wakif_donor_id"`
+	WakifAddress      string                  `json:"wakif_address"`
+	PledgedAt         *string                 `json:"pledged_at"` // YYYY-MM-DD
+	Purpose           string                  `json:"purpose"`
+	LegalStatus       models.WakafLegalStatus `json:"legal_status"`
+	AIWNumber         *string                 `json:"aiw_number"`
+	CertificateNumber *string                 `json:"certificate_number"`
+	NazhirID          *uuid.UUID              `json:"nazhir_id"`
+	Valuation         models.Money            `json:"valuation"`
+	ValuationDate     *string                 `json:"valuation_date"` // YYYY-MM-DD
+	TargetAmount      models.Money            `json:"target_amount"`
+	IsProductive      bool                    `json:"is_productive"`
+	Status            models.WakafAssetStatus `json:"status"`
+	HideFromPublic    bool                    `json:"hide_from_public"`
+}
+
+func parseOptionalDate(value *string, field string) (*time.Time, error) {
+	if value == nil || *value == "" {
+		return nil, nil
+	}
+	date, err := time.Parse("2006-01-02", *value)
+	if err != nil {
+		return nil, errors.New("invalid " + field + ". Use YYYY-MM-DD")
+	}
+	return &date, nil
+}
+
+func (r WakafAssetRequest) apply(asset *models.WakafAsset) error {
+	pledgedAt, err := parseOptionalDate(r.PledgedAt, "pledged_at")
+	if err != nil {
+		return err
+	}
+	valuationDate, err := parseOptionalDate(r.ValuationDate, "valuation_date")
+	if err != nil {
+		return err
+	}
+
+	asset.Name = r.Name
+	asset.Type = r.Type
+	asset.Description = r.Description
+	asset.Location = r.Location
+	asset.AreaM2 = r.AreaM2
+	asset.Quantity = r.Quantity
+	if asset.Quantity == 0 {
+		asset.Quantity = 1
+	}
+	asset.WakifName = r.WakifName
+	asset.WakifDonorID = r.WakifDonorID
+	asset.WakifAddress = r.WakifAddress
+	asset.PledgedAt = pledgedAt
+	asset.Purpose = r.Purpose
+	asset.LegalStatus = r.LegalStatus
+	if asset.LegalStatus == "" {
+		asset.LegalStatus = models.WakafPledged
+	}
+	asset.AIWNumber = r.AIWNumber
+	asset.CertificateNumber = r.CertificateNumber
+	asset.NazhirID = r.NazhirID
+	asset.Valuation = r.Valuation
+	asset.ValuationDate = valuationDate
+	asset.TargetAmount = r.TargetAmount
+	asset.IsProductive = r.IsProductive
+	asset.Status = r.Status
+	if asset.Status == "" {
+		asset.Status = models.WakafActive
+	}
+	asset.HideFromPublic = r.HideFromPublic
+	return nil
+}
+
+func (h *Handler) bindWakafAsset(c *gin.Context, asset *models.WakafAsset) bool {
+	var req WakafAssetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return false
+	}
+	if err := req.apply(asset); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return false
+	}
+	if err := services.ValidateWakafAsset(asset); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return false
+	}
+	if asset.NazhirID != nil {
+		if err := h.DB.First(&models.Nazhir{}, "id = ?", asset.NazhirID).Error; err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Nazhir not found")
+			return false
+		}
+	}
+	if asset.WakifDonorID != nil {
+		if err := h.DB.First(&models.Donor{}, "id = ?", asset.WakifDonorID).Error; err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Wakif donor not found")
+			return false
+		}
+	}
+	return true
+}
+
+type WakafAssetWithFunding struct {
+	models.WakafAsset
+	Funding services.WakafFunding `json:"funding"`
+}
+
+func (h *Handler) GetWakafAssets(c *gin.Context) {
+	page, limit := utils.GetPaginationParams(c)
+	offset := utils.GetOffset(page, limit)
+
+	var assets []models.WakafAsset
+	var total int64
+
+	query := h.DB.Model(&models.WakafAsset{})
+	if assetType := c.Query("type"); assetType != "" {
+		query = query.Where("type = ?", assetType)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if legalStatus := c.Query("legal_status"); legalStatus != "" {
+		query = query.Where("legal_status = ?", legalStatus)
+	}
+	if q := c.Query("q"); q != "" {
+		like := "%" + q + "%"
+		query = query.Where("name ILIKE ? OR wakif_name ILIKE ? OR aiw_number = ? OR certificate_number = ?", like, like, q, q)
+	}
+
+	query.Count(&total)
+	query.Preload("Nazhir").
+		Order("name ASC").
+		Offset(offset).
+		Limit(limit).
+		Find(&assets)
+
+	ids := make([]uuid.UUID, len(assets))
+	for i, a := range assets {
+		ids[i] = a.ID
+	}
+	funding, err := services.GetWakafFunding(h.DB, ids)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to get wakaf funding")
+		return
+	}
+
+	result := make([]WakafAssetWithFunding, len(assets))
+	for i, a := range assets {
+		result[i] = WakafAssetWithFunding{WakafAsset: a, Funding: funding[a.ID]}
+	}
+
+	utils.PaginatedSuccessResponse(c, result, page, limit, total)
+}
+
+func (h *Handler) GetWakafAsset(c *gin.Context) {
+	var asset models.WakafAsset
+	if err := h.DB.Preload("Nazhir").Preload("WakifDonor").First(&asset, "id = ?", c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Wakaf asset not found")
+		return
+	}
+
+	funding, err := services.GetWakafFunding(h.DB, []uuid.UUID{asset.ID})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to get wakaf funding")
+		return
+	}
+
+	var donations []models.Donation
+	h.DB.Where("wakaf_asset_id = ?", asset.ID).Order("created_at DESC").Find(&donations)
+
+	var yields []models.WakafYield
+	h.DB.Where("asset_id = ?", asset.ID).Order("received_at DESC").Find(&yields)
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{
+		"asset":     WakafAssetWithFunding{WakafAsset: asset, Funding: funding[asset.ID]},
+		"donations": donations,
+		"yields":    yields,
+	}, "")
+}
+
+func (h *Handler) CreateWakafAsset(c *gin.Context) {
+	var asset models.WakafAsset
+	if !h.bindWakafAsset(c, &asset) {
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	createdBy := userID.(uuid.UUID)
+	asset.CreatedBy = &createdBy
+
+	if err := h.DB.Create(&asset).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create wakaf asset")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, asset, "Wakaf asset created successfully")
+}
+
+func (h *Handler) UpdateWakafAsset(c *gin.Context) {
+	var asset models.WakafAsset
+	if err := h.DB.First(&asset, "id = ?", c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Wakaf asset not found")
+		return
+	}
+	if !h.bindWakafAsset(c, &asset) {
+		return
+	}
+
+	if err := h.DB.Omit("Nazhir", "WakifDonor").Save(&asset).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update wakaf asset")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, asset, "Wakaf asset updated successfully")
+}
+
+// DeleteWakafAsset removes an asset registered in error. Assets that were
+// really endowed are kept and marked inactive instead.
+func (h *Handler) DeleteWakafAsset(c *gin.Context) {
+	var asset models.WakafAsset
+	if err := h.DB.First(&asset, "id = ?", c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Wakaf asset not found")
+		return
+	}
+
+	var donations, yields int64
+	h.DB.Model(&models.Donation{}).Where("wakaf_asset_id = ?", asset.ID).Count(&donations)
+	h.DB.Model(&models.WakafYield{}).Where("asset_id = ?", asset.ID).Count(&yields)
+	if donations > 0 || yields > 0 {
+		utils.ErrorResponse(c, http.StatusConflict, "Wakaf asset has donations or yields, mark it inactive instead")
+		return
+	}
+
+	if err := h.DB.Delete(&asset).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete wakaf asset")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, nil, "Wakaf asset deleted successfully")
+}
+
+// Legal documents
+
+// UploadWakafDocument adds a scan of the AIW, certificate or another legal
+// document to an asset.
+func (h *Handler) UploadWakafDocument(c *gin.Context) {
+	var asset models.WakafAsset
+	if err := h.DB.First(&asset, "id = ?", c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Wakaf asset not found")
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "No file provided")
+		return
+	}
+
+	key, err := services.SavePrivateFile("wakaf", file, MaxWakafDocumentSize, services.DocumentContentTypes)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrFileTooLarge):
+			utils.ErrorResponse(c, http.StatusBadRequest, "File size exceeds 10MB limit")
+		case errors.Is(err, services.ErrFileTypeInvalid):
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid file type. Only JPG, PNG, WebP or PDF are allowed")
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to save file")
+		}
+		return
+	}
+
+	asset.Documents = append(asset.Documents, key)
+	if err := h.DB.Model(&asset).Update("documents", asset.Documents).Error; err != nil {
+		os.Remove(services.PrivateFilePath(key))
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to save document")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, asset, "Document uploaded successfully")
+}
+
+// GetWakafDocument streams the document at the given index of an asset.
+func (h *Handler) GetWakafDocument(c *gin.Context) {
+	var asset models.WakafAsset
+	if err := h.DB.First(&asset, "id = ?", c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Wakaf asset not found")
+		return
+	}
+
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil || index < 0 || index >= len(asset.Documents) {
+		utils.ErrorResponse(c, http.StatusNotFound, "Document not found")
+		return
+	}
+
+	c.File(services.PrivateFilePath(asset.Documents[index]))
+}
+
+func (h *Handler) DeleteWakafDocument(c *gin.Context) {
+	var asset models.WakafAsset
+	if err := h.DB.First(&asset, "id = ?", c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Wakaf asset not found")
+		return
+	}
+
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil || index < 0 || index >= len(asset.Documents) {
+		utils.ErrorResponse(c, http.StatusNotFound, "Document not found")
+		return
+	}
+
+	key := asset.Documents[index]
+	asset.Documents = append(asset.Documents[:index], asset.Documents[index+1:]...)
+	if err := h.DB.Model(&asset).Update("documents", asset.Documents).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to remove document")
+		return
+	}
+	os.Remove(services.PrivateFilePath(key))
+
+	utils.SuccessResponse(c, http.StatusOK, asset, "Document removed successfully")
+}
+
+// Yields
+
+type WakafYieldRequest struct {
+	Amount      models.Money `json:"amount" binding:"required"`
+	ReceivedAt  string       `json:"received_at" binding:"required"` // YYYY-MM-DD
+	Description string       `json:"description"`
+	AccountID   *uuid.UUID   `json:"account_id"` // post to the cash book when set
+}
+
+func (h *Handler) CreateWakafYield(c *gin.Context) {
+	assetID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid wakaf asset ID")
+		return
+	}
+
+	var req WakafYieldRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	receivedAt, err := time.Parse("2006-01-02", req.ReceivedAt)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid received_at. Use YYYY-MM-DD")
+		return
+	}
+
+	yield := models.WakafYield{
+		AssetID:     assetID,
+		Amount:      req.Amount,
+		ReceivedAt:  receivedAt,
+		Description: req.Description,
+		AccountID:   req.AccountID,
+	}
+	userID, _ := c.Get("userID")
+	if err := services.RecordWakafYield(h.DB, &yield, userID.(uuid.UUID)); err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			utils.ErrorResponse(c, http.StatusNotFound, "Wakaf asset not found")
+		case errors.Is(err, services.ErrWakafYieldNotProduct), errors.Is(err, services.ErrWakafYieldAmount),
+			errors.Is(err, services.ErrWakafYieldAccount):
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to record yield")
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, yield, "Yield recorded successfully")
+}
+
+func (h *Handler) DeleteWakafYield(c *gin.Context) {
+	assetID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid wakaf asset ID")
+		return
+	}
+	yieldID, err := uuid.Parse(c.Param("yieldId"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid yield ID")
+		return
+	}
+
+	if err := services.DeleteWakafYield(h.DB, assetID, yieldID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.ErrorResponse(c, http.StatusNotFound, "Yield not found")
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete yield")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, nil, "Yield deleted successfully")
+}
+
+// Cash wakaf
+
+type LinkWakafDonationRequest struct {
+	WakafAssetID *uuid.UUID `json:"wakaf_asset_id"` // null to unlink
+}
+
+// LinkWakafDonation sets the asset a cash wakaf donation funds.
+func (h *Handler) LinkWakafDonation(c *gin.Context) {
+	donationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid donation ID")
+		return
+	}
+
+	var req LinkWakafDonationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	donation, err := services.LinkDonationToWakafAsset(h.DB, donationID, req.WakafAssetID)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			utils.ErrorResponse(c, http.StatusNotFound, "Donation or wakaf asset not found")
+		case errors.Is(err, services.ErrWakafDonationCategory):
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to link donation")
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, donation, "Donation linked successfully")
+}
+
+// GetWakafSummary is the public overview of the mosque's wakaf assets.
+func (h *Handler) GetWakafSummary(c *gin.Context) {
+	summary, err := services.GetWakafSummary(h.DB)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to get wakaf summary")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, summary, "")
+}
@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+	"masjid-baiturrahim-backend/internal/services"
+	"masjid-baiturrahim-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Calculators
+
+func (h *Handler) GetZakatRates(c *gin.Context) {
+	utils.SuccessResponse(c, http.StatusOK, services.GetZakatRates(h.DB), "")
+}
+
+func (h *Handler) CalculateZakatMaal(c *gin.Context) {
+	var input services.ZakatMaalInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := services.CalculateZakatMaal(services.GetZakatRates(h.DB), input)
+	if err != nil {
+		zakatCalculatorError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, result, "")
+}
+
+func (h *Handler) CalculateZakatProfesi(c *gin.Context) {
+	var input services.ZakatProfesiInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := services.CalculateZakatProfesi(services.GetZakatRates(h.DB), input)
+	if err != nil {
+		zakatCalculatorError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, result, "")
+}
+
+func (h *Handler) CalculateZakatFitrah(c *gin.Context) {
+	var input struct {
+		People int `json:"people" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := services.CalculateZakatFitrah(services.GetZakatRates(h.DB), input.People)
+	if err != nil {
+		zakatCalculatorError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, result, "")
+}
+
+func zakatCalculatorError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrGoldPriceNotSet) {
+		utils.ErrorResponse(c, http.StatusServiceUnavailable, "Nisab is not available until the gold price is configured")
+		return
+	}
+	utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+}
+
+// Muzakki
+
+func (h *Handler) GetMuzakkiList(c *gin.Context) {
+	page, limit := utils.GetPaginationParams(c)
+	offset := utils.GetOffset(page, limit)
+
+	var muzakki []models.Muzakki
+	var total int64
+
+	query := h.DB.Model(&models.Muzakki{})
+	if q := c.Query("q"); q != "" {
+		query = query.Where("name ILIKE ? OR phone LIKE ?", "%"+q+"%", "%"+q+"%")
+	}
+
+	query.Count(&total)
+	query.Order("name ASC").
+		Offset(offset).
+		Limit(limit).
+		Find(&muzakki)
+
+	utils.PaginatedSuccessResponse(c, muzakki, page, limit, total)
+}
+
+func (h *Handler) GetMuzakki(c *gin.Context) {
+	var muzakki models.Muzakki
+	if err := h.DB.First(&muzakki, "id = ?", c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Muzakki not found")
+		return
+	}
+
+	var payments []models.ZakatPayment
+	h.DB.Where("muzakki_id = ?", muzakki.ID).Order("created_at DESC").Find(&payments)
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{
+		"muzakki":  muzakki,
+		"payments": payments,
+	}, "")
+}
+
+func (h *Handler) CreateMuzakki(c *gin.Context) {
+	var muzakki models.Muzakki
+	if err := c.ShouldBindJSON(&muzakki); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if muzakki.Name == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Name is required")
+		return
+	}
+
+	if err := h.DB.Create(&muzakki).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create muzakki")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, muzakki, "Muzakki created successfully")
+}
+
+func (h *Handler) UpdateMuzakki(c *gin.Context) {
+	var muzakki models.Muzakki
+	if err := h.DB.First(&muzakki, "id = ?", c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Muzakki not found")
+		return
+	}
+
+	if err := c.ShouldBindJSON(&muzakki); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.DB.Save(&muzakki).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update muzakki")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, muzakki, "Muzakki updated successfully")
+}
+
+// Payments
+
+func (h *Handler) GetZakatPayments(c *gin.Context) {
+	page, limit := utils.GetPaginationParams(c)
+	offset := utils.GetOffset(page, limit)
+
+	var payments []models.ZakatPayment
+	var total int64
+
+	query := h.DB.Model(&models.ZakatPayment{})
+	if year := c.Query("year"); year != "" {
+		query = query.Where("year = ?", year)
+	}
+	if zakatType := c.Query("type"); zakatType != "" {
+		query = query.Where("type = ?", zakatType)
+	}
+	if muzakkiID := c.Query("muzakki_id"); muzakkiID != "" {
+		query = query.Where("muzakki_id = ?", muzakkiID)
+	}
+
+	query.Count(&total)
+	query.Preload("Muzakki").Preload("Receiver").
+		Order("created_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&payments)
+
+	utils.PaginatedSuccessResponse(c, payments, page, limit, total)
+}
+
+// RecordZakatPayment takes a payment at the zakat counter. Pass muzakki_id for
+// a known payer or a muzakki object to register a new one.
+func (h *Handler) RecordZakatPayment(c *gin.Context) {
+	var payment models.ZakatPayment
+	if err := c.ShouldBindJSON(&payment); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	payment.ID = uuid.Nil
+	payment.DonationID = nil
+
+	userID, _ := c.Get("userID")
+	approval, err := services.RecordZakatPayment(h.DB, &payment, userID.(uuid.UUID))
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			utils.ErrorResponse(c, http.StatusNotFound, "Muzakki not found")
+		case errors.Is(err, services.ErrApprovalPending):
+			utils.ErrorResponse(c, http.StatusConflict, err.Error())
+		case errors.Is(err, services.ErrZakatType), errors.Is(err, services.ErrZakatPeopleCount),
+			errors.Is(err, services.ErrZakatNegative), errors.Is(err, services.ErrZakatPaymentEmpty),
+			errors.Is(err, services.ErrZakatRiceNotFitrah), errors.Is(err, services.ErrMuzakkiRequired),
+			errors.Is(err, services.ErrDonationAmountFraction), errors.Is(err, services.ErrDonationAmountRange),
+			errors.Is(err, services.ErrNoLedgerAccount), errors.Is(err, services.ErrNoLedgerCategory):
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to record zakat payment")
+		}
+		return
+	}
+
+	h.DB.Preload("Muzakki").Preload("Donation").First(&payment, "id = ?", payment.ID)
+	if approval != nil {
+		// The payment is recorded, its cash stays pending until approved
+		services.NotifyApprovalRequested(h.DB, h.Notifier, approval)
+		utils.SuccessResponse(c, http.StatusAccepted, gin.H{
+			"payment":  payment,
+			"approval": approval,
+		}, "Zakat payment recorded, the cash part is waiting for a treasurer's approval")
+		return
+	}
+	utils.SuccessResponse(c, http.StatusCreated, payment, "Zakat payment recorded successfully")
+}
+
+func (h *Handler) GetZakatSummary(c *gin.Context) {
+	year := time.Now().Year()
+	if s := c.Query("year"); s != "" {
+		parsed, err := strconv.Atoi(s)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid year")
+			return
+		}
+		year = parsed
+	}
+
+	summary, err := services.GetZakatSummary(h.DB, year)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to get zakat summary")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, summary, "")
+}
@@ -1,13 +1,18 @@
 package middleware
 
 import (
+	"errors"
+	"log"
 	"net/http"
 	"masjid-baiturrahim-backend/config"
 	"masjid-baiturrahim-backend/internal/models"
+	"masjid-baiturrahim-backend/internal/services"
 	"masjid-baiturrahim-backend/internal/utils"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 func AuthRequired() gin.HandlerFunc {
@@ -39,7 +44,17 @@ func AuthRequired() gin.HandlerFunc {
 			return
 		}
 
+		// Logging out revokes the session, which ends its access tokens too
+		value, _ := c.Get("db")
+		db, _ := value.(*gorm.DB)
+		if claims.SessionID == uuid.Nil || db == nil || !services.SessionActive(db, claims.SessionID) {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "Session has ended, please log in again")
+			c.Abort()
+			return
+		}
+
 		// Store claims in context
+		c.Set("sessionID", claims.SessionID)
 		c.Set("userID", claims.UserID)
 		c.Set("userEmail", claims.Email)
 		c.Set("userRole", claims.Role)
@@ -52,6 +67,71 @@ func AuthRequired() gin.HandlerFunc {
 	}
 }
 
+// PasswordChangeRequired turns away users who still have to replace a
+// temporary or default password, so that password can't be used for
+// anything but choosing a new one. It goes after AuthRequired.
+func PasswordChangeRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, _ := c.Get("db")
+		db, _ := value.(*gorm.DB)
+		userID, _ := c.Get("userID")
+		id, _ := userID.(uuid.UUID)
+		if db == nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to check the account")
+			c.Abort()
+			return
+		}
+		mustChange, err := services.MustChangePassword(db, id)
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			utils.ErrorResponse(c, http.StatusUnauthorized, "User not found")
+			c.Abort()
+			return
+		case err != nil:
+			log.Printf("Failed to check whether user %s must change their password: %v", id, err)
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to check the account")
+			c.Abort()
+			return
+		case mustChange:
+			utils.ErrorResponse(c, http.StatusForbidden, "Password change required")
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// DonorAuthRequired accepts donor tokens from the login link flow and stores
+// the donor's ID as "donorID".
+func DonorAuthRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+		if authHeader == "" || tokenString == authHeader {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "Authorization header required")
+			c.Abort()
+			return
+		}
+
+		claims, err := utils.ParseToken(tokenString, config.Load().JWTSecret)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid or expired token")
+			c.Abort()
+			return
+		}
+
+		if claims.TokenType != utils.TokenTypeDonor {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid token type")
+			c.Abort()
+			return
+		}
+
+		c.Set("donorID", claims.UserID)
+		c.Next()
+	}
+}
+
 func RequireRole(roles ...models.UserRole) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		role, exists := c.Get("userRole")
@@ -79,3 +159,24 @@ func RequireRole(roles ...models.UserRole) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// RequirePermission lets the request through when the user's role has
+// permission p in models.RolePermissions.
+func RequirePermission(p models.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, exists := c.Get("userRole")
+		if !exists {
+			utils.ErrorResponse(c, http.StatusForbidden, "Role not found in context")
+			c.Abort()
+			return
+		}
+
+		if !models.UserRole(role.(string)).Can(p) {
+			utils.ErrorResponse(c, http.StatusForbidden, "Insufficient permissions: "+string(p)+" required")
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
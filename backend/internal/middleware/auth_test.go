@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"masjid-baiturrahim-backend/internal/models"
+	"masjid-baiturrahim-backend/internal/testutil"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func TestPasswordChangeRequired(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testutil.NewDB(t)
+	user := testutil.NewUser(t, db, models.RoleEditor, "")
+	flagged := testutil.NewUser(t, db, models.RoleEditor, "-new")
+	db.Model(&flagged).Update("must_change_password", true)
+
+	status := func(db *gorm.DB, userID uuid.UUID) int {
+		r := gin.New()
+		r.GET("/", func(c *gin.Context) {
+			if db != nil {
+				c.Set("db", db)
+			}
+			c.Set("userID", userID)
+			c.Next()
+		}, PasswordChangeRequired(), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		return w.Code
+	}
+
+	if code := status(db, user.ID); code != http.StatusOK {
+		t.Errorf("changed password: status = %d, want 200", code)
+	}
+	if code := status(db, flagged.ID); code != http.StatusForbidden {
+		t.Errorf("temporary password: status = %d, want 403", code)
+	}
+	if code := status(db, uuid.New()); code != http.StatusUnauthorized {
+		t.Errorf("unknown user: status = %d, want 401", code)
+	}
+	if code := status(nil, user.ID); code != http.StatusInternalServerError {
+		t.Errorf("no database: status = %d, want 500", code)
+	}
+
+	// The flag can't be read, so the request can't be let through
+	if err := db.Exec("ALTER TABLE users RENAME COLUMN must_change_password TO must_change_password_old").Error; err != nil {
+		t.Fatal(err)
+	}
+	if code := status(db, user.ID); code != http.StatusInternalServerError {
+		t.Errorf("failing query: status = %d, want 500", code)
+	}
+}
@@ -0,0 +1,120 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ApprovalKind string
+
+const (
+	ApprovalDonationConfirmation ApprovalKind = "donation_confirmation"
+	ApprovalExpense              ApprovalKind = "expense"
+	ApprovalExpenseDeletion      ApprovalKind = "expense_deletion"
+	ApprovalDistribution         ApprovalKind = "distribution"
+	ApprovalThresholdChange      ApprovalKind = "approval_threshold" // Amount is the new threshold
+)
+
+type ApprovalStatus string
+
+const (
+	ApprovalPending   ApprovalStatus = "pending"
+	ApprovalApproved  ApprovalStatus = "approved"
+	ApprovalRejected  ApprovalStatus = "rejected"
+	ApprovalCancelled ApprovalStatus = "cancelled" // withdrawn, or the subject changed before a decision
+)
+
+// ExpenseProposal is a manual expense entry waiting for approval. It only
+// reaches the ledger once approved. Type is set when a large expense is to
+// become another type of entry; empty means an expense.
+type ExpenseProposal struct {
+	Type        LedgerEntryType `json:"type,omitempty"`
+	AccountID   uuid.UUID       `json:"account_id"`
+	CategoryID  uuid.UUID       `json:"category_id"`
+	Amount      Money           `json:"amount"`
+	EntryDate   time.Time       `json:"entry_date"`
+	Description string          `json:"description"`
+	Reference   *string         `json:"reference,omitempty"`
+}
+
+func (p ExpenseProposal) Value() (driver.Value, error) {
+	return json.Marshal(p)
+}
+
+func (p *ExpenseProposal) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, p)
+}
+
+// DistributionProposal is a cash distribution to a mustahik waiting for
+// approval. The distribution and its ledger expense are only recorded once
+// approved, after checking the funds again.
+type DistributionProposal struct {
+	MustahikID     uuid.UUID        `json:"mustahik_id"`
+	SourceCategory DonationCategory `json:"source_category"`
+	Amount         Money            `json:"amount"`
+	RiceKg         float64          `json:"rice_kg"`
+	Description    string           `json:"description"`
+	DistributedAt  time.Time        `json:"distributed_at"`
+	AccountID      *uuid.UUID       `json:"account_id,omitempty"`
+}
+
+func (p DistributionProposal) Value() (driver.Value, error) {
+	return json.Marshal(p)
+}
+
+func (p *DistributionProposal) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, p)
+}
+
+// Approval is a request for a treasurer to sign off on a large donation
+// confirmation, expense or distribution made by someone else, or on another
+// treasurer's change to the approval threshold. SubjectID is the donation,
+// the ledger entry the expense creates, changes or deletes, the distribution
+// to be recorded, or the threshold setting; a subject has at most one
+// pending approval.
+type Approval struct {
+	ID           uuid.UUID             `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Kind         ApprovalKind          `gorm:"type:varchar(30);not null;index" json:"kind"`
+	Status       ApprovalStatus        `gorm:"type:varchar(20);default:'pending';not null;index" json:"status"`
+	SubjectID    uuid.UUID             `gorm:"type:uuid;not null;index;uniqueIndex:idx_approval_pending_subject,where:status = 'pending'" json:"subject_id"`
+	Amount       Money                 `gorm:"type:bigint;not null" json:"amount"`
+	Summary      string                `gorm:"type:text;not null" json:"summary"`
+	Expense      *ExpenseProposal      `gorm:"type:jsonb" json:"expense,omitempty"`
+	Distribution *DistributionProposal `gorm:"type:jsonb" json:"distribution,omitempty"`
+	MutationID   *uuid.UUID            `gorm:"type:uuid" json:"mutation_id,omitempty"` // bank mutation confirmed along with the donation
+	RequestedBy  uuid.UUID             `gorm:"type:uuid;not null;index" json:"requested_by"`
+	RequestNote  string                `gorm:"type:text" json:"request_note"`
+	DecidedBy    *uuid.UUID            `gorm:"type:uuid" json:"decided_by,omitempty"`
+	DecidedAt    *time.Time            `json:"decided_at,omitempty"`
+	DecisionNote string                `gorm:"type:text" json:"decision_note"`
+	CreatedAt    time.Time             `json:"created_at"`
+	UpdatedAt    time.Time             `json:"updated_at"`
+
+	Requester *User `gorm:"foreignKey:RequestedBy" json:"requester,omitempty"`
+	Decider   *User `gorm:"foreignKey:DecidedBy" json:"decider,omitempty"`
+}
+
+func (a *Approval) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type BankCode string
+
+const (
+	BankBCA     BankCode = "bca"
+	BankBSI     BankCode = "bsi"
+	BankMandiri BankCode = "mandiri"
+)
+
+type MutationType string
+
+const (
+	MutationCredit MutationType = "credit"
+	MutationDebit  MutationType = "debit"
+)
+
+// MutationSource tells whether a mutation came from an uploaded statement,
+// was pushed by a mutation-checker service such as Moota, or is a virtual
+// account payment that couldn't be applied to its donation.
+type MutationSource string
+
+const (
+	MutationSourceStatement      MutationSource = "statement"
+	MutationSourceWebhook        MutationSource = "webhook"
+	MutationSourceVirtualAccount MutationSource = "virtual_account"
+)
+
+type MutationMatchStatus string
+
+const (
+	MutationUnmatched MutationMatchStatus = "unmatched"
+	MutationMatched   MutationMatchStatus = "matched"   // single candidate, awaiting confirmation
+	MutationAmbiguous MutationMatchStatus = "ambiguous" // several candidates, needs review
+	MutationConfirmed MutationMatchStatus = "confirmed"
+	MutationIgnored   MutationMatchStatus = "ignored"
+)
+
+type UUIDList []uuid.UUID
+
+func (l UUIDList) Value() (driver.Value, error) {
+	return json.Marshal(l)
+}
+
+func (l *UUIDList) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, l)
+}
+
+type BankStatementImport struct {
+	ID             uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Bank           BankCode  `gorm:"type:varchar(20);not null;index" json:"bank"`
+	FileName       string    `gorm:"type:varchar(255);not null" json:"file_name"`
+	RowCount       int       `gorm:"default:0;not null" json:"row_count"`
+	DuplicateCount int       `gorm:"default:0;not null" json:"duplicate_count"`
+	MatchedCount   int       `gorm:"default:0;not null" json:"matched_count"`
+	AmbiguousCount int       `gorm:"default:0;not null" json:"ambiguous_count"`
+	ImportedBy     uuid.UUID `gorm:"type:uuid;not null;index" json:"imported_by"`
+	CreatedAt      time.Time `json:"created_at"`
+
+	Importer User `gorm:"foreignKey:ImportedBy" json:"importer,omitempty"`
+}
+
+func (b *BankStatementImport) BeforeCreate(tx *gorm.DB) error {
+	if b.ID == uuid.Nil {
+		b.ID = uuid.New()
+	}
+	return nil
+}
+
+type BankMutation struct {
+	ID              uuid.UUID           `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	ImportID        *uuid.UUID          `gorm:"type:uuid;index" json:"import_id,omitempty"`
+	Source          MutationSource      `gorm:"type:varchar(20);default:'statement';not null;index" json:"source"`
+	ExternalID      string              `gorm:"type:varchar(100);index" json:"external_id,omitempty"` // mutation ID at the checker service
+	Bank            BankCode            `gorm:"type:varchar(20);not null;index" json:"bank"`
+	Fingerprint     string              `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	TransactionDate time.Time           `gorm:"type:date;not null;index" json:"transaction_date"`
+	TransactionTime *time.Time          `json:"transaction_time,omitempty"` // only known for pushed mutations
+	Description     string              `gorm:"type:text" json:"description"`
+	Type            MutationType        `gorm:"type:varchar(10);not null" json:"type"`
+	Amount          Money               `gorm:"type:bigint;not null;index" json:"amount"`
+	Balance         *Money              `gorm:"type:bigint" json:"balance,omitempty"`
+	MatchStatus     MutationMatchStatus `gorm:"type:varchar(20);default:'unmatched';not null;index" json:"match_status"`
+	DonationID      *uuid.UUID          `gorm:"type:uuid;index" json:"donation_id,omitempty"`
+	CandidateIDs    UUIDList            `gorm:"type:jsonb" json:"candidate_ids,omitempty"`
+	ReviewedBy      *uuid.UUID          `gorm:"type:uuid" json:"reviewed_by,omitempty"`
+	ReviewedAt      *time.Time          `json:"reviewed_at,omitempty"`
+	ReviewNote      string              `gorm:"type:text" json:"review_note,omitempty"` // why a different amount was accepted
+	RawPayload      string              `gorm:"type:text" json:"raw_payload,omitempty"` // as received from the webhook
+	CreatedAt       time.Time           `json:"created_at"`
+	UpdatedAt       time.Time           `json:"updated_at"`
+
+	Donation *Donation `gorm:"foreignKey:DonationID" json:"donation,omitempty"`
+}
+
+func (b *BankMutation) BeforeCreate(tx *gorm.DB) error {
+	if b.ID == uuid.Nil {
+		b.ID = uuid.New()
+	}
+	return nil
+}
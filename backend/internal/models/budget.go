@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type BudgetPlanStatus string
+
+const (
+	BudgetPlanDraft    BudgetPlanStatus = "draft"
+	BudgetPlanApproved BudgetPlanStatus = "approved" // adopted by the takmir, lines are frozen
+)
+
+// BudgetPlan is the annual budget (RAPB, Rencana Anggaran Pendapatan dan
+// Belanja) of one fiscal year.
+type BudgetPlan struct {
+	ID         uuid.UUID        `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	FiscalYear int              `gorm:"uniqueIndex;not null" json:"fiscal_year"`
+	Title      string           `gorm:"type:varchar(255);not null" json:"title"`
+	Notes      string           `gorm:"type:text" json:"notes"`
+	Status     BudgetPlanStatus `gorm:"type:varchar(20);default:'draft';not null" json:"status"`
+	ApprovedBy *uuid.UUID       `gorm:"type:uuid" json:"approved_by,omitempty"`
+	ApprovedAt *time.Time       `json:"approved_at,omitempty"`
+	CreatedBy  uuid.UUID        `gorm:"type:uuid;not null" json:"created_by"`
+	CreatedAt  time.Time        `json:"created_at"`
+	UpdatedAt  time.Time        `json:"updated_at"`
+
+	Lines []BudgetLine `gorm:"foreignKey:PlanID" json:"lines,omitempty"`
+}
+
+func (p *BudgetPlan) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	return nil
+}
+
+// BudgetLine is the amount planned for one ledger category over the year.
+type BudgetLine struct {
+	ID         uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	PlanID     uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_budget_line_category" json:"plan_id"`
+	CategoryID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_budget_line_category" json:"category_id"`
+	Amount     Money     `gorm:"type:bigint;not null" json:"amount"`
+	Notes      string    `gorm:"type:text" json:"notes"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+
+	Category *LedgerCategory `gorm:"foreignKey:CategoryID" json:"category,omitempty"`
+}
+
+func (l *BudgetLine) BeforeCreate(tx *gorm.DB) error {
+	if l.ID == uuid.Nil {
+		l.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type CampaignStatus string
+
+const (
+	CampaignStatusDraft     CampaignStatus = "draft"
+	CampaignStatusActive    CampaignStatus = "active"
+	CampaignStatusCompleted CampaignStatus = "completed"
+	CampaignStatusCancelled CampaignStatus = "cancelled"
+)
+
+type Campaign struct {
+	ID            uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Title         string         `gorm:"type:varchar(255);not null" json:"title"`
+	Slug          string         `gorm:"type:varchar(255);uniqueIndex;not null" json:"slug"`
+	Summary       string         `gorm:"type:varchar(500)" json:"summary"`
+	Story         string         `gorm:"type:text" json:"story"`
+	TargetAmount  Money          `gorm:"type:bigint;not null" json:"target_amount"`
+	Deadline      *time.Time     `gorm:"type:date;index" json:"deadline,omitempty"`
+	CoverImageURL *string        `gorm:"type:varchar(500)" json:"cover_image_url,omitempty"`
+	Status        CampaignStatus `gorm:"type:varchar(20);default:'draft';not null;index" json:"status"`
+	CreatedBy     uuid.UUID      `gorm:"type:uuid;not null;index" json:"created_by"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Creator User `gorm:"foreignKey:CreatedBy" json:"creator,omitempty"`
+}
+
+func (c *Campaign) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	return nil
+}
+
+// AcceptsDonations reports whether new donations may still be attached.
+func (c *Campaign) AcceptsDonations(now time.Time) bool {
+	if c.Status != CampaignStatusActive {
+		return false
+	}
+	return c.Deadline == nil || !now.After(c.Deadline.AddDate(0, 0, 1))
+}
+
+// CampaignUpdate is a news post published on a campaign page, e.g. progress
+// photos of the renovation.
+type CampaignUpdate struct {
+	ID          uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	CampaignID  uuid.UUID      `gorm:"type:uuid;not null;index" json:"campaign_id"`
+	Title       string         `gorm:"type:varchar(255);not null" json:"title"`
+	Content     string         `gorm:"type:text;not null" json:"content"`
+	ImageURL    *string        `gorm:"type:varchar(500)" json:"image_url,omitempty"`
+	PublishedAt *time.Time     `gorm:"index" json:"published_at,omitempty"`
+	CreatedBy   uuid.UUID      `gorm:"type:uuid;not null;index" json:"created_by"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Creator User `gorm:"foreignKey:CreatedBy" json:"creator,omitempty"`
+}
+
+func (u *CampaignUpdate) BeforeCreate(tx *gorm.DB) error {
+	if u.ID == uuid.Nil {
+		u.ID = uuid.New()
+	}
+	if u.PublishedAt == nil {
+		now := time.Now()
+		u.PublishedAt = &now
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RupiahDenominations are the notes and coins a charity box count is broken
+// down into, in rupiah.
+var RupiahDenominations = []int64{100000, 50000, 20000, 10000, 5000, 2000, 1000, 500, 200, 100}
+
+func ValidDenomination(rupiah int64) bool {
+	for _, d := range RupiahDenominations {
+		if d == rupiah {
+			return true
+		}
+	}
+	return false
+}
+
+// CharityBox is a kotak amal placed in the mosque.
+type CharityBox struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Code      string    `gorm:"type:varchar(20);uniqueIndex;not null" json:"code"` // label on the box, e.g. KA-01
+	Location  string    `gorm:"type:varchar(255)" json:"location"`
+	IsActive  bool      `gorm:"default:true;not null" json:"is_active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (b *CharityBox) BeforeCreate(tx *gorm.DB) error {
+	if b.ID == uuid.Nil {
+		b.ID = uuid.New()
+	}
+	return nil
+}
+
+type CharityCountStatus string
+
+const (
+	CharityCountDraft      CharityCountStatus = "draft"
+	CharityCountPosted     CharityCountStatus = "posted"
+	CharityCountSuperseded CharityCountStatus = "superseded" // replaced by a recount, kept as counted
+)
+
+// CharityCount is one counting session of the charity boxes. Once a witness
+// signs, the counted values are frozen; mistakes are fixed by a recount that
+// supersedes the session instead of editing it.
+type CharityCount struct {
+	ID           uuid.UUID          `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	CountDate    time.Time          `gorm:"type:date;not null;index" json:"count_date"`
+	Category     DonationCategory   `gorm:"type:varchar(50);not null" json:"category"`
+	Total        Money              `gorm:"type:bigint;default:0;not null" json:"total"`
+	Status       CharityCountStatus `gorm:"type:varchar(20);default:'draft';not null;index" json:"status"`
+	Notes        string             `gorm:"type:text" json:"notes"`
+	RecountOf    *uuid.UUID         `gorm:"type:uuid;index" json:"recount_of,omitempty"`
+	RecountNote  string             `gorm:"type:text" json:"recount_note"` // why the recount was needed
+	SupersededBy *uuid.UUID         `gorm:"type:uuid" json:"superseded_by,omitempty"`
+	DonationID   *uuid.UUID         `gorm:"type:uuid;uniqueIndex" json:"donation_id,omitempty"`
+	PostedAt     *time.Time         `json:"posted_at,omitempty"`
+	CreatedBy    uuid.UUID          `gorm:"type:uuid;not null" json:"created_by"`
+	CreatedAt    time.Time          `json:"created_at"`
+	UpdatedAt    time.Time          `json:"updated_at"`
+
+	Lines    []CharityCountLine    `gorm:"foreignKey:CountID" json:"lines,omitempty"`
+	Signoffs []CharityCountSignoff `gorm:"foreignKey:CountID" json:"signoffs,omitempty"`
+	Creator  *User                 `gorm:"foreignKey:CreatedBy" json:"creator,omitempty"`
+}
+
+func (c *CharityCount) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	return nil
+}
+
+// CharityCountLine is how many notes or coins of one denomination were found
+// in one box.
+type CharityCountLine struct {
+	ID           uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	CountID      uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_charity_count_line" json:"count_id"`
+	BoxID        uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_charity_count_line" json:"box_id"`
+	Denomination int64     `gorm:"not null;uniqueIndex:idx_charity_count_line" json:"denomination"` // rupiah
+	Quantity     int       `gorm:"not null" json:"quantity"`
+	Subtotal     Money     `gorm:"type:bigint;not null" json:"subtotal"`
+
+	Box *CharityBox `gorm:"foreignKey:BoxID" json:"box,omitempty"`
+}
+
+func (l *CharityCountLine) BeforeCreate(tx *gorm.DB) error {
+	if l.ID == uuid.Nil {
+		l.ID = uuid.New()
+	}
+	return nil
+}
+
+// CharityCountSignoff is a witness confirming a count.
+type CharityCountSignoff struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	CountID   uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_charity_count_signoff" json:"count_id"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_charity_count_signoff" json:"user_id"`
+	SignedAt  time.Time `gorm:"not null" json:"signed_at"`
+	CreatedAt time.Time `json:"created_at"`
+
+	User *User `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+func (s *CharityCountSignoff) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return nil
+}
@@ -15,14 +15,23 @@ const (
 	DonationCategoryZakat      DonationCategory = "zakat"
 	DonationCategoryWakaf      DonationCategory = "wakaf"
 	DonationCategoryOperasional DonationCategory = "operasional"
+	DonationCategoryQurban     DonationCategory = "qurban"
 )
 
+var AllDonationCategories = []DonationCategory{
+	DonationCategoryZakat, DonationCategoryInfaq, DonationCategorySedekah,
+	DonationCategoryWakaf, DonationCategoryOperasional, DonationCategoryQurban,
+}
+
 type DonationStatus string
 
 const (
 	DonationStatusPending   DonationStatus = "pending"
 	DonationStatusConfirmed DonationStatus = "confirmed"
 	DonationStatusCancelled DonationStatus = "cancelled"
+	DonationStatusRejected  DonationStatus = "rejected"
+	DonationStatusExpired   DonationStatus = "expired"
+	DonationStatusRefunded  DonationStatus = "refunded"
 )
 
 type Donation struct {
@@ -31,18 +40,40 @@ type Donation struct {
 	DonorName       string           `gorm:"type:varchar(255);not null" json:"donor_name"`
 	DonorEmail      *string          `gorm:"type:varchar(255);index" json:"donor_email,omitempty"`
 	DonorPhone      *string          `gorm:"type:varchar(20)" json:"donor_phone,omitempty"`
-	Amount          float64          `gorm:"type:decimal(15,2);not null" json:"amount"`
+	DonorID         *uuid.UUID       `gorm:"type:uuid;index" json:"donor_id,omitempty"`
+	IsAnonymous     bool             `gorm:"default:false;not null" json:"is_anonymous"`    // listed as "Hamba Allah"
+	DisplayName     *string          `gorm:"type:varchar(100)" json:"display_name,omitempty"` // shown instead of the donor's name
+	HideFromWall    bool             `gorm:"default:false;not null" json:"hide_from_wall"`  // never listed publicly
+	Amount          Money            `gorm:"type:bigint;not null" json:"amount"`
+	UniqueCode      int              `gorm:"default:0;not null" json:"unique_code"`
+	TransferAmount  Money            `gorm:"type:bigint;default:0;not null;index" json:"transfer_amount"` // amount + unique code
 	PaymentMethodID *uuid.UUID       `gorm:"type:uuid;index" json:"payment_method_id,omitempty"`
 	Category        DonationCategory `gorm:"type:varchar(50);not null;index" json:"category"`
+	CampaignID      *uuid.UUID       `gorm:"type:uuid;index" json:"campaign_id,omitempty"`
+	WakafAssetID    *uuid.UUID       `gorm:"type:uuid;index" json:"wakaf_asset_id,omitempty"` // the asset a cash wakaf funds
 	Notes           string           `gorm:"type:text" json:"notes"`
 	Status          DonationStatus    `gorm:"type:varchar(50);default:'pending';not null;index" json:"status"`
 	ProofURL        *string          `gorm:"type:varchar(500)" json:"proof_url,omitempty"`
+	ProofUploadedAt *time.Time       `json:"proof_uploaded_at,omitempty"`
+	AccessTokenHash string           `gorm:"type:varchar(64)" json:"-"`
+	QRISPayload     *string          `gorm:"type:text" json:"qris_payload,omitempty"`
+	QRISImageURL    *string          `gorm:"type:varchar(500)" json:"qris_image_url,omitempty"`
+	VANumber        *string          `gorm:"type:varchar(50);uniqueIndex" json:"va_number,omitempty"` // virtual account issued for this donation
+	VABankCode      *string          `gorm:"type:varchar(20)" json:"va_bank_code,omitempty"`
+	VAExpiresAt     *time.Time       `json:"va_expires_at,omitempty"`
 	ConfirmedBy    *uuid.UUID        `gorm:"type:uuid;index" json:"confirmed_by,omitempty"`
 	ConfirmedAt    *time.Time       `json:"confirmed_at,omitempty"`
 	CreatedAt       time.Time        `json:"created_at"`
 	UpdatedAt       time.Time        `json:"updated_at"`
 
 	PaymentMethod  PaymentMethod    `gorm:"foreignKey:PaymentMethodID" json:"payment_method,omitempty"`
+	Campaign       *Campaign        `gorm:"foreignKey:CampaignID" json:"campaign,omitempty"`
+	WakafAsset     *WakafAsset      `gorm:"foreignKey:WakafAssetID" json:"wakaf_asset,omitempty"`
+	Donor          *Donor           `gorm:"foreignKey:DonorID" json:"donor,omitempty"`
+	History        []DonationHistory `gorm:"foreignKey:DonationID" json:"history,omitempty"`
+
+	// AccessToken is only returned once, when the donation is created
+	AccessToken    string           `gorm:"-" json:"access_token,omitempty"`
 	Confirmer      User             `gorm:"foreignKey:ConfirmedBy" json:"confirmer,omitempty"`
 }
 
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DonationHistory records every status change of a donation. ActorID is nil
+// for changes made by the system, such as expiry.
+type DonationHistory struct {
+	ID         uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	DonationID uuid.UUID      `gorm:"type:uuid;not null;index" json:"donation_id"`
+	FromStatus DonationStatus `gorm:"type:varchar(50);not null" json:"from_status"`
+	ToStatus   DonationStatus `gorm:"type:varchar(50);not null" json:"to_status"`
+	Reason     string         `gorm:"type:text" json:"reason"`
+	ActorID    *uuid.UUID     `gorm:"type:uuid;index" json:"actor_id,omitempty"`
+	CreatedAt  time.Time      `gorm:"index" json:"created_at"`
+
+	Actor *User `gorm:"foreignKey:ActorID" json:"actor,omitempty"`
+}
+
+func (h *DonationHistory) BeforeCreate(tx *gorm.DB) error {
+	if h.ID == uuid.Nil {
+		h.ID = uuid.New()
+	}
+	return nil
+}
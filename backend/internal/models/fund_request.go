@@ -0,0 +1,102 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Division is a unit of the takmir that spends money on its own programmes,
+// such as the education (pendidikan) or youth (remaja masjid) division.
+type Division struct {
+	ID         uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Name       string         `gorm:"type:varchar(255);uniqueIndex;not null" json:"name"`
+	HeadUserID *uuid.UUID     `gorm:"type:uuid" json:"head_user_id,omitempty"` // ketua divisi
+	IsActive   bool           `gorm:"default:true;not null" json:"is_active"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Budgets  []DivisionBudget `gorm:"foreignKey:DivisionID" json:"budgets,omitempty"`
+	HeadUser *User            `gorm:"foreignKey:HeadUserID" json:"head_user,omitempty"`
+}
+
+func (d *Division) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	return nil
+}
+
+// DivisionBudget is how much a division may request in a year.
+type DivisionBudget struct {
+	ID         uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	DivisionID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_division_budget_year" json:"division_id"`
+	Year       int       `gorm:"not null;uniqueIndex:idx_division_budget_year" json:"year"`
+	Amount     Money     `gorm:"type:bigint;not null" json:"amount"`
+	Notes      string    `gorm:"type:text" json:"notes"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func (b *DivisionBudget) BeforeCreate(tx *gorm.DB) error {
+	if b.ID == uuid.Nil {
+		b.ID = uuid.New()
+	}
+	return nil
+}
+
+type FundRequestStatus string
+
+const (
+	FundRequestSubmitted FundRequestStatus = "submitted"
+	FundRequestApproved  FundRequestStatus = "approved"
+	FundRequestRejected  FundRequestStatus = "rejected"
+	FundRequestCancelled FundRequestStatus = "cancelled"
+	FundRequestDisbursed FundRequestStatus = "disbursed" // money handed over, receipts outstanding
+	FundRequestSettled   FundRequestStatus = "settled"   // receipts reported, leftover returned
+)
+
+// FundRequest is a division's request for money (pengajuan dana), usually
+// ahead of an event. The disbursed money is an advance until the division
+// reports what it spent with receipts.
+type FundRequest struct {
+	ID              uuid.UUID         `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	DivisionID      uuid.UUID         `gorm:"type:uuid;not null;index" json:"division_id"`
+	EventID         *uuid.UUID        `gorm:"type:uuid;index" json:"event_id,omitempty"`
+	Title           string            `gorm:"type:varchar(255);not null" json:"title"`
+	Description     string            `gorm:"type:text" json:"description"`
+	Amount          Money             `gorm:"type:bigint;not null" json:"amount"` // requested
+	NeededBy        *time.Time        `gorm:"type:date" json:"needed_by,omitempty"`
+	Year            int               `gorm:"not null;index" json:"year"` // budget year the request counts against
+	Status          FundRequestStatus `gorm:"type:varchar(20);default:'submitted';not null;index" json:"status"`
+	RequestedBy     uuid.UUID         `gorm:"type:uuid;not null;index" json:"requested_by"`
+	ApprovedAmount  Money             `gorm:"type:bigint;default:0;not null" json:"approved_amount"`
+	DecidedBy       *uuid.UUID        `gorm:"type:uuid" json:"decided_by,omitempty"`
+	DecidedAt       *time.Time        `json:"decided_at,omitempty"`
+	DecisionNote    string            `gorm:"type:text" json:"decision_note"`
+	DisbursedAmount Money             `gorm:"type:bigint;default:0;not null" json:"disbursed_amount"`
+	AccountID       *uuid.UUID        `gorm:"type:uuid" json:"account_id,omitempty"` // paid from
+	LedgerEntryID   *uuid.UUID        `gorm:"type:uuid" json:"ledger_entry_id,omitempty"`
+	DisbursedBy     *uuid.UUID        `gorm:"type:uuid" json:"disbursed_by,omitempty"`
+	DisbursedAt     *time.Time        `json:"disbursed_at,omitempty"`
+	Receipts        Gallery           `gorm:"type:jsonb" json:"receipts,omitempty"` // private storage keys
+	SpentAmount     Money             `gorm:"type:bigint;default:0;not null" json:"spent_amount"`
+	ReportNote      string            `gorm:"type:text" json:"report_note"`
+	SettledBy       *uuid.UUID        `gorm:"type:uuid" json:"settled_by,omitempty"`
+	SettledAt       *time.Time        `json:"settled_at,omitempty"`
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+
+	Division  *Division `gorm:"foreignKey:DivisionID" json:"division,omitempty"`
+	Event     *Event    `gorm:"foreignKey:EventID" json:"event,omitempty"`
+	Requester *User     `gorm:"foreignKey:RequestedBy" json:"requester,omitempty"`
+}
+
+func (r *FundRequest) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type LedgerAccountType string
+
+const (
+	LedgerAccountCash    LedgerAccountType = "cash"
+	LedgerAccountBank    LedgerAccountType = "bank"
+	LedgerAccountQRIS    LedgerAccountType = "qris"
+	LedgerAccountEWallet LedgerAccountType = "ewallet"
+)
+
+// LedgerAccount is a place money is kept: the cash box (kas tunai), a bank
+// account or a QRIS merchant balance.
+type LedgerAccount struct {
+	ID              uuid.UUID         `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Name            string            `gorm:"type:varchar(255);not null" json:"name"`
+	Type            LedgerAccountType `gorm:"type:varchar(20);not null" json:"type"`
+	AccountNumber   *string           `gorm:"type:varchar(100)" json:"account_number,omitempty"`
+	PaymentMethodID *uuid.UUID        `gorm:"type:uuid;uniqueIndex" json:"payment_method_id,omitempty"` // donations paid via this method post here
+	OpeningBalance  Money             `gorm:"type:bigint;default:0;not null" json:"opening_balance"`
+	IsDefault       bool              `gorm:"default:false;not null" json:"is_default"` // receives donations without a linked payment method
+	IsActive        bool              `gorm:"default:true;not null" json:"is_active"`
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt    `gorm:"index" json:"-"`
+}
+
+func (a *LedgerAccount) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return nil
+}
+
+type LedgerEntryType string
+
+const (
+	LedgerIncome  LedgerEntryType = "income"
+	LedgerExpense LedgerEntryType = "expense"
+)
+
+type LedgerCategory struct {
+	ID               uuid.UUID         `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Code             string            `gorm:"type:varchar(50);uniqueIndex;not null" json:"code"`
+	Name             string            `gorm:"type:varchar(255);not null" json:"name"`
+	Type             LedgerEntryType   `gorm:"type:varchar(20);not null;index" json:"type"`
+	DonationCategory *DonationCategory `gorm:"type:varchar(50);uniqueIndex" json:"donation_category,omitempty"` // confirmed donations of this category post here
+	IsActive         bool              `gorm:"default:true;not null" json:"is_active"`
+	CreatedAt        time.Time         `json:"created_at"`
+	UpdatedAt        time.Time         `json:"updated_at"`
+}
+
+func (c *LedgerCategory) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	return nil
+}
+
+type LedgerEntrySource string
+
+const (
+	LedgerSourceManual         LedgerEntrySource = "manual"
+	LedgerSourceDonation       LedgerEntrySource = "donation"
+	LedgerSourceDonationRefund LedgerEntrySource = "donation_refund"
+	LedgerSourceDistribution   LedgerEntrySource = "distribution"
+	LedgerSourceWakafYield     LedgerEntrySource = "wakaf_yield"
+	LedgerSourceFundRequest    LedgerEntrySource = "fund_request"
+)
+
+type LedgerEntry struct {
+	ID          uuid.UUID         `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	AccountID   uuid.UUID         `gorm:"type:uuid;not null;index" json:"account_id"`
+	CategoryID  uuid.UUID         `gorm:"type:uuid;not null;index" json:"category_id"`
+	Type        LedgerEntryType   `gorm:"type:varchar(20);not null;index" json:"type"`
+	Amount      Money             `gorm:"type:bigint;not null" json:"amount"`
+	EntryDate   time.Time         `gorm:"type:date;not null;index" json:"entry_date"`
+	Description string            `gorm:"type:text;not null" json:"description"`
+	Reference   *string           `gorm:"type:varchar(100)" json:"reference,omitempty"`
+	Source      LedgerEntrySource `gorm:"type:varchar(30);default:'manual';not null;uniqueIndex:idx_ledger_donation_source" json:"source"`
+	DonationID  *uuid.UUID        `gorm:"type:uuid;uniqueIndex:idx_ledger_donation_source" json:"donation_id,omitempty"`
+	Attachments Gallery           `gorm:"type:jsonb" json:"attachments,omitempty"` // private storage keys
+	CreatedBy   *uuid.UUID        `gorm:"type:uuid;index" json:"created_by,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+
+	Account  LedgerAccount  `gorm:"foreignKey:AccountID" json:"account,omitempty"`
+	Category LedgerCategory `gorm:"foreignKey:CategoryID" json:"category,omitempty"`
+	Creator  *User          `gorm:"foreignKey:CreatedBy" json:"creator,omitempty"`
+}
+
+func (e *LedgerEntry) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return nil
+}
+
+// SignedAmount is the entry's effect on its account balance.
+func (e *LedgerEntry) SignedAmount() Money {
+	if e.Type == LedgerExpense {
+		return -e.Amount
+	}
+	return e.Amount
+}
@@ -0,0 +1,163 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount of rupiah stored in minor units (1/100 rupiah) as an
+// integer, so totals are exact no matter how many donations are summed. It is
+// written to JSON as a plain decimal number, e.g. 100037 or 2500.50.
+type Money int64
+
+const Rupiah Money = 100
+
+var ErrInvalidMoney = errors.New("invalid money amount")
+
+func NewMoney(rupiah int64) Money {
+	return Money(rupiah) * Rupiah
+}
+
+// ParseMoney parses a decimal string with at most two fractional digits
+// without going through floating point.
+func ParseMoney(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+
+	whole, frac, hasFrac := strings.Cut(s, ".")
+	if whole == "" && !hasFrac || hasFrac && (frac == "" || len(frac) > 2) {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidMoney, s)
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	for len(frac) < 2 {
+		frac += "0"
+	}
+
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || units > math.MaxInt64/int64(Rupiah)-1 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidMoney, s)
+	}
+	cents, err := strconv.ParseInt(frac, 10, 64)
+	if err != nil || strings.ContainsAny(whole+frac, "+-") {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidMoney, s)
+	}
+
+	m := Money(units)*Rupiah + Money(cents)
+	if negative {
+		m = -m
+	}
+	return m, nil
+}
+
+// Rupiah returns the whole rupiah part of m.
+func (m Money) Rupiah() int64 {
+	return int64(m / Rupiah)
+}
+
+func (m Money) IsWholeRupiah() bool {
+	return m%Rupiah == 0
+}
+
+// String renders m as a decimal number, omitting the fraction for whole
+// rupiah amounts: "100037", "2500.50".
+func (m Money) String() string {
+	sign := ""
+	if m < 0 {
+		sign = "-"
+		m = -m
+	}
+	if m.IsWholeRupiah() {
+		return fmt.Sprintf("%s%d", sign, m/Rupiah)
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, m/Rupiah, m%Rupiah)
+}
+
+// Format renders m the way it is written in Indonesia: Rp100.037 or Rp2.500,50.
+func (m Money) Format() string {
+	sign := ""
+	if m < 0 {
+		sign = "-"
+		m = -m
+	}
+
+	digits := strconv.FormatInt(int64(m/Rupiah), 10)
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(d)
+	}
+	if !m.IsWholeRupiah() {
+		fmt.Fprintf(&b, ",%02d", m%Rupiah)
+	}
+	return sign + "Rp" + b.String()
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a numeric string. Numbers are parsed
+// from their literal text, so 0.1 stays exactly 10 minor units.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "null" || s == "" {
+		return nil
+	}
+	parsed, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+func (m Money) Value() (driver.Value, error) {
+	return int64(m), nil
+}
+
+// Scan reads bigint columns as well as the numeric results of SUM/AVG.
+func (m *Money) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*m = 0
+	case int64:
+		*m = Money(v)
+	case float64:
+		*m = Money(math.Round(v))
+	case []byte:
+		return m.scanNumeric(string(v))
+	case string:
+		return m.scanNumeric(v)
+	default:
+		return fmt.Errorf("%w: cannot scan %T", ErrInvalidMoney, value)
+	}
+	return nil
+}
+
+// scanNumeric reads a database numeric that is already in minor units; any
+// fraction (e.g. from AVG) is rounded half away from zero.
+func (m *Money) scanNumeric(s string) error {
+	whole, frac, _ := strings.Cut(s, ".")
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: %q", ErrInvalidMoney, s)
+	}
+	if frac != "" && frac[0] >= '5' {
+		if strings.HasPrefix(whole, "-") {
+			units--
+		} else {
+			units++
+		}
+	}
+	*m = Money(units)
+	return nil
+}
@@ -0,0 +1,138 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Money
+		wantErr bool
+	}{
+		{"100037", NewMoney(100037), false},
+		{"2500.5", NewMoney(2500) + 50, false},
+		{"2500.50", NewMoney(2500) + 50, false},
+		{"0.01", 1, false},
+		{".75", 75, false},
+		{" 12 ", NewMoney(12), false},
+		{"-45000.25", -(NewMoney(45000) + 25), false},
+		{"-0.5", -50, false},
+		{"1.005", 0, true}, // fractions of a sen aren't money
+		{"1.", 0, true},
+		{"", 0, true},
+		{"-", 0, true},
+		{"+5", 0, true},
+		{"--5", 0, true},
+		{"1,000", 0, true},
+		{"1e3", 0, true},
+		{"92233720368547758", 0, true}, // overflows in minor units
+	}
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseMoney(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if err != nil && !errors.Is(err, ErrInvalidMoney) {
+			t.Errorf("ParseMoney(%q) error = %v, want %v", tt.in, err, ErrInvalidMoney)
+		}
+		if got != tt.want {
+			t.Errorf("ParseMoney(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyFormat(t *testing.T) {
+	tests := []struct {
+		m              Money
+		str, formatted string
+	}{
+		{0, "0", "Rp0"},
+		{NewMoney(100037), "100037", "Rp100.037"},
+		{NewMoney(1000000), "1000000", "Rp1.000.000"},
+		{NewMoney(2500) + 50, "2500.50", "Rp2.500,50"},
+		{5, "0.05", "Rp0,05"},
+		{-(NewMoney(1250000) + 5), "-1250000.05", "-Rp1.250.000,05"},
+	}
+	for _, tt := range tests {
+		if got := tt.m.String(); got != tt.str {
+			t.Errorf("%d.String() = %q, want %q", tt.m, got, tt.str)
+		}
+		if got := tt.m.Format(); got != tt.formatted {
+			t.Errorf("%d.Format() = %q, want %q", tt.m, got, tt.formatted)
+		}
+		if parsed, err := ParseMoney(tt.m.String()); err != nil || parsed != tt.m {
+			t.Errorf("ParseMoney(%q) = %d, %v, want it back unchanged", tt.m.String(), parsed, err)
+		}
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	var v struct {
+		Amount Money `json:"amount"`
+	}
+	tests := []struct {
+		in      string
+		want    Money
+		wantErr bool
+	}{
+		{`{"amount":100037}`, NewMoney(100037), false},
+		{`{"amount":0.1}`, 10, false}, // read from the literal, not through float64
+		{`{"amount":"2500.50"}`, NewMoney(2500) + 50, false},
+		{`{"amount":-75000}`, -NewMoney(75000), false},
+		{`{"amount":null}`, 0, false},
+		{`{"amount":12.345}`, 0, true},
+		{`{"amount":"sepuluh"}`, 0, true},
+	}
+	for _, tt := range tests {
+		v.Amount = 0
+		err := json.Unmarshal([]byte(tt.in), &v)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Unmarshal(%s) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if v.Amount != tt.want {
+			t.Errorf("Unmarshal(%s) = %d, want %d", tt.in, v.Amount, tt.want)
+		}
+	}
+
+	v.Amount = -(NewMoney(2500) + 50)
+	out, err := json.Marshal(v)
+	if err != nil || string(out) != `{"amount":-2500.50}` {
+		t.Errorf("Marshal = %s, %v, want {\"amount\":-2500.50}", out, err)
+	}
+}
+
+func TestMoneyScan(t *testing.T) {
+	tests := []struct {
+		in      interface{}
+		want    Money
+		wantErr bool
+	}{
+		{nil, 0, false},
+		{int64(150037), 150037, false},
+		{float64(149.5), 150, false},
+		{[]byte("150037"), 150037, false},
+		{"-150037", -150037, false},
+		{"1500.4999", 1500, false}, // AVG of minor units
+		{"1500.5", 1501, false},
+		{"-1500.5", -1501, false}, // half away from zero
+		{"-0.5", -1, false},
+		{"abc", 0, true},
+		{true, 0, true},
+	}
+	for _, tt := range tests {
+		var m Money
+		err := m.Scan(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Scan(%#v) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if m != tt.want {
+			t.Errorf("Scan(%#v) = %d, want %d", tt.in, m, tt.want)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Asnaf are the eight groups entitled to receive zakat (QS At-Taubah: 60).
+type Asnaf string
+
+const (
+	AsnafFakir        Asnaf = "fakir"
+	AsnafMiskin       Asnaf = "miskin"
+	AsnafAmil         Asnaf = "amil"
+	AsnafMualaf       Asnaf = "mualaf"
+	AsnafRiqab        Asnaf = "riqab"
+	AsnafGharimin     Asnaf = "gharimin"
+	AsnafFisabilillah Asnaf = "fisabilillah"
+	AsnafIbnuSabil    Asnaf = "ibnu_sabil"
+)
+
+var AllAsnaf = []Asnaf{
+	AsnafFakir, AsnafMiskin, AsnafAmil, AsnafMualaf,
+	AsnafRiqab, AsnafGharimin, AsnafFisabilillah, AsnafIbnuSabil,
+}
+
+func (a Asnaf) Valid() bool {
+	for _, asnaf := range AllAsnaf {
+		if a == asnaf {
+			return true
+		}
+	}
+	return false
+}
+
+type VerificationStatus string
+
+const (
+	VerificationPending  VerificationStatus = "pending"
+	VerificationVerified VerificationStatus = "verified"
+	VerificationRejected VerificationStatus = "rejected"
+)
+
+// Mustahik is a registered recipient of zakat and aid.
+type Mustahik struct {
+	ID                 uuid.UUID          `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Name               string             `gorm:"type:varchar(255);not null" json:"name"`
+	NIK                *string            `gorm:"type:varchar(20);uniqueIndex" json:"nik,omitempty"` // national ID number
+	Phone              *string            `gorm:"type:varchar(20)" json:"phone,omitempty"`
+	Address            string             `gorm:"type:text;not null" json:"address"`
+	Asnaf              Asnaf              `gorm:"type:varchar(20);not null;index" json:"asnaf"`
+	HouseholdSize      int                `gorm:"default:1;not null" json:"household_size"`
+	Dependents         int                `gorm:"default:0;not null" json:"dependents"`
+	Occupation         string             `gorm:"type:varchar(255)" json:"occupation"`
+	MonthlyIncome      Money              `gorm:"type:bigint;default:0;not null" json:"monthly_income"`
+	HousingStatus      string             `gorm:"type:varchar(50)" json:"housing_status"` // milik sendiri, sewa, menumpang
+	Notes              string             `gorm:"type:text" json:"notes"`
+	VerificationStatus VerificationStatus `gorm:"type:varchar(20);default:'pending';not null;index" json:"verification_status"`
+	VerificationNotes  string             `gorm:"type:text" json:"verification_notes"`
+	VerifiedBy         *uuid.UUID         `gorm:"type:uuid" json:"verified_by,omitempty"`
+	VerifiedAt         *time.Time         `json:"verified_at,omitempty"`
+	CreatedAt          time.Time          `json:"created_at"`
+	UpdatedAt          time.Time          `json:"updated_at"`
+	DeletedAt          gorm.DeletedAt     `gorm:"index" json:"-"`
+
+	Verifier *User `gorm:"foreignKey:VerifiedBy" json:"verifier,omitempty"`
+}
+
+func (m *Mustahik) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == uuid.Nil {
+		m.ID = uuid.New()
+	}
+	return nil
+}
+
+// Distribution records zakat or aid handed to a mustahik, paid from the funds
+// of a donation category. Cash distributions are booked as ledger expenses.
+type Distribution struct {
+	ID             uuid.UUID        `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	MustahikID     uuid.UUID        `gorm:"type:uuid;not null;index" json:"mustahik_id"`
+	SourceCategory DonationCategory `gorm:"type:varchar(50);not null;index" json:"source_category"`
+	Asnaf          Asnaf            `gorm:"type:varchar(20);not null;index" json:"asnaf"` // the mustahik's asnaf when distributed
+	Amount         Money            `gorm:"type:bigint;default:0;not null" json:"amount"`
+	RiceKg         float64          `gorm:"type:decimal(10,2);default:0;not null" json:"rice_kg"`
+	Description    string           `gorm:"type:text" json:"description"`
+	DistributedAt  time.Time        `gorm:"type:date;not null;index" json:"distributed_at"`
+	AccountID      *uuid.UUID       `gorm:"type:uuid" json:"account_id,omitempty"`
+	LedgerEntryID  *uuid.UUID       `gorm:"type:uuid" json:"ledger_entry_id,omitempty"`
+	CreatedBy      uuid.UUID        `gorm:"type:uuid;not null" json:"created_by"`
+	CreatedAt      time.Time        `json:"created_at"`
+	UpdatedAt      time.Time        `json:"updated_at"`
+
+	Mustahik Mustahik `gorm:"foreignKey:MustahikID" json:"mustahik,omitempty"`
+	Creator  User     `gorm:"foreignKey:CreatedBy" json:"creator,omitempty"`
+}
+
+func (d *Distribution) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	return nil
+}
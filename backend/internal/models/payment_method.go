@@ -22,6 +22,7 @@ type PaymentMethod struct {
 	AccountNumber *string          `gorm:"type:varchar(100)" json:"account_number,omitempty"`
 	AccountName   *string          `gorm:"type:varchar(255)" json:"account_name,omitempty"`
 	QRCodeURL     *string          `gorm:"type:varchar(500)" json:"qr_code_url,omitempty"`
+	QRISPayload   *string          `gorm:"type:text" json:"qris_payload,omitempty"` // static merchant QRIS string
 	Instructions  string           `gorm:"type:text" json:"instructions"`
 	IsActive      bool             `gorm:"default:true;not null" json:"is_active"`
 	DisplayOrder  int              `gorm:"default:0;not null;index" json:"display_order"`
@@ -0,0 +1,88 @@
+package models
+
+import "sort"
+
+// Permission is an action on the admin API. Routes require a permission
+// rather than a role, so adding a role only means listing what it may do.
+type Permission string
+
+const (
+	PermMosqueEdit         Permission = "mosque.edit"          // mosque profile, structure, prayer times
+	PermContentEdit        Permission = "content.edit"         // content sections, events, announcements, campaigns, uploads
+	PermDonationsView      Permission = "donations.view"       // donations, donors, pledges, reports
+	PermDonationsManage    Permission = "donations.manage"     // reject, cancel, expire, donors, pledges
+	PermDonationsConfirm   Permission = "donations.confirm"    // confirm, refund, reconcile bank mutations
+	PermFinanceView        Permission = "finance.view"         // cash book, budgets, fund requests
+	PermFinanceManage      Permission = "finance.manage"       // cash book entries, accounts and categories
+	PermFinanceApprove     Permission = "finance.approve"      // approvals, budgets, fund request decisions and disbursement
+	PermFundRequestsSubmit Permission = "fund_requests.submit" // request money for a division and report its use
+	PermZakatManage        Permission = "zakat.manage"         // muzakki, zakat payments, mustahik, distributions
+	PermQurbanManage       Permission = "qurban.manage"
+	PermWakafManage        Permission = "wakaf.manage"
+	PermCharityBoxCount    Permission = "charity_box.count" // count and sign off kotak amal
+	PermSettingsManage     Permission = "settings.manage"   // settings and payment methods
+	PermUsersManage        Permission = "users.manage"
+)
+
+var AllPermissions = []Permission{
+	PermMosqueEdit, PermContentEdit,
+	PermDonationsView, PermDonationsManage, PermDonationsConfirm,
+	PermFinanceView, PermFinanceManage, PermFinanceApprove, PermFundRequestsSubmit,
+	PermZakatManage, PermQurbanManage, PermWakafManage, PermCharityBoxCount,
+	PermSettingsManage, PermUsersManage,
+}
+
+// allExcept lists every permission but the given ones.
+func allExcept(excluded ...Permission) []Permission {
+	var perms []Permission
+	for _, p := range AllPermissions {
+		keep := true
+		for _, e := range excluded {
+			keep = keep && p != e
+		}
+		if keep {
+			perms = append(perms, p)
+		}
+	}
+	return perms
+}
+
+// RolePermissions is the permission matrix. Approving money is kept with the
+// treasurers, so large confirmations and expenses always take a second
+// person, however senior the one who entered them.
+var RolePermissions = map[UserRole][]Permission{
+	// Deliberately without finance.approve. A user has a single role, so
+	// approving means holding the treasurer role, which doesn't manage users
+	// or settings: nobody can both run the site and sign off money.
+	RoleSuperAdmin: allExcept(PermFinanceApprove),
+	RoleAdmin:      allExcept(PermFinanceApprove),
+	RoleEditor: {
+		PermMosqueEdit, PermContentEdit, PermFundRequestsSubmit,
+	},
+	RoleTreasurer: {
+		PermDonationsView, PermDonationsManage, PermDonationsConfirm,
+		PermFinanceView, PermFinanceManage, PermFinanceApprove, PermFundRequestsSubmit,
+		PermZakatManage, PermWakafManage, PermCharityBoxCount,
+	},
+}
+
+func IsValidRole(role UserRole) bool {
+	_, ok := RolePermissions[role]
+	return ok
+}
+
+func (r UserRole) Can(p Permission) bool {
+	for _, granted := range RolePermissions[r] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Permissions returns the role's permissions in a stable order.
+func (r UserRole) Permissions() []Permission {
+	perms := append([]Permission{}, RolePermissions[r]...)
+	sort.Slice(perms, func(i, j int) bool { return perms[i] < perms[j] })
+	return perms
+}
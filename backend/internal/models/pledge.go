@@ -0,0 +1,102 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type PledgeFrequency string
+
+const (
+	PledgeMonthly   PledgeFrequency = "monthly"
+	PledgeQuarterly PledgeFrequency = "quarterly"
+	PledgeYearly    PledgeFrequency = "yearly"
+)
+
+// Months returns how many months lie between two installments, or 0 for an
+// unknown frequency.
+func (f PledgeFrequency) Months() int {
+	switch f {
+	case PledgeMonthly:
+		return 1
+	case PledgeQuarterly:
+		return 3
+	case PledgeYearly:
+		return 12
+	}
+	return 0
+}
+
+type PledgeStatus string
+
+const (
+	PledgeActive PledgeStatus = "active"
+	PledgePaused PledgeStatus = "paused"
+	PledgeEnded  PledgeStatus = "ended"
+)
+
+// Pledge is a donor's promise to give a fixed amount regularly, e.g. infaq
+// Rp200.000 every 25th. DayOfMonth past the end of a short month falls on its
+// last day.
+type Pledge struct {
+	ID           uuid.UUID        `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	DonorID      uuid.UUID        `gorm:"type:uuid;not null;index" json:"donor_id"`
+	Amount       Money            `gorm:"type:bigint;not null" json:"amount"`
+	Category     DonationCategory `gorm:"type:varchar(50);not null" json:"category"`
+	CampaignID   *uuid.UUID       `gorm:"type:uuid" json:"campaign_id,omitempty"`
+	Frequency    PledgeFrequency  `gorm:"type:varchar(20);default:'monthly';not null" json:"frequency"`
+	DayOfMonth   int              `gorm:"not null" json:"day_of_month"`
+	StartDate    time.Time        `gorm:"type:date;not null" json:"start_date"`
+	EndDate      *time.Time       `gorm:"type:date" json:"end_date,omitempty"`
+	Status       PledgeStatus     `gorm:"type:varchar(20);default:'active';not null;index" json:"status"`
+	ScheduleFrom time.Time        `gorm:"type:date;not null" json:"schedule_from"` // moves forward on resume so a pause isn't back-filled
+	Notes        string           `gorm:"type:text" json:"notes"`
+	CreatedBy    *uuid.UUID       `gorm:"type:uuid" json:"created_by,omitempty"`
+	CreatedAt    time.Time        `json:"created_at"`
+	UpdatedAt    time.Time        `json:"updated_at"`
+
+	Donor        *Donor              `gorm:"foreignKey:DonorID" json:"donor,omitempty"`
+	Campaign     *Campaign           `gorm:"foreignKey:CampaignID" json:"campaign,omitempty"`
+	Installments []PledgeInstallment `gorm:"foreignKey:PledgeID" json:"installments,omitempty"`
+}
+
+func (p *Pledge) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	return nil
+}
+
+type PledgeInstallmentStatus string
+
+const (
+	InstallmentExpected  PledgeInstallmentStatus = "expected"
+	InstallmentFulfilled PledgeInstallmentStatus = "fulfilled"
+	InstallmentMissed    PledgeInstallmentStatus = "missed"
+	InstallmentCancelled PledgeInstallmentStatus = "cancelled"
+)
+
+// PledgeInstallment is one expected payment of a pledge, backed by a pending
+// donation the donor pays like any other.
+type PledgeInstallment struct {
+	ID         uuid.UUID               `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	PledgeID   uuid.UUID               `gorm:"type:uuid;not null;uniqueIndex:idx_pledge_installment_due" json:"pledge_id"`
+	DueDate    time.Time               `gorm:"type:date;not null;uniqueIndex:idx_pledge_installment_due;index" json:"due_date"`
+	DonationID uuid.UUID               `gorm:"type:uuid;not null;uniqueIndex" json:"donation_id"`
+	Status     PledgeInstallmentStatus `gorm:"type:varchar(20);default:'expected';not null;index" json:"status"`
+	RemindedAt *time.Time              `json:"reminded_at,omitempty"`
+	CreatedAt  time.Time               `json:"created_at"`
+	UpdatedAt  time.Time               `json:"updated_at"`
+
+	Pledge   *Pledge   `gorm:"foreignKey:PledgeID" json:"pledge,omitempty"`
+	Donation *Donation `gorm:"foreignKey:DonationID" json:"donation,omitempty"`
+}
+
+func (i *PledgeInstallment) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == uuid.Nil {
+		i.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type QurbanSpecies string
+
+const (
+	QurbanGoat  QurbanSpecies = "goat"
+	QurbanSheep QurbanSpecies = "sheep"
+	QurbanCow   QurbanSpecies = "cow"
+	QurbanCamel QurbanSpecies = "camel"
+)
+
+// MaxQurbanShares is how many shohibul may share one cow or camel.
+const MaxQurbanShares = 7
+
+// QurbanAnimalType is an animal offered in a given Idul Adha, e.g. "Sapi
+// Standar 2026". Price is per share; goats and sheep have a single share.
+type QurbanAnimalType struct {
+	ID              uuid.UUID     `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Year            int           `gorm:"not null;index" json:"year"`
+	Name            string        `gorm:"type:varchar(255);not null" json:"name"`
+	Species         QurbanSpecies `gorm:"type:varchar(20);not null" json:"species"`
+	Description     string        `gorm:"type:text" json:"description"`
+	Price           Money         `gorm:"type:bigint;not null" json:"price"`
+	SharesPerAnimal int           `gorm:"default:1;not null" json:"shares_per_animal"`
+	Quota           int           `gorm:"default:0;not null" json:"quota"` // most animals to buy, 0 for no limit
+	IsActive        bool          `gorm:"default:true;not null" json:"is_active"`
+	CreatedAt       time.Time     `json:"created_at"`
+	UpdatedAt       time.Time     `json:"updated_at"`
+}
+
+func (t *QurbanAnimalType) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
+
+type QurbanAnimalStatus string
+
+const (
+	QurbanAnimalWaiting     QurbanAnimalStatus = "waiting"
+	QurbanAnimalSlaughtered QurbanAnimalStatus = "slaughtered"
+	QurbanAnimalDistributed QurbanAnimalStatus = "distributed"
+)
+
+type QurbanAnimal struct {
+	ID            uuid.UUID          `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	TypeID        uuid.UUID          `gorm:"type:uuid;not null;index" json:"type_id"`
+	Year          int                `gorm:"not null;uniqueIndex:idx_qurban_animal_tag" json:"year"`
+	TagNumber     string             `gorm:"type:varchar(50);not null;uniqueIndex:idx_qurban_animal_tag" json:"tag_number"`
+	WeightKg      *float64           `gorm:"type:decimal(8,2)" json:"weight_kg,omitempty"`
+	Status        QurbanAnimalStatus `gorm:"type:varchar(20);default:'waiting';not null;index" json:"status"`
+	SlaughteredAt *time.Time         `json:"slaughtered_at,omitempty"`
+	Notes         string             `gorm:"type:text" json:"notes"`
+	CreatedAt     time.Time          `json:"created_at"`
+	UpdatedAt     time.Time          `json:"updated_at"`
+
+	Type   QurbanAnimalType `gorm:"foreignKey:TypeID" json:"type,omitempty"`
+	Shares []QurbanShare    `gorm:"foreignKey:AnimalID" json:"shares,omitempty"`
+}
+
+func (a *QurbanAnimal) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return nil
+}
+
+// QurbanShare is one shohibul qurban's part of an animal, paid for through a
+// qurban donation. A share holds its slot while the donation is pending or
+// confirmed; if the donation fails the slot is free again.
+type QurbanShare struct {
+	ID           uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	AnimalID     uuid.UUID `gorm:"type:uuid;not null;index" json:"animal_id"`
+	ShareNumber  int       `gorm:"not null" json:"share_number"` // 1..shares per animal
+	DonationID   uuid.UUID `gorm:"type:uuid;not null;uniqueIndex" json:"donation_id"`
+	ShohibulName string    `gorm:"type:varchar(255);not null" json:"shohibul_name"`
+	OnBehalfOf   string    `gorm:"type:varchar(255)" json:"on_behalf_of"` // atas nama, if not the payer
+	Phone        *string   `gorm:"type:varchar(20)" json:"phone,omitempty"`
+	Address      *string   `gorm:"type:text" json:"address,omitempty"`
+	WantsMeat    bool      `gorm:"default:false;not null" json:"wants_meat"` // shohibul takes up to a third of the meat
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+
+	Animal   *QurbanAnimal `gorm:"foreignKey:AnimalID" json:"animal,omitempty"`
+	Donation *Donation     `gorm:"foreignKey:DonationID" json:"donation,omitempty"`
+}
+
+func (s *QurbanShare) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return nil
+}
+
+// QurbanCoupon is a meat coupon (kupon daging) handed to a recipient before
+// Idul Adha and exchanged for a meat package.
+type QurbanCoupon struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Year          int        `gorm:"not null;index" json:"year"`
+	Code          string     `gorm:"type:varchar(20);uniqueIndex;not null" json:"code"`
+	RecipientName string     `gorm:"type:varchar(255)" json:"recipient_name"`
+	MustahikID    *uuid.UUID `gorm:"type:uuid;index" json:"mustahik_id,omitempty"`
+	RedeemedAt    *time.Time `json:"redeemed_at,omitempty"`
+	RedeemedBy    *uuid.UUID `gorm:"type:uuid" json:"redeemed_by,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+func (c *QurbanCoupon) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	return nil
+}
@@ -13,6 +13,7 @@ const (
 	RoleSuperAdmin UserRole = "super_admin"
 	RoleAdmin      UserRole = "admin"
 	RoleEditor     UserRole = "editor"
+	RoleTreasurer  UserRole = "treasurer" // bendahara, approves large confirmations and expenses
 )
 
 type User struct {
@@ -25,6 +26,8 @@ type User struct {
 	AvatarURL   *string         `gorm:"type:varchar(500)" json:"avatar_url,omitempty"`
 	IsActive    bool            `gorm:"default:true;not null" json:"is_active"`
 	LastLoginAt *time.Time      `json:"last_login_at,omitempty"`
+	MustChangePassword bool     `gorm:"default:false;not null" json:"must_change_password"` // temporary or default password
+	PasswordChangedAt  *time.Time `json:"password_changed_at,omitempty"`
 	CreatedAt   time.Time       `json:"created_at"`
 	UpdatedAt   time.Time       `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt  `gorm:"index" json:"-"`
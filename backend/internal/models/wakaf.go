@@ -0,0 +1,137 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type WakafAssetType string
+
+const (
+	WakafLand      WakafAssetType = "land"
+	WakafBuilding  WakafAssetType = "building"
+	WakafQuran     WakafAssetType = "quran"
+	WakafEquipment WakafAssetType = "equipment"
+	WakafVehicle   WakafAssetType = "vehicle"
+	WakafCash      WakafAssetType = "cash" // wakaf uang kept as an endowment
+	WakafOther     WakafAssetType = "other"
+)
+
+var AllWakafAssetTypes = []WakafAssetType{
+	WakafLand, WakafBuilding, WakafQuran, WakafEquipment, WakafVehicle, WakafCash, WakafOther,
+}
+
+func (t WakafAssetType) Valid() bool {
+	for _, assetType := range AllWakafAssetTypes {
+		if t == assetType {
+			return true
+		}
+	}
+	return false
+}
+
+// WakafLegalStatus follows an asset from the wakif's declaration to the
+// land office certificate.
+type WakafLegalStatus string
+
+const (
+	WakafPledged    WakafLegalStatus = "pledged"    // ikrar made, not yet formalised
+	WakafRegistered WakafLegalStatus = "registered" // Akta Ikrar Wakaf signed before the PPAIW
+	WakafCertified  WakafLegalStatus = "certified"  // sertifikat wakaf issued
+)
+
+type WakafAssetStatus string
+
+const (
+	WakafFundraising WakafAssetStatus = "fundraising" // being paid for with cash wakaf
+	WakafActive      WakafAssetStatus = "active"
+	WakafInactive    WakafAssetStatus = "inactive" // e.g. lost, damaged or exchanged (ruislag)
+)
+
+// Nazhir is a custodian entrusted with managing wakaf assets.
+type Nazhir struct {
+	ID        uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Name      string         `gorm:"type:varchar(255);not null" json:"name"`
+	Position  string         `gorm:"type:varchar(100)" json:"position"` // e.g. ketua, sekretaris
+	NIK       *string        `gorm:"type:varchar(20)" json:"nik,omitempty"`
+	Phone     *string        `gorm:"type:varchar(20)" json:"phone,omitempty"`
+	Email     *string        `gorm:"type:varchar(255)" json:"email,omitempty"`
+	Address   string         `gorm:"type:text" json:"address"`
+	IsActive  bool           `gorm:"default:true;not null" json:"is_active"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (n *Nazhir) BeforeCreate(tx *gorm.DB) error {
+	if n.ID == uuid.Nil {
+		n.ID = uuid.New()
+	}
+	return nil
+}
+
+// WakafAsset is an endowed asset. Assets given by one wakif record them
+// directly; assets paid for with cash wakaf are funded by donations linked
+// through Donation.WakafAssetID, whose donors are the wakif.
+type WakafAsset struct {
+	ID                uuid.UUID        `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Name              string           `gorm:"type:varchar(255);not null" json:"name"`
+	Type              WakafAssetType   `gorm:"type:varchar(20);not null;index" json:"type"`
+	Description       string           `gorm:"type:text" json:"description"`
+	Location          string           `gorm:"type:text" json:"location"`
+	AreaM2            *float64         `gorm:"type:decimal(12,2)" json:"area_m2,omitempty"` // land and buildings
+	Quantity          int              `gorm:"default:1;not null" json:"quantity"`          // e.g. copies of the Qur'an
+	WakifName         string           `gorm:"type:varchar(255)" json:"wakif_name"`
+	WakifDonorID      *uuid.UUID       `gorm:"type:uuid;index" json:"wakif_donor_id,omitempty"`
+	WakifAddress      string           `gorm:"type:text" json:"wakif_address"`
+	PledgedAt         *time.Time       `gorm:"type:date" json:"pledged_at,omitempty"` // date of the ikrar wakaf
+	Purpose           string           `gorm:"type:text" json:"purpose"`              // peruntukan, as stated in the ikrar
+	LegalStatus       WakafLegalStatus `gorm:"type:varchar(20);default:'pledged';not null" json:"legal_status"`
+	AIWNumber         *string          `gorm:"type:varchar(100)" json:"aiw_number,omitempty"`         // Akta Ikrar Wakaf
+	CertificateNumber *string          `gorm:"type:varchar(100)" json:"certificate_number,omitempty"` // sertifikat wakaf
+	Documents         Gallery          `gorm:"type:jsonb" json:"documents,omitempty"`                 // private storage keys of scans
+	NazhirID          *uuid.UUID       `gorm:"type:uuid;index" json:"nazhir_id,omitempty"`
+	Valuation         Money            `gorm:"type:bigint;default:0;not null" json:"valuation"`
+	ValuationDate     *time.Time       `gorm:"type:date" json:"valuation_date,omitempty"`
+	TargetAmount      Money            `gorm:"type:bigint;default:0;not null" json:"target_amount"` // cash wakaf sought, 0 if none
+	IsProductive      bool             `gorm:"default:false;not null" json:"is_productive"`         // yields go to the stated purpose
+	Status            WakafAssetStatus `gorm:"type:varchar(20);default:'active';not null;index" json:"status"`
+	HideFromPublic    bool             `gorm:"default:false;not null" json:"hide_from_public"`
+	CreatedBy         *uuid.UUID       `gorm:"type:uuid" json:"created_by,omitempty"`
+	CreatedAt         time.Time        `json:"created_at"`
+	UpdatedAt         time.Time        `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt   `gorm:"index" json:"-"`
+
+	Nazhir     *Nazhir `gorm:"foreignKey:NazhirID" json:"nazhir,omitempty"`
+	WakifDonor *Donor  `gorm:"foreignKey:WakifDonorID" json:"wakif_donor,omitempty"`
+}
+
+func (a *WakafAsset) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return nil
+}
+
+// WakafYield is income produced by a productive wakaf asset, such as rent
+// from a shop or the harvest of a field.
+type WakafYield struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	AssetID       uuid.UUID  `gorm:"type:uuid;not null;index" json:"asset_id"`
+	Amount        Money      `gorm:"type:bigint;not null" json:"amount"`
+	ReceivedAt    time.Time  `gorm:"type:date;not null;index" json:"received_at"`
+	Description   string     `gorm:"type:text" json:"description"`
+	AccountID     *uuid.UUID `gorm:"type:uuid" json:"account_id,omitempty"`
+	LedgerEntryID *uuid.UUID `gorm:"type:uuid" json:"ledger_entry_id,omitempty"`
+	CreatedBy     uuid.UUID  `gorm:"type:uuid;not null" json:"created_by"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+func (y *WakafYield) BeforeCreate(tx *gorm.DB) error {
+	if y.ID == uuid.Nil {
+		y.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Muzakki is a person who pays zakat through the mosque.
+type Muzakki struct {
+	ID        uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Name      string         `gorm:"type:varchar(255);not null" json:"name"`
+	Phone     *string        `gorm:"type:varchar(20);index" json:"phone,omitempty"`
+	Email     *string        `gorm:"type:varchar(255)" json:"email,omitempty"`
+	Address   *string        `gorm:"type:text" json:"address,omitempty"`
+	Notes     string         `gorm:"type:text" json:"notes"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (m *Muzakki) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == uuid.Nil {
+		m.ID = uuid.New()
+	}
+	return nil
+}
+
+type ZakatType string
+
+const (
+	ZakatFitrah  ZakatType = "fitrah"
+	ZakatMaal    ZakatType = "maal"
+	ZakatProfesi ZakatType = "profesi"
+)
+
+// ZakatPayment is one payment received at the zakat counter. Fitrah may be
+// paid in rice, in cash or both; the cash part is booked as a confirmed zakat
+// donation so it reaches the cash book.
+type ZakatPayment struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	MuzakkiID   uuid.UUID  `gorm:"type:uuid;not null;index" json:"muzakki_id"`
+	Type        ZakatType  `gorm:"type:varchar(20);not null;index" json:"type"`
+	Year        int        `gorm:"not null;index" json:"year"`
+	PeopleCount int        `gorm:"default:1;not null" json:"people_count"`
+	PeopleNames Gallery    `gorm:"type:jsonb" json:"people_names,omitempty"` // whom fitrah is paid for
+	RiceKg      float64    `gorm:"type:decimal(10,2);default:0;not null" json:"rice_kg"`
+	CashAmount  Money      `gorm:"type:bigint;default:0;not null" json:"cash_amount"`
+	DonationID  *uuid.UUID `gorm:"type:uuid;uniqueIndex" json:"donation_id,omitempty"`
+	ReceivedBy  uuid.UUID  `gorm:"type:uuid;not null" json:"received_by"`
+	Notes       string     `gorm:"type:text" json:"notes"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	Muzakki  Muzakki   `gorm:"foreignKey:MuzakkiID" json:"muzakki,omitempty"`
+	Donation *Donation `gorm:"foreignKey:DonationID" json:"donation,omitempty"`
+	Receiver User      `gorm:"foreignKey:ReceivedBy" json:"receiver,omitempty"`
+}
+
+func (p *ZakatPayment) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,550 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SettingApprovalThreshold is the amount above which a donation confirmation
+// or expense needs a treasurer's approval. Approvals can't be turned off: it
+// is only changed through RequestApprovalThreshold, and a stored value that
+// isn't positive counts as the default.
+const SettingApprovalThreshold = "approval_threshold"
+
+var defaultApprovalThreshold = models.NewMoney(10000000)
+
+// approvalThresholdSubject stands for the threshold setting in approvals, so
+// there is at most one pending change to it.
+var approvalThresholdSubject = uuid.NewSHA1(uuid.NameSpaceURL, []byte("setting:"+SettingApprovalThreshold))
+
+var (
+	ErrApprovalPending    = errors.New("this is already waiting for a treasurer's approval")
+	ErrApprovalDecided    = errors.New("approval has already been decided")
+	ErrSelfApproval       = errors.New("you can't approve or reject your own request")
+	ErrApprovalNoteNeeded = errors.New("a note is required when rejecting")
+	ErrApprovalNotYours   = errors.New("only the requester can withdraw this request")
+	ErrApprovalExpense    = errors.New("the expense can no longer be posted")
+	ErrApprovalThreshold  = errors.New("the approval threshold must be more than zero")
+	ErrSettingProtected   = errors.New("this setting is changed by a treasurer and approved by another")
+)
+
+// ProtectedSetting tells whether key can't be changed through the generic
+// settings endpoint.
+func ProtectedSetting(key string) bool {
+	return key == SettingApprovalThreshold
+}
+
+// ApprovalThreshold returns the configured threshold.
+func ApprovalThreshold(db *gorm.DB) models.Money {
+	threshold := GetSettingMoney(db, SettingApprovalThreshold, defaultApprovalThreshold)
+	if threshold <= 0 {
+		return defaultApprovalThreshold
+	}
+	return threshold
+}
+
+// NeedsApproval reports whether amount is above the approval threshold.
+func NeedsApproval(db *gorm.DB, amount models.Money) bool {
+	return amount > ApprovalThreshold(db)
+}
+
+func createApproval(tx *gorm.DB, approval *models.Approval) error {
+	var pending int64
+	if err := tx.Model(&models.Approval{}).
+		Where("subject_id = ? AND status = ?", approval.SubjectID, models.ApprovalPending).
+		Count(&pending).Error; err != nil {
+		return err
+	}
+	if pending > 0 {
+		return ErrApprovalPending
+	}
+
+	approval.Status = models.ApprovalPending
+	return tx.Create(approval).Error
+}
+
+// RequestDonationConfirmation queues a pending donation for confirmation by
+// a treasurer other than actorID.
+func RequestDonationConfirmation(db *gorm.DB, donationID, actorID uuid.UUID, note string) (*models.Approval, error) {
+	return requestDonationConfirmation(db, donationID, actorID, note, nil)
+}
+
+// requestDonationConfirmation also takes the bank mutation that paid the
+// donation, if any, so approving confirms the mutation too.
+func requestDonationConfirmation(db *gorm.DB, donationID, actorID uuid.UUID, note string, mutationID *uuid.UUID) (*models.Approval, error) {
+	var approval models.Approval
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var donation models.Donation
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&donation, "id = ?", donationID).Error; err != nil {
+			return err
+		}
+		if !CanTransitionDonation(donation.Status, models.DonationStatusConfirmed) {
+			return fmt.Errorf("%w: %s to %s", ErrInvalidTransition, donation.Status, models.DonationStatusConfirmed)
+		}
+
+		approval = models.Approval{
+			Kind:        models.ApprovalDonationConfirmation,
+			SubjectID:   donation.ID,
+			Amount:      donation.Amount,
+			Summary:     fmt.Sprintf("Konfirmasi donasi %s %s dari %s", donation.DonationCode, donation.Category, donation.DonorName),
+			MutationID:  mutationID,
+			RequestedBy: actorID,
+			RequestNote: strings.TrimSpace(note),
+		}
+		return createApproval(tx, &approval)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &approval, nil
+}
+
+// RequestExpense queues a validated manual expense entry for approval. A new
+// entry gets its ID now so the approval can create it under that ID; an
+// existing entry is changed only once approved. That includes a large
+// expense being turned into another type of entry, whose amount would
+// otherwise leave the expenses without anyone noticing.
+func RequestExpense(db *gorm.DB, entry *models.LedgerEntry, actorID uuid.UUID, note string) (*models.Approval, error) {
+	subjectID := entry.ID
+	if subjectID == uuid.Nil {
+		subjectID = uuid.New()
+	}
+
+	summary := "Pengeluaran: " + entry.Description
+	var entryType models.LedgerEntryType
+	if entry.Type != models.LedgerExpense {
+		entryType = entry.Type
+		summary = fmt.Sprintf("Ubah pengeluaran menjadi %s: %s", entry.Type, entry.Description)
+	}
+
+	approval := models.Approval{
+		Kind:      models.ApprovalExpense,
+		SubjectID: subjectID,
+		Amount:    entry.Amount,
+		Summary:   summary,
+		Expense: &models.ExpenseProposal{
+			Type:        entryType,
+			AccountID:   entry.AccountID,
+			CategoryID:  entry.CategoryID,
+			Amount:      entry.Amount,
+			EntryDate:   entry.EntryDate,
+			Description: entry.Description,
+			Reference:   entry.Reference,
+		},
+		RequestedBy: actorID,
+		RequestNote: strings.TrimSpace(note),
+	}
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return createApproval(tx, &approval)
+	}); err != nil {
+		return nil, err
+	}
+	return &approval, nil
+}
+
+// RequestExpenseDeletion queues the deletion of a large manual expense; the
+// entry stays in the ledger until approved.
+func RequestExpenseDeletion(db *gorm.DB, entry *models.LedgerEntry, actorID uuid.UUID, note string) (*models.Approval, error) {
+	approval := models.Approval{
+		Kind:        models.ApprovalExpenseDeletion,
+		SubjectID:   entry.ID,
+		Amount:      entry.Amount,
+		Summary:     "Hapus pengeluaran: " + entry.Description,
+		RequestedBy: actorID,
+		RequestNote: strings.TrimSpace(note),
+	}
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return createApproval(tx, &approval)
+	}); err != nil {
+		return nil, err
+	}
+	return &approval, nil
+}
+
+// RequestDistribution queues a validated distribution for approval. The
+// mustahik and the funds are checked now so the request isn't pointless,
+// and again when it is approved.
+func RequestDistribution(db *gorm.DB, distribution *models.Distribution, actorID uuid.UUID, note string) (*models.Approval, error) {
+	if err := validateDistribution(distribution); err != nil {
+		return nil, err
+	}
+
+	var approval models.Approval
+	err := db.Transaction(func(tx *gorm.DB) error {
+		mustahik, err := checkDistribution(tx, distribution)
+		if err != nil {
+			return err
+		}
+
+		approval = models.Approval{
+			Kind:      models.ApprovalDistribution,
+			SubjectID: uuid.New(),
+			Amount:    distribution.Amount,
+			Summary:   fmt.Sprintf("Penyaluran %s kepada %s (%s)", distribution.SourceCategory, mustahik.Name, mustahik.Asnaf),
+			Distribution: &models.DistributionProposal{
+				MustahikID:     distribution.MustahikID,
+				SourceCategory: distribution.SourceCategory,
+				Amount:         distribution.Amount,
+				RiceKg:         distribution.RiceKg,
+				Description:    distribution.Description,
+				DistributedAt:  distribution.DistributedAt,
+				AccountID:      distribution.AccountID,
+			},
+			RequestedBy: actorID,
+			RequestNote: strings.TrimSpace(note),
+		}
+		return createApproval(tx, &approval)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &approval, nil
+}
+
+// RequestApprovalThreshold queues a change of the approval threshold, which
+// takes effect once another treasurer approves it.
+func RequestApprovalThreshold(db *gorm.DB, threshold models.Money, actorID uuid.UUID, note string) (*models.Approval, error) {
+	if threshold <= 0 {
+		return nil, ErrApprovalThreshold
+	}
+
+	approval := models.Approval{
+		Kind:        models.ApprovalThresholdChange,
+		SubjectID:   approvalThresholdSubject,
+		Amount:      threshold,
+		Summary:     fmt.Sprintf("Ubah batas persetujuan dari %s menjadi %s", ApprovalThreshold(db).Format(), threshold.Format()),
+		RequestedBy: actorID,
+		RequestNote: strings.TrimSpace(note),
+	}
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return createApproval(tx, &approval)
+	}); err != nil {
+		return nil, err
+	}
+	return &approval, nil
+}
+
+func lockPendingApproval(tx *gorm.DB, approvalID uuid.UUID) (*models.Approval, error) {
+	var approval models.Approval
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&approval, "id = ?", approvalID).Error; err != nil {
+		return nil, err
+	}
+	if approval.Status != models.ApprovalPending {
+		return nil, ErrApprovalDecided
+	}
+	return &approval, nil
+}
+
+func decideApproval(tx *gorm.DB, approval *models.Approval, status models.ApprovalStatus, actorID uuid.UUID, note string) error {
+	now := time.Now()
+	approval.Status = status
+	approval.DecidedBy = &actorID
+	approval.DecidedAt = &now
+	approval.DecisionNote = note
+	return tx.Model(approval).Updates(map[string]interface{}{
+		"status":        status,
+		"decided_by":    actorID,
+		"decided_at":    now,
+		"decision_note": note,
+	}).Error
+}
+
+// ApproveApproval carries out the request: the donation is confirmed, the
+// expense is written to or deleted from the ledger, the distribution is
+// recorded, or the threshold is changed. The approver must not be the
+// requester.
+func ApproveApproval(db *gorm.DB, approvalID, actorID uuid.UUID, note string) (*models.Approval, error) {
+	var approval *models.Approval
+	var removed []string // attachments of a deleted entry
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if approval, err = lockPendingApproval(tx, approvalID); err != nil {
+			return err
+		}
+		if approval.RequestedBy == actorID {
+			return ErrSelfApproval
+		}
+		note = strings.TrimSpace(note)
+		if err := decideApproval(tx, approval, models.ApprovalApproved, actorID, note); err != nil {
+			return err
+		}
+
+		switch approval.Kind {
+		case models.ApprovalDonationConfirmation:
+			var requester models.User
+			tx.Select("full_name").First(&requester, "id = ?", approval.RequestedBy)
+			reason := "Disetujui bendahara atas pengajuan " + requester.FullName
+			if note != "" {
+				reason += ": " + note
+			}
+			if _, err := TransitionDonation(tx, approval.SubjectID, models.DonationStatusConfirmed, actorID, reason); err != nil {
+				return err
+			}
+			if approval.MutationID != nil {
+				if err := confirmApprovedMutation(tx, *approval.MutationID, approval.SubjectID, actorID); err != nil {
+					return err
+				}
+			}
+		case models.ApprovalExpense:
+			if err := applyExpenseProposal(tx, approval); err != nil {
+				return err
+			}
+		case models.ApprovalExpenseDeletion:
+			var err error
+			if removed, err = applyExpenseDeletion(tx, approval); err != nil {
+				return err
+			}
+		case models.ApprovalDistribution:
+			if err := applyDistributionProposal(tx, approval); err != nil {
+				return err
+			}
+		case models.ApprovalThresholdChange:
+			if err := applyApprovalThreshold(tx, approval); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range removed {
+		os.Remove(PrivateFilePath(key))
+	}
+	return approval, nil
+}
+
+func applyApprovalThreshold(tx *gorm.DB, approval *models.Approval) error {
+	if approval.Amount <= 0 {
+		return ErrApprovalThreshold
+	}
+	setting := models.Setting{Key: SettingApprovalThreshold}
+	if err := tx.Where("key = ?", setting.Key).FirstOrInit(&setting).Error; err != nil {
+		return err
+	}
+	setting.Value = approval.Amount.String()
+	setting.DataType = models.SettingTypeInt
+	if err := tx.Save(&setting).Error; err != nil {
+		return err
+	}
+	log.Printf("Approval threshold set to %s by approval %s", approval.Amount.Format(), approval.ID)
+	return nil
+}
+
+func applyExpenseProposal(tx *gorm.DB, approval *models.Approval) error {
+	p := approval.Expense
+	if p == nil {
+		return fmt.Errorf("approval %s has no expense", approval.ID)
+	}
+
+	var entry models.LedgerEntry
+	err := tx.First(&entry, "id = ?", approval.SubjectID).Error
+	isNew := errors.Is(err, gorm.ErrRecordNotFound)
+	if err != nil && !isNew {
+		return err
+	}
+	if !isNew && entry.Source != models.LedgerSourceManual {
+		return ErrLedgerEntryGenerated
+	}
+	if isNew {
+		entry = models.LedgerEntry{
+			ID:        approval.SubjectID,
+			Source:    models.LedgerSourceManual,
+			CreatedBy: &approval.RequestedBy,
+		}
+	}
+
+	entry.AccountID = p.AccountID
+	entry.CategoryID = p.CategoryID
+	entry.Type = models.LedgerExpense
+	if p.Type != "" {
+		entry.Type = p.Type
+	}
+	entry.Amount = p.Amount
+	entry.EntryDate = p.EntryDate
+	entry.Description = p.Description
+	entry.Reference = p.Reference
+	if err := ValidateLedgerEntry(tx, &entry); err != nil {
+		return fmt.Errorf("%w (%s)", ErrApprovalExpense, err.Error())
+	}
+	if isNew {
+		return tx.Create(&entry).Error
+	}
+	return tx.Omit("Account", "Category", "Creator").Save(&entry).Error
+}
+
+func applyExpenseDeletion(tx *gorm.DB, approval *models.Approval) ([]string, error) {
+	var entry models.LedgerEntry
+	if err := tx.First(&entry, "id = ?", approval.SubjectID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w (already deleted)", ErrApprovalExpense)
+		}
+		return nil, err
+	}
+	if entry.Source != models.LedgerSourceManual {
+		return nil, ErrLedgerEntryGenerated
+	}
+	if err := tx.Delete(&entry).Error; err != nil {
+		return nil, err
+	}
+	return entry.Attachments, nil
+}
+
+func applyDistributionProposal(tx *gorm.DB, approval *models.Approval) error {
+	p := approval.Distribution
+	if p == nil {
+		return fmt.Errorf("approval %s has no distribution", approval.ID)
+	}
+
+	distribution := models.Distribution{
+		ID:             approval.SubjectID,
+		MustahikID:     p.MustahikID,
+		SourceCategory: p.SourceCategory,
+		Amount:         p.Amount,
+		RiceKg:         p.RiceKg,
+		Description:    p.Description,
+		DistributedAt:  p.DistributedAt,
+		AccountID:      p.AccountID,
+		CreatedBy:      approval.RequestedBy,
+	}
+	return recordDistribution(tx, &distribution)
+}
+
+// confirmApprovedMutation marks the mutation a confirmation was requested
+// from as confirmed, unless it was reviewed some other way meanwhile.
+func confirmApprovedMutation(tx *gorm.DB, mutationID, donationID, actorID uuid.UUID) error {
+	return tx.Model(&models.BankMutation{}).
+		Where("id = ? AND match_status NOT IN ?", mutationID, []models.MutationMatchStatus{models.MutationConfirmed, models.MutationIgnored}).
+		Updates(map[string]interface{}{
+			"match_status": models.MutationConfirmed,
+			"donation_id":  donationID,
+			"reviewed_by":  actorID,
+			"reviewed_at":  time.Now(),
+		}).Error
+}
+
+// RejectApproval turns the request down; the donation stays pending and the
+// expense is dropped.
+func RejectApproval(db *gorm.DB, approvalID, actorID uuid.UUID, note string) (*models.Approval, error) {
+	note = strings.TrimSpace(note)
+	if note == "" {
+		return nil, ErrApprovalNoteNeeded
+	}
+
+	var approval *models.Approval
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if approval, err = lockPendingApproval(tx, approvalID); err != nil {
+			return err
+		}
+		if approval.RequestedBy == actorID {
+			return ErrSelfApproval
+		}
+		return decideApproval(tx, approval, models.ApprovalRejected, actorID, note)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return approval, nil
+}
+
+// CancelApproval withdraws a request; only its requester may do so.
+func CancelApproval(db *gorm.DB, approvalID, actorID uuid.UUID) (*models.Approval, error) {
+	var approval *models.Approval
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if approval, err = lockPendingApproval(tx, approvalID); err != nil {
+			return err
+		}
+		if approval.RequestedBy != actorID {
+			return ErrApprovalNotYours
+		}
+		return decideApproval(tx, approval, models.ApprovalCancelled, actorID, "")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return approval, nil
+}
+
+// CancelPendingApprovals withdraws approvals whose subject has moved on, e.g.
+// a donation rejected or an entry deleted while waiting for a decision.
+func CancelPendingApprovals(tx *gorm.DB, subjectID uuid.UUID, note string) error {
+	return tx.Model(&models.Approval{}).
+		Where("subject_id = ? AND status = ?", subjectID, models.ApprovalPending).
+		Updates(map[string]interface{}{
+			"status":        models.ApprovalCancelled,
+			"decided_at":    time.Now(),
+			"decision_note": note,
+		}).Error
+}
+
+// notifyTreasurers sends a message to every active treasurer except the
+// one who caused it.
+func notifyTreasurers(db *gorm.DB, notifier Notifier, except uuid.UUID, subject, message string) {
+	var treasurers []models.User
+	if err := db.Where("role = ? AND is_active = ? AND id <> ?", models.RoleTreasurer, true, except).
+		Find(&treasurers).Error; err != nil {
+		log.Printf("Failed to find treasurers to notify: %v", err)
+		return
+	}
+
+	for _, t := range treasurers {
+		email := t.Email
+		err := notifier.Notify(Recipient{Name: t.FullName, Email: &email}, subject, message)
+		if err != nil && !errors.Is(err, ErrNoNotificationChannel) {
+			log.Printf("Failed to notify treasurer %s: %v", t.ID, err)
+		}
+	}
+}
+
+// notifyUser sends a message to a staff member by email.
+func notifyUser(db *gorm.DB, notifier Notifier, userID uuid.UUID, subject, message string) {
+	var user models.User
+	if err := db.First(&user, "id = ?", userID).Error; err != nil {
+		return
+	}
+
+	email := user.Email
+	err := notifier.Notify(Recipient{Name: user.FullName, Email: &email}, subject, message)
+	if err != nil && !errors.Is(err, ErrNoNotificationChannel) {
+		log.Printf("Failed to notify user %s: %v", user.ID, err)
+	}
+}
+
+// NotifyApprovalRequested tells every active treasurer except the requester
+// that a request is waiting.
+func NotifyApprovalRequested(db *gorm.DB, notifier Notifier, approval *models.Approval) {
+	var requester models.User
+	db.Select("full_name").First(&requester, "id = ?", approval.RequestedBy)
+	message := fmt.Sprintf("%s mengajukan persetujuan:\n%s\nJumlah: %s\n\nSilakan tinjau di antrean persetujuan panel admin.",
+		requester.FullName, approval.Summary, approval.Amount.Format())
+	if approval.RequestNote != "" {
+		message += "\nCatatan: " + approval.RequestNote
+	}
+
+	notifyTreasurers(db, notifier, approval.RequestedBy, "Menunggu persetujuan bendahara", message)
+}
+
+// NotifyApprovalDecided tells the requester how their request was decided.
+func NotifyApprovalDecided(db *gorm.DB, notifier Notifier, approval *models.Approval) {
+	outcome := "disetujui"
+	if approval.Status == models.ApprovalRejected {
+		outcome = "ditolak"
+	}
+	message := fmt.Sprintf("Pengajuan Anda telah %s:\n%s\nJumlah: %s", outcome, approval.Summary, approval.Amount.Format())
+	if approval.DecisionNote != "" {
+		message += "\nCatatan: " + approval.DecisionNote
+	}
+
+	notifyUser(db, notifier, approval.RequestedBy, "Pengajuan "+outcome, message)
+}
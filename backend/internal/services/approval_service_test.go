@@ -0,0 +1,57 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"masjid-baiturrahim-backend/internal/models"
+	"masjid-baiturrahim-backend/internal/testutil"
+)
+
+func TestApprovalThreshold(t *testing.T) {
+	db := testutil.NewDB(t)
+	treasurer := testutil.NewUser(t, db, models.RoleTreasurer, "")
+	otherTreasurer := testutil.NewUser(t, db, models.RoleTreasurer, "-2")
+
+	// Values that would once have turned approvals off count as the default
+	for _, value := range []string{"0", "-1", "sepuluh juta"} {
+		if err := db.Save(&models.Setting{Key: SettingApprovalThreshold, Value: value}).Error; err != nil {
+			t.Fatal(err)
+		}
+		if got := ApprovalThreshold(db); got != defaultApprovalThreshold {
+			t.Errorf("stored %q: threshold = %s, want the default %s", value, got.Format(), defaultApprovalThreshold.Format())
+		}
+		if !NeedsApproval(db, defaultApprovalThreshold+1) {
+			t.Errorf("stored %q: an amount above the default doesn't need approval", value)
+		}
+		db.Where("key = ?", SettingApprovalThreshold).Delete(&models.Setting{})
+	}
+
+	for _, threshold := range []models.Money{0, models.NewMoney(-1)} {
+		if _, err := RequestApprovalThreshold(db, threshold, treasurer.ID, ""); !errors.Is(err, ErrApprovalThreshold) {
+			t.Errorf("threshold %s: err = %v, want %v", threshold.Format(), err, ErrApprovalThreshold)
+		}
+	}
+
+	approval, err := RequestApprovalThreshold(db, models.NewMoney(5000000), treasurer.ID, "rapat takmir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RequestApprovalThreshold(db, models.NewMoney(2000000), otherTreasurer.ID, ""); !errors.Is(err, ErrApprovalPending) {
+		t.Errorf("second pending change: err = %v, want %v", err, ErrApprovalPending)
+	}
+	if got := ApprovalThreshold(db); got != defaultApprovalThreshold {
+		t.Errorf("threshold = %s before the change is approved, want %s", got.Format(), defaultApprovalThreshold.Format())
+	}
+	if _, err := ApproveApproval(db, approval.ID, treasurer.ID, ""); !errors.Is(err, ErrSelfApproval) {
+		t.Errorf("approving own change: err = %v, want %v", err, ErrSelfApproval)
+	}
+	if _, err := ApproveApproval(db, approval.ID, otherTreasurer.ID, ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := ApprovalThreshold(db); got != models.NewMoney(5000000) {
+		t.Errorf("threshold = %s after approval, want Rp5.000.000", got.Format())
+	}
+	if NeedsApproval(db, models.NewMoney(5000000)) || !NeedsApproval(db, models.NewMoney(5000001)) {
+		t.Error("NeedsApproval doesn't follow the new threshold")
+	}
+}
@@ -0,0 +1,301 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SettingFiscalYearStartMonth is the month (1-12) a fiscal year starts in. A
+// fiscal year is named after the calendar year it starts in.
+const SettingFiscalYearStartMonth = "fiscal_year_start_month"
+
+var (
+	ErrBudgetPlanApproved  = errors.New("budget plan has been approved; reopen it to make changes")
+	ErrBudgetLineCategory  = errors.New("budget line needs an active ledger category")
+	ErrBudgetLineDuplicate = errors.New("each category can only appear once in a budget plan")
+	ErrBudgetLineAmount    = errors.New("budget amount can't be negative")
+)
+
+// FiscalYearRange returns the first day of fiscal year year and the first
+// day of the next one.
+func FiscalYearRange(db *gorm.DB, year int) (time.Time, time.Time) {
+	month := GetSettingInt(db, SettingFiscalYearStartMonth, 1)
+	if month < 1 || month > 12 {
+		month = 1
+	}
+	start := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.Local)
+	return start, start.AddDate(1, 0, 0)
+}
+
+type BudgetLineInput struct {
+	CategoryID uuid.UUID    `json:"category_id" binding:"required"`
+	Amount     models.Money `json:"amount"`
+	Notes      string       `json:"notes"`
+}
+
+// SaveBudgetLines replaces the lines of a draft plan.
+func SaveBudgetLines(db *gorm.DB, planID uuid.UUID, inputs []BudgetLineInput) ([]models.BudgetLine, error) {
+	lines := make([]models.BudgetLine, 0, len(inputs))
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var plan models.BudgetPlan
+		if err := tx.First(&plan, "id = ?", planID).Error; err != nil {
+			return err
+		}
+		if plan.Status == models.BudgetPlanApproved {
+			return ErrBudgetPlanApproved
+		}
+
+		seen := make(map[uuid.UUID]bool, len(inputs))
+		for _, in := range inputs {
+			if seen[in.CategoryID] {
+				return ErrBudgetLineDuplicate
+			}
+			seen[in.CategoryID] = true
+			if in.Amount < 0 {
+				return ErrBudgetLineAmount
+			}
+			if err := tx.First(&models.LedgerCategory{}, "id = ? AND is_active = ?", in.CategoryID, true).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return ErrBudgetLineCategory
+				}
+				return err
+			}
+			lines = append(lines, models.BudgetLine{
+				PlanID:     plan.ID,
+				CategoryID: in.CategoryID,
+				Amount:     in.Amount,
+				Notes:      in.Notes,
+			})
+		}
+
+		if err := tx.Where("plan_id = ?", plan.ID).Delete(&models.BudgetLine{}).Error; err != nil {
+			return err
+		}
+		if len(lines) == 0 {
+			return nil
+		}
+		return tx.Create(&lines).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+type BudgetVarianceLine struct {
+	CategoryID  uuid.UUID              `json:"category_id"`
+	Code        string                 `json:"code"`
+	Name        string                 `json:"name"`
+	Type        models.LedgerEntryType `json:"type"`
+	Budget      models.Money           `json:"budget"`
+	Actual      models.Money           `json:"actual"`
+	Variance    models.Money           `json:"variance"`              // actual minus budget
+	Realisation *float64               `json:"realisation,omitempty"` // actual as a percentage of budget
+	Unbudgeted  bool                   `json:"unbudgeted"`            // money moved in a category the plan has no line for
+}
+
+type BudgetSection struct {
+	Budget      models.Money         `json:"budget"`
+	Actual      models.Money         `json:"actual"`
+	Variance    models.Money         `json:"variance"`
+	Realisation *float64             `json:"realisation,omitempty"`
+	Lines       []BudgetVarianceLine `json:"lines"`
+}
+
+// BudgetMonth is one point of the burn chart: what came in and went out in
+// the month, and the running totals against an even spread of the budget.
+type BudgetMonth struct {
+	Month             string       `json:"month"` // YYYY-MM
+	Income            models.Money `json:"income"`
+	Expense           models.Money `json:"expense"`
+	CumulativeIncome  models.Money `json:"cumulative_income"`
+	CumulativeExpense models.Money `json:"cumulative_expense"`
+	PlannedIncome     models.Money `json:"planned_income"`  // cumulative
+	PlannedExpense    models.Money `json:"planned_expense"` // cumulative
+}
+
+type BudgetReport struct {
+	PlanID         uuid.UUID               `json:"plan_id"`
+	Title          string                  `json:"title"`
+	FiscalYear     int                     `json:"fiscal_year"`
+	Status         models.BudgetPlanStatus `json:"status"`
+	From           string                  `json:"from"`
+	To             string                  `json:"to"` // last day of the fiscal year
+	Income         BudgetSection           `json:"income"`
+	Expense        BudgetSection           `json:"expense"`
+	PlannedSurplus models.Money            `json:"planned_surplus"`
+	ActualSurplus  models.Money            `json:"actual_surplus"`
+	Months         []BudgetMonth           `json:"months"`
+}
+
+func realisation(actual, budget models.Money) *float64 {
+	if budget == 0 {
+		return nil
+	}
+	pct := float64(actual) / float64(budget) * 100
+	return &pct
+}
+
+// GetBudgetReport compares a plan with the cash book over its fiscal year.
+// Confirmed donations are in the cash book as income of their category, so
+// the actuals cover both donations and recorded expenses.
+func GetBudgetReport(db *gorm.DB, planID uuid.UUID) (*BudgetReport, error) {
+	var plan models.BudgetPlan
+	if err := db.Preload("Lines.Category").First(&plan, "id = ?", planID).Error; err != nil {
+		return nil, err
+	}
+	start, end := FiscalYearRange(db, plan.FiscalYear)
+
+	var actuals []struct {
+		CategoryID uuid.UUID
+		Total      models.Money
+	}
+	if err := db.Model(&models.LedgerEntry{}).
+		Select("category_id, SUM(amount) as total").
+		Where("entry_date >= ? AND entry_date < ?", start.Format(dateLayout), end.Format(dateLayout)).
+		Group("category_id").
+		Scan(&actuals).Error; err != nil {
+		return nil, err
+	}
+	actualByCategory := make(map[uuid.UUID]models.Money, len(actuals))
+	for _, a := range actuals {
+		actualByCategory[a.CategoryID] = a.Total
+	}
+
+	report := &BudgetReport{
+		PlanID:     plan.ID,
+		Title:      plan.Title,
+		FiscalYear: plan.FiscalYear,
+		Status:     plan.Status,
+		From:       start.Format(dateLayout),
+		To:         end.AddDate(0, 0, -1).Format(dateLayout),
+		Income:     BudgetSection{Lines: []BudgetVarianceLine{}},
+		Expense:    BudgetSection{Lines: []BudgetVarianceLine{}},
+		Months:     []BudgetMonth{},
+	}
+	section := func(t models.LedgerEntryType) *BudgetSection {
+		if t == models.LedgerExpense {
+			return &report.Expense
+		}
+		return &report.Income
+	}
+	add := func(line BudgetVarianceLine) {
+		line.Variance = line.Actual - line.Budget
+		line.Realisation = realisation(line.Actual, line.Budget)
+		s := section(line.Type)
+		s.Budget += line.Budget
+		s.Actual += line.Actual
+		s.Lines = append(s.Lines, line)
+	}
+
+	budgeted := make(map[uuid.UUID]bool, len(plan.Lines))
+	for _, l := range plan.Lines {
+		budgeted[l.CategoryID] = true
+		line := BudgetVarianceLine{CategoryID: l.CategoryID, Budget: l.Amount, Actual: actualByCategory[l.CategoryID]}
+		if l.Category != nil {
+			line.Code, line.Name, line.Type = l.Category.Code, l.Category.Name, l.Category.Type
+		}
+		add(line)
+	}
+
+	var unbudgeted []uuid.UUID
+	for id := range actualByCategory {
+		if !budgeted[id] {
+			unbudgeted = append(unbudgeted, id)
+		}
+	}
+	if len(unbudgeted) > 0 {
+		var categories []models.LedgerCategory
+		if err := db.Where("id IN ?", unbudgeted).Find(&categories).Error; err != nil {
+			return nil, err
+		}
+		for _, c := range categories {
+			add(BudgetVarianceLine{
+				CategoryID: c.ID,
+				Code:       c.Code,
+				Name:       c.Name,
+				Type:       c.Type,
+				Actual:     actualByCategory[c.ID],
+				Unbudgeted: true,
+			})
+		}
+	}
+
+	for _, s := range []*BudgetSection{&report.Income, &report.Expense} {
+		s.Variance = s.Actual - s.Budget
+		s.Realisation = realisation(s.Actual, s.Budget)
+		sort.SliceStable(s.Lines, func(i, j int) bool {
+			if s.Lines[i].Unbudgeted != s.Lines[j].Unbudgeted {
+				return !s.Lines[i].Unbudgeted
+			}
+			return s.Lines[i].Code < s.Lines[j].Code
+		})
+	}
+	report.PlannedSurplus = report.Income.Budget - report.Expense.Budget
+	report.ActualSurplus = report.Income.Actual - report.Expense.Actual
+
+	months, err := budgetMonths(db, start, end, report.Income.Budget, report.Expense.Budget)
+	if err != nil {
+		return nil, err
+	}
+	report.Months = months
+	return report, nil
+}
+
+// budgetMonths builds the burn chart of a fiscal year. The plan is spread
+// evenly over the twelve months.
+func budgetMonths(db *gorm.DB, start, end time.Time, incomeBudget, expenseBudget models.Money) ([]BudgetMonth, error) {
+	// Totals per day, bucketed into months below
+	var rows []struct {
+		EntryDate time.Time
+		Type      models.LedgerEntryType
+		Total     models.Money
+	}
+	if err := db.Model(&models.LedgerEntry{}).
+		Select("entry_date, type, SUM(amount) as total").
+		Where("entry_date >= ? AND entry_date < ?", start.Format(dateLayout), end.Format(dateLayout)).
+		Group("entry_date, type").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	months := make([]BudgetMonth, 12)
+	index := make(map[string]int, 12)
+	for i := range months {
+		months[i].Month = start.AddDate(0, i, 0).Format("2006-01")
+		index[months[i].Month] = i
+	}
+	for _, r := range rows {
+		i, ok := index[r.EntryDate.Format("2006-01")]
+		if !ok {
+			continue
+		}
+		if r.Type == models.LedgerExpense {
+			months[i].Expense += r.Total
+		} else {
+			months[i].Income += r.Total
+		}
+	}
+
+	var income, expense models.Money
+	for i := range months {
+		income += months[i].Income
+		expense += months[i].Expense
+		months[i].CumulativeIncome = income
+		months[i].CumulativeExpense = expense
+		months[i].PlannedIncome = incomeBudget * models.Money(i+1) / 12
+		months[i].PlannedExpense = expenseBudget * models.Money(i+1) / 12
+	}
+	return months, nil
+}
+
+// BudgetReportFilename is the download name of a plan's report.
+func BudgetReportFilename(report *BudgetReport) string {
+	return fmt.Sprintf("rapb-%d-realisasi.xlsx", report.FiscalYear)
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"testing"
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+	"masjid-baiturrahim-backend/internal/testutil"
+
+	"github.com/google/uuid"
+)
+
+func TestGetBudgetReport(t *testing.T) {
+	db := testutil.NewDB(t)
+	treasurer := testutil.NewUser(t, db, models.RoleTreasurer, "-budget")
+	if err := db.Create(&models.Setting{Key: SettingFiscalYearStartMonth, Value: "7"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var account models.LedgerAccount
+	db.First(&account, "is_default = ?", true)
+	category := func(code string) uuid.UUID {
+		t.Helper()
+		var c models.LedgerCategory
+		if err := db.First(&c, "code = ?", code).Error; err != nil {
+			t.Fatal(err)
+		}
+		return c.ID
+	}
+
+	plan := models.BudgetPlan{FiscalYear: 2026, Title: "RAPB 2026/2027", CreatedBy: treasurer.ID}
+	if err := db.Create(&plan).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SaveBudgetLines(db, plan.ID, []BudgetLineInput{
+		{CategoryID: category("infaq"), Amount: models.NewMoney(12000000)},
+		{CategoryID: category("listrik-air"), Amount: models.NewMoney(2400000)},
+		{CategoryID: category("gaji-marbot"), Amount: models.NewMoney(6000000)},
+		{CategoryID: category("honor-kajian")},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := []struct {
+		code   string
+		date   string
+		amount int64
+	}{
+		{"infaq", "2026-06-30", 9999000}, // the fiscal year before
+		{"infaq", "2026-07-10", 3000000},
+		{"infaq", "2026-08-31", 3000000},
+		{"listrik-air", "2026-07-05", 300000},
+		{"listrik-air", "2026-07-25", 300000},
+		{"pemeliharaan", "2026-09-15", 750000}, // not in the plan
+		{"gaji-marbot", "2027-01-01", 1500000},
+		{"infaq", "2027-07-01", 9999000}, // the fiscal year after
+	}
+	for _, e := range entries {
+		var c models.LedgerCategory
+		db.First(&c, "code = ?", e.code)
+		date, _ := time.Parse(dateLayout, e.date)
+		entry := models.LedgerEntry{
+			AccountID:   account.ID,
+			CategoryID:  c.ID,
+			Type:        c.Type,
+			Amount:      models.NewMoney(e.amount),
+			EntryDate:   date,
+			Description: e.code,
+		}
+		if err := db.Create(&entry).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := GetBudgetReport(db, plan.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.From != "2026-07-01" || report.To != "2027-06-30" {
+		t.Errorf("report covers %s to %s, want 2026-07-01 to 2027-06-30", report.From, report.To)
+	}
+
+	pct := func(f float64) *float64 { return &f }
+	checkLine := func(section string, got BudgetVarianceLine, code string, budget, actual int64, realisation *float64, unbudgeted bool) {
+		t.Helper()
+		if got.Code != code || got.Budget != models.NewMoney(budget) || got.Actual != models.NewMoney(actual) ||
+			got.Variance != models.NewMoney(actual-budget) || got.Unbudgeted != unbudgeted {
+			t.Errorf("%s line %s = budget %s, actual %s, variance %s, unbudgeted %v; want %s with budget %s, actual %s",
+				section, got.Code, got.Budget.Format(), got.Actual.Format(), got.Variance.Format(), got.Unbudgeted,
+				code, models.NewMoney(budget).Format(), models.NewMoney(actual).Format())
+		}
+		if (got.Realisation == nil) != (realisation == nil) || (realisation != nil && *got.Realisation != *realisation) {
+			t.Errorf("%s line %s realisation = %v, want %v", section, code, got.Realisation, realisation)
+		}
+	}
+
+	if len(report.Income.Lines) != 1 {
+		t.Fatalf("%d income lines, want 1", len(report.Income.Lines))
+	}
+	checkLine("income", report.Income.Lines[0], "infaq", 12000000, 6000000, pct(50), false)
+
+	// Budgeted lines by code, then what the plan left out
+	if len(report.Expense.Lines) != 4 {
+		t.Fatalf("%d expense lines, want 4", len(report.Expense.Lines))
+	}
+	checkLine("expense", report.Expense.Lines[0], "gaji-marbot", 6000000, 1500000, pct(25), false)
+	checkLine("expense", report.Expense.Lines[1], "honor-kajian", 0, 0, nil, false)
+	checkLine("expense", report.Expense.Lines[2], "listrik-air", 2400000, 600000, pct(25), false)
+	checkLine("expense", report.Expense.Lines[3], "pemeliharaan", 0, 750000, nil, true)
+
+	if s := report.Expense; s.Budget != models.NewMoney(8400000) || s.Actual != models.NewMoney(2850000) || s.Variance != models.NewMoney(-5550000) {
+		t.Errorf("expenses = budget %s, actual %s, variance %s", s.Budget.Format(), s.Actual.Format(), s.Variance.Format())
+	}
+	if report.PlannedSurplus != models.NewMoney(3600000) || report.ActualSurplus != models.NewMoney(3150000) {
+		t.Errorf("surplus = planned %s, actual %s", report.PlannedSurplus.Format(), report.ActualSurplus.Format())
+	}
+
+	// The burn chart runs over the fiscal year, with the plan spread evenly
+	if len(report.Months) != 12 || report.Months[0].Month != "2026-07" || report.Months[11].Month != "2027-06" {
+		t.Fatalf("months = %v, want July 2026 to June 2027", report.Months)
+	}
+	months := []struct {
+		i                             int
+		income, expense               int64
+		cumIncome, cumExpense         int64
+		plannedIncome, plannedExpense int64
+	}{
+		{0, 3000000, 600000, 3000000, 600000, 1000000, 700000},
+		{1, 3000000, 0, 6000000, 600000, 2000000, 1400000},
+		{2, 0, 750000, 6000000, 1350000, 3000000, 2100000},
+		{5, 0, 0, 6000000, 1350000, 6000000, 4200000},
+		{6, 0, 1500000, 6000000, 2850000, 7000000, 4900000},
+		{11, 0, 0, 6000000, 2850000, 12000000, 8400000},
+	}
+	for _, m := range months {
+		got := report.Months[m.i]
+		if got.Income != models.NewMoney(m.income) || got.Expense != models.NewMoney(m.expense) ||
+			got.CumulativeIncome != models.NewMoney(m.cumIncome) || got.CumulativeExpense != models.NewMoney(m.cumExpense) ||
+			got.PlannedIncome != models.NewMoney(m.plannedIncome) || got.PlannedExpense != models.NewMoney(m.plannedExpense) {
+			t.Errorf("%s = in %s, out %s, cumulative %s/%s, planned %s/%s", got.Month,
+				got.Income.Format(), got.Expense.Format(), got.CumulativeIncome.Format(), got.CumulativeExpense.Format(),
+				got.PlannedIncome.Format(), got.PlannedExpense.Format())
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"fmt"
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	budgetSummarySheet = "Ringkasan"
+	budgetMonthlySheet = "Bulanan"
+)
+
+// rupiah converts an amount to a spreadsheet number; cells are formatted to
+// whole rupiah.
+func rupiah(m models.Money) float64 {
+	return float64(m) / float64(models.Rupiah)
+}
+
+func indonesianMonth(month string) string {
+	t, err := time.Parse("2006-01", month)
+	if err != nil {
+		return month
+	}
+	return fmt.Sprintf("%s %d", indonesianMonths[t.Month()-1], t.Year())
+}
+
+type budgetStyles struct {
+	title, header, money, percent, bold, boldMoney, boldPercent int
+}
+
+func newBudgetStyles(f *excelize.File) (*budgetStyles, error) {
+	moneyFmt := `#,##0;[Red]-#,##0`
+	percentFmt := `0.0%`
+	headerFill := excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"#DDEBF7"}}
+	border := []excelize.Border{{Type: "bottom", Color: "#000000", Style: 1}}
+
+	s := &budgetStyles{}
+	specs := []struct {
+		dst   *int
+		style *excelize.Style
+	}{
+		{&s.title, &excelize.Style{Font: &excelize.Font{Bold: true, Size: 14}}},
+		{&s.header, &excelize.Style{Font: &excelize.Font{Bold: true}, Fill: headerFill, Border: border,
+			Alignment: &excelize.Alignment{Horizontal: "center", Vertical: "center", WrapText: true}}},
+		{&s.money, &excelize.Style{CustomNumFmt: &moneyFmt}},
+		{&s.percent, &excelize.Style{CustomNumFmt: &percentFmt}},
+		{&s.bold, &excelize.Style{Font: &excelize.Font{Bold: true}}},
+		{&s.boldMoney, &excelize.Style{Font: &excelize.Font{Bold: true}, CustomNumFmt: &moneyFmt}},
+		{&s.boldPercent, &excelize.Style{Font: &excelize.Font{Bold: true}, CustomNumFmt: &percentFmt}},
+	}
+	for _, spec := range specs {
+		id, err := f.NewStyle(spec.style)
+		if err != nil {
+			return nil, err
+		}
+		*spec.dst = id
+	}
+	return s, nil
+}
+
+// RenderBudgetReportXLSX writes the budget report as a workbook for the
+// annual meeting (musyawarah): a summary sheet comparing each line with its
+// realisation and a monthly sheet with burn charts.
+func RenderBudgetReportXLSX(report *BudgetReport, mosqueName string) ([]byte, error) {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	styles, err := newBudgetStyles(f)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.SetSheetName("Sheet1", budgetSummarySheet); err != nil {
+		return nil, err
+	}
+	if err := writeBudgetSummary(f, styles, report, mosqueName); err != nil {
+		return nil, err
+	}
+	if _, err := f.NewSheet(budgetMonthlySheet); err != nil {
+		return nil, err
+	}
+	if err := writeBudgetMonths(f, styles, report); err != nil {
+		return nil, err
+	}
+
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeBudgetSummary(f *excelize.File, s *budgetStyles, report *BudgetReport, mosqueName string) error {
+	sheet := budgetSummarySheet
+	cell := func(col, row int) string {
+		name, _ := excelize.CoordinatesToCellName(col, row)
+		return name
+	}
+	set := func(col, row int, value interface{}, style int) {
+		f.SetCellValue(sheet, cell(col, row), value)
+		if style != 0 {
+			f.SetCellStyle(sheet, cell(col, row), cell(col, row), style)
+		}
+	}
+	setPercent := func(col, row int, pct *float64, style int) {
+		if pct != nil {
+			set(col, row, *pct/100, style)
+		}
+	}
+
+	set(1, 1, fmt.Sprintf("%s: %s", report.Title, mosqueName), s.title)
+	set(1, 2, fmt.Sprintf("Tahun Anggaran %d (%s s.d. %s)", report.FiscalYear,
+		FormatIndonesianDate(report.From), FormatIndonesianDate(report.To)), 0)
+	status := "Draf"
+	if report.Status == models.BudgetPlanApproved {
+		status = "Disahkan"
+	}
+	set(1, 3, "Status: "+status, 0)
+
+	row := 5
+	headers := []string{"Kode", "Kategori", "Anggaran", "Realisasi", "Selisih", "Realisasi (%)", "Keterangan"}
+	for i, h := range headers {
+		set(i+1, row, h, s.header)
+	}
+	f.SetPanes(sheet, &excelize.Panes{Freeze: true, YSplit: row, TopLeftCell: cell(1, row+1), ActivePane: "bottomLeft"})
+	row++
+
+	sections := []struct {
+		title   string
+		section BudgetSection
+	}{
+		{"PENDAPATAN", report.Income},
+		{"BELANJA", report.Expense},
+	}
+	for _, sec := range sections {
+		set(1, row, sec.title, s.bold)
+		row++
+		for _, line := range sec.section.Lines {
+			set(1, row, line.Code, 0)
+			set(2, row, line.Name, 0)
+			set(3, row, rupiah(line.Budget), s.money)
+			set(4, row, rupiah(line.Actual), s.money)
+			set(5, row, rupiah(line.Variance), s.money)
+			setPercent(6, row, line.Realisation, s.percent)
+			if line.Unbudgeted {
+				set(7, row, "Di luar anggaran", 0)
+			}
+			row++
+		}
+		set(2, row, "Jumlah "+sec.title, s.bold)
+		set(3, row, rupiah(sec.section.Budget), s.boldMoney)
+		set(4, row, rupiah(sec.section.Actual), s.boldMoney)
+		set(5, row, rupiah(sec.section.Variance), s.boldMoney)
+		setPercent(6, row, sec.section.Realisation, s.boldPercent)
+		row += 2
+	}
+
+	set(2, row, "SURPLUS / (DEFISIT)", s.bold)
+	set(3, row, rupiah(report.PlannedSurplus), s.boldMoney)
+	set(4, row, rupiah(report.ActualSurplus), s.boldMoney)
+	set(5, row, rupiah(report.ActualSurplus-report.PlannedSurplus), s.boldMoney)
+
+	f.SetColWidth(sheet, "A", "A", 18)
+	f.SetColWidth(sheet, "B", "B", 36)
+	f.SetColWidth(sheet, "C", "E", 18)
+	f.SetColWidth(sheet, "F", "F", 14)
+	f.SetColWidth(sheet, "G", "G", 18)
+	return nil
+}
+
+func writeBudgetMonths(f *excelize.File, s *budgetStyles, report *BudgetReport) error {
+	sheet := budgetMonthlySheet
+	headers := []interface{}{
+		"Bulan", "Pendapatan", "Belanja", "Kumulatif Pendapatan", "Kumulatif Belanja",
+		"Rencana Kumulatif Pendapatan", "Rencana Kumulatif Belanja",
+	}
+	if err := f.SetSheetRow(sheet, "A1", &headers); err != nil {
+		return err
+	}
+	f.SetCellStyle(sheet, "A1", "G1", s.header)
+
+	for i, m := range report.Months {
+		row := i + 2
+		values := []interface{}{
+			indonesianMonth(m.Month),
+			rupiah(m.Income), rupiah(m.Expense),
+			rupiah(m.CumulativeIncome), rupiah(m.CumulativeExpense),
+			rupiah(m.PlannedIncome), rupiah(m.PlannedExpense),
+		}
+		if err := f.SetSheetRow(sheet, fmt.Sprintf("A%d", row), &values); err != nil {
+			return err
+		}
+	}
+	last := len(report.Months) + 1
+	if last > 1 {
+		f.SetCellStyle(sheet, "B2", fmt.Sprintf("G%d", last), s.money)
+	}
+	f.SetColWidth(sheet, "A", "A", 18)
+	f.SetColWidth(sheet, "B", "G", 20)
+	if last < 2 {
+		return nil
+	}
+
+	// Burn charts: actual running totals against the evenly spread plan
+	categories := fmt.Sprintf("%s!$A$2:$A$%d", sheet, last)
+	series := func(col string) excelize.ChartSeries {
+		return excelize.ChartSeries{
+			Name:       fmt.Sprintf("%s!$%s$1", sheet, col),
+			Categories: categories,
+			Values:     fmt.Sprintf("%s!$%s$2:$%s$%d", sheet, col, col, last),
+		}
+	}
+	charts := []struct {
+		cell, title  string
+		actual, plan string
+	}{
+		{"I2", "Realisasi Belanja vs Rencana", "E", "G"},
+		{"I20", "Realisasi Pendapatan vs Rencana", "D", "F"},
+	}
+	for _, c := range charts {
+		if err := f.AddChart(sheet, c.cell, &excelize.Chart{
+			Type:      excelize.Line,
+			Series:    []excelize.ChartSeries{series(c.actual), series(c.plan)},
+			Title:     []excelize.RichTextRun{{Text: c.title}},
+			Legend:    excelize.ChartLegend{Position: "bottom"},
+			Dimension: excelize.ChartDimension{Width: 640, Height: 320},
+			YAxis:     excelize.ChartAxis{MajorGridLines: true, NumFmt: excelize.ChartNumFmt{CustomNumFmt: "#,##0"}},
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Only confirmed donations count towards a campaign's progress.
+type CampaignProgress struct {
+	CollectedAmount models.Money `json:"collected_amount"`
+	TargetAmount    models.Money `json:"target_amount"`
+	Percentage      float64      `json:"percentage"`
+	DonorCount      int64        `json:"donor_count"`
+	DonationCount   int64        `json:"donation_count"`
+	DaysLeft        *int         `json:"days_left,omitempty"`
+}
+
+// donorIdentity groups donations into one donor: the linked donor profile,
+// or the inline donor fields for donations that have none.
+const donorIdentity = "COALESCE(donor_id::text, LOWER(donor_email), donor_phone, LOWER(donor_name))"
+
+// GetCampaignsProgress computes progress for several campaigns at once.
+func GetCampaignsProgress(db *gorm.DB, campaigns []models.Campaign) (map[uuid.UUID]CampaignProgress, error) {
+	ids := make([]uuid.UUID, len(campaigns))
+	for i, campaign := range campaigns {
+		ids[i] = campaign.ID
+	}
+
+	var rows []struct {
+		CampaignID    uuid.UUID
+		Total         models.Money
+		DonorCount    int64
+		DonationCount int64
+	}
+	if len(ids) > 0 {
+		err := db.Model(&models.Donation{}).
+			Select("campaign_id, COALESCE(SUM(amount), 0) as total, COUNT(DISTINCT "+donorIdentity+") as donor_count, COUNT(*) as donation_count").
+			Where("status = ? AND campaign_id IN ?", models.DonationStatusConfirmed, ids).
+			Group("campaign_id").
+			Scan(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	progress := make(map[uuid.UUID]CampaignProgress, len(campaigns))
+	for _, campaign := range campaigns {
+		progress[campaign.ID] = newCampaignProgress(campaign)
+	}
+	for _, row := range rows {
+		p := progress[row.CampaignID]
+		p.CollectedAmount, p.DonorCount, p.DonationCount = row.Total, row.DonorCount, row.DonationCount
+		if p.TargetAmount > 0 {
+			p.Percentage = float64(p.CollectedAmount) / float64(p.TargetAmount) * 100
+		}
+		progress[row.CampaignID] = p
+	}
+	return progress, nil
+}
+
+func newCampaignProgress(campaign models.Campaign) CampaignProgress {
+	p := CampaignProgress{TargetAmount: campaign.TargetAmount}
+	if campaign.Deadline != nil {
+		days := int(time.Until(campaign.Deadline.AddDate(0, 0, 1)).Hours() / 24)
+		if days < 0 {
+			days = 0
+		}
+		p.DaysLeft = &days
+	}
+	return p
+}
+
+// GetRecentCampaignDonors lists the latest confirmed donations to a campaign
+// the same way the public donor wall does.
+func GetRecentCampaignDonors(db *gorm.DB, campaignID uuid.UUID, limit int) ([]DonorWallEntry, error) {
+	donors, _, err := GetDonorWall(db, DonorWallFilter{CampaignID: &campaignID}, 0, limit)
+	return donors, err
+}
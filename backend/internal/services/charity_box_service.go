@@ -0,0 +1,435 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SettingCharityCountWitnesses is how many users must sign a count before it
+// is posted; it is never less than two.
+const SettingCharityCountWitnesses = "charity_count_witnesses"
+
+const CharityBoxDonorName = "Kotak Amal"
+
+var (
+	ErrCharityCountLocked     = errors.New("count has been signed and can no longer be changed, start a recount instead")
+	ErrCharityCountPosted     = errors.New("count has already been posted")
+	ErrCharityCountSuperseded = errors.New("count has been superseded by a recount")
+	ErrCharityCountEmpty      = errors.New("count has no money counted")
+	ErrCharityCountSigned     = errors.New("you have already signed this count")
+	ErrCharityDenomination    = errors.New("invalid denomination")
+	ErrCharityQuantity        = errors.New("quantity can't be negative")
+	ErrCharityBoxUnknown      = errors.New("unknown charity box")
+	ErrCharityBoxTwice        = errors.New("each box can only be listed once per count")
+	ErrCharityCategory        = errors.New("invalid donation category")
+	ErrCharityRecountNote     = errors.New("a recount needs a note explaining why")
+)
+
+// CharityBoxCount is what was counted in one box: quantity per denomination
+// in rupiah, e.g. {"100000": 3, "500": 12}.
+type CharityBoxCount struct {
+	BoxID         uuid.UUID      `json:"box_id" binding:"required"`
+	Denominations map[string]int `json:"denominations"`
+}
+
+func charityWitnessesRequired(db *gorm.DB) int {
+	n := int(GetSettingInt(db, SettingCharityCountWitnesses, 2))
+	if n < 2 {
+		n = 2
+	}
+	return n
+}
+
+// buildCharityCountLines validates the counted boxes and turns them into
+// lines, returning the lines and their total.
+func buildCharityCountLines(tx *gorm.DB, countID uuid.UUID, boxes []CharityBoxCount) ([]models.CharityCountLine, models.Money, error) {
+	var lines []models.CharityCountLine
+	var total models.Money
+	seen := make(map[uuid.UUID]bool, len(boxes))
+
+	for _, box := range boxes {
+		if seen[box.BoxID] {
+			return nil, 0, ErrCharityBoxTwice
+		}
+		seen[box.BoxID] = true
+		if err := tx.First(&models.CharityBox{}, "id = ?", box.BoxID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, 0, ErrCharityBoxUnknown
+			}
+			return nil, 0, err
+		}
+
+		for key, quantity := range box.Denominations {
+			var rupiah int64
+			if _, err := fmt.Sscan(key, &rupiah); err != nil || !models.ValidDenomination(rupiah) {
+				return nil, 0, fmt.Errorf("%w: %s", ErrCharityDenomination, key)
+			}
+			if quantity < 0 {
+				return nil, 0, ErrCharityQuantity
+			}
+			if quantity == 0 {
+				continue
+			}
+			subtotal := models.NewMoney(rupiah * int64(quantity))
+			lines = append(lines, models.CharityCountLine{
+				CountID:      countID,
+				BoxID:        box.BoxID,
+				Denomination: rupiah,
+				Quantity:     quantity,
+				Subtotal:     subtotal,
+			})
+			total += subtotal
+		}
+	}
+	return lines, total, nil
+}
+
+func validCharityCategory(category models.DonationCategory) bool {
+	for _, c := range models.AllDonationCategories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateCharityCount starts a counting session, optionally as a recount of
+// an earlier one. The recounted session keeps its values and is marked
+// superseded.
+func CreateCharityCount(db *gorm.DB, count *models.CharityCount, boxes []CharityBoxCount, actorID uuid.UUID) error {
+	if count.Category == "" {
+		count.Category = models.DonationCategoryInfaq
+	}
+	if !validCharityCategory(count.Category) {
+		return ErrCharityCategory
+	}
+	count.ID = uuid.New()
+	count.Status = models.CharityCountDraft
+	count.CreatedBy = actorID
+	count.SupersededBy = nil
+	count.DonationID = nil
+	count.PostedAt = nil
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if count.RecountOf != nil {
+			if strings.TrimSpace(count.RecountNote) == "" {
+				return ErrCharityRecountNote
+			}
+			var original models.CharityCount
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&original, "id = ?", count.RecountOf).Error; err != nil {
+				return err
+			}
+			if original.Status == models.CharityCountSuperseded {
+				return ErrCharityCountSuperseded
+			}
+			if err := tx.Model(&original).Updates(map[string]interface{}{
+				"status":        models.CharityCountSuperseded,
+				"superseded_by": count.ID,
+			}).Error; err != nil {
+				return err
+			}
+			if count.CountDate.IsZero() {
+				count.CountDate = original.CountDate
+			}
+		}
+		if count.CountDate.IsZero() {
+			count.CountDate = time.Now()
+		}
+
+		lines, total, err := buildCharityCountLines(tx, count.ID, boxes)
+		if err != nil {
+			return err
+		}
+		count.Total = total
+		if err := tx.Omit("Lines", "Signoffs", "Creator").Create(count).Error; err != nil {
+			return err
+		}
+		if len(lines) > 0 {
+			if err := tx.Create(&lines).Error; err != nil {
+				return err
+			}
+		}
+		count.Lines = lines
+		return nil
+	})
+}
+
+// UpdateCharityCountLines replaces the counted values of a session nobody
+// has signed yet.
+func UpdateCharityCountLines(db *gorm.DB, countID uuid.UUID, boxes []CharityBoxCount) (*models.CharityCount, error) {
+	var count models.CharityCount
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&count, "id = ?", countID).Error; err != nil {
+			return err
+		}
+		if err := checkCharityCountEditable(tx, &count); err != nil {
+			return err
+		}
+
+		lines, total, err := buildCharityCountLines(tx, count.ID, boxes)
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("count_id = ?", count.ID).Delete(&models.CharityCountLine{}).Error; err != nil {
+			return err
+		}
+		if len(lines) > 0 {
+			if err := tx.Create(&lines).Error; err != nil {
+				return err
+			}
+		}
+		count.Lines = lines
+		count.Total = total
+		return tx.Model(&count).Update("total", total).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &count, nil
+}
+
+func checkCharityCountEditable(tx *gorm.DB, count *models.CharityCount) error {
+	switch count.Status {
+	case models.CharityCountPosted:
+		return ErrCharityCountPosted
+	case models.CharityCountSuperseded:
+		return ErrCharityCountSuperseded
+	}
+	var signoffs int64
+	if err := tx.Model(&models.CharityCountSignoff{}).Where("count_id = ?", count.ID).Count(&signoffs).Error; err != nil {
+		return err
+	}
+	if signoffs > 0 {
+		return ErrCharityCountLocked
+	}
+	return nil
+}
+
+// DeleteCharityCount discards an unsigned draft. Discarding a recount puts
+// the session it would have replaced back in force.
+func DeleteCharityCount(db *gorm.DB, countID uuid.UUID) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var count models.CharityCount
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&count, "id = ?", countID).Error; err != nil {
+			return err
+		}
+		if err := checkCharityCountEditable(tx, &count); err != nil {
+			return err
+		}
+
+		if count.RecountOf != nil {
+			var original models.CharityCount
+			if err := tx.First(&original, "id = ?", count.RecountOf).Error; err != nil {
+				return err
+			}
+			status := models.CharityCountDraft
+			if original.PostedAt != nil {
+				status = models.CharityCountPosted
+			}
+			if err := tx.Model(&original).Updates(map[string]interface{}{
+				"status":        status,
+				"superseded_by": nil,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("count_id = ?", count.ID).Delete(&models.CharityCountLine{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&count).Error
+	})
+}
+
+// SignCharityCount records the actor as a witness of the count. When enough
+// witnesses have signed, the count is posted as a confirmed donation, which
+// books it in the cash book; a posted recount refunds the donation of the
+// session it corrects so only the corrected amount remains booked.
+func SignCharityCount(db *gorm.DB, countID, actorID uuid.UUID) (*models.CharityCount, error) {
+	var count models.CharityCount
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&count, "id = ?", countID).Error; err != nil {
+			return err
+		}
+		switch count.Status {
+		case models.CharityCountPosted:
+			return ErrCharityCountPosted
+		case models.CharityCountSuperseded:
+			return ErrCharityCountSuperseded
+		}
+		if count.Total <= 0 {
+			return ErrCharityCountEmpty
+		}
+
+		var existing int64
+		if err := tx.Model(&models.CharityCountSignoff{}).
+			Where("count_id = ? AND user_id = ?", count.ID, actorID).
+			Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return ErrCharityCountSigned
+		}
+		if err := tx.Create(&models.CharityCountSignoff{
+			CountID:  count.ID,
+			UserID:   actorID,
+			SignedAt: time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+
+		var signoffs int64
+		if err := tx.Model(&models.CharityCountSignoff{}).Where("count_id = ?", count.ID).Count(&signoffs).Error; err != nil {
+			return err
+		}
+		if int(signoffs) < charityWitnessesRequired(tx) {
+			return nil
+		}
+		return postCharityCount(tx, &count, actorID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &count, nil
+}
+
+func postCharityCount(tx *gorm.DB, count *models.CharityCount, actorID uuid.UUID) error {
+	var boxCodes []string
+	if err := tx.Model(&models.CharityBox{}).
+		Where("id IN (SELECT box_id FROM charity_count_lines WHERE count_id = ?)", count.ID).
+		Order("code ASC").
+		Pluck("code", &boxCodes).Error; err != nil {
+		return err
+	}
+
+	notes := fmt.Sprintf("Penghitungan kotak amal %s (%s)",
+		FormatIndonesianDate(count.CountDate.Format(dateLayout)), strings.Join(boxCodes, ", "))
+	if count.RecountOf != nil {
+		notes += ", hitung ulang: " + count.RecountNote
+	}
+	donation := models.Donation{
+		DonationCode:   GenerateDonationCode(),
+		DonorName:      CharityBoxDonorName,
+		Amount:         count.Total,
+		TransferAmount: count.Total,
+		Category:       count.Category,
+		Notes:          notes,
+		Status:         models.DonationStatusPending,
+		HideFromWall:   true,
+	}
+	if err := tx.Create(&donation).Error; err != nil {
+		return err
+	}
+	if _, err := TransitionDonation(tx, donation.ID, models.DonationStatusConfirmed, actorID, notes); err != nil {
+		return err
+	}
+
+	// Reverse what the corrected session booked
+	if count.RecountOf != nil {
+		var original models.CharityCount
+		if err := tx.First(&original, "id = ?", count.RecountOf).Error; err != nil {
+			return err
+		}
+		if original.DonationID != nil {
+			reason := "Dikoreksi oleh hitung ulang: " + count.RecountNote
+			if _, err := TransitionDonation(tx, *original.DonationID, models.DonationStatusRefunded, actorID, reason); err != nil {
+				return err
+			}
+		}
+	}
+
+	now := time.Now()
+	count.Status = models.CharityCountPosted
+	count.DonationID = &donation.ID
+	count.PostedAt = &now
+	return tx.Model(count).Updates(map[string]interface{}{
+		"status":      count.Status,
+		"donation_id": count.DonationID,
+		"posted_at":   count.PostedAt,
+	}).Error
+}
+
+type CharityBoxTotal struct {
+	BoxID uuid.UUID    `json:"box_id"`
+	Code  string       `json:"code"`
+	Total models.Money `json:"total"`
+}
+
+type CharityDenominationTotal struct {
+	Denomination int64        `json:"denomination"`
+	Quantity     int          `json:"quantity"`
+	Total        models.Money `json:"total"`
+}
+
+type CharityCountDetail struct {
+	models.CharityCount
+	ByBox             []CharityBoxTotal          `json:"by_box"`
+	ByDenomination    []CharityDenominationTotal `json:"by_denomination"`
+	WitnessesRequired int                        `json:"witnesses_required"`
+	Recounts          []models.CharityCount      `json:"recounts"` // later recounts of this session, oldest first
+}
+
+// GetCharityCount loads a session with its totals per box and denomination
+// and the chain of recounts that followed it.
+func GetCharityCount(db *gorm.DB, countID uuid.UUID) (*CharityCountDetail, error) {
+	detail := &CharityCountDetail{
+		ByBox:             []CharityBoxTotal{},
+		ByDenomination:    []CharityDenominationTotal{},
+		WitnessesRequired: charityWitnessesRequired(db),
+		Recounts:          []models.CharityCount{},
+	}
+	if err := db.Preload("Lines.Box").Preload("Signoffs.User").Preload("Creator").
+		First(&detail.CharityCount, "id = ?", countID).Error; err != nil {
+		return nil, err
+	}
+
+	byBox := make(map[uuid.UUID]*CharityBoxTotal)
+	byDenomination := make(map[int64]*CharityDenominationTotal)
+	for _, line := range detail.Lines {
+		b := byBox[line.BoxID]
+		if b == nil {
+			b = &CharityBoxTotal{BoxID: line.BoxID}
+			if line.Box != nil {
+				b.Code = line.Box.Code
+			}
+			byBox[line.BoxID] = b
+		}
+		b.Total += line.Subtotal
+
+		d := byDenomination[line.Denomination]
+		if d == nil {
+			d = &CharityDenominationTotal{Denomination: line.Denomination}
+			byDenomination[line.Denomination] = d
+		}
+		d.Quantity += line.Quantity
+		d.Total += line.Subtotal
+	}
+	for _, b := range byBox {
+		detail.ByBox = append(detail.ByBox, *b)
+	}
+	sort.Slice(detail.ByBox, func(i, j int) bool { return detail.ByBox[i].Code < detail.ByBox[j].Code })
+	for _, denomination := range models.RupiahDenominations {
+		if d := byDenomination[denomination]; d != nil {
+			detail.ByDenomination = append(detail.ByDenomination, *d)
+		}
+	}
+
+	next := detail.SupersededBy
+	for next != nil && len(detail.Recounts) < 50 {
+		var recount models.CharityCount
+		if err := db.First(&recount, "id = ?", *next).Error; err != nil {
+			break
+		}
+		detail.Recounts = append(detail.Recounts, recount)
+		next = recount.SupersededBy
+	}
+	return detail, nil
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"masjid-baiturrahim-backend/internal/models"
+	"masjid-baiturrahim-backend/internal/testutil"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// signedCount has each witness sign the count in turn.
+func signedCount(t *testing.T, db *gorm.DB, countID uuid.UUID, witnesses ...models.User) *models.CharityCount {
+	t.Helper()
+	var count *models.CharityCount
+	for _, w := range witnesses {
+		var err error
+		if count, err = SignCharityCount(db, countID, w.ID); err != nil {
+			t.Fatalf("%s signs: %v", w.Username, err)
+		}
+	}
+	return count
+}
+
+// bookedForDonations is what the cash book holds for donations: income less
+// refunds.
+func bookedForDonations(t *testing.T, db *gorm.DB, ids ...uuid.UUID) models.Money {
+	t.Helper()
+	var booked models.Money
+	if err := db.Model(&models.LedgerEntry{}).
+		Select("COALESCE(SUM(CASE WHEN type = ? THEN amount ELSE -amount END), 0)", models.LedgerIncome).
+		Where("donation_id IN ?", ids).
+		Scan(&booked).Error; err != nil {
+		t.Fatal(err)
+	}
+	return booked
+}
+
+func TestCharityCountRecount(t *testing.T) {
+	db := testutil.NewDB(t)
+	counter := testutil.NewUser(t, db, models.RoleTreasurer, "-counter")
+	first := testutil.NewUser(t, db, models.RoleEditor, "-witness1")
+	second := testutil.NewUser(t, db, models.RoleEditor, "-witness2")
+
+	box := models.CharityBox{Code: "KA-01", Location: "Pintu utama", IsActive: true}
+	if err := db.Create(&box).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Rp550.000 counted after Jumat
+	original := &models.CharityCount{}
+	counted := []CharityBoxCount{{BoxID: box.ID, Denominations: map[string]int{"100000": 5, "5000": 10}}}
+	if err := CreateCharityCount(db, original, counted, counter.ID); err != nil {
+		t.Fatal(err)
+	}
+	if original.Total != models.NewMoney(550000) {
+		t.Fatalf("total = %s, want Rp550.000", original.Total.Format())
+	}
+
+	if count := signedCount(t, db, original.ID, first); count.Status != models.CharityCountDraft {
+		t.Errorf("after one witness the count is %s, want draft", count.Status)
+	}
+	if _, err := SignCharityCount(db, original.ID, first.ID); !errors.Is(err, ErrCharityCountSigned) {
+		t.Errorf("signing twice: err = %v, want ErrCharityCountSigned", err)
+	}
+	if _, err := UpdateCharityCountLines(db, original.ID, counted); !errors.Is(err, ErrCharityCountLocked) {
+		t.Errorf("editing a signed count: err = %v, want ErrCharityCountLocked", err)
+	}
+	posted := signedCount(t, db, original.ID, second)
+	if posted.Status != models.CharityCountPosted || posted.DonationID == nil {
+		t.Fatalf("after two witnesses the count is %s with donation %v, want posted", posted.Status, posted.DonationID)
+	}
+	if booked := bookedForDonations(t, db, *posted.DonationID); booked != models.NewMoney(550000) {
+		t.Errorf("booked %s for the count, want Rp550.000", booked.Format())
+	}
+
+	// A Rp50.000 note turns out to have been a Rp5.000 one
+	if err := CreateCharityCount(db, &models.CharityCount{RecountOf: &original.ID}, counted, counter.ID); !errors.Is(err, ErrCharityRecountNote) {
+		t.Errorf("recount without a note: err = %v, want ErrCharityRecountNote", err)
+	}
+	recount := &models.CharityCount{RecountOf: &original.ID, RecountNote: "Salah hitung pecahan"}
+	corrected := []CharityBoxCount{{BoxID: box.ID, Denominations: map[string]int{"100000": 4, "50000": 1, "5000": 10}}}
+	if err := CreateCharityCount(db, recount, corrected, counter.ID); err != nil {
+		t.Fatal(err)
+	}
+	if recount.Total != models.NewMoney(500000) || !recount.CountDate.Equal(original.CountDate) {
+		t.Errorf("recount total %s on %v, want Rp500.000 on the original date", recount.Total.Format(), recount.CountDate)
+	}
+
+	var superseded models.CharityCount
+	db.First(&superseded, "id = ?", original.ID)
+	if superseded.Status != models.CharityCountSuperseded || superseded.SupersededBy == nil || *superseded.SupersededBy != recount.ID {
+		t.Errorf("original is %s superseded by %v, want superseded by the recount", superseded.Status, superseded.SupersededBy)
+	}
+	if _, err := SignCharityCount(db, original.ID, counter.ID); !errors.Is(err, ErrCharityCountSuperseded) {
+		t.Errorf("signing the superseded count: err = %v, want ErrCharityCountSuperseded", err)
+	}
+	if err := CreateCharityCount(db, &models.CharityCount{RecountOf: &original.ID, RecountNote: "Lagi"}, corrected, counter.ID); !errors.Is(err, ErrCharityCountSuperseded) {
+		t.Errorf("recounting a superseded count: err = %v, want ErrCharityCountSuperseded", err)
+	}
+
+	// Nothing changes in the cash book until the recount is signed
+	if booked := bookedForDonations(t, db, *posted.DonationID); booked != models.NewMoney(550000) {
+		t.Errorf("booked %s before the recount was signed, want Rp550.000", booked.Format())
+	}
+
+	reposted := signedCount(t, db, recount.ID, first, second)
+	if reposted.Status != models.CharityCountPosted || reposted.DonationID == nil {
+		t.Fatalf("recount is %s with donation %v, want posted", reposted.Status, reposted.DonationID)
+	}
+
+	var refunded models.Donation
+	db.First(&refunded, "id = ?", *posted.DonationID)
+	if refunded.Status != models.DonationStatusRefunded {
+		t.Errorf("original donation is %s, want refunded", refunded.Status)
+	}
+	if booked := bookedForDonations(t, db, *posted.DonationID, *reposted.DonationID); booked != models.NewMoney(500000) {
+		t.Errorf("booked %s after the recount, want only the corrected Rp500.000", booked.Format())
+	}
+	if booked := bookedForDonations(t, db, *posted.DonationID); booked != 0 {
+		t.Errorf("original donation still books %s", booked.Format())
+	}
+}
+
+func TestCharityCountRecountBeforePosting(t *testing.T) {
+	db := testutil.NewDB(t)
+	counter := testutil.NewUser(t, db, models.RoleTreasurer, "-counter")
+	first := testutil.NewUser(t, db, models.RoleEditor, "-witness1")
+	second := testutil.NewUser(t, db, models.RoleEditor, "-witness2")
+
+	box := models.CharityBox{Code: "KA-02", IsActive: true}
+	if err := db.Create(&box).Error; err != nil {
+		t.Fatal(err)
+	}
+	counted := []CharityBoxCount{{BoxID: box.ID, Denominations: map[string]int{"20000": 10}}}
+
+	// Signed by one witness, then found to be wrong: nothing was booked yet
+	original := &models.CharityCount{}
+	if err := CreateCharityCount(db, original, counted, counter.ID); err != nil {
+		t.Fatal(err)
+	}
+	signedCount(t, db, original.ID, first)
+
+	recount := &models.CharityCount{RecountOf: &original.ID, RecountNote: "Satu lembar terselip"}
+	if err := CreateCharityCount(db, recount, []CharityBoxCount{{BoxID: box.ID, Denominations: map[string]int{"20000": 11}}}, counter.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Discarding the recount puts the original back
+	if err := DeleteCharityCount(db, recount.ID); err != nil {
+		t.Fatal(err)
+	}
+	var restored models.CharityCount
+	db.First(&restored, "id = ?", original.ID)
+	if restored.Status != models.CharityCountDraft || restored.SupersededBy != nil {
+		t.Errorf("original is %s superseded by %v after the recount was discarded, want draft", restored.Status, restored.SupersededBy)
+	}
+
+	recount = &models.CharityCount{RecountOf: &original.ID, RecountNote: "Satu lembar terselip"}
+	if err := CreateCharityCount(db, recount, []CharityBoxCount{{BoxID: box.ID, Denominations: map[string]int{"20000": 11}}}, counter.ID); err != nil {
+		t.Fatal(err)
+	}
+	posted := signedCount(t, db, recount.ID, first, second)
+	if posted.Status != models.CharityCountPosted {
+		t.Fatalf("recount is %s, want posted", posted.Status)
+	}
+
+	var donations []models.Donation
+	db.Where("donor_name = ?", CharityBoxDonorName).Find(&donations)
+	if len(donations) != 1 || donations[0].Status != models.DonationStatusConfirmed || donations[0].Amount != models.NewMoney(220000) {
+		t.Errorf("charity box donations = %+v, want one confirmed Rp220.000", donations)
+	}
+}
@@ -0,0 +1,337 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const distributionLockKey = 7202 // pg advisory lock serialising fund balance checks
+
+var (
+	ErrMustahikNotVerified = errors.New("mustahik has not been verified")
+	ErrDistributionEmpty   = errors.New("distribution needs an amount or rice")
+	ErrDistributionAccount = errors.New("cash distributions need an active account paying them")
+	ErrDistributionRice    = errors.New("rice can only be distributed from zakat")
+	ErrInsufficientFunds   = errors.New("not enough collected funds in this category")
+	ErrInsufficientRice    = errors.New("not enough collected rice")
+	ErrInvalidVerification = errors.New("status must be verified or rejected")
+)
+
+// distributionCategoryCode is the ledger expense category distributions of a
+// donation category are booked to, e.g. "penyaluran-zakat".
+func distributionCategoryCode(category models.DonationCategory) string {
+	return "penyaluran-" + string(category)
+}
+
+func distributionLedgerCategory(tx *gorm.DB, category models.DonationCategory) (*models.LedgerCategory, error) {
+	ledgerCategory := models.LedgerCategory{
+		Code:     distributionCategoryCode(category),
+		Name:     fmt.Sprintf("Penyaluran %s", category),
+		Type:     models.LedgerExpense,
+		IsActive: true,
+	}
+	err := tx.Where("code = ?", ledgerCategory.Code).FirstOrCreate(&ledgerCategory).Error
+	return &ledgerCategory, err
+}
+
+// VerifyMustahik records the outcome of a home visit or document check.
+func VerifyMustahik(db *gorm.DB, id uuid.UUID, status models.VerificationStatus, notes string, actorID uuid.UUID) (*models.Mustahik, error) {
+	if status != models.VerificationVerified && status != models.VerificationRejected {
+		return nil, ErrInvalidVerification
+	}
+
+	var mustahik models.Mustahik
+	if err := db.First(&mustahik, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	err := db.Model(&mustahik).Updates(map[string]interface{}{
+		"verification_status": status,
+		"verification_notes":  notes,
+		"verified_by":         actorID,
+		"verified_at":         now,
+	}).Error
+	return &mustahik, err
+}
+
+type FundBalance struct {
+	Category        models.DonationCategory `json:"category"`
+	Collected       models.Money            `json:"collected"`
+	Distributed     models.Money            `json:"distributed"`
+	Available       models.Money            `json:"available"`
+	RiceCollected   float64                 `json:"rice_collected_kg"`
+	RiceDistributed float64                 `json:"rice_distributed_kg"`
+	RiceAvailable   float64                 `json:"rice_available_kg"`
+}
+
+// GetFundBalance compares everything collected for a donation category with
+// everything distributed from it so far.
+func GetFundBalance(db *gorm.DB, category models.DonationCategory) (*FundBalance, error) {
+	balance := &FundBalance{Category: category}
+
+	if err := db.Model(&models.Donation{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("category = ? AND status = ?", category, models.DonationStatusConfirmed).
+		Scan(&balance.Collected).Error; err != nil {
+		return nil, err
+	}
+
+	var distributed struct {
+		Amount models.Money
+		RiceKg float64
+	}
+	if err := db.Model(&models.Distribution{}).
+		Select("COALESCE(SUM(amount), 0) as amount, COALESCE(SUM(rice_kg), 0) as rice_kg").
+		Where("source_category = ?", category).
+		Scan(&distributed).Error; err != nil {
+		return nil, err
+	}
+	balance.Distributed = distributed.Amount
+	balance.RiceDistributed = distributed.RiceKg
+
+	// Rice only comes in as zakat fitrah
+	if category == models.DonationCategoryZakat {
+		if err := db.Model(&models.ZakatPayment{}).
+			Select("COALESCE(SUM(rice_kg), 0)").
+			Scan(&balance.RiceCollected).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	balance.Available = balance.Collected - balance.Distributed
+	balance.RiceAvailable = math.Round((balance.RiceCollected-balance.RiceDistributed)*100) / 100
+	return balance, nil
+}
+
+func validateDistribution(distribution *models.Distribution) error {
+	if distribution.Amount < 0 || distribution.RiceKg < 0 {
+		return ErrZakatNegative
+	}
+	if distribution.Amount == 0 && distribution.RiceKg == 0 {
+		return ErrDistributionEmpty
+	}
+	if distribution.Amount > 0 && distribution.AccountID == nil {
+		return ErrDistributionAccount
+	}
+	if distribution.RiceKg > 0 && distribution.SourceCategory != models.DonationCategoryZakat {
+		return ErrDistributionRice
+	}
+	if distribution.DistributedAt.IsZero() {
+		distribution.DistributedAt = time.Now()
+	}
+	return nil
+}
+
+// checkDistribution checks that the mustahik is verified and the source
+// category still holds enough funds. The balance stays locked until the
+// transaction ends.
+func checkDistribution(tx *gorm.DB, distribution *models.Distribution) (*models.Mustahik, error) {
+	var mustahik models.Mustahik
+	if err := tx.Clauses(clause.Locking{Strength: "SHARE"}).First(&mustahik, "id = ?", distribution.MustahikID).Error; err != nil {
+		return nil, err
+	}
+	if mustahik.VerificationStatus != models.VerificationVerified {
+		return nil, ErrMustahikNotVerified
+	}
+
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", distributionLockKey).Error; err != nil {
+		return nil, err
+	}
+	balance, err := GetFundBalance(tx, distribution.SourceCategory)
+	if err != nil {
+		return nil, err
+	}
+	if distribution.Amount > balance.Available {
+		return nil, fmt.Errorf("%w: %s available", ErrInsufficientFunds, balance.Available.Format())
+	}
+	if distribution.RiceKg > balance.RiceAvailable {
+		return nil, fmt.Errorf("%w: %.2f kg available", ErrInsufficientRice, balance.RiceAvailable)
+	}
+	return &mustahik, nil
+}
+
+// RecordDistribution saves a distribution to a verified mustahik after
+// checking the source category still holds enough funds, and books its cash
+// part as an expense of the paying account. Amounts above the approval
+// threshold go through RequestDistribution instead.
+func RecordDistribution(db *gorm.DB, distribution *models.Distribution, actorID uuid.UUID) error {
+	if err := validateDistribution(distribution); err != nil {
+		return err
+	}
+	distribution.CreatedBy = actorID
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		return recordDistribution(tx, distribution)
+	})
+}
+
+func recordDistribution(tx *gorm.DB, distribution *models.Distribution) error {
+	mustahik, err := checkDistribution(tx, distribution)
+	if err != nil {
+		return err
+	}
+	distribution.Asnaf = mustahik.Asnaf
+
+	if distribution.Amount > 0 {
+		category, err := distributionLedgerCategory(tx, distribution.SourceCategory)
+		if err != nil {
+			return err
+		}
+		entry := models.LedgerEntry{
+			AccountID:   *distribution.AccountID,
+			CategoryID:  category.ID,
+			Type:        models.LedgerExpense,
+			Amount:      distribution.Amount,
+			EntryDate:   distribution.DistributedAt,
+			Description: fmt.Sprintf("Penyaluran %s kepada %s (%s)", distribution.SourceCategory, mustahik.Name, mustahik.Asnaf),
+			Source:      models.LedgerSourceDistribution,
+			CreatedBy:   &distribution.CreatedBy,
+		}
+		if err := ValidateLedgerEntry(tx, &entry); err != nil {
+			return fmt.Errorf("%w (%s)", ErrDistributionAccount, err.Error())
+		}
+		if err := tx.Create(&entry).Error; err != nil {
+			return err
+		}
+		distribution.LedgerEntryID = &entry.ID
+	}
+
+	return tx.Omit("Mustahik", "Creator").Create(distribution).Error
+}
+
+// DeleteDistribution removes a distribution recorded in error together with
+// its ledger entry.
+func DeleteDistribution(db *gorm.DB, id uuid.UUID) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var distribution models.Distribution
+		if err := tx.First(&distribution, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if distribution.LedgerEntryID != nil {
+			if err := tx.Delete(&models.LedgerEntry{}, "id = ? AND source = ?", distribution.LedgerEntryID, models.LedgerSourceDistribution).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&distribution).Error
+	})
+}
+
+type CategoryFlow struct {
+	Category        models.DonationCategory `json:"category"`
+	Collected       models.Money            `json:"collected"`
+	Distributed     models.Money            `json:"distributed"`
+	RiceCollected   float64                 `json:"rice_collected_kg"`
+	RiceDistributed float64                 `json:"rice_distributed_kg"`
+}
+
+type AsnafDistribution struct {
+	Asnaf         models.Asnaf `json:"asnaf"`
+	Recipients    int64        `json:"recipients"`
+	Distributions int64        `json:"distributions"`
+	Amount        models.Money `json:"amount"`
+	RiceKg        float64      `json:"rice_kg"`
+}
+
+// DistributionReport is the collected-versus-distributed report LAZ and
+// BAZNAS ask for.
+type DistributionReport struct {
+	From       string              `json:"from"`
+	To         string              `json:"to"`
+	ByCategory []CategoryFlow      `json:"by_category"`
+	ByAsnaf    []AsnafDistribution `json:"by_asnaf"`
+}
+
+// GetDistributionReport covers the dates from and to, inclusive.
+func GetDistributionReport(db *gorm.DB, from, to time.Time) (*DistributionReport, error) {
+	report := &DistributionReport{From: from.Format(dateLayout), To: to.Format(dateLayout)}
+	end := to.AddDate(0, 0, 1)
+
+	var collected []struct {
+		Category models.DonationCategory
+		Amount   models.Money
+	}
+	if err := db.Model(&models.Donation{}).
+		Select("category, SUM(amount) as amount").
+		Where("status = ? AND confirmed_at >= ? AND confirmed_at < ?", models.DonationStatusConfirmed, from, end).
+		Group("category").
+		Scan(&collected).Error; err != nil {
+		return nil, err
+	}
+
+	var rice float64
+	if err := db.Model(&models.ZakatPayment{}).
+		Select("COALESCE(SUM(rice_kg), 0)").
+		Where("created_at >= ? AND created_at < ?", from, end).
+		Scan(&rice).Error; err != nil {
+		return nil, err
+	}
+
+	var distributed []struct {
+		SourceCategory models.DonationCategory
+		Amount         models.Money
+		RiceKg         float64
+	}
+	if err := db.Model(&models.Distribution{}).
+		Select("source_category, SUM(amount) as amount, SUM(rice_kg) as rice_kg").
+		Where("distributed_at BETWEEN ? AND ?", report.From, report.To).
+		Group("source_category").
+		Scan(&distributed).Error; err != nil {
+		return nil, err
+	}
+
+	flows := make(map[models.DonationCategory]*CategoryFlow)
+	flow := func(category models.DonationCategory) *CategoryFlow {
+		if flows[category] == nil {
+			flows[category] = &CategoryFlow{Category: category}
+		}
+		return flows[category]
+	}
+	for _, row := range collected {
+		flow(row.Category).Collected = row.Amount
+	}
+	if rice > 0 {
+		flow(models.DonationCategoryZakat).RiceCollected = rice
+	}
+	for _, row := range distributed {
+		f := flow(row.SourceCategory)
+		f.Distributed = row.Amount
+		f.RiceDistributed = row.RiceKg
+	}
+
+	report.ByCategory = []CategoryFlow{}
+	for _, category := range models.AllDonationCategories {
+		if f, ok := flows[category]; ok {
+			report.ByCategory = append(report.ByCategory, *f)
+		}
+	}
+
+	var byAsnaf []AsnafDistribution
+	if err := db.Model(&models.Distribution{}).
+		Select(`asnaf, COUNT(DISTINCT mustahik_id) as recipients, COUNT(*) as distributions,
+			SUM(amount) as amount, SUM(rice_kg) as rice_kg`).
+		Where("distributed_at BETWEEN ? AND ?", report.From, report.To).
+		Group("asnaf").
+		Scan(&byAsnaf).Error; err != nil {
+		return nil, err
+	}
+
+	// Every asnaf is listed, in the Quranic order, so gaps are visible
+	report.ByAsnaf = make([]AsnafDistribution, len(models.AllAsnaf))
+	for i, asnaf := range models.AllAsnaf {
+		report.ByAsnaf[i] = AsnafDistribution{Asnaf: asnaf}
+		for _, row := range byAsnaf {
+			if row.Asnaf == asnaf {
+				report.ByAsnaf[i] = row
+			}
+		}
+	}
+	return report, nil
+}
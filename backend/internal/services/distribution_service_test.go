@@ -0,0 +1,180 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+	"masjid-baiturrahim-backend/internal/testutil"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// confirmedDonation records a donation of amount to category as confirmed by
+// treasurer, with its ledger entry.
+func confirmedDonation(t *testing.T, db *gorm.DB, code string, category models.DonationCategory, amount models.Money, treasurer uuid.UUID) models.Donation {
+	t.Helper()
+	donation := pendingDonation(t, db, code, amount)
+	if err := db.Model(&donation).Update("category", category).Error; err != nil {
+		t.Fatal(err)
+	}
+	confirmed, err := TransitionDonation(db, donation.ID, models.DonationStatusConfirmed, treasurer, "Transfer masuk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *confirmed
+}
+
+func newMustahik(t *testing.T, db *gorm.DB, name string, status models.VerificationStatus) models.Mustahik {
+	t.Helper()
+	mustahik := models.Mustahik{
+		Name:               name,
+		Address:            "Jl. Masjid No. 1",
+		Asnaf:              models.AsnafFakir,
+		VerificationStatus: status,
+	}
+	if err := db.Create(&mustahik).Error; err != nil {
+		t.Fatal(err)
+	}
+	return mustahik
+}
+
+func TestRecordDistribution(t *testing.T) {
+	db := testutil.NewDB(t)
+	treasurer := testutil.NewUser(t, db, models.RoleTreasurer, "-distribution")
+	var account models.LedgerAccount
+	db.First(&account, "is_default = ?", true)
+
+	confirmedDonation(t, db, "DON-ZAKAT-1", models.DonationCategoryZakat, models.NewMoney(1000000), treasurer.ID)
+	confirmedDonation(t, db, "DON-ZAKAT-2", models.DonationCategoryZakat, models.NewMoney(500000), treasurer.ID)
+	// Pending donations haven't been collected yet
+	pending := pendingDonation(t, db, "DON-ZAKAT-3", models.NewMoney(700000))
+	db.Model(&pending).Update("category", models.DonationCategoryZakat)
+
+	muzakki := models.Muzakki{Name: "Hamba Allah"}
+	if err := db.Create(&muzakki).Error; err != nil {
+		t.Fatal(err)
+	}
+	fitrah := models.ZakatPayment{MuzakkiID: muzakki.ID, Type: models.ZakatFitrah, Year: 2026, PeopleCount: 4, RiceKg: 10, ReceivedBy: treasurer.ID}
+	if err := db.Create(&fitrah).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	verified := newMustahik(t, db, "Pak Ahmad", models.VerificationVerified)
+	unverified := newMustahik(t, db, "Bu Siti", models.VerificationPending)
+
+	// The cases run in order, each spending from what the earlier ones left
+	tests := []struct {
+		name     string
+		mustahik uuid.UUID
+		category models.DonationCategory
+		amount   int64
+		riceKg   float64
+		noAcct   bool
+		wantErr  error
+	}{
+		{name: "unverified mustahik", mustahik: unverified.ID, category: models.DonationCategoryZakat, amount: 100000, wantErr: ErrMustahikNotVerified},
+		{name: "more than collected", mustahik: verified.ID, category: models.DonationCategoryZakat, amount: 1500001, wantErr: ErrInsufficientFunds},
+		{name: "part of the funds", mustahik: verified.ID, category: models.DonationCategoryZakat, amount: 1000000},
+		{name: "more than is left", mustahik: verified.ID, category: models.DonationCategoryZakat, amount: 500001, wantErr: ErrInsufficientFunds},
+		{name: "the rest", mustahik: verified.ID, category: models.DonationCategoryZakat, amount: 500000},
+		{name: "nothing left", mustahik: verified.ID, category: models.DonationCategoryZakat, amount: 1, wantErr: ErrInsufficientFunds},
+		{name: "other category", mustahik: verified.ID, category: models.DonationCategoryInfaq, amount: 100000, wantErr: ErrInsufficientFunds},
+		{name: "rice", mustahik: verified.ID, category: models.DonationCategoryZakat, riceKg: 7.5},
+		{name: "more rice than is left", mustahik: verified.ID, category: models.DonationCategoryZakat, riceKg: 2.6, wantErr: ErrInsufficientRice},
+		{name: "rice from infaq", mustahik: verified.ID, category: models.DonationCategoryInfaq, riceKg: 1, wantErr: ErrDistributionRice},
+		{name: "empty", mustahik: verified.ID, category: models.DonationCategoryZakat, wantErr: ErrDistributionEmpty},
+		{name: "cash without an account", mustahik: verified.ID, category: models.DonationCategoryZakat, amount: 1, noAcct: true, wantErr: ErrDistributionAccount},
+	}
+	for _, tt := range tests {
+		distribution := &models.Distribution{
+			MustahikID:     tt.mustahik,
+			SourceCategory: tt.category,
+			Amount:         models.NewMoney(tt.amount),
+			RiceKg:         tt.riceKg,
+		}
+		if !tt.noAcct {
+			distribution.AccountID = &account.ID
+		}
+		err := RecordDistribution(db, distribution, treasurer.ID)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: err = %v, want %v", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if distribution.Asnaf != verified.Asnaf {
+			t.Errorf("%s: asnaf = %s, want %s", tt.name, distribution.Asnaf, verified.Asnaf)
+		}
+		if (distribution.LedgerEntryID != nil) != (tt.amount > 0) {
+			t.Errorf("%s: ledger entry = %v, want one only for cash", tt.name, distribution.LedgerEntryID)
+		}
+	}
+
+	balance, err := GetFundBalance(db, models.DonationCategoryZakat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.Collected != models.NewMoney(1500000) || balance.Distributed != models.NewMoney(1500000) || balance.Available != 0 {
+		t.Errorf("zakat balance = %s collected, %s distributed, %s available",
+			balance.Collected.Format(), balance.Distributed.Format(), balance.Available.Format())
+	}
+	if balance.RiceAvailable != 2.5 {
+		t.Errorf("rice available = %.2f kg, want 2.50", balance.RiceAvailable)
+	}
+
+	var expenses models.Money
+	db.Model(&models.LedgerEntry{}).Select("COALESCE(SUM(amount), 0)").
+		Where("source = ?", models.LedgerSourceDistribution).Scan(&expenses)
+	if expenses != models.NewMoney(1500000) {
+		t.Errorf("distribution expenses = %s, want Rp1.500.000", expenses.Format())
+	}
+}
+
+func TestApproveDistributionRechecksFunds(t *testing.T) {
+	db := testutil.NewDB(t)
+	requester := testutil.NewUser(t, db, models.RoleTreasurer, "-requester")
+	approver := testutil.NewUser(t, db, models.RoleTreasurer, "-approver")
+	var account models.LedgerAccount
+	db.First(&account, "is_default = ?", true)
+
+	confirmedDonation(t, db, "DON-SEDEKAH-1", models.DonationCategorySedekah, models.NewMoney(2000000), requester.ID)
+	mustahik := newMustahik(t, db, "Pak Ahmad", models.VerificationVerified)
+
+	distribution := func(amount int64) *models.Distribution {
+		return &models.Distribution{
+			MustahikID:     mustahik.ID,
+			SourceCategory: models.DonationCategorySedekah,
+			Amount:         models.NewMoney(amount),
+			AccountID:      &account.ID,
+			DistributedAt:  time.Now(),
+		}
+	}
+
+	if _, err := RequestDistribution(db, distribution(2000001), requester.ID, ""); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("request beyond the funds: err = %v, want ErrInsufficientFunds", err)
+	}
+
+	approval, err := RequestDistribution(db, distribution(1500000), requester.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Spent elsewhere while the request waited
+	if err := RecordDistribution(db, distribution(1000000), requester.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ApproveApproval(db, approval.ID, approver.ID, ""); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("approve after the funds were spent: err = %v, want ErrInsufficientFunds", err)
+	}
+	var count int64
+	db.Model(&models.Distribution{}).Count(&count)
+	if count != 1 {
+		t.Errorf("%d distributions recorded, want only the direct one", count)
+	}
+}
@@ -1,7 +1,9 @@
 package services
 
 import (
+	"errors"
 	"fmt"
+	"math/rand"
 	"time"
 	"masjid-baiturrahim-backend/internal/models"
 
@@ -16,84 +18,59 @@ func GenerateDonationCode() string {
 	return fmt.Sprintf("DON-%s-%s", timestamp, random)
 }
 
-type DonationStats struct {
-	TotalAmount     float64                `json:"total_amount"`
-	TotalCount      int64                  `json:"total_count"`
-	ByCategory      map[string]interface{} `json:"by_category"`
-	ByMonth         map[string]interface{} `json:"by_month"`
-	PendingCount    int64                  `json:"pending_count"`
-	ConfirmedCount  int64                  `json:"confirmed_count"`
-	CancelledCount  int64                  `json:"cancelled_count"`
-}
+// Donations must be whole rupiah within these bounds.
+var (
+	MinDonationAmount = models.NewMoney(1000)
+	MaxDonationAmount = models.NewMoney(1_000_000_000)
+)
 
-func GetDonationStats(db *gorm.DB) (*DonationStats, error) {
-	stats := &DonationStats{
-		ByCategory: make(map[string]interface{}),
-		ByMonth:    make(map[string]interface{}),
-	}
+var (
+	ErrDonationAmountRange    = fmt.Errorf("donation amount must be between %s and %s", MinDonationAmount.Format(), MaxDonationAmount.Format())
+	ErrDonationAmountFraction = errors.New("donation amount must be in whole rupiah")
+)
 
-	// Total amount and count
-	var totalAmount struct {
-		Total float64
+func ValidateDonationAmount(amount models.Money) error {
+	if amount < MinDonationAmount || amount > MaxDonationAmount {
+		return ErrDonationAmountRange
+	}
+	if !amount.IsWholeRupiah() {
+		return ErrDonationAmountFraction
 	}
-	db.Model(&models.Donation{}).
-		Where("status = ?", models.DonationStatusConfirmed).
-		Select("COALESCE(SUM(amount), 0) as total").
-		Scan(&totalAmount)
-	stats.TotalAmount = totalAmount.Total
+	return nil
+}
 
-	db.Model(&models.Donation{}).Count(&stats.TotalCount)
+const (
+	MaxUniqueCode     = 999
+	uniqueCodeLockKey = 7201 // pg advisory lock serialising unique code assignment
+)
 
-	// By status
-	db.Model(&models.Donation{}).
-		Where("status = ?", models.DonationStatusPending).
-		Count(&stats.PendingCount)
-	db.Model(&models.Donation{}).
-		Where("status = ?", models.DonationStatusConfirmed).
-		Count(&stats.ConfirmedCount)
-	db.Model(&models.Donation{}).
-		Where("status = ?", models.DonationStatusCancelled).
-		Count(&stats.CancelledCount)
+var ErrNoUniqueCodeAvailable = errors.New("no unique code available for this amount")
 
-	// By category
-	var categoryStats []struct {
-		Category string
-		Total    float64
-		Count    int64
+// AssignUniqueCode picks a suffix between 1 and MaxUniqueCode so that
+// amount+code is not the transfer amount of any other pending donation. It
+// must run inside a transaction.
+func AssignUniqueCode(tx *gorm.DB, amount models.Money) (int, error) {
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", uniqueCodeLockKey).Error; err != nil {
+		return 0, err
 	}
-	db.Model(&models.Donation{}).
-		Where("status = ?", models.DonationStatusConfirmed).
-		Select("category, SUM(amount) as total, COUNT(*) as count").
-		Group("category").
-		Scan(&categoryStats)
 
-	for _, cs := range categoryStats {
-		stats.ByCategory[cs.Category] = map[string]interface{}{
-			"total": cs.Total,
-			"count": cs.Count,
-		}
+	var pending []models.Money
+	if err := tx.Model(&models.Donation{}).
+		Where("status = ? AND transfer_amount BETWEEN ? AND ?", models.DonationStatusPending, amount+models.NewMoney(1), amount+models.NewMoney(MaxUniqueCode)).
+		Pluck("transfer_amount", &pending).Error; err != nil {
+		return 0, err
 	}
 
-	// By month (last 12 months)
-	var monthStats []struct {
-		Month string
-		Total float64
-		Count int64
+	taken := make(map[int]bool, len(pending))
+	for _, transferAmount := range pending {
+		taken[int((transferAmount-amount).Rupiah())] = true
 	}
-	db.Model(&models.Donation{}).
-		Where("status = ? AND created_at >= ?", models.DonationStatusConfirmed, time.Now().AddDate(0, -12, 0)).
-		Select("TO_CHAR(created_at, 'YYYY-MM') as month, SUM(amount) as total, COUNT(*) as count").
-		Group("month").
-		Order("month ASC").
-		Scan(&monthStats)
 
-	for _, ms := range monthStats {
-		stats.ByMonth[ms.Month] = map[string]interface{}{
-			"total": ms.Total,
-			"count": ms.Count,
+	for _, n := range rand.Perm(MaxUniqueCode) {
+		if code := n + 1; !taken[code] {
+			return code, nil
 		}
 	}
-
-	return stats, nil
+	return 0, ErrNoUniqueCodeAvailable
 }
 
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidTransition = errors.New("invalid donation status transition")
+	ErrReasonRequired    = errors.New("a reason is required for this status change")
+	ErrActorRequired     = errors.New("an actor is required for this status change")
+)
+
+// donationTransitions lists the statuses each status may move to. Confirmed
+// donations can only be refunded; every other end state is final.
+var donationTransitions = map[models.DonationStatus][]models.DonationStatus{
+	models.DonationStatusPending: {
+		models.DonationStatusConfirmed,
+		models.DonationStatusRejected,
+		models.DonationStatusExpired,
+		models.DonationStatusCancelled,
+	},
+	models.DonationStatusConfirmed: {
+		models.DonationStatusRefunded,
+	},
+}
+
+func CanTransitionDonation(from, to models.DonationStatus) bool {
+	for _, allowed := range donationTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionDonation moves a donation to status to on behalf of a staff
+// member and records the change in its history. Every manual change needs
+// an actor and a reason. The row is locked for the duration of the
+// transaction, so of two admins confirming the same donation only the first
+// succeeds and the second gets ErrInvalidTransition.
+func TransitionDonation(db *gorm.DB, donationID uuid.UUID, to models.DonationStatus, actorID uuid.UUID, reason string) (*models.Donation, error) {
+	if actorID == uuid.Nil {
+		return nil, ErrActorRequired
+	}
+	return transitionDonation(db, donationID, to, &actorID, reason)
+}
+
+// SystemTransitionDonation moves a donation without a staff member behind
+// it, which is only done to expire unpaid donations and to confirm those a
+// payment provider reported paid. The history has no actor, so the reason
+// has to say what made the change.
+func SystemTransitionDonation(db *gorm.DB, donationID uuid.UUID, to models.DonationStatus, reason string) (*models.Donation, error) {
+	if to != models.DonationStatusExpired && to != models.DonationStatusConfirmed {
+		return nil, fmt.Errorf("%w: %s", ErrActorRequired, to)
+	}
+	return transitionDonation(db, donationID, to, nil, reason)
+}
+
+func transitionDonation(db *gorm.DB, donationID uuid.UUID, to models.DonationStatus, actorID *uuid.UUID, reason string) (*models.Donation, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, ErrReasonRequired
+	}
+
+	var donation models.Donation
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&donation, "id = ?", donationID).Error; err != nil {
+			return err
+		}
+
+		from := donation.Status
+		if !CanTransitionDonation(from, to) {
+			return fmt.Errorf("%w: %s to %s", ErrInvalidTransition, from, to)
+		}
+
+		updates := map[string]interface{}{"status": to}
+		if to == models.DonationStatusConfirmed {
+			now := time.Now()
+			updates["confirmed_by"] = actorID
+			updates["confirmed_at"] = now
+			donation.ConfirmedBy = actorID
+			donation.ConfirmedAt = &now
+		}
+		if err := tx.Model(&donation).Updates(updates).Error; err != nil {
+			return err
+		}
+		donation.Status = to
+
+		if from == models.DonationStatusPending {
+			if err := CancelPendingApprovals(tx, donation.ID, "Status donasi berubah menjadi "+string(to)); err != nil {
+				return err
+			}
+		}
+
+		// Money received or paid back goes into the cash book
+		switch to {
+		case models.DonationStatusConfirmed:
+			if err := PostDonation(tx, &donation); err != nil {
+				return err
+			}
+		case models.DonationStatusRefunded:
+			if err := PostDonationRefund(tx, &donation, actorID); err != nil {
+				return err
+			}
+		}
+
+		return tx.Create(&models.DonationHistory{
+			DonationID: donation.ID,
+			FromStatus: from,
+			ToStatus:   to,
+			Reason:     reason,
+			ActorID:    actorID,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &donation, nil
+}
+
+// ExpireStaleDonations expires pending donations created before cutoff and
+// returns how many were expired.
+func ExpireStaleDonations(db *gorm.DB, cutoff time.Time) (int, error) {
+	var ids []uuid.UUID
+	if err := db.Model(&models.Donation{}).
+		Where("status = ? AND created_at < ?", models.DonationStatusPending, cutoff).
+		// Pledge installments are created ahead of their due date and expire
+		// on the pledge's own schedule
+		Where("id NOT IN (SELECT donation_id FROM pledge_installments)").
+		// Waiting for a treasurer, not for the donor
+		Where("id NOT IN (SELECT subject_id FROM approvals WHERE status = ?)", models.ApprovalPending).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, id := range ids {
+		_, err := SystemTransitionDonation(db, id, models.DonationStatusExpired, "Tidak ada pembayaran sebelum batas waktu")
+		if err != nil {
+			// Confirmed in the meantime
+			if errors.Is(err, ErrInvalidTransition) {
+				continue
+			}
+			return expired, err
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+// RunDonationExpiry periodically expires donations left pending for longer
+// than ttl. It blocks, so run it in its own goroutine.
+func RunDonationExpiry(db *gorm.DB, ttl time.Duration) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for ; true; <-ticker.C {
+		n, err := ExpireStaleDonations(db, time.Now().Add(-ttl))
+		if err != nil {
+			log.Printf("Failed to expire stale donations: %v", err)
+			continue
+		}
+		if n > 0 {
+			log.Printf("Expired %d unpaid donations", n)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+	"masjid-baiturrahim-backend/internal/testutil"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var donationStatuses = []models.DonationStatus{
+	models.DonationStatusPending,
+	models.DonationStatusConfirmed,
+	models.DonationStatusCancelled,
+	models.DonationStatusRejected,
+	models.DonationStatusExpired,
+	models.DonationStatusRefunded,
+}
+
+// donationWithStatus creates an infaq donation that is already in status.
+func donationWithStatus(t *testing.T, db *gorm.DB, code string, status models.DonationStatus) models.Donation {
+	t.Helper()
+	donation := pendingDonation(t, db, code, models.NewMoney(100000))
+	if status != models.DonationStatusPending {
+		if err := db.Model(&donation).Update("status", status).Error; err != nil {
+			t.Fatal(err)
+		}
+		donation.Status = status
+	}
+	return donation
+}
+
+func TestTransitionDonation(t *testing.T) {
+	db := testutil.NewDB(t)
+	admin := testutil.NewUser(t, db, models.RoleTreasurer, "-transition")
+
+	allowed := map[[2]models.DonationStatus]bool{
+		{models.DonationStatusPending, models.DonationStatusConfirmed}:  true,
+		{models.DonationStatusPending, models.DonationStatusRejected}:   true,
+		{models.DonationStatusPending, models.DonationStatusExpired}:    true,
+		{models.DonationStatusPending, models.DonationStatusCancelled}:  true,
+		{models.DonationStatusConfirmed, models.DonationStatusRefunded}: true,
+	}
+
+	n := 0
+	for _, from := range donationStatuses {
+		for _, to := range donationStatuses {
+			want := allowed[[2]models.DonationStatus{from, to}]
+			if got := CanTransitionDonation(from, to); got != want {
+				t.Errorf("CanTransitionDonation(%s, %s) = %v, want %v", from, to, got, want)
+			}
+
+			n++
+			donation := donationWithStatus(t, db, fmt.Sprintf("DON-STATE-%02d", n), from)
+			_, err := TransitionDonation(db, donation.ID, to, admin.ID, "Diperiksa bendahara")
+			if want && err != nil {
+				t.Errorf("%s to %s: %v", from, to, err)
+				continue
+			}
+			if !want && !errors.Is(err, ErrInvalidTransition) {
+				t.Errorf("%s to %s: err = %v, want ErrInvalidTransition", from, to, err)
+			}
+
+			var stored models.Donation
+			db.First(&stored, "id = ?", donation.ID)
+			var history int64
+			db.Model(&models.DonationHistory{}).Where("donation_id = ?", donation.ID).Count(&history)
+			if want && (stored.Status != to || history != 1) {
+				t.Errorf("%s to %s: stored as %s with %d history rows", from, to, stored.Status, history)
+			}
+			if !want && (stored.Status != from || history != 0) {
+				t.Errorf("%s to %s: refused but stored as %s with %d history rows", from, to, stored.Status, history)
+			}
+		}
+	}
+
+	t.Run("ledger", func(t *testing.T) {
+		donation := pendingDonation(t, db, "DON-STATE-LEDGER", models.NewMoney(250000))
+		if _, err := TransitionDonation(db, donation.ID, models.DonationStatusConfirmed, admin.ID, "Transfer masuk"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := TransitionDonation(db, donation.ID, models.DonationStatusRefunded, admin.ID, "Salah transfer"); err != nil {
+			t.Fatal(err)
+		}
+		var sources []models.LedgerEntrySource
+		db.Model(&models.LedgerEntry{}).Where("donation_id = ?", donation.ID).Order("created_at").Pluck("source", &sources)
+		if len(sources) != 2 || sources[0] != models.LedgerSourceDonation || sources[1] != models.LedgerSourceDonationRefund {
+			t.Errorf("ledger entries = %v, want the donation and its refund", sources)
+		}
+	})
+
+	t.Run("cancels pending approval", func(t *testing.T) {
+		donation := pendingDonation(t, db, "DON-STATE-APPROVAL", models.NewMoney(250000))
+		approval, err := RequestDonationConfirmation(db, donation.ID, admin.ID, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := TransitionDonation(db, donation.ID, models.DonationStatusRejected, admin.ID, "Bukti palsu"); err != nil {
+			t.Fatal(err)
+		}
+		db.First(approval, "id = ?", approval.ID)
+		if approval.Status != models.ApprovalCancelled {
+			t.Errorf("approval is %s after the donation was rejected, want cancelled", approval.Status)
+		}
+	})
+
+	t.Run("actor and reason", func(t *testing.T) {
+		donation := pendingDonation(t, db, "DON-STATE-ACTOR", models.NewMoney(100000))
+		if _, err := TransitionDonation(db, donation.ID, models.DonationStatusConfirmed, uuid.Nil, "Transfer masuk"); !errors.Is(err, ErrActorRequired) {
+			t.Errorf("without an actor: err = %v, want ErrActorRequired", err)
+		}
+		if _, err := TransitionDonation(db, donation.ID, models.DonationStatusConfirmed, admin.ID, "  "); !errors.Is(err, ErrReasonRequired) {
+			t.Errorf("blank reason: err = %v, want ErrReasonRequired", err)
+		}
+		for _, to := range []models.DonationStatus{models.DonationStatusRejected, models.DonationStatusCancelled, models.DonationStatusRefunded} {
+			if _, err := SystemTransitionDonation(db, donation.ID, to, "Otomatis"); !errors.Is(err, ErrActorRequired) {
+				t.Errorf("system change to %s: err = %v, want ErrActorRequired", to, err)
+			}
+		}
+
+		var stored models.Donation
+		db.First(&stored, "id = ?", donation.ID)
+		if stored.Status != models.DonationStatusPending {
+			t.Errorf("donation is %s after refused changes, want pending", stored.Status)
+		}
+
+		if _, err := SystemTransitionDonation(db, donation.ID, models.DonationStatusConfirmed, "Dibayar lewat virtual account"); err != nil {
+			t.Fatal(err)
+		}
+		var history models.DonationHistory
+		db.First(&history, "donation_id = ?", donation.ID)
+		if history.ActorID != nil {
+			t.Errorf("system change recorded actor %v", history.ActorID)
+		}
+	})
+}
+
+func TestExpireStaleDonations(t *testing.T) {
+	db := testutil.NewDB(t)
+	treasurer := testutil.NewUser(t, db, models.RoleTreasurer, "-expiry")
+	cutoff := time.Now().Add(-24 * time.Hour)
+
+	stale := func(code string) models.Donation {
+		t.Helper()
+		donation := pendingDonation(t, db, code, models.NewMoney(100000))
+		if err := db.Model(&donation).Update("created_at", cutoff.Add(-time.Hour)).Error; err != nil {
+			t.Fatal(err)
+		}
+		return donation
+	}
+
+	unpaid := stale("DON-EXP-UNPAID")
+	recent := pendingDonation(t, db, "DON-EXP-RECENT", models.NewMoney(100000))
+
+	awaiting := stale("DON-EXP-AWAITING")
+	if _, err := RequestDonationConfirmation(db, awaiting.ID, treasurer.ID, "Transfer sudah masuk"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A rejected approval no longer holds the donation back
+	declined := stale("DON-EXP-DECLINED")
+	approval, err := RequestDonationConfirmation(db, declined.ID, treasurer.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Model(approval).Update("status", models.ApprovalRejected).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	installment := stale("DON-EXP-PLEDGE")
+	donor := models.Donor{Name: "Hamba Allah"}
+	if err := db.Create(&donor).Error; err != nil {
+		t.Fatal(err)
+	}
+	pledge := models.Pledge{
+		DonorID:      donor.ID,
+		Amount:       models.NewMoney(100000),
+		Category:     models.DonationCategoryInfaq,
+		Frequency:    models.PledgeMonthly,
+		DayOfMonth:   25,
+		StartDate:    cutoff,
+		ScheduleFrom: cutoff,
+	}
+	if err := db.Create(&pledge).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&models.PledgeInstallment{PledgeID: pledge.ID, DueDate: cutoff, DonationID: installment.ID}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	confirmed := stale("DON-EXP-CONFIRMED")
+	if _, err := TransitionDonation(db, confirmed.ID, models.DonationStatusConfirmed, treasurer.ID, "Transfer masuk"); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := ExpireStaleDonations(db, cutoff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("expired %d donations, want 2", n)
+	}
+
+	want := map[uuid.UUID]models.DonationStatus{
+		unpaid.ID:      models.DonationStatusExpired,
+		recent.ID:      models.DonationStatusPending,
+		awaiting.ID:    models.DonationStatusPending,
+		declined.ID:    models.DonationStatusExpired,
+		installment.ID: models.DonationStatusPending,
+		confirmed.ID:   models.DonationStatusConfirmed,
+	}
+	for id, status := range want {
+		var d models.Donation
+		db.First(&d, "id = ?", id)
+		if d.Status != status {
+			t.Errorf("%s is %s, want %s", d.DonationCode, d.Status, status)
+		}
+	}
+
+	// Running again finds nothing left to expire
+	if n, err := ExpireStaleDonations(db, cutoff); err != nil || n != 0 {
+		t.Errorf("second run expired %d (%v), want 0", n, err)
+	}
+}
@@ -0,0 +1,313 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type StatsGrouping string
+
+const (
+	StatsByDay           StatsGrouping = "day"
+	StatsByWeek          StatsGrouping = "week"
+	StatsByMonth         StatsGrouping = "month"
+	StatsByCategory      StatsGrouping = "category"
+	StatsByPaymentMethod StatsGrouping = "payment_method"
+
+	// maxStatsBuckets keeps day and week charts readable
+	maxStatsBuckets = 400
+)
+
+var (
+	ErrInvalidStatsGrouping = errors.New("group_by must be day, week, month, category or payment_method")
+	ErrStatsRangeTooLong    = errors.New("date range has too many buckets for this grouping")
+)
+
+var allDonationStatuses = []models.DonationStatus{
+	models.DonationStatusPending, models.DonationStatusConfirmed, models.DonationStatusCancelled,
+	models.DonationStatusRejected, models.DonationStatusExpired, models.DonationStatusRefunded,
+}
+
+type DonationStatsQuery struct {
+	From    time.Time // first day, inclusive
+	To      time.Time // last day, inclusive
+	GroupBy StatsGrouping
+	Compare bool // also compute the same range one year earlier
+}
+
+// StatsSummary covers confirmed donations unless noted. Donors are new when
+// their first ever confirmed donation falls inside the range.
+type StatsSummary struct {
+	TotalAmount     models.Money                    `json:"total_amount"`
+	DonationCount   int64                           `json:"donation_count"`
+	AverageGift     models.Money                    `json:"average_gift"`
+	DonorCount      int64                           `json:"donor_count"`
+	NewDonors       int64                           `json:"new_donors"`
+	ReturningDonors int64                           `json:"returning_donors"`
+	ByStatus        map[models.DonationStatus]int64 `json:"by_status"` // all donations created in the range
+}
+
+type StatsValues struct {
+	TotalAmount   models.Money `json:"total_amount"`
+	DonationCount int64        `json:"donation_count"`
+	AverageGift   models.Money `json:"average_gift"`
+	DonorCount    int64        `json:"donor_count"`
+}
+
+type StatsGroup struct {
+	Key   string `json:"key"`
+	Label string `json:"label"`
+	StatsValues
+	Previous *StatsValues `json:"previous,omitempty"` // the same bucket a year earlier
+}
+
+type StatsComparison struct {
+	From          string       `json:"from"`
+	To            string       `json:"to"`
+	Summary       StatsSummary `json:"summary"`
+	ChangePercent *float64     `json:"change_percent,omitempty"` // of the total amount, nil when last year was zero
+}
+
+type DonationStats struct {
+	From     string           `json:"from"`
+	To       string           `json:"to"`
+	GroupBy  StatsGrouping    `json:"group_by"`
+	Summary  StatsSummary     `json:"summary"`
+	Groups   []StatsGroup     `json:"groups"`
+	Previous *StatsComparison `json:"previous,omitempty"`
+}
+
+// statsBucket returns the SQL for a row's bucket key and label. Previous
+// year rows are shifted forward a year so their buckets line up.
+func statsBucket(groupBy StatsGrouping) (string, string, error) {
+	shifted := "(d.created_at + CASE WHEN d.period = 'previous' THEN INTERVAL '1 year' ELSE INTERVAL '0' END)"
+	switch groupBy {
+	case StatsByDay:
+		return "TO_CHAR(" + shifted + ", 'YYYY-MM-DD')", "", nil
+	case StatsByWeek:
+		return "TO_CHAR(DATE_TRUNC('week', " + shifted + "), 'YYYY-MM-DD')", "", nil
+	case StatsByMonth:
+		return "TO_CHAR(" + shifted + ", 'YYYY-MM')", "", nil
+	case StatsByCategory:
+		return "d.category", "", nil
+	case StatsByPaymentMethod:
+		return "COALESCE(d.payment_method_id::text, '')", "COALESCE(pm.name, 'Tanpa metode pembayaran')", nil
+	}
+	return "", "", ErrInvalidStatsGrouping
+}
+
+// statsSQL aggregates the range, and the year before when comparing, in one
+// pass: per period totals, per period status counts and per period buckets.
+const statsSQL = `
+WITH first_gift AS (
+	SELECT ` + donorIdentity + ` AS donor_key, MIN(created_at) AS first_at
+	FROM donations
+	WHERE status = @confirmed
+	GROUP BY 1
+),
+d AS (
+	SELECT donations.*, ` + donorIdentity + ` AS donor_key,
+		CASE WHEN created_at >= @from THEN 'current' ELSE 'previous' END AS period,
+		CASE WHEN created_at >= @from THEN CAST(@from AS timestamptz) ELSE CAST(@prev_from AS timestamptz) END AS period_start
+	FROM donations
+	WHERE (created_at >= @from AND created_at < @to)
+		OR (CAST(@compare AS boolean) AND created_at >= @prev_from AND created_at < @prev_to)
+),
+b AS (
+	SELECT d.*, %s AS bucket, %s AS label
+	FROM d
+	LEFT JOIN payment_methods pm ON pm.id = d.payment_method_id
+)
+SELECT b.period, b.status, b.bucket, MAX(b.label) AS label,
+	GROUPING(b.status) AS no_status, GROUPING(b.bucket) AS no_bucket,
+	COUNT(*) AS all_count,
+	COUNT(*) FILTER (WHERE b.status = @confirmed) AS count,
+	COALESCE(SUM(b.amount) FILTER (WHERE b.status = @confirmed), 0) AS total,
+	COUNT(DISTINCT b.donor_key) FILTER (WHERE b.status = @confirmed) AS donors,
+	COUNT(DISTINCT b.donor_key) FILTER (WHERE b.status = @confirmed AND f.first_at >= b.period_start) AS new_donors
+FROM b
+LEFT JOIN first_gift f ON f.donor_key = b.donor_key
+GROUP BY GROUPING SETS ((b.period), (b.period, b.status), (b.period, b.bucket))`
+
+type statsRow struct {
+	Period    string
+	Status    *models.DonationStatus
+	Bucket    *string
+	Label     *string
+	NoStatus  int
+	NoBucket  int
+	AllCount  int64
+	Count     int64
+	Total     models.Money
+	Donors    int64
+	NewDonors int64
+}
+
+func newStatsValues(total models.Money, count, donors int64) StatsValues {
+	v := StatsValues{TotalAmount: total, DonationCount: count, DonorCount: donors}
+	if count > 0 {
+		v.AverageGift = total / models.Money(count)
+	}
+	return v
+}
+
+func newStatsSummary() StatsSummary {
+	s := StatsSummary{ByStatus: make(map[models.DonationStatus]int64, len(allDonationStatuses))}
+	for _, status := range allDonationStatuses {
+		s.ByStatus[status] = 0
+	}
+	return s
+}
+
+// GetDonationStats aggregates donations created between q.From and q.To,
+// grouped by time bucket, category or payment method.
+func GetDonationStats(db *gorm.DB, q DonationStatsQuery) (*DonationStats, error) {
+	bucket, label, err := statsBucket(q.GroupBy)
+	if err != nil {
+		return nil, err
+	}
+	if label == "" {
+		label = bucket
+	}
+
+	keys, err := statsTimeBuckets(q)
+	if err != nil {
+		return nil, err
+	}
+
+	to := q.To.AddDate(0, 0, 1)
+	var rows []statsRow
+	err = db.Raw(fmt.Sprintf(statsSQL, bucket, label), map[string]interface{}{
+		"confirmed": models.DonationStatusConfirmed,
+		"from":      q.From,
+		"to":        to,
+		"prev_from": q.From.AddDate(-1, 0, 0),
+		"prev_to":   to.AddDate(-1, 0, 0),
+		"compare":   q.Compare,
+	}).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &DonationStats{
+		From:    q.From.Format(dateLayout),
+		To:      q.To.Format(dateLayout),
+		GroupBy: q.GroupBy,
+		Summary: newStatsSummary(),
+		Groups:  []StatsGroup{},
+	}
+	var previous StatsSummary
+	if q.Compare {
+		previous = newStatsSummary()
+	}
+
+	groups := make(map[string]*StatsGroup)
+	for _, key := range keys {
+		groups[key] = &StatsGroup{Key: key, Label: key}
+	}
+	previousValues := make(map[string]StatsValues)
+
+	for _, row := range rows {
+		summary := &stats.Summary
+		if row.Period == "previous" {
+			summary = &previous
+		}
+
+		switch {
+		case row.NoStatus == 1 && row.NoBucket == 1:
+			values := newStatsValues(row.Total, row.Count, row.Donors)
+			summary.TotalAmount, summary.DonationCount = values.TotalAmount, values.DonationCount
+			summary.AverageGift, summary.DonorCount = values.AverageGift, values.DonorCount
+			summary.NewDonors = row.NewDonors
+			summary.ReturningDonors = row.Donors - row.NewDonors
+		case row.NoBucket == 1:
+			summary.ByStatus[*row.Status] = row.AllCount
+		case row.Bucket != nil:
+			values := newStatsValues(row.Total, row.Count, row.Donors)
+			if row.Period == "previous" {
+				previousValues[*row.Bucket] = values
+				if _, ok := groups[*row.Bucket]; !ok && keys == nil && row.Count > 0 {
+					groups[*row.Bucket] = &StatsGroup{Key: *row.Bucket, Label: *row.Label}
+				}
+				continue
+			}
+			if keys != nil {
+				if _, ok := groups[*row.Bucket]; !ok {
+					continue // a time zone edge outside the range
+				}
+			}
+			g := groups[*row.Bucket]
+			if g == nil {
+				g = &StatsGroup{Key: *row.Bucket}
+				groups[*row.Bucket] = g
+			}
+			if row.Label != nil {
+				g.Label = *row.Label
+			}
+			g.StatsValues = values
+		}
+	}
+
+	for key, g := range groups {
+		if q.Compare {
+			values := previousValues[key]
+			g.Previous = &values
+		}
+		stats.Groups = append(stats.Groups, *g)
+	}
+	sort.Slice(stats.Groups, func(i, j int) bool {
+		a, b := stats.Groups[i], stats.Groups[j]
+		if keys != nil || a.TotalAmount == b.TotalAmount {
+			return a.Key < b.Key
+		}
+		return a.TotalAmount > b.TotalAmount
+	})
+
+	if q.Compare {
+		stats.Previous = &StatsComparison{
+			From:    q.From.AddDate(-1, 0, 0).Format(dateLayout),
+			To:      q.To.AddDate(-1, 0, 0).Format(dateLayout),
+			Summary: previous,
+		}
+		if previous.TotalAmount > 0 {
+			change := float64(stats.Summary.TotalAmount-previous.TotalAmount) / float64(previous.TotalAmount) * 100
+			stats.Previous.ChangePercent = &change
+		}
+	}
+	return stats, nil
+}
+
+// statsTimeBuckets lists every bucket key in the range so charts get zero
+// values for quiet periods. It returns nil for non-time groupings.
+func statsTimeBuckets(q DonationStatsQuery) ([]string, error) {
+	var keys []string
+	switch q.GroupBy {
+	case StatsByDay:
+		for d := q.From; !d.After(q.To); d = d.AddDate(0, 0, 1) {
+			keys = append(keys, d.Format(dateLayout))
+			if len(keys) > maxStatsBuckets {
+				return nil, ErrStatsRangeTooLong
+			}
+		}
+	case StatsByWeek:
+		// Postgres weeks start on Monday
+		start := q.From.AddDate(0, 0, -((int(q.From.Weekday()) + 6) % 7))
+		for d := start; !d.After(q.To); d = d.AddDate(0, 0, 7) {
+			keys = append(keys, d.Format(dateLayout))
+			if len(keys) > maxStatsBuckets {
+				return nil, ErrStatsRangeTooLong
+			}
+		}
+	case StatsByMonth:
+		start := time.Date(q.From.Year(), q.From.Month(), 1, 0, 0, 0, 0, q.From.Location())
+		for d := start; !d.After(q.To); d = d.AddDate(0, 1, 0) {
+			keys = append(keys, d.Format("2006-01"))
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,540 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+	"masjid-baiturrahim-backend/internal/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	donorLockKey = 7204 // pg advisory lock serialising donor creation
+
+	DonorLoginTokenTTL = 15 * time.Minute
+)
+
+var (
+	ErrDonorMergeSelf    = errors.New("a donor can't be merged into itself")
+	ErrDonorMergeSources = errors.New("at least one donor to merge is required")
+	ErrDonorLoginInvalid = errors.New("login link is invalid or has expired")
+)
+
+func normalizedContact(email, phone *string) (string, string) {
+	var e, p string
+	if email != nil && strings.Contains(*email, "@") {
+		e = utils.NormalizeEmail(*email)
+	}
+	if phone != nil {
+		p = utils.NormalizePhone(*phone)
+	}
+	return e, p
+}
+
+// ResolveDonor finds the donor that the given contact details belong to,
+// creating one when nothing matches. The details come from public forms and
+// are never written onto an existing donor: the email decides who can open a
+// donor's history, so a form could otherwise hand someone else's profile to
+// its own inbox. Details that don't fit an existing donor make a new one, to
+// be merged by an admin if they turn out to be the same person.
+//
+// An email identifies its donor. A phone alone only matches a donor without
+// an email, since anyone may type a phone number and the donor's history
+// would be mailed to whoever registered it first; in that case nil is
+// returned and the donation is left for an admin to link. It also returns nil
+// when there is neither a usable phone nor email, and must run inside a
+// transaction.
+func ResolveDonor(tx *gorm.DB, name string, email, phone *string) (*models.Donor, error) {
+	e, p := normalizedContact(email, phone)
+	if e == "" && p == "" {
+		return nil, nil
+	}
+
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", donorLockKey).Error; err != nil {
+		return nil, err
+	}
+
+	var donor models.Donor
+	column, value := "email", e
+	if e == "" {
+		column, value = "phone", p
+	}
+	err := tx.Where(column+" = ?", value).First(&donor).Error
+	if err == nil {
+		if e == "" && donor.Email != nil {
+			return nil, nil
+		}
+		return &donor, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	donor = models.Donor{Name: strings.TrimSpace(name)}
+	if e != "" {
+		donor.Email = &e
+	}
+	// A phone already on another donor stays there
+	if p != "" && (e == "" || !contactTaken(tx, "phone", p)) {
+		donor.Phone = &p
+	}
+	if err := tx.Create(&donor).Error; err != nil {
+		return nil, err
+	}
+	return &donor, nil
+}
+
+func contactTaken(tx *gorm.DB, column, value string) bool {
+	var count int64
+	tx.Model(&models.Donor{}).Unscoped().Where(column+" = ?", value).Count(&count)
+	return count > 0
+}
+
+// AttachDonor links a donation that is about to be created to its donor.
+func AttachDonor(tx *gorm.DB, donation *models.Donation) error {
+	donor, err := ResolveDonor(tx, donation.DonorName, donation.DonorEmail, donation.DonorPhone)
+	if err != nil {
+		return err
+	}
+	if donor != nil {
+		donation.DonorID = &donor.ID
+	}
+	return nil
+}
+
+// LinkDonationsToDonors attaches donors to donations made before donor
+// profiles existed and returns how many were linked.
+func LinkDonationsToDonors(db *gorm.DB) (int, error) {
+	var donations []models.Donation
+	if err := db.Where("donor_id IS NULL AND (donor_email IS NOT NULL OR donor_phone IS NOT NULL)").
+		Order("created_at ASC").
+		Find(&donations).Error; err != nil {
+		return 0, err
+	}
+
+	linked := 0
+	for i := range donations {
+		d := &donations[i]
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := AttachDonor(tx, d); err != nil {
+				return err
+			}
+			if d.DonorID == nil {
+				return nil
+			}
+			linked++
+			return tx.Model(d).Update("donor_id", d.DonorID).Error
+		})
+		if err != nil {
+			return linked, fmt.Errorf("donation %s: %w", d.DonationCode, err)
+		}
+	}
+	return linked, nil
+}
+
+type DonorTotals struct {
+	DonationCount   int64        `json:"donation_count"`
+	TotalAmount     models.Money `json:"total_amount"`
+	FirstDonationAt *time.Time   `json:"first_donation_at"`
+	LastDonationAt  *time.Time   `json:"last_donation_at"`
+}
+
+type DonorWithTotals struct {
+	models.Donor
+	DonorTotals
+}
+
+// donorTotalsQuery aggregates confirmed donations per donor.
+func donorTotalsQuery(db *gorm.DB) *gorm.DB {
+	return db.Model(&models.Donation{}).
+		Select(`donor_id, COUNT(*) as donation_count, SUM(amount) as total_amount,
+			MIN(confirmed_at) as first_donation_at, MAX(confirmed_at) as last_donation_at`).
+		Where("status = ? AND donor_id IS NOT NULL", models.DonationStatusConfirmed).
+		Group("donor_id")
+}
+
+// ListDonors returns donors matching q (name, phone or email) with their
+// lifetime totals, ordered by sort: "total", "recent" or name.
+func ListDonors(db *gorm.DB, q, sortBy string, offset, limit int) ([]DonorWithTotals, int64, error) {
+	query := db.Model(&models.Donor{})
+	if q != "" {
+		like := "%" + q + "%"
+		query = query.Where("donors.name ILIKE ? OR donors.email ILIKE ? OR donors.phone LIKE ?", like, like, like)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	order := "donors.name ASC"
+	switch sortBy {
+	case "total":
+		order = "total_amount DESC NULLS LAST, donors.name ASC"
+	case "recent":
+		order = "last_donation_at DESC NULLS LAST, donors.name ASC"
+	}
+
+	var donors []DonorWithTotals
+	err := query.Select(`donors.*, COALESCE(totals.donation_count, 0) as donation_count,
+			COALESCE(totals.total_amount, 0) as total_amount, totals.first_donation_at, totals.last_donation_at`).
+		Joins("LEFT JOIN (?) as totals ON totals.donor_id = donors.id", donorTotalsQuery(db)).
+		Order(order).
+		Offset(offset).
+		Limit(limit).
+		Scan(&donors).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return donors, total, nil
+}
+
+type DonorCategoryTotal struct {
+	Category models.DonationCategory `json:"category"`
+	Count    int64                   `json:"count"`
+	Total    models.Money            `json:"total"`
+}
+
+type DonorSummary struct {
+	Donor      models.Donor         `json:"donor"`
+	Totals     DonorTotals          `json:"totals"`
+	ByCategory []DonorCategoryTotal `json:"by_category"`
+}
+
+// GetDonorSummary returns a donor's lifetime giving, in total and per category.
+func GetDonorSummary(db *gorm.DB, donorID uuid.UUID) (*DonorSummary, error) {
+	summary := &DonorSummary{ByCategory: []DonorCategoryTotal{}}
+	if err := db.First(&summary.Donor, "id = ?", donorID).Error; err != nil {
+		return nil, err
+	}
+
+	if err := donorTotalsQuery(db).Where("donor_id = ?", donorID).Scan(&summary.Totals).Error; err != nil {
+		return nil, err
+	}
+
+	if err := db.Model(&models.Donation{}).
+		Select("category, COUNT(*) as count, SUM(amount) as total").
+		Where("donor_id = ? AND status = ?", donorID, models.DonationStatusConfirmed).
+		Group("category").
+		Order("total DESC").
+		Scan(&summary.ByCategory).Error; err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+type DuplicateDonorGroup struct {
+	Reason string         `json:"reason"`
+	Donors []models.Donor `json:"donors"`
+}
+
+// nameDistance is the Levenshtein distance between two names.
+func nameDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// namesSimilar allows one typo per six letters, so "Ahmad Fauzi" matches
+// "Ahmad Fauzy" but "Ali" doesn't match "Ani".
+func namesSimilar(a, b string) bool {
+	if a == b {
+		return true
+	}
+	shorter := len([]rune(a))
+	if n := len([]rune(b)); n < shorter {
+		shorter = n
+	}
+	return nameDistance(a, b) <= shorter/6
+}
+
+// maxDuplicateBlock is the most donors compared pair by pair under one key.
+// A bigger block says little (many numbers share a common ending) and is
+// skipped rather than scanned.
+const maxDuplicateBlock = 50
+
+// phonesSimilar reports whether two normalised numbers differ by a single
+// digit or by two neighbouring digits swapped, the usual typing slips.
+func phonesSimilar(a, b string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	var diff []int
+	for i := 0; i < len(a); i++ {
+		if a[i] != b[i] {
+			if diff = append(diff, i); len(diff) > 2 {
+				return false
+			}
+		}
+	}
+	switch len(diff) {
+	case 1:
+		return true
+	case 2:
+		i, j := diff[0], diff[1]
+		return j == i+1 && a[i] == b[j] && a[j] == b[i]
+	}
+	return false
+}
+
+// phoneBlocks are the keys a number is compared under. A wrong digit leaves
+// either the last four digits or everything before them unchanged, and a
+// swap leaves the digits themselves unchanged.
+func phoneBlocks(phone string) []string {
+	digits := []byte(phone)
+	sort.Slice(digits, func(i, j int) bool { return digits[i] < digits[j] })
+	return []string{
+		"start:" + phone[:len(phone)-4],
+		"end:" + phone[len(phone)-4:],
+		"digits:" + string(digits),
+	}
+}
+
+// FindDuplicateDonors groups donors who are probably the same person, for an
+// admin to review and merge: their email addresses reach the same mailbox
+// (gmail and googlemail, dots, a +tag), or their phone numbers are one typo
+// apart and their names look alike once honorifics are ignored. A similar
+// name alone isn't enough, since many donors share common names. Donors are
+// only compared with those sharing a block key, so the scan stays short as
+// the donor list grows.
+func FindDuplicateDonors(db *gorm.DB) ([]DuplicateDonorGroup, error) {
+	var donors []models.Donor
+	if err := db.Where("phone IS NOT NULL OR email IS NOT NULL").Order("created_at ASC").Find(&donors).Error; err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(donors))
+	phones := make([]string, len(donors))
+	blocks := make(map[string][]int)
+	for i, d := range donors {
+		names[i] = utils.NormalizeName(d.Name)
+		if d.Phone != nil {
+			if phones[i] = utils.NormalizePhone(*d.Phone); phones[i] != "" {
+				for _, key := range phoneBlocks(phones[i]) {
+					blocks[key] = append(blocks[key], i)
+				}
+			}
+		}
+		if d.Email != nil && strings.TrimSpace(*d.Email) != "" {
+			key := "email:" + utils.CanonicalEmail(*d.Email)
+			blocks[key] = append(blocks[key], i)
+		}
+	}
+
+	parent := make([]int, len(donors))
+	for i := range parent {
+		parent[i] = i
+	}
+	find := func(i int) int {
+		for parent[i] != i {
+			parent[i] = parent[parent[i]]
+			i = parent[i]
+		}
+		return i
+	}
+	reasons := make(map[int]map[string]bool) // by the first donor of each pair
+	for key, members := range blocks {
+		if len(members) < 2 || len(members) > maxDuplicateBlock {
+			continue
+		}
+		for a := 0; a < len(members); a++ {
+			for b := a + 1; b < len(members); b++ {
+				i, j := members[a], members[b]
+				var reason string
+				switch {
+				case strings.HasPrefix(key, "email:"):
+					reason = "same email address"
+				case phonesSimilar(phones[i], phones[j]) && namesSimilar(names[i], names[j]):
+					reason = "similar phone and name"
+				default:
+					continue
+				}
+				if reasons[i] == nil {
+					reasons[i] = make(map[string]bool)
+				}
+				reasons[i][reason] = true
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+
+	byRoot := make(map[int]*DuplicateDonorGroup)
+	groupReasons := make(map[int]map[string]bool)
+	var roots []int
+	for i := range donors {
+		root := find(i)
+		if byRoot[root] == nil {
+			byRoot[root] = &DuplicateDonorGroup{}
+			groupReasons[root] = make(map[string]bool)
+			roots = append(roots, root)
+		}
+		byRoot[root].Donors = append(byRoot[root].Donors, donors[i])
+		for reason := range reasons[i] {
+			groupReasons[root][reason] = true
+		}
+	}
+
+	groups := []DuplicateDonorGroup{}
+	for _, root := range roots {
+		group := byRoot[root]
+		if len(group.Donors) < 2 {
+			continue
+		}
+		var list []string
+		for reason := range groupReasons[root] {
+			list = append(list, reason)
+		}
+		sort.Strings(list)
+		group.Reason = strings.Join(list, ", ")
+		groups = append(groups, *group)
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		return len(groups[i].Donors) > len(groups[j].Donors)
+	})
+	return groups, nil
+}
+
+// MergeDonors moves the donations and pledges of the source donors to the
+// target, keeps any contact details the target lacks and removes the sources.
+func MergeDonors(db *gorm.DB, targetID uuid.UUID, sourceIDs []uuid.UUID) (*models.Donor, error) {
+	if len(sourceIDs) == 0 {
+		return nil, ErrDonorMergeSources
+	}
+	for _, id := range sourceIDs {
+		if id == targetID {
+			return nil, ErrDonorMergeSelf
+		}
+	}
+
+	var target models.Donor
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", donorLockKey).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&target, "id = ?", targetID).Error; err != nil {
+			return err
+		}
+
+		var sources []models.Donor
+		if err := tx.Where("id IN ?", sourceIDs).Find(&sources).Error; err != nil {
+			return err
+		}
+		if len(sources) != len(sourceIDs) {
+			return gorm.ErrRecordNotFound
+		}
+
+		for _, source := range sources {
+			if err := tx.Model(&models.Donation{}).Where("donor_id = ?", source.ID).
+				Update("donor_id", target.ID).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.Pledge{}).Where("donor_id = ?", source.ID).
+				Update("donor_id", target.ID).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("donor_id = ?", source.ID).Delete(&models.DonorLoginToken{}).Error; err != nil {
+				return err
+			}
+
+			// Free the source's unique contact details before the target takes them
+			if err := tx.Model(&models.Donor{}).Where("id = ?", source.ID).
+				Updates(map[string]interface{}{"phone": nil, "email": nil}).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&models.Donor{}, "id = ?", source.ID).Error; err != nil {
+				return err
+			}
+
+			if target.Phone == nil && source.Phone != nil {
+				target.Phone = source.Phone
+			}
+			if target.Email == nil && source.Email != nil {
+				target.Email = source.Email
+			}
+			if source.Notes != "" {
+				target.Notes = strings.TrimSpace(target.Notes + "\n" + source.Notes)
+			}
+		}
+
+		return tx.Save(&target).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &target, nil
+}
+
+// RequestDonorLogin emails a single-use login link to the donor with this
+// email. Unknown addresses are silently ignored so the endpoint can't be used
+// to find out who donates.
+func RequestDonorLogin(db *gorm.DB, mailer Mailer, email, frontendURL string) error {
+	e := utils.NormalizeEmail(email)
+	var donor models.Donor
+	if err := db.Where("email = ?", e).First(&donor).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return err
+	}
+	if err := db.Create(&models.DonorLoginToken{
+		DonorID:   donor.ID,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(DonorLoginTokenTTL),
+	}).Error; err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/donor/login?token=%s", strings.TrimRight(frontendURL, "/"), token)
+	body := fmt.Sprintf("Assalamu'alaikum %s,\n\nGunakan tautan berikut untuk melihat riwayat donasi Anda:\n%s\n\nTautan ini berlaku %d menit dan hanya dapat digunakan sekali. Abaikan email ini jika Anda tidak memintanya.\n",
+		donor.Name, link, int(DonorLoginTokenTTL.Minutes()))
+	return mailer.Send(e, "Tautan masuk riwayat donasi", body)
+}
+
+// VerifyDonorLogin consumes a login link token and returns its donor.
+func VerifyDonorLogin(db *gorm.DB, token string) (*models.Donor, error) {
+	var loginToken models.DonorLoginToken
+	result := db.Model(&loginToken).
+		Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", utils.HashToken(token), time.Now()).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrDonorLoginInvalid
+	}
+
+	if err := db.First(&loginToken, "token_hash = ?", utils.HashToken(token)).Error; err != nil {
+		return nil, err
+	}
+	var donor models.Donor
+	if err := db.First(&donor, "id = ?", loginToken.DonorID).Error; err != nil {
+		return nil, ErrDonorLoginInvalid
+	}
+	return &donor, nil
+}
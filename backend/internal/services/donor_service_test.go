@@ -0,0 +1,90 @@
+package services
+
+import (
+	"testing"
+	"masjid-baiturrahim-backend/internal/models"
+	"masjid-baiturrahim-backend/internal/testutil"
+)
+
+func TestPhonesSimilar(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"+6281234567890", "+6281234567891", true},  // one wrong digit
+		{"+6281234567890", "+6281234567809", true},  // last two swapped
+		{"+6281234567890", "+6281243567890", true},  // swapped in the middle
+		{"+6281234567890", "+6281234567988", false}, // two wrong digits
+		{"+6281234567890", "+6281534567820", false}, // two digits apart swapped
+		{"+6281234567890", "+628123456789", false},  // a digit missing
+	}
+	for _, tt := range tests {
+		if got := phonesSimilar(tt.a, tt.b); got != tt.want {
+			t.Errorf("phonesSimilar(%s, %s) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestFindDuplicateDonors(t *testing.T) {
+	db := testutil.NewDB(t)
+	donor := func(name, phone, email string) models.Donor {
+		d := models.Donor{Name: name}
+		if phone != "" {
+			d.Phone = &phone
+		}
+		if email != "" {
+			d.Email = &email
+		}
+		if err := db.Create(&d).Error; err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	ahmad := donor("Bpk. Ahmad Fauzi", "+6281234567890", "ahmad.fauzi@gmail.com")
+	swapped := donor("Ahmad Fauzy", "+6281234567809", "")
+	googlemail := donor("A. Fauzi", "", "ahmadfauzi+infaq@googlemail.com")
+	donor("Siti Aminah", "+6281234567891", "") // one digit from Ahmad, but someone else
+	donor("Ahmad Fauzi", "+6285700001111", "") // same name, nothing else in common
+	donor("Hamba Allah", "", "ahmad.fauzi@yahoo.co.id")
+	budi := donor("Budi Santoso", "+6281311112222", "")
+	budiTypo := donor("Budi Santosa", "+6281311112223", "budi@example.com")
+
+	groups, err := FindDuplicateDonors(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		reason string
+		donors []models.Donor
+	}{
+		{"same email address, similar phone and name", []models.Donor{ahmad, swapped, googlemail}},
+		{"similar phone and name", []models.Donor{budi, budiTypo}},
+	}
+	if len(groups) != len(want) {
+		for _, g := range groups {
+			var names []string
+			for _, d := range g.Donors {
+				names = append(names, d.Name)
+			}
+			t.Logf("group %q: %v", g.Reason, names)
+		}
+		t.Fatalf("%d groups, want %d", len(groups), len(want))
+	}
+	for i, w := range want {
+		g := groups[i]
+		if g.Reason != w.reason {
+			t.Errorf("group %d: reason = %q, want %q", i, g.Reason, w.reason)
+		}
+		if len(g.Donors) != len(w.donors) {
+			t.Errorf("group %d: %d donors, want %d", i, len(g.Donors), len(w.donors))
+			continue
+		}
+		for j, d := range w.donors {
+			if g.Donors[j].ID != d.ID {
+				t.Errorf("group %d donor %d = %s, want %s", i, j, g.Donors[j].Name, d.Name)
+			}
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+	"masjid-baiturrahim-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	AnonymousDonorName = "Hamba Allah"
+
+	// SettingDonorWallShowAmounts controls whether public donor lists show
+	// how much each donor gave.
+	SettingDonorWallShowAmounts = "donor_wall_show_amounts"
+)
+
+// DonorWallEntry is a confirmed donation as shown to the public. Amount is
+// rounded and left out entirely when amounts are hidden.
+type DonorWallEntry struct {
+	DonorName   string                  `json:"donor_name"`
+	Amount      *models.Money           `json:"amount,omitempty"`
+	Category    models.DonationCategory `json:"category"`
+	ConfirmedAt time.Time               `json:"confirmed_at"`
+}
+
+type DonorWallFilter struct {
+	Category   models.DonationCategory
+	CampaignID *uuid.UUID
+}
+
+// MaskDonorName keeps the first half of the first name and the initials of
+// the rest: "Ahmad Fauzi" becomes "Ahm** F.".
+func MaskDonorName(name string) string {
+	words := strings.Fields(name)
+	if len(words) == 0 {
+		return AnonymousDonorName
+	}
+
+	first := []rune(words[0])
+	shown := (len(first) + 1) / 2
+	masked := []string{string(first[:shown]) + strings.Repeat("*", len(first)-shown)}
+	for _, word := range words[1:] {
+		r, _ := utf8.DecodeRuneInString(word)
+		masked = append(masked, string(r)+".")
+	}
+	return strings.Join(masked, " ")
+}
+
+// roundWallAmount rounds to the nearest thousand below Rp100.000, ten
+// thousand below Rp1.000.000 and hundred thousand above, so exact amounts
+// can't be used to identify a transfer.
+func roundWallAmount(amount models.Money) models.Money {
+	step := models.NewMoney(100000)
+	switch {
+	case amount < models.NewMoney(100000):
+		step = models.NewMoney(1000)
+	case amount < models.NewMoney(1000000):
+		step = models.NewMoney(10000)
+	}
+	rounded := (amount + step/2) / step * step
+	if rounded == 0 {
+		rounded = step
+	}
+	return rounded
+}
+
+func donorWallName(donorName string, displayName *string, anonymous bool) string {
+	switch {
+	case anonymous:
+		return AnonymousDonorName
+	case displayName != nil && *displayName != "":
+		return *displayName
+	default:
+		return MaskDonorName(donorName)
+	}
+}
+
+// GetDonorWall lists confirmed donations that donors agreed to show, newest
+// first.
+func GetDonorWall(db *gorm.DB, filter DonorWallFilter, offset, limit int) ([]DonorWallEntry, int64, error) {
+	query := db.Model(&models.Donation{}).
+		Where("status = ? AND hide_from_wall = ?", models.DonationStatusConfirmed, false)
+	if filter.Category != "" {
+		query = query.Where("category = ?", filter.Category)
+	}
+	if filter.CampaignID != nil {
+		query = query.Where("campaign_id = ?", *filter.CampaignID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var rows []struct {
+		DonorName   string
+		DisplayName *string
+		IsAnonymous bool
+		Amount      models.Money
+		Category    models.DonationCategory
+		ConfirmedAt time.Time
+	}
+	err := query.Select("donor_name, display_name, is_anonymous, amount, category, confirmed_at").
+		Order("confirmed_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	showAmounts := GetSettingBool(db, SettingDonorWallShowAmounts, true)
+	entries := make([]DonorWallEntry, len(rows))
+	for i, row := range rows {
+		entries[i] = DonorWallEntry{
+			DonorName:   donorWallName(row.DonorName, row.DisplayName, row.IsAnonymous),
+			Category:    row.Category,
+			ConfirmedAt: row.ConfirmedAt,
+		}
+		if showAmounts {
+			amount := roundWallAmount(row.Amount)
+			entries[i].Amount = &amount
+		}
+	}
+	return entries, total, nil
+}
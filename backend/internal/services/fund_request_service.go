@@ -0,0 +1,559 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SettingFundReportDays is how many days after the event (or the date the
+// money was needed) a division has to report its receipts.
+const SettingFundReportDays = "fund_request_report_days"
+
+var (
+	ErrFundRequestInvalid   = errors.New("a fund request needs a title and a positive amount")
+	ErrFundRequestStatus    = errors.New("fund request can't do that in its current status")
+	ErrFundRequestNotYours  = errors.New("only the requester can change or withdraw this request")
+	ErrFundSelfApproval     = errors.New("you can't decide on your own fund request")
+	ErrFundApprovedAmount   = errors.New("approved amount must be positive and not more than requested")
+	ErrFundNoteRequired     = errors.New("a note is required when rejecting")
+	ErrFundDivisionInactive = errors.New("division not found or inactive")
+	ErrFundEventNotFound    = errors.New("event not found")
+	ErrNoDivisionBudget     = errors.New("division has no budget for this year")
+	ErrOverBudget           = errors.New("amount exceeds the division's remaining budget")
+	ErrFundSpentAmount      = errors.New("spent amount can't be negative")
+	ErrFundReceiptsRequired = errors.New("upload the receipts before reporting what was spent")
+	ErrFundDisburseAccount  = errors.New("a valid account and expense category are required to disburse")
+	ErrFundReportNotAllowed = errors.New("only the requester or the treasury can report on this request")
+	ErrFundOverspend        = errors.New("spending more than was disbursed has to be settled by a treasurer")
+)
+
+func ValidateFundRequest(r *models.FundRequest) error {
+	if strings.TrimSpace(r.Title) == "" || r.Amount <= 0 {
+		return ErrFundRequestInvalid
+	}
+	return nil
+}
+
+// fundRequestYear picks the budget year a request counts against: the year
+// of its event, else of the date the money is needed, else this year.
+func fundRequestYear(tx *gorm.DB, r *models.FundRequest) (int, error) {
+	if r.EventID != nil {
+		var event models.Event
+		if err := tx.Select("id", "event_date").First(&event, "id = ?", r.EventID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return 0, ErrFundEventNotFound
+			}
+			return 0, err
+		}
+		return event.EventDate.Year(), nil
+	}
+	if r.NeededBy != nil {
+		return r.NeededBy.Year(), nil
+	}
+	return time.Now().Year(), nil
+}
+
+type DivisionBudgetStatus struct {
+	DivisionID uuid.UUID    `json:"division_id"`
+	Year       int          `json:"year"`
+	HasBudget  bool         `json:"has_budget"`
+	Budget     models.Money `json:"budget"`
+	Committed  models.Money `json:"committed"` // approved, disbursed or spent
+	Remaining  models.Money `json:"remaining"`
+}
+
+// GetDivisionBudgetStatus reports how much of a division's yearly budget is
+// taken by approved requests.
+func GetDivisionBudgetStatus(db *gorm.DB, divisionID uuid.UUID, year int) (*DivisionBudgetStatus, error) {
+	status := &DivisionBudgetStatus{DivisionID: divisionID, Year: year}
+
+	var budget models.DivisionBudget
+	err := db.Where("division_id = ? AND year = ?", divisionID, year).First(&budget).Error
+	switch {
+	case err == nil:
+		status.HasBudget = true
+		status.Budget = budget.Amount
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, err
+	}
+
+	if err := db.Model(&models.FundRequest{}).
+		Select("COALESCE(SUM(CASE WHEN status = ? THEN spent_amount ELSE approved_amount END), 0)", models.FundRequestSettled).
+		Where("division_id = ? AND year = ? AND status IN ?", divisionID, year, []models.FundRequestStatus{
+			models.FundRequestApproved, models.FundRequestDisbursed, models.FundRequestSettled,
+		}).
+		Scan(&status.Committed).Error; err != nil {
+		return nil, err
+	}
+	status.Remaining = status.Budget - status.Committed
+	return status, nil
+}
+
+func checkFundRequestRefs(tx *gorm.DB, r *models.FundRequest) error {
+	var division models.Division
+	if err := tx.First(&division, "id = ? AND is_active = ?", r.DivisionID, true).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrFundDivisionInactive
+		}
+		return err
+	}
+
+	year, err := fundRequestYear(tx, r)
+	if err != nil {
+		return err
+	}
+	r.Year = year
+	return nil
+}
+
+// SubmitFundRequest files a request on behalf of a division.
+func SubmitFundRequest(db *gorm.DB, r *models.FundRequest, actorID uuid.UUID) error {
+	if err := ValidateFundRequest(r); err != nil {
+		return err
+	}
+	if err := checkFundRequestRefs(db, r); err != nil {
+		return err
+	}
+
+	r.Status = models.FundRequestSubmitted
+	r.RequestedBy = actorID
+	r.ApprovedAmount = 0
+	return db.Create(r).Error
+}
+
+// UpdateFundRequest lets the requester change a request nobody has decided
+// on yet.
+func UpdateFundRequest(db *gorm.DB, r *models.FundRequest, actorID uuid.UUID) error {
+	if r.Status != models.FundRequestSubmitted {
+		return ErrFundRequestStatus
+	}
+	if r.RequestedBy != actorID {
+		return ErrFundRequestNotYours
+	}
+	if err := ValidateFundRequest(r); err != nil {
+		return err
+	}
+	if err := checkFundRequestRefs(db, r); err != nil {
+		return err
+	}
+	return db.Omit("Division", "Event", "Requester").Save(r).Error
+}
+
+func lockFundRequest(tx *gorm.DB, id uuid.UUID, allowed ...models.FundRequestStatus) (*models.FundRequest, error) {
+	var r models.FundRequest
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&r, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	for _, status := range allowed {
+		if r.Status == status {
+			return &r, nil
+		}
+	}
+	return nil, fmt.Errorf("%w (%s)", ErrFundRequestStatus, r.Status)
+}
+
+// ApproveFundRequest approves amount (the requested amount when 0) if it
+// fits in the division's remaining budget. Approvals within a division are
+// serialised by locking the division so two can't spend the same rupiah.
+func ApproveFundRequest(db *gorm.DB, id, actorID uuid.UUID, amount models.Money, note string) (*models.FundRequest, error) {
+	var r *models.FundRequest
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if r, err = lockFundRequest(tx, id, models.FundRequestSubmitted); err != nil {
+			return err
+		}
+		if r.RequestedBy == actorID {
+			return ErrFundSelfApproval
+		}
+		if amount == 0 {
+			amount = r.Amount
+		}
+		if amount < 0 || amount > r.Amount {
+			return ErrFundApprovedAmount
+		}
+
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.Division{}, "id = ?", r.DivisionID).Error; err != nil {
+			return err
+		}
+		budget, err := GetDivisionBudgetStatus(tx, r.DivisionID, r.Year)
+		if err != nil {
+			return err
+		}
+		if !budget.HasBudget {
+			return fmt.Errorf("%w (%d)", ErrNoDivisionBudget, r.Year)
+		}
+		if amount > budget.Remaining {
+			return fmt.Errorf("%w: %s left of %s", ErrOverBudget, budget.Remaining.Format(), budget.Budget.Format())
+		}
+
+		now := time.Now()
+		r.Status = models.FundRequestApproved
+		r.ApprovedAmount = amount
+		r.DecidedBy = &actorID
+		r.DecidedAt = &now
+		r.DecisionNote = strings.TrimSpace(note)
+		return tx.Model(r).Updates(map[string]interface{}{
+			"status":          r.Status,
+			"approved_amount": r.ApprovedAmount,
+			"decided_by":      r.DecidedBy,
+			"decided_at":      r.DecidedAt,
+			"decision_note":   r.DecisionNote,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func RejectFundRequest(db *gorm.DB, id, actorID uuid.UUID, note string) (*models.FundRequest, error) {
+	note = strings.TrimSpace(note)
+	if note == "" {
+		return nil, ErrFundNoteRequired
+	}
+
+	var r *models.FundRequest
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if r, err = lockFundRequest(tx, id, models.FundRequestSubmitted); err != nil {
+			return err
+		}
+		if r.RequestedBy == actorID {
+			return ErrFundSelfApproval
+		}
+
+		now := time.Now()
+		r.Status = models.FundRequestRejected
+		r.DecidedBy = &actorID
+		r.DecidedAt = &now
+		r.DecisionNote = note
+		return tx.Model(r).Updates(map[string]interface{}{
+			"status":        r.Status,
+			"decided_by":    r.DecidedBy,
+			"decided_at":    r.DecidedAt,
+			"decision_note": r.DecisionNote,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// CancelFundRequest withdraws a request that hasn't been paid out, giving
+// any approved amount back to the division's budget.
+func CancelFundRequest(db *gorm.DB, id, actorID uuid.UUID) (*models.FundRequest, error) {
+	var r *models.FundRequest
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if r, err = lockFundRequest(tx, id, models.FundRequestSubmitted, models.FundRequestApproved); err != nil {
+			return err
+		}
+		if r.RequestedBy != actorID {
+			return ErrFundRequestNotYours
+		}
+		r.Status = models.FundRequestCancelled
+		return tx.Model(r).Update("status", r.Status).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func fundRequestDescription(tx *gorm.DB, r *models.FundRequest) string {
+	var division models.Division
+	tx.Select("name").First(&division, "id = ?", r.DivisionID)
+	return fmt.Sprintf("Pengajuan dana %s: %s", division.Name, r.Title)
+}
+
+// DisburseFundRequest pays the approved amount out of accountID and books it
+// as an expense in categoryID. The entry is corrected to what was actually
+// spent when the receipts are reported.
+func DisburseFundRequest(db *gorm.DB, id, actorID, accountID, categoryID uuid.UUID) (*models.FundRequest, error) {
+	var r *models.FundRequest
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if r, err = lockFundRequest(tx, id, models.FundRequestApproved); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		entry := models.LedgerEntry{
+			AccountID:   accountID,
+			CategoryID:  categoryID,
+			Type:        models.LedgerExpense,
+			Amount:      r.ApprovedAmount,
+			EntryDate:   now,
+			Description: fundRequestDescription(tx, r),
+			Source:      models.LedgerSourceFundRequest,
+			CreatedBy:   &actorID,
+		}
+		if err := ValidateLedgerEntry(tx, &entry); err != nil {
+			return fmt.Errorf("%w (%s)", ErrFundDisburseAccount, err.Error())
+		}
+		if err := tx.Create(&entry).Error; err != nil {
+			return err
+		}
+
+		r.Status = models.FundRequestDisbursed
+		r.DisbursedAmount = r.ApprovedAmount
+		r.AccountID = &accountID
+		r.LedgerEntryID = &entry.ID
+		r.DisbursedBy = &actorID
+		r.DisbursedAt = &now
+		return tx.Model(r).Updates(map[string]interface{}{
+			"status":           r.Status,
+			"disbursed_amount": r.DisbursedAmount,
+			"account_id":       r.AccountID,
+			"ledger_entry_id":  r.LedgerEntryID,
+			"disbursed_by":     r.DisbursedBy,
+			"disbursed_at":     r.DisbursedAt,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// CanReportFundRequest tells whether someone may add receipts to a request
+// and report what it spent: the requester, or whoever keeps the cash book.
+func CanReportFundRequest(r *models.FundRequest, actorID uuid.UUID, role models.UserRole) bool {
+	return r.RequestedBy == actorID || role.Can(models.PermFinanceManage)
+}
+
+// SettleFundRequest records what the division spent, backed by the uploaded
+// receipts. The disbursement's ledger entry is corrected to the amount spent,
+// so leftover money returned to the account, or an overspend reimbursed from
+// it, is reflected in the account's balance. An overspend is new money out of
+// the division's budget, so it is settled like an approval: by a treasurer
+// other than the requester, within the remaining budget.
+func SettleFundRequest(db *gorm.DB, id, actorID uuid.UUID, role models.UserRole, spent models.Money, note string) (*models.FundRequest, error) {
+	if spent < 0 {
+		return nil, ErrFundSpentAmount
+	}
+
+	var r *models.FundRequest
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if r, err = lockFundRequest(tx, id, models.FundRequestDisbursed); err != nil {
+			return err
+		}
+		if !CanReportFundRequest(r, actorID, role) {
+			return ErrFundReportNotAllowed
+		}
+		if spent > 0 && len(r.Receipts) == 0 {
+			return ErrFundReceiptsRequired
+		}
+		if spent > r.DisbursedAmount {
+			if !role.Can(models.PermFinanceApprove) {
+				return fmt.Errorf("%w (spent %s of %s)", ErrFundOverspend, spent.Format(), r.DisbursedAmount.Format())
+			}
+			if r.RequestedBy == actorID {
+				return ErrFundSelfApproval
+			}
+			if err := checkFundOverspend(tx, r, spent-r.DisbursedAmount); err != nil {
+				return err
+			}
+		}
+
+		if r.LedgerEntryID != nil {
+			if spent == 0 {
+				if err := tx.Delete(&models.LedgerEntry{}, "id = ? AND source = ?", r.LedgerEntryID, models.LedgerSourceFundRequest).Error; err != nil {
+					return err
+				}
+				r.LedgerEntryID = nil
+			} else if err := tx.Model(&models.LedgerEntry{}).
+				Where("id = ? AND source = ?", r.LedgerEntryID, models.LedgerSourceFundRequest).
+				Update("amount", spent).Error; err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		r.Status = models.FundRequestSettled
+		r.SpentAmount = spent
+		r.ReportNote = strings.TrimSpace(note)
+		r.SettledBy = &actorID
+		r.SettledAt = &now
+		return tx.Model(r).Updates(map[string]interface{}{
+			"status":          r.Status,
+			"spent_amount":    r.SpentAmount,
+			"report_note":     r.ReportNote,
+			"ledger_entry_id": r.LedgerEntryID,
+			"settled_by":      r.SettledBy,
+			"settled_at":      r.SettledAt,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// checkFundOverspend checks that the division's budget can take extra on top
+// of what the request already commits, the disbursed amount.
+func checkFundOverspend(tx *gorm.DB, r *models.FundRequest, extra models.Money) error {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.Division{}, "id = ?", r.DivisionID).Error; err != nil {
+		return err
+	}
+	budget, err := GetDivisionBudgetStatus(tx, r.DivisionID, r.Year)
+	if err != nil {
+		return err
+	}
+	if !budget.HasBudget {
+		return fmt.Errorf("%w (%d)", ErrNoDivisionBudget, r.Year)
+	}
+	if extra > budget.Remaining {
+		return fmt.Errorf("%w: overspend of %s, %s left of %s", ErrOverBudget, extra.Format(), budget.Remaining.Format(), budget.Budget.Format())
+	}
+	return nil
+}
+
+type OutstandingAdvance struct {
+	ID              uuid.UUID    `json:"id"`
+	Title           string       `json:"title"`
+	DivisionID      uuid.UUID    `json:"division_id"`
+	DivisionName    string       `json:"division_name"`
+	EventID         *uuid.UUID   `json:"event_id,omitempty"`
+	EventTitle      *string      `json:"event_title,omitempty"`
+	RequesterName   string       `json:"requester_name"`
+	DisbursedAmount models.Money `json:"disbursed_amount"`
+	DisbursedAt     time.Time    `json:"disbursed_at"`
+	ReportDue       string       `json:"report_due"` // YYYY-MM-DD
+	DaysOutstanding int          `json:"days_outstanding"`
+	Overdue         bool         `json:"overdue"`
+	ReceiptCount    int          `json:"receipt_count"`
+}
+
+type DivisionOutstanding struct {
+	DivisionID   uuid.UUID    `json:"division_id"`
+	DivisionName string       `json:"division_name"`
+	Count        int          `json:"count"`
+	Total        models.Money `json:"total"`
+	Overdue      models.Money `json:"overdue"`
+}
+
+type OutstandingAdvancesReport struct {
+	Total      models.Money          `json:"total"`
+	Overdue    models.Money          `json:"overdue"`
+	ByDivision []DivisionOutstanding `json:"by_division"`
+	Advances   []OutstandingAdvance  `json:"advances"`
+}
+
+// GetOutstandingAdvances lists money paid out to divisions whose receipts
+// haven't been reported yet, oldest first. A report is due a configurable
+// number of days after the event, or after the date the money was needed.
+func GetOutstandingAdvances(db *gorm.DB, now time.Time) (*OutstandingAdvancesReport, error) {
+	var requests []models.FundRequest
+	if err := db.Preload("Division").Preload("Event").Preload("Requester").
+		Where("status = ?", models.FundRequestDisbursed).
+		Order("disbursed_at ASC").
+		Find(&requests).Error; err != nil {
+		return nil, err
+	}
+
+	reportDays := int(GetSettingInt(db, SettingFundReportDays, 14))
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	report := &OutstandingAdvancesReport{ByDivision: []DivisionOutstanding{}, Advances: []OutstandingAdvance{}}
+	byDivision := make(map[uuid.UUID]*DivisionOutstanding)
+
+	for _, r := range requests {
+		disbursedAt := r.CreatedAt
+		if r.DisbursedAt != nil {
+			disbursedAt = *r.DisbursedAt
+		}
+		base := disbursedAt
+		switch {
+		case r.Event != nil:
+			base = r.Event.EventDate
+		case r.NeededBy != nil && r.NeededBy.After(base):
+			base = *r.NeededBy
+		}
+		due := time.Date(base.Year(), base.Month(), base.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, reportDays)
+
+		advance := OutstandingAdvance{
+			ID:              r.ID,
+			Title:           r.Title,
+			DivisionID:      r.DivisionID,
+			EventID:         r.EventID,
+			DisbursedAmount: r.DisbursedAmount,
+			DisbursedAt:     disbursedAt,
+			ReportDue:       due.Format(dateLayout),
+			DaysOutstanding: int(today.Sub(time.Date(disbursedAt.Year(), disbursedAt.Month(), disbursedAt.Day(), 0, 0, 0, 0, now.Location())).Hours() / 24),
+			Overdue:         today.After(due),
+			ReceiptCount:    len(r.Receipts),
+		}
+		if r.Division != nil {
+			advance.DivisionName = r.Division.Name
+		}
+		if r.Event != nil {
+			advance.EventTitle = &r.Event.Title
+		}
+		if r.Requester != nil {
+			advance.RequesterName = r.Requester.FullName
+		}
+		report.Advances = append(report.Advances, advance)
+
+		d := byDivision[r.DivisionID]
+		if d == nil {
+			d = &DivisionOutstanding{DivisionID: r.DivisionID, DivisionName: advance.DivisionName}
+			byDivision[r.DivisionID] = d
+		}
+		d.Count++
+		d.Total += r.DisbursedAmount
+		report.Total += r.DisbursedAmount
+		if advance.Overdue {
+			d.Overdue += r.DisbursedAmount
+			report.Overdue += r.DisbursedAmount
+		}
+	}
+
+	for _, d := range byDivision {
+		report.ByDivision = append(report.ByDivision, *d)
+	}
+	sort.Slice(report.ByDivision, func(i, j int) bool {
+		return report.ByDivision[i].DivisionName < report.ByDivision[j].DivisionName
+	})
+	return report, nil
+}
+
+// NotifyFundRequestSubmitted tells the treasurers a division is asking for
+// money.
+func NotifyFundRequestSubmitted(db *gorm.DB, notifier Notifier, r *models.FundRequest) {
+	message := fmt.Sprintf("%s\nJumlah diajukan: %s\n\nSilakan tinjau di menu pengajuan dana panel admin.",
+		fundRequestDescription(db, r), r.Amount.Format())
+	notifyTreasurers(db, notifier, r.RequestedBy, "Pengajuan dana baru", message)
+}
+
+// NotifyFundRequestUpdated tells the requester their request moved on.
+func NotifyFundRequestUpdated(db *gorm.DB, notifier Notifier, r *models.FundRequest) {
+	var subject, message string
+	switch r.Status {
+	case models.FundRequestApproved:
+		subject = "Pengajuan dana disetujui"
+		message = fmt.Sprintf("%s\nDisetujui: %s", fundRequestDescription(db, r), r.ApprovedAmount.Format())
+	case models.FundRequestRejected:
+		subject = "Pengajuan dana ditolak"
+		message = fmt.Sprintf("%s\nAlasan: %s", fundRequestDescription(db, r), r.DecisionNote)
+	case models.FundRequestDisbursed:
+		subject = "Dana telah dicairkan"
+		message = fmt.Sprintf("%s\nDicairkan: %s\n\nJangan lupa melaporkan nota/kuitansi setelah kegiatan.",
+			fundRequestDescription(db, r), r.DisbursedAmount.Format())
+	default:
+		return
+	}
+	if r.DecisionNote != "" && r.Status == models.FundRequestApproved {
+		message += "\nCatatan: " + r.DecisionNote
+	}
+	notifyUser(db, notifier, r.RequestedBy, subject, message)
+}
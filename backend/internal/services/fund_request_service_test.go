@@ -0,0 +1,110 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+	"masjid-baiturrahim-backend/internal/testutil"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// disbursedFundRequest files a request for amount on behalf of requester,
+// has the treasurer approve and disburse it, and attaches a receipt.
+func disbursedFundRequest(t *testing.T, db *gorm.DB, divisionID, requester, treasurer uuid.UUID, amount models.Money) *models.FundRequest {
+	t.Helper()
+	r := &models.FundRequest{DivisionID: divisionID, Title: "Santunan yatim", Amount: amount}
+	if err := SubmitFundRequest(db, r, requester); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ApproveFundRequest(db, r.ID, treasurer, 0, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	var account models.LedgerAccount
+	var category models.LedgerCategory
+	db.First(&account, "is_default = ?", true)
+	db.First(&category, "code = ?", "operasional-lain")
+	r, err := DisburseFundRequest(db, r.ID, treasurer, account.ID, category.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Receipts = models.Gallery{"receipts/nota.jpg"}
+	if err := db.Model(r).Update("receipts", r.Receipts).Error; err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestSettleFundRequest(t *testing.T) {
+	db := testutil.NewDB(t)
+	editor := testutil.NewUser(t, db, models.RoleEditor, "")
+	otherEditor := testutil.NewUser(t, db, models.RoleEditor, "-2")
+	admin := testutil.NewUser(t, db, models.RoleAdmin, "")
+	treasurer := testutil.NewUser(t, db, models.RoleTreasurer, "")
+	otherTreasurer := testutil.NewUser(t, db, models.RoleTreasurer, "-2")
+
+	division := models.Division{Name: "Remaja Masjid", IsActive: true}
+	if err := db.Create(&division).Error; err != nil {
+		t.Fatal(err)
+	}
+	budget := models.DivisionBudget{DivisionID: division.ID, Year: time.Now().Year(), Amount: models.NewMoney(1000000)}
+	if err := db.Create(&budget).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		actor   models.User
+		spent   int64
+		wantErr error
+	}{
+		{"requester returns the leftover", editor, 300000, nil},
+		{"requester spends it all", editor, 400000, nil},
+		{"another editor", otherEditor, 300000, ErrFundReportNotAllowed},
+		{"admin on the requester's behalf", admin, 300000, nil},
+		{"requester overspends", editor, 450000, ErrFundOverspend},
+		{"admin overspends", admin, 450000, ErrFundOverspend},
+		{"treasurer settles an overspend", treasurer, 450000, nil},
+		{"overspend past the budget", treasurer, 1100000, ErrOverBudget},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := disbursedFundRequest(t, db, division.ID, editor.ID, otherTreasurer.ID, models.NewMoney(400000))
+			defer db.Model(r).Update("status", models.FundRequestCancelled) // frees the budget for the next case
+
+			settled, err := SettleFundRequest(db, r.ID, tt.actor.ID, tt.actor.Role, models.NewMoney(tt.spent), "")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				var unchanged models.FundRequest
+				db.First(&unchanged, "id = ?", r.ID)
+				if unchanged.Status != models.FundRequestDisbursed {
+					t.Errorf("status = %s after a refused report, want disbursed", unchanged.Status)
+				}
+				return
+			}
+
+			if settled.Status != models.FundRequestSettled || settled.SpentAmount != models.NewMoney(tt.spent) {
+				t.Errorf("settled as %s with %s spent", settled.Status, settled.SpentAmount.Format())
+			}
+			var entry models.LedgerEntry
+			if err := db.First(&entry, "id = ?", r.LedgerEntryID).Error; err != nil {
+				t.Fatal(err)
+			}
+			if entry.Amount != models.NewMoney(tt.spent) {
+				t.Errorf("ledger entry = %s, want %s", entry.Amount.Format(), models.NewMoney(tt.spent).Format())
+			}
+		})
+	}
+
+	t.Run("treasurer who requested it", func(t *testing.T) {
+		r := disbursedFundRequest(t, db, division.ID, treasurer.ID, otherTreasurer.ID, models.NewMoney(400000))
+		if _, err := SettleFundRequest(db, r.ID, treasurer.ID, treasurer.Role, models.NewMoney(450000), ""); !errors.Is(err, ErrFundSelfApproval) {
+			t.Errorf("err = %v, want %v", err, ErrFundSelfApproval)
+		}
+	})
+}
@@ -0,0 +1,301 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RefundCategoryCode is the expense category refunds of donations post to.
+const RefundCategoryCode = "pengembalian-donasi"
+
+var (
+	ErrNoLedgerAccount      = errors.New("no ledger account for this donation; set a default account")
+	ErrNoLedgerCategory     = errors.New("no ledger category for this donation category")
+	ErrLedgerCategoryType   = errors.New("category type does not match entry type")
+	ErrLedgerAmount         = errors.New("amount must be positive")
+	ErrLedgerEntryGenerated = errors.New("entries posted from donations can't be edited by hand")
+)
+
+func donationLedgerAccount(tx *gorm.DB, donation *models.Donation) (*models.LedgerAccount, error) {
+	var account models.LedgerAccount
+	if donation.PaymentMethodID != nil {
+		err := tx.Where("payment_method_id = ? AND is_active = ?", donation.PaymentMethodID, true).First(&account).Error
+		if err == nil {
+			return &account, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+
+	err := tx.Where("is_default = ? AND is_active = ?", true, true).First(&account).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNoLedgerAccount
+	}
+	return &account, err
+}
+
+func postDonationEntry(tx *gorm.DB, donation *models.Donation, entry models.LedgerEntry) error {
+	account, err := donationLedgerAccount(tx, donation)
+	if err != nil {
+		return err
+	}
+
+	// The account receives the transfer amount, unique code included, so
+	// the balance matches the bank statement
+	entry.AccountID = account.ID
+	entry.Amount = donation.TransferAmount
+	entry.DonationID = &donation.ID
+	entry.Reference = &donation.DonationCode
+
+	// Posting twice (e.g. a sync after a live confirmation) is a no-op
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "source"}, {Name: "donation_id"}},
+		DoNothing: true,
+	}).Create(&entry).Error
+}
+
+// PostDonation records a confirmed donation as income in the account of its
+// payment method and the category of its donation category.
+func PostDonation(tx *gorm.DB, donation *models.Donation) error {
+	var category models.LedgerCategory
+	if err := tx.Where("donation_category = ?", donation.Category).First(&category).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: %s", ErrNoLedgerCategory, donation.Category)
+		}
+		return err
+	}
+
+	entryDate := donation.CreatedAt
+	if donation.ConfirmedAt != nil {
+		entryDate = *donation.ConfirmedAt
+	}
+
+	return postDonationEntry(tx, donation, models.LedgerEntry{
+		CategoryID:  category.ID,
+		Type:        models.LedgerIncome,
+		EntryDate:   entryDate,
+		Description: donationEntryDescription(donation),
+		Source:      models.LedgerSourceDonation,
+		CreatedBy:   donation.ConfirmedBy,
+	})
+}
+
+func donationEntryDescription(donation *models.Donation) string {
+	description := fmt.Sprintf("Donasi %s dari %s", donation.Category, donation.DonorName)
+	if donation.UniqueCode > 0 {
+		description += fmt.Sprintf(" (termasuk kode unik %s)", models.NewMoney(int64(donation.UniqueCode)).Format())
+	}
+	return description
+}
+
+// PostDonationRefund records the money returned for a refunded donation.
+func PostDonationRefund(tx *gorm.DB, donation *models.Donation, actorID *uuid.UUID) error {
+	var category models.LedgerCategory
+	if err := tx.Where("code = ?", RefundCategoryCode).First(&category).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: %s", ErrNoLedgerCategory, RefundCategoryCode)
+		}
+		return err
+	}
+
+	return postDonationEntry(tx, donation, models.LedgerEntry{
+		CategoryID:  category.ID,
+		Type:        models.LedgerExpense,
+		EntryDate:   time.Now(),
+		Description: fmt.Sprintf("Pengembalian donasi %s kepada %s", donation.DonationCode, donation.DonorName),
+		Source:      models.LedgerSourceDonationRefund,
+		CreatedBy:   actorID,
+	})
+}
+
+// SyncDonationsToLedger posts confirmed and refunded donations that have no
+// ledger entry yet, e.g. those confirmed before the ledger existed.
+func SyncDonationsToLedger(db *gorm.DB) (int, error) {
+	var donations []models.Donation
+	err := db.Where("status IN ?", []models.DonationStatus{models.DonationStatusConfirmed, models.DonationStatusRefunded}).
+		Where("NOT EXISTS (SELECT 1 FROM ledger_entries WHERE ledger_entries.donation_id = donations.id AND ledger_entries.source = ?)", models.LedgerSourceDonation).
+		Order("confirmed_at ASC").
+		Find(&donations).Error
+	if err != nil {
+		return 0, err
+	}
+
+	posted := 0
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for i := range donations {
+			d := &donations[i]
+			if err := PostDonation(tx, d); err != nil {
+				return fmt.Errorf("donation %s: %w", d.DonationCode, err)
+			}
+			if d.Status == models.DonationStatusRefunded {
+				if err := PostDonationRefund(tx, d, nil); err != nil {
+					return fmt.Errorf("donation %s: %w", d.DonationCode, err)
+				}
+			}
+			posted++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return posted, nil
+}
+
+// ValidateLedgerEntry checks a manual entry against its account and category.
+func ValidateLedgerEntry(db *gorm.DB, entry *models.LedgerEntry) error {
+	if entry.Amount <= 0 {
+		return ErrLedgerAmount
+	}
+
+	var account models.LedgerAccount
+	if err := db.Where("id = ? AND is_active = ?", entry.AccountID, true).First(&account).Error; err != nil {
+		return fmt.Errorf("invalid account: %w", err)
+	}
+
+	var category models.LedgerCategory
+	if err := db.Where("id = ? AND is_active = ?", entry.CategoryID, true).First(&category).Error; err != nil {
+		return fmt.Errorf("invalid category: %w", err)
+	}
+	if category.Type != entry.Type {
+		return ErrLedgerCategoryType
+	}
+	return nil
+}
+
+type AccountBalance struct {
+	models.LedgerAccount
+	TotalIncome  models.Money `json:"total_income"`
+	TotalExpense models.Money `json:"total_expense"`
+	Balance      models.Money `json:"balance"`
+}
+
+// GetAccountBalances returns each account's balance as of the end of asOf
+// (or all entries when asOf is nil), including its opening balance.
+func GetAccountBalances(db *gorm.DB, asOf *time.Time) ([]AccountBalance, error) {
+	var accounts []models.LedgerAccount
+	if err := db.Order("name ASC").Find(&accounts).Error; err != nil {
+		return nil, err
+	}
+
+	query := db.Model(&models.LedgerEntry{}).
+		Select(`account_id,
+			COALESCE(SUM(amount) FILTER (WHERE type = 'income'), 0) as total_income,
+			COALESCE(SUM(amount) FILTER (WHERE type = 'expense'), 0) as total_expense`).
+		Group("account_id")
+	if asOf != nil {
+		query = query.Where("entry_date <= ?", asOf.Format("2006-01-02"))
+	}
+
+	var rows []struct {
+		AccountID    uuid.UUID
+		TotalIncome  models.Money
+		TotalExpense models.Money
+	}
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	balances := make([]AccountBalance, len(accounts))
+	for i, account := range accounts {
+		balances[i] = AccountBalance{LedgerAccount: account, Balance: account.OpeningBalance}
+		for _, row := range rows {
+			if row.AccountID == account.ID {
+				balances[i].TotalIncome = row.TotalIncome
+				balances[i].TotalExpense = row.TotalExpense
+				balances[i].Balance += row.TotalIncome - row.TotalExpense
+			}
+		}
+	}
+	return balances, nil
+}
+
+type LedgerEntryFilter struct {
+	AccountID  *uuid.UUID
+	CategoryID *uuid.UUID
+	Type       models.LedgerEntryType
+	From       *time.Time
+	To         *time.Time
+}
+
+// LedgerEntryWithBalance carries the running balance of the entry's account
+// after the entry, counting every earlier entry regardless of filters.
+type LedgerEntryWithBalance struct {
+	models.LedgerEntry
+	RunningBalance models.Money `json:"running_balance"`
+}
+
+// ListLedgerEntries returns a page of entries in booking order, newest first.
+func ListLedgerEntries(db *gorm.DB, filter LedgerEntryFilter, offset, limit int) ([]LedgerEntryWithBalance, int64, error) {
+	running := db.Model(&models.LedgerEntry{}).
+		Select(`ledger_entries.*, ledger_accounts.opening_balance + SUM(
+			CASE WHEN ledger_entries.type = 'expense' THEN -ledger_entries.amount ELSE ledger_entries.amount END
+		) OVER (
+			PARTITION BY ledger_entries.account_id
+			ORDER BY ledger_entries.entry_date, ledger_entries.created_at, ledger_entries.id
+		) as running_balance`).
+		Joins("JOIN ledger_accounts ON ledger_accounts.id = ledger_entries.account_id")
+
+	query := db.Table("(?) as entries", running)
+	if filter.AccountID != nil {
+		query = query.Where("account_id = ?", *filter.AccountID)
+	}
+	if filter.CategoryID != nil {
+		query = query.Where("category_id = ?", *filter.CategoryID)
+	}
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.From != nil {
+		query = query.Where("entry_date >= ?", filter.From.Format("2006-01-02"))
+	}
+	if filter.To != nil {
+		query = query.Where("entry_date <= ?", filter.To.Format("2006-01-02"))
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var rows []struct {
+		ID             uuid.UUID
+		RunningBalance models.Money
+	}
+	if err := query.Select("id, running_balance").
+		Order("entry_date DESC, created_at DESC, id DESC").
+		Offset(offset).
+		Limit(limit).
+		Scan(&rows).Error; err != nil {
+		return nil, 0, err
+	}
+
+	ids := make([]uuid.UUID, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+	}
+	var entries []models.LedgerEntry
+	if len(ids) > 0 {
+		if err := db.Preload("Account").Preload("Category").Preload("Creator").
+			Where("id IN ?", ids).Find(&entries).Error; err != nil {
+			return nil, 0, err
+		}
+	}
+
+	byID := make(map[uuid.UUID]models.LedgerEntry, len(entries))
+	for _, e := range entries {
+		byID[e.ID] = e
+	}
+	result := make([]LedgerEntryWithBalance, len(rows))
+	for i, row := range rows {
+		result[i] = LedgerEntryWithBalance{LedgerEntry: byID[row.ID], RunningBalance: row.RunningBalance}
+	}
+	return result, total, nil
+}
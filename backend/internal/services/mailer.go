@@ -0,0 +1,89 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/smtp"
+	"regexp"
+	"strings"
+	"masjid-baiturrahim-backend/config"
+)
+
+// Mailer sends plain-text email. It is an interface so deployments without
+// SMTP (and local development) can log messages instead.
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+var ErrMailNotConfigured = errors.New("email is not configured, set SMTP_HOST")
+
+// NewMailer returns an SMTP mailer when SMTP_HOST is configured and a logging
+// mailer otherwise. Production never falls back to the log: mail there holds
+// reset and login links, so without SMTP nothing is sent.
+func NewMailer(cfg *config.Config) Mailer {
+	if cfg.SMTPHost == "" {
+		if cfg.Environment == "production" {
+			log.Println("SMTP_HOST is not set, email is disabled")
+			return disabledMailer{}
+		}
+		return LogMailer{}
+	}
+	return &SMTPMailer{
+		Host:     cfg.SMTPHost,
+		Port:     cfg.SMTPPort,
+		Username: cfg.SMTPUsername,
+		Password: cfg.SMTPPassword,
+		From:     cfg.MailFrom,
+	}
+}
+
+// LogMailer writes messages to the server log for development. Links and
+// anything that looks like a token are redacted, since the log is read by
+// more people than the recipient.
+type LogMailer struct{}
+
+var (
+	mailLinkPattern  = regexp.MustCompile(`https?://\S+`)
+	mailTokenPattern = regexp.MustCompile(`[A-Za-z0-9_-]{20,}`)
+)
+
+func (LogMailer) Send(to, subject, body string) error {
+	body = mailLinkPattern.ReplaceAllString(body, "[link redacted]")
+	body = mailTokenPattern.ReplaceAllString(body, "[token redacted]")
+	log.Printf("Mail to %s: %s\n%s", to, subject, body)
+	return nil
+}
+
+type disabledMailer struct{}
+
+func (disabledMailer) Send(to, subject, body string) error {
+	return ErrMailNotConfigured
+}
+
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) Send(to, subject, body string) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	msg := strings.Join([]string{
+		"From: " + m.From,
+		"To: " + to,
+		"Subject: " + subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n")
+
+	return smtp.SendMail(fmt.Sprintf("%s:%d", m.Host, m.Port), auth, m.From, []string{to}, []byte(msg))
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+	"masjid-baiturrahim-backend/config"
+)
+
+func TestLogMailerRedacts(t *testing.T) {
+	var out bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&out)
+
+	token := "kQ3v9X_2mZpL8rT-wYb7NcHd"
+	body := "Gunakan tautan berikut:\nhttp://localhost:3000/reset-password?token=" + token + "\n\nKode akses: " + token + "\nBerlaku 60 menit."
+	if err := (LogMailer{}).Send("admin@example.com", "Atur ulang kata sandi", body); err != nil {
+		t.Fatal(err)
+	}
+
+	logged := out.String()
+	if strings.Contains(logged, token) || strings.Contains(logged, "reset-password") {
+		t.Errorf("log contains the link or token:\n%s", logged)
+	}
+	for _, want := range []string{"admin@example.com", "Atur ulang kata sandi", "[link redacted]", "[token redacted]", "Berlaku 60 menit."} {
+		if !strings.Contains(logged, want) {
+			t.Errorf("log is missing %q:\n%s", want, logged)
+		}
+	}
+}
+
+func TestNewMailerWithoutSMTP(t *testing.T) {
+	if _, ok := NewMailer(&config.Config{Environment: "development"}).(LogMailer); !ok {
+		t.Error("development without SMTP_HOST should log mail")
+	}
+
+	mailer := NewMailer(&config.Config{Environment: "production"})
+	if _, ok := mailer.(LogMailer); ok {
+		t.Fatal("production without SMTP_HOST must not log mail")
+	}
+	if err := mailer.Send("admin@example.com", "Atur ulang kata sandi", "secret link"); !errors.Is(err, ErrMailNotConfigured) {
+		t.Errorf("Send = %v, want ErrMailNotConfigured", err)
+	}
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MutationSignatureHeader carries the hex HMAC-SHA256 of the raw request body,
+// keyed with the webhook secret shared with the mutation-checker service.
+const MutationSignatureHeader = "Signature"
+
+var ErrMutationPayload = errors.New("invalid mutation webhook payload")
+
+// WebhookMutation is one mutation as pushed by Moota. Amounts may come as
+// numbers or numeric strings.
+type WebhookMutation struct {
+	MutationID    string      `json:"mutation_id"`
+	BankType      string      `json:"bank_type"`
+	AccountNumber string      `json:"account_number"`
+	Date          string      `json:"date"` // YYYY-MM-DD HH:MM:SS, local time
+	Description   string      `json:"description"`
+	Type          string      `json:"type"` // CR or DB
+	Amount        json.Number `json:"amount"`
+	Balance       json.Number `json:"balance"`
+}
+
+type MutationWebhookResult struct {
+	Received   int `json:"received"`
+	Duplicates int `json:"duplicates"`
+	Matched    int `json:"matched"`
+	Ambiguous  int `json:"ambiguous"`
+}
+
+// SignMutationPayload returns the signature the sender puts in
+// MutationSignatureHeader.
+func SignMutationPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func VerifyMutationSignature(secret string, body []byte, signature string) bool {
+	if secret == "" || signature == "" {
+		return false
+	}
+	expected := SignMutationPayload(secret, body)
+	return hmac.Equal([]byte(expected), []byte(strings.ToLower(strings.TrimSpace(signature))))
+}
+
+// webhookBank maps the checker's bank type (e.g. "bcaGiro", "mandiriOnline")
+// to our bank codes; other banks are kept as sent.
+func webhookBank(bankType string) models.BankCode {
+	t := strings.ToLower(strings.TrimSpace(bankType))
+	for _, bank := range []models.BankCode{models.BankBCA, models.BankBSI, models.BankMandiri} {
+		if strings.HasPrefix(t, string(bank)) {
+			return bank
+		}
+	}
+	if len(t) > 20 {
+		t = t[:20]
+	}
+	return models.BankCode(t)
+}
+
+func webhookFingerprint(mutationID string) string {
+	sum := sha256.Sum256([]byte("moota|" + mutationID))
+	return hex.EncodeToString(sum[:])
+}
+
+// ParseMutationWebhook reads a webhook body, a JSON array of mutations.
+func ParseMutationWebhook(body []byte) ([]models.BankMutation, error) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("%w (%s)", ErrMutationPayload, err.Error())
+	}
+
+	mutations := make([]models.BankMutation, 0, len(raw))
+	for i, item := range raw {
+		var in WebhookMutation
+		if err := json.Unmarshal(item, &in); err != nil {
+			return nil, fmt.Errorf("%w (mutation %d: %s)", ErrMutationPayload, i, err.Error())
+		}
+		if in.MutationID == "" {
+			return nil, fmt.Errorf("%w (mutation %d has no mutation_id)", ErrMutationPayload, i)
+		}
+
+		at, err := time.ParseInLocation("2006-01-02 15:04:05", strings.TrimSpace(in.Date), time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("%w (mutation %s: invalid date %q)", ErrMutationPayload, in.MutationID, in.Date)
+		}
+		amount, err := models.ParseMoney(in.Amount.String())
+		if err != nil || amount <= 0 {
+			return nil, fmt.Errorf("%w (mutation %s: invalid amount %q)", ErrMutationPayload, in.MutationID, in.Amount)
+		}
+
+		m := models.BankMutation{
+			Source:          models.MutationSourceWebhook,
+			ExternalID:      in.MutationID,
+			Bank:            webhookBank(in.BankType),
+			Fingerprint:     webhookFingerprint(in.MutationID),
+			TransactionDate: time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, time.UTC),
+			TransactionTime: &at,
+			Description:     strings.TrimSpace(in.Description),
+			Type:            models.MutationCredit,
+			Amount:          amount,
+			RawPayload:      string(item),
+		}
+		switch strings.ToUpper(strings.TrimSpace(in.Type)) {
+		case "CR", "CREDIT":
+		case "DB", "DEBIT":
+			m.Type = models.MutationDebit
+		default:
+			return nil, fmt.Errorf("%w (mutation %s: unknown type %q)", ErrMutationPayload, in.MutationID, in.Type)
+		}
+		if in.Balance != "" {
+			if balance, err := models.ParseMoney(in.Balance.String()); err == nil {
+				m.Balance = &balance
+			}
+		}
+		mutations = append(mutations, m)
+	}
+	return mutations, nil
+}
+
+// IngestMutationWebhook stores pushed mutations and proposes matches for new
+// credits. Services retry deliveries, so a mutation that was stored before is
+// counted as a duplicate and left as it is.
+func IngestMutationWebhook(db *gorm.DB, body []byte) (*MutationWebhookResult, error) {
+	mutations, err := ParseMutationWebhook(body)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &MutationWebhookResult{Received: len(mutations)}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for i := range mutations {
+			m := &mutations[i]
+			if err := MatchMutation(tx, m); err != nil {
+				return err
+			}
+
+			created := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "fingerprint"}}, DoNothing: true}).Create(m)
+			if created.Error != nil {
+				return created.Error
+			}
+			switch {
+			case created.RowsAffected == 0:
+				result.Duplicates++
+			case m.MatchStatus == models.MutationMatched:
+				result.Matched++
+			case m.MatchStatus == models.MutationAmbiguous:
+				result.Ambiguous++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
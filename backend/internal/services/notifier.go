@@ -0,0 +1,41 @@
+package services
+
+import (
+	"errors"
+	"log"
+)
+
+var ErrNoNotificationChannel = errors.New("recipient has no contact this notifier can reach")
+
+// Recipient is someone a notification is addressed to.
+type Recipient struct {
+	Name  string
+	Email *string
+	Phone *string
+}
+
+// Notifier delivers short messages to donors and staff. Email is the only
+// channel today; a WhatsApp or SMS gateway can be added as another Notifier.
+type Notifier interface {
+	Notify(to Recipient, subject, message string) error
+}
+
+// MailNotifier notifies recipients by email.
+type MailNotifier struct {
+	Mailer Mailer
+}
+
+func (n MailNotifier) Notify(to Recipient, subject, message string) error {
+	if to.Email == nil || *to.Email == "" {
+		return ErrNoNotificationChannel
+	}
+	return n.Mailer.Send(*to.Email, subject, message)
+}
+
+// LogNotifier writes notifications to the server log.
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(to Recipient, subject, message string) error {
+	log.Printf("Notification to %s: %s\n%s", to.Name, subject, message)
+	return nil
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+	"masjid-baiturrahim-backend/internal/utils"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	MinPasswordLength     = 8
+	PasswordResetTokenTTL = time.Hour
+	passwordHashCost      = 12
+)
+
+var (
+	ErrPasswordIncorrect    = errors.New("current password is incorrect")
+	ErrPasswordTooShort     = fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+	ErrPasswordUnchanged    = errors.New("new password must differ from the current one")
+	ErrPasswordResetInvalid = errors.New("reset link is invalid or has expired")
+)
+
+func ValidatePassword(password string) error {
+	if len([]rune(password)) < MinPasswordLength {
+		return ErrPasswordTooShort
+	}
+	return nil
+}
+
+// setPassword stores a new password for a user and ends all of their
+// sessions and outstanding reset links, so whoever knew the old password or
+// held a token is logged out.
+func setPassword(tx *gorm.DB, userID uuid.UUID, password string, mustChange bool, reason string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), passwordHashCost)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"password_hash":        string(hash),
+		"must_change_password": mustChange,
+		"password_changed_at":  now,
+	}).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", now).Error; err != nil {
+		return err
+	}
+	return RevokeUserSessions(tx, userID, reason)
+}
+
+// MustChangePassword tells whether the user still has a temporary or default
+// password to replace. A user who can't be looked up is an error, not a
+// pass.
+func MustChangePassword(db *gorm.DB, userID uuid.UUID) (bool, error) {
+	var user models.User
+	if err := db.Select("must_change_password").First(&user, "id = ?", userID).Error; err != nil {
+		return false, err
+	}
+	return user.MustChangePassword, nil
+}
+
+// ChangePassword sets a new password for a user who knows the current one.
+func ChangePassword(db *gorm.DB, userID uuid.UUID, current, password string) error {
+	var user models.User
+	if err := db.First(&user, "id = ?", userID).Error; err != nil {
+		return err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(current)) != nil {
+		return ErrPasswordIncorrect
+	}
+	if err := ValidatePassword(password); err != nil {
+		return err
+	}
+	if password == current {
+		return ErrPasswordUnchanged
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		return setPassword(tx, user.ID, password, false, RevokePasswordChange)
+	})
+}
+
+func sendPasswordResetLink(db *gorm.DB, mailer Mailer, user *models.User, frontendURL, intro string) error {
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return err
+	}
+	if err := db.Create(&models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(PasswordResetTokenTTL),
+	}).Error; err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", strings.TrimRight(frontendURL, "/"), token)
+	body := fmt.Sprintf("Assalamu'alaikum %s,\n\n%s\n%s\n\nTautan ini berlaku %d menit dan hanya dapat digunakan sekali.\n",
+		user.FullName, intro, link, int(PasswordResetTokenTTL.Minutes()))
+	return mailer.Send(user.Email, "Atur ulang kata sandi", body)
+}
+
+// RequestPasswordReset emails a reset link to the active user with this
+// email. Unknown addresses are silently ignored so the endpoint can't be used
+// to find out who has an account.
+func RequestPasswordReset(db *gorm.DB, mailer Mailer, email, frontendURL string) error {
+	var user models.User
+	if err := db.Where("LOWER(email) = ? AND is_active = ?", utils.NormalizeEmail(email), true).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	return sendPasswordResetLink(db, mailer, &user, frontendURL,
+		"Kami menerima permintaan untuk mengatur ulang kata sandi Anda. Gunakan tautan berikut untuk membuat kata sandi baru, atau abaikan email ini jika Anda tidak memintanya:")
+}
+
+// ResetPassword consumes a reset link token and sets the new password.
+func ResetPassword(db *gorm.DB, token, password string) error {
+	if err := ValidatePassword(password); err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var resetToken models.PasswordResetToken
+		result := tx.Model(&resetToken).
+			Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", utils.HashToken(token), time.Now()).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrPasswordResetInvalid
+		}
+
+		if err := tx.First(&resetToken, "token_hash = ?", utils.HashToken(token)).Error; err != nil {
+			return err
+		}
+		var user models.User
+		if err := tx.First(&user, "id = ? AND is_active = ?", resetToken.UserID, true).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrPasswordResetInvalid
+			}
+			return err
+		}
+		return setPassword(tx, user.ID, password, false, RevokePasswordReset)
+	})
+}
+
+// ForcePasswordReset is an admin resetting someone else's password. With a
+// temporary password the user has to choose a new one after logging in;
+// without one the old password stops working and a reset link is emailed.
+func ForcePasswordReset(db *gorm.DB, mailer Mailer, userID uuid.UUID, temporary, frontendURL string) error {
+	var user models.User
+	if err := db.First(&user, "id = ?", userID).Error; err != nil {
+		return err
+	}
+
+	if temporary != "" {
+		if err := ValidatePassword(temporary); err != nil {
+			return err
+		}
+		return db.Transaction(func(tx *gorm.DB) error {
+			return setPassword(tx, user.ID, temporary, true, RevokePasswordReset)
+		})
+	}
+
+	// Nobody knows this password, the link is the only way back in
+	unusable, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return err
+	}
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return setPassword(tx, user.ID, unusable, true, RevokePasswordReset)
+	}); err != nil {
+		return err
+	}
+	return sendPasswordResetLink(db, mailer, &user, frontendURL,
+		"Administrator telah mengatur ulang kata sandi akun Anda. Gunakan tautan berikut untuk membuat kata sandi baru:")
+}
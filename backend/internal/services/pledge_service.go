@@ -0,0 +1,450 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// SettingPledgeLeadDays is how many days before the due date the
+	// expected donation is created, so the donor can already pay it
+	SettingPledgeLeadDays = "pledge_lead_days"
+	// SettingPledgeReminderDays is how many days before the due date the
+	// donor is reminded
+	SettingPledgeReminderDays = "pledge_reminder_days"
+	// SettingPledgeGraceDays is how long after the due date an unpaid
+	// installment is flagged as missed
+	SettingPledgeGraceDays = "pledge_grace_days"
+)
+
+var (
+	ErrPledgeInvalid   = errors.New("pledge needs a donor, a category and a valid amount")
+	ErrPledgeFrequency = errors.New("frequency must be monthly, quarterly or yearly")
+	ErrPledgeDay       = errors.New("day of month must be between 1 and 31")
+	ErrPledgeDates     = errors.New("end date must not be before start date")
+	ErrPledgeStatus    = errors.New("invalid pledge status")
+)
+
+func ValidatePledge(p *models.Pledge) error {
+	if p.DonorID == uuid.Nil || p.Category == "" {
+		return ErrPledgeInvalid
+	}
+	if p.Frequency.Months() == 0 {
+		return ErrPledgeFrequency
+	}
+	if p.DayOfMonth < 1 || p.DayOfMonth > 31 {
+		return ErrPledgeDay
+	}
+	if p.EndDate != nil && p.EndDate.Before(p.StartDate) {
+		return ErrPledgeDates
+	}
+	return ValidateDonationAmount(p.Amount)
+}
+
+// pledgeDate drops the time of day. Date columns come back from the database
+// as UTC midnight, so every pledge date is compared in UTC.
+func pledgeDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// PledgeDueDates lists the due dates of a pledge between from and to,
+// inclusive. A day past the end of the month falls on the month's last day.
+func PledgeDueDates(p models.Pledge, from, to time.Time) []time.Time {
+	step := p.Frequency.Months()
+	if step == 0 {
+		return nil
+	}
+	start := pledgeDate(p.StartDate)
+	from, to = pledgeDate(from), pledgeDate(to)
+	if p.EndDate != nil && pledgeDate(*p.EndDate).Before(to) {
+		to = pledgeDate(*p.EndDate)
+	}
+
+	var dates []time.Time
+	for i := 0; ; i += step {
+		month := time.Date(start.Year(), start.Month()+time.Month(i), 1, 0, 0, 0, 0, time.UTC)
+		day := p.DayOfMonth
+		if last := month.AddDate(0, 1, -1).Day(); day > last {
+			day = last
+		}
+		due := month.AddDate(0, 0, day-1)
+		if due.After(to) {
+			return dates
+		}
+		if due.Before(start) || due.Before(from) {
+			continue
+		}
+		dates = append(dates, due)
+	}
+}
+
+// createPledgeInstallment creates the pending donation the donor is expected
+// to pay for one due date, with a unique code so the transfer can be matched.
+func createPledgeInstallment(db *gorm.DB, p models.Pledge, due time.Time) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		code, err := AssignUniqueCode(tx, p.Amount)
+		if err != nil {
+			return err
+		}
+
+		donation := models.Donation{
+			DonationCode:   GenerateDonationCode(),
+			DonorName:      p.Donor.Name,
+			DonorEmail:     p.Donor.Email,
+			DonorPhone:     p.Donor.Phone,
+			DonorID:        &p.DonorID,
+			Amount:         p.Amount,
+			UniqueCode:     code,
+			TransferAmount: p.Amount + models.NewMoney(int64(code)),
+			Category:       p.Category,
+			CampaignID:     p.CampaignID,
+			Notes:          fmt.Sprintf("Janji donasi jatuh tempo %s", FormatIndonesianDate(due.Format(dateLayout))),
+			Status:         models.DonationStatusPending,
+		}
+		if err := tx.Create(&donation).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&models.PledgeInstallment{
+			PledgeID:   p.ID,
+			DueDate:    due,
+			DonationID: donation.ID,
+			Status:     models.InstallmentExpected,
+		}).Error
+	})
+}
+
+// GeneratePledgeInstallments creates expected donations for active pledges
+// falling due within the lead time and returns how many were created.
+func GeneratePledgeInstallments(db *gorm.DB, now time.Time) (int, error) {
+	today := pledgeDate(now)
+	horizon := today.AddDate(0, 0, int(GetSettingInt(db, SettingPledgeLeadDays, 7)))
+
+	var pledges []models.Pledge
+	if err := db.Preload("Donor").
+		Where("status = ? AND start_date <= ?", models.PledgeActive, horizon).
+		Find(&pledges).Error; err != nil {
+		return 0, err
+	}
+
+	created := 0
+	for _, p := range pledges {
+		if p.Donor == nil {
+			continue
+		}
+
+		var existing []time.Time
+		if err := db.Model(&models.PledgeInstallment{}).
+			Where("pledge_id = ?", p.ID).
+			Pluck("due_date", &existing).Error; err != nil {
+			return created, err
+		}
+		scheduled := make(map[string]bool, len(existing))
+		for _, d := range existing {
+			scheduled[d.Format(dateLayout)] = true
+		}
+
+		for _, due := range PledgeDueDates(p, p.ScheduleFrom, horizon) {
+			if scheduled[due.Format(dateLayout)] {
+				continue
+			}
+			if err := createPledgeInstallment(db, p, due); err != nil {
+				return created, fmt.Errorf("pledge %s due %s: %w", p.ID, due.Format(dateLayout), err)
+			}
+			created++
+		}
+	}
+	return created, nil
+}
+
+// SyncPledgeInstallments updates installments from their donations: paid ones
+// are fulfilled, even if paid after being flagged missed, rejected or expired
+// ones are missed and cancelled ones were excused.
+func SyncPledgeInstallments(db *gorm.DB) error {
+	updates := []struct {
+		from     []models.PledgeInstallmentStatus
+		to       models.PledgeInstallmentStatus
+		statuses []models.DonationStatus
+	}{
+		{
+			from:     []models.PledgeInstallmentStatus{models.InstallmentExpected, models.InstallmentMissed},
+			to:       models.InstallmentFulfilled,
+			statuses: []models.DonationStatus{models.DonationStatusConfirmed, models.DonationStatusRefunded},
+		},
+		{
+			from:     []models.PledgeInstallmentStatus{models.InstallmentExpected},
+			to:       models.InstallmentMissed,
+			statuses: []models.DonationStatus{models.DonationStatusRejected, models.DonationStatusExpired},
+		},
+		{
+			from:     []models.PledgeInstallmentStatus{models.InstallmentExpected},
+			to:       models.InstallmentCancelled,
+			statuses: []models.DonationStatus{models.DonationStatusCancelled},
+		},
+	}
+
+	for _, u := range updates {
+		err := db.Exec(`UPDATE pledge_installments SET status = ?, updated_at = ?
+			FROM donations
+			WHERE donations.id = pledge_installments.donation_id
+				AND pledge_installments.status IN ? AND donations.status IN ?`,
+			u.to, time.Now(), u.from, u.statuses).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FlagMissedPledgeInstallments expires the donations of installments left
+// unpaid past the grace period and marks them missed.
+func FlagMissedPledgeInstallments(db *gorm.DB, now time.Time) (int, error) {
+	cutoff := pledgeDate(now).AddDate(0, 0, -int(GetSettingInt(db, SettingPledgeGraceDays, 14)))
+
+	var installments []models.PledgeInstallment
+	if err := db.Where("status = ? AND due_date < ?", models.InstallmentExpected, cutoff).
+		Find(&installments).Error; err != nil {
+		return 0, err
+	}
+
+	missed := 0
+	for _, inst := range installments {
+		_, err := SystemTransitionDonation(db, inst.DonationID, models.DonationStatusExpired, "Janji donasi tidak dibayar hingga batas waktu")
+		if err != nil && !errors.Is(err, ErrInvalidTransition) {
+			return missed, err
+		}
+		if err != nil {
+			// Settled in the meantime; the next sync records how
+			continue
+		}
+		if err := db.Model(&inst).Update("status", models.InstallmentMissed).Error; err != nil {
+			return missed, err
+		}
+		missed++
+	}
+	return missed, nil
+}
+
+// SendPledgeReminders reminds donors of installments falling due soon.
+// Installments are reminded once; donors the notifier can't reach are
+// skipped for good, other failures are retried on the next run.
+func SendPledgeReminders(db *gorm.DB, notifier Notifier, now time.Time) (int, error) {
+	until := pledgeDate(now).AddDate(0, 0, int(GetSettingInt(db, SettingPledgeReminderDays, 3)))
+
+	var installments []models.PledgeInstallment
+	if err := db.Preload("Pledge.Donor").Preload("Donation").
+		Where("status = ? AND reminded_at IS NULL AND due_date <= ?", models.InstallmentExpected, until).
+		Find(&installments).Error; err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, inst := range installments {
+		if inst.Pledge == nil || inst.Pledge.Donor == nil || inst.Donation == nil ||
+			inst.Donation.Status != models.DonationStatusPending {
+			continue
+		}
+		donor := inst.Pledge.Donor
+
+		message := fmt.Sprintf("Assalamu'alaikum %s,\n\nMengingatkan janji %s Anda sebesar %s yang jatuh tempo %s.\n\nMohon transfer tepat %s dengan kode donasi %s agar pembayaran Anda tercatat otomatis.\n\nJazakumullahu khairan.\n",
+			donor.Name, inst.Donation.Category, inst.Donation.Amount.Format(),
+			FormatIndonesianDate(inst.DueDate.Format(dateLayout)),
+			inst.Donation.TransferAmount.Format(), inst.Donation.DonationCode)
+		err := notifier.Notify(Recipient{Name: donor.Name, Email: donor.Email, Phone: donor.Phone}, "Pengingat janji donasi", message)
+		if err != nil && !errors.Is(err, ErrNoNotificationChannel) {
+			log.Printf("Failed to send pledge reminder for installment %s: %v", inst.ID, err)
+			continue
+		}
+
+		if err := db.Model(&inst).Update("reminded_at", now).Error; err != nil {
+			return sent, err
+		}
+		if err == nil {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+type PledgeRunResult struct {
+	Created  int `json:"created"`
+	Missed   int `json:"missed"`
+	Reminded int `json:"reminded"`
+}
+
+// RunPledgeSchedule brings every pledge up to date as of now.
+func RunPledgeSchedule(db *gorm.DB, notifier Notifier, now time.Time) (*PledgeRunResult, error) {
+	result := &PledgeRunResult{}
+	var err error
+
+	if err = SyncPledgeInstallments(db); err != nil {
+		return result, err
+	}
+	if result.Missed, err = FlagMissedPledgeInstallments(db, now); err != nil {
+		return result, err
+	}
+	if result.Created, err = GeneratePledgeInstallments(db, now); err != nil {
+		return result, err
+	}
+	if result.Reminded, err = SendPledgeReminders(db, notifier, now); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// RunPledgeScheduler runs the pledge schedule every hour. It blocks, so run
+// it in its own goroutine.
+func RunPledgeScheduler(db *gorm.DB, notifier Notifier) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for ; true; <-ticker.C {
+		result, err := RunPledgeSchedule(db, notifier, time.Now())
+		if err != nil {
+			log.Printf("Failed to run pledge schedule: %v", err)
+			continue
+		}
+		if result.Created+result.Missed+result.Reminded > 0 {
+			log.Printf("Pledges: %d installments created, %d missed, %d reminders sent", result.Created, result.Missed, result.Reminded)
+		}
+	}
+}
+
+// SetPledgeStatus pauses, resumes or ends a pledge. Installments not yet due
+// are cancelled when a pledge stops; overdue ones stay open so they can
+// still be paid.
+func SetPledgeStatus(db *gorm.DB, pledgeID uuid.UUID, status models.PledgeStatus, actorID uuid.UUID) (*models.Pledge, error) {
+	switch status {
+	case models.PledgeActive, models.PledgePaused, models.PledgeEnded:
+	default:
+		return nil, ErrPledgeStatus
+	}
+
+	var pledge models.Pledge
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&pledge, "id = ?", pledgeID).Error; err != nil {
+			return err
+		}
+		if pledge.Status == models.PledgeEnded && status != models.PledgeEnded {
+			return ErrPledgeStatus
+		}
+
+		today := pledgeDate(time.Now())
+		updates := map[string]interface{}{"status": status}
+		if status == models.PledgeActive && pledge.Status == models.PledgePaused {
+			updates["schedule_from"] = today
+		}
+		if err := tx.Model(&pledge).Updates(updates).Error; err != nil {
+			return err
+		}
+		if status == models.PledgeActive {
+			return nil
+		}
+
+		var upcoming []models.PledgeInstallment
+		if err := tx.Where("pledge_id = ? AND status = ? AND due_date > ?", pledge.ID, models.InstallmentExpected, today).
+			Find(&upcoming).Error; err != nil {
+			return err
+		}
+		for _, inst := range upcoming {
+			_, err := TransitionDonation(tx, inst.DonationID, models.DonationStatusCancelled, actorID, "Janji donasi dihentikan")
+			if err != nil && !errors.Is(err, ErrInvalidTransition) {
+				return err
+			}
+			if err == nil {
+				if err := tx.Model(&inst).Update("status", models.InstallmentCancelled).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &pledge, nil
+}
+
+type PledgeFulfilment struct {
+	PledgeID       uuid.UUID              `json:"pledge_id"`
+	DonorName      string                 `json:"donor_name"`
+	Amount         models.Money           `json:"amount"`
+	Frequency      models.PledgeFrequency `json:"frequency"`
+	Status         models.PledgeStatus    `json:"status"`
+	Due            int64                  `json:"due"`
+	Fulfilled      int64                  `json:"fulfilled"`
+	Missed         int64                  `json:"missed"`
+	Open           int64                  `json:"open"` // due but still within the grace period
+	PledgedAmount  models.Money           `json:"pledged_amount"`
+	ReceivedAmount models.Money           `json:"received_amount"`
+	Rate           float64                `json:"rate"` // fulfilled / due, in percent
+}
+
+type PledgeFulfilmentReport struct {
+	From    string             `json:"from"`
+	To      string             `json:"to"`
+	Pledges []PledgeFulfilment `json:"pledges"`
+	Total   PledgeFulfilment   `json:"total"`
+}
+
+// GetPledgeFulfilment reports how many installments due between from and to
+// (never later than today) were paid, per pledge and overall. Cancelled
+// installments don't count.
+func GetPledgeFulfilment(db *gorm.DB, from, to time.Time) (*PledgeFulfilmentReport, error) {
+	if today := pledgeDate(time.Now()); to.After(today) {
+		to = today
+	}
+
+	report := &PledgeFulfilmentReport{
+		From:    from.Format(dateLayout),
+		To:      to.Format(dateLayout),
+		Pledges: []PledgeFulfilment{},
+	}
+	err := db.Table("pledge_installments").
+		Select(`pledges.id as pledge_id, donors.name as donor_name, pledges.amount, pledges.frequency, pledges.status,
+			COUNT(*) as due,
+			COUNT(*) FILTER (WHERE pledge_installments.status = ?) as fulfilled,
+			COUNT(*) FILTER (WHERE pledge_installments.status = ?) as missed,
+			COUNT(*) FILTER (WHERE pledge_installments.status = ?) as open,
+			COALESCE(SUM(donations.amount), 0) as pledged_amount,
+			COALESCE(SUM(donations.amount) FILTER (WHERE donations.status = ?), 0) as received_amount`,
+			models.InstallmentFulfilled, models.InstallmentMissed, models.InstallmentExpected, models.DonationStatusConfirmed).
+		Joins("JOIN pledges ON pledges.id = pledge_installments.pledge_id").
+		Joins("JOIN donors ON donors.id = pledges.donor_id").
+		Joins("JOIN donations ON donations.id = pledge_installments.donation_id").
+		Where("pledge_installments.status <> ? AND pledge_installments.due_date BETWEEN ? AND ?",
+			models.InstallmentCancelled, from.Format(dateLayout), to.Format(dateLayout)).
+		Group("pledges.id, donors.name").
+		Order("donors.name ASC").
+		Scan(&report.Pledges).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range report.Pledges {
+		p := &report.Pledges[i]
+		p.Rate = fulfilmentRate(p.Fulfilled, p.Due)
+
+		report.Total.Due += p.Due
+		report.Total.Fulfilled += p.Fulfilled
+		report.Total.Missed += p.Missed
+		report.Total.Open += p.Open
+		report.Total.PledgedAmount += p.PledgedAmount
+		report.Total.ReceivedAmount += p.ReceivedAmount
+	}
+	report.Total.Rate = fulfilmentRate(report.Total.Fulfilled, report.Total.Due)
+	return report, nil
+}
+
+func fulfilmentRate(fulfilled, due int64) float64 {
+	if due == 0 {
+		return 0
+	}
+	return float64(fulfilled) / float64(due) * 100
+}
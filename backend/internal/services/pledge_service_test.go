@@ -0,0 +1,110 @@
+package services
+
+import (
+	"testing"
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+)
+
+func TestPledgeDueDates(t *testing.T) {
+	date := func(s string) time.Time {
+		d, err := time.Parse(dateLayout, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	ptr := func(s string) *time.Time {
+		d := date(s)
+		return &d
+	}
+
+	tests := []struct {
+		name      string
+		frequency models.PledgeFrequency
+		day       int
+		start     string
+		end       *time.Time
+		from, to  string
+		want      []string
+	}{
+		{
+			name: "31st in a short month", frequency: models.PledgeMonthly, day: 31,
+			start: "2026-01-01", from: "2026-01-01", to: "2026-04-30",
+			want: []string{"2026-01-31", "2026-02-28", "2026-03-31", "2026-04-30"},
+		},
+		{
+			name: "31st in a leap February", frequency: models.PledgeMonthly, day: 31,
+			start: "2028-02-01", from: "2028-02-01", to: "2028-03-31",
+			want: []string{"2028-02-29", "2028-03-31"},
+		},
+		{
+			// Falling on the 28th in February doesn't move later months
+			name: "30th after February", frequency: models.PledgeMonthly, day: 30,
+			start: "2027-02-01", from: "2027-02-01", to: "2027-04-30",
+			want: []string{"2027-02-28", "2027-03-30", "2027-04-30"},
+		},
+		{
+			name: "day already passed in the first month", frequency: models.PledgeMonthly, day: 5,
+			start: "2026-01-20", from: "2026-01-01", to: "2026-03-31",
+			want: []string{"2026-02-05", "2026-03-05"},
+		},
+		{
+			name: "quarterly", frequency: models.PledgeQuarterly, day: 31,
+			start: "2026-01-10", from: "2026-01-01", to: "2026-12-31",
+			want: []string{"2026-01-31", "2026-04-30", "2026-07-31", "2026-10-31"},
+		},
+		{
+			// Quarters count from the start, not from the window
+			name: "quarterly window", frequency: models.PledgeQuarterly, day: 15,
+			start: "2026-01-01", from: "2026-03-01", to: "2026-08-31",
+			want: []string{"2026-04-15", "2026-07-15"},
+		},
+		{
+			name: "yearly", frequency: models.PledgeYearly, day: 29,
+			start: "2028-02-01", from: "2028-01-01", to: "2030-12-31",
+			want: []string{"2028-02-29", "2029-02-28", "2030-02-28"},
+		},
+		{
+			name: "ends on a due date", frequency: models.PledgeMonthly, day: 25,
+			start: "2026-01-01", end: ptr("2026-03-25"), from: "2026-01-01", to: "2026-12-31",
+			want: []string{"2026-01-25", "2026-02-25", "2026-03-25"},
+		},
+		{
+			name: "ends before the due date", frequency: models.PledgeMonthly, day: 25,
+			start: "2026-01-01", end: ptr("2026-03-24"), from: "2026-01-01", to: "2026-12-31",
+			want: []string{"2026-01-25", "2026-02-25"},
+		},
+		{
+			name: "window after the end", frequency: models.PledgeMonthly, day: 25,
+			start: "2026-01-01", end: ptr("2026-03-31"), from: "2026-04-01", to: "2026-06-30",
+		},
+		{
+			name: "window before the start", frequency: models.PledgeMonthly, day: 1,
+			start: "2026-06-01", from: "2026-01-01", to: "2026-05-31",
+		},
+		{
+			name: "unknown frequency", frequency: "weekly", day: 1,
+			start: "2026-01-01", from: "2026-01-01", to: "2026-12-31",
+		},
+	}
+	for _, tt := range tests {
+		p := models.Pledge{Frequency: tt.frequency, DayOfMonth: tt.day, StartDate: date(tt.start), EndDate: tt.end}
+		got := PledgeDueDates(p, date(tt.from), date(tt.to))
+
+		var gotDates []string
+		for _, d := range got {
+			gotDates = append(gotDates, d.Format(dateLayout))
+		}
+		if len(gotDates) != len(tt.want) {
+			t.Errorf("%s: due dates = %v, want %v", tt.name, gotDates, tt.want)
+			continue
+		}
+		for i := range tt.want {
+			if gotDates[i] != tt.want[i] {
+				t.Errorf("%s: due dates = %v, want %v", tt.name, gotDates, tt.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"masjid-baiturrahim-backend/config"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrFileTooLarge    = errors.New("file is too large")
+	ErrFileTypeInvalid = errors.New("file type is not allowed")
+)
+
+// Documents accepted as transfer receipts and other private attachments,
+// keyed by sniffed content type.
+var DocumentContentTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+}
+
+// SavePrivateFile stores an upload under the private storage directory after
+// checking its size and sniffed content type, and returns its storage key
+// (e.g. "proofs/<uuid>.pdf"). Private files are only served through
+// authorised handlers.
+func SavePrivateFile(folder string, file *multipart.FileHeader, maxSize int64, allowed map[string]string) (string, error) {
+	if file.Size > maxSize {
+		return "", ErrFileTooLarge
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(src, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	contentType := strings.Split(http.DetectContentType(head[:n]), ";")[0]
+	ext, ok := allowed[contentType]
+	if !ok {
+		return "", ErrFileTypeInvalid
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(config.Load().PrivateStorageDir, folder)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	key := filepath.ToSlash(filepath.Join(folder, uuid.New().String()+ext))
+	dst, err := os.OpenFile(PrivateFilePath(key), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", err
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, io.LimitReader(src, maxSize)); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// PrivateFilePath resolves a storage key to its path on disk, refusing keys
+// that would escape the storage directory.
+func PrivateFilePath(key string) string {
+	clean := filepath.Clean("/" + key)
+	return filepath.Join(config.Load().PrivateStorageDir, clean)
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+	"masjid-baiturrahim-backend/internal/models"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// QRIS payloads follow the EMVCo merchant-presented QR specification: a flat
+// list of ID (2 digits), length (2 digits) and value fields, closed by a
+// CRC16 over everything that precedes its value.
+const (
+	qrisTagPointOfInitiation = "01"
+	qrisTagAmount            = "54"
+	qrisTagAdditionalData    = "62"
+	qrisTagCRC               = "63"
+
+	qrisSubTagReferenceLabel = "05"
+
+	qrisDynamic      = "12"
+	qrisMaxFieldLen  = 99 // the length is two digits
+	qrisMaxAmountLen = 13
+	qrisMaxRefLen    = 25
+
+	QRISImageSize = 512
+)
+
+var ErrInvalidQRIS = errors.New("invalid QRIS payload")
+
+type qrisField struct {
+	Tag   string
+	Value string
+}
+
+// parseQRISFields splits a payload into its fields. Lengths count characters,
+// not bytes, so merchant names in other scripts parse too.
+func parseQRISFields(payload string) ([]qrisField, error) {
+	runes := []rune(payload)
+	var fields []qrisField
+	for i := 0; i < len(runes); {
+		if i+4 > len(runes) {
+			return nil, fmt.Errorf("%w: truncated field at offset %d", ErrInvalidQRIS, i)
+		}
+		tag := string(runes[i : i+2])
+		length, err := strconv.Atoi(string(runes[i+2 : i+4]))
+		if err != nil {
+			return nil, fmt.Errorf("%w: bad length for tag %s", ErrInvalidQRIS, tag)
+		}
+		start := i + 4
+		if start+length > len(runes) {
+			return nil, fmt.Errorf("%w: value of tag %s overflows payload", ErrInvalidQRIS, tag)
+		}
+		fields = append(fields, qrisField{Tag: tag, Value: string(runes[start : start+length])})
+		i = start + length
+	}
+	return fields, nil
+}
+
+func encodeQRISFields(fields []qrisField) (string, error) {
+	var b strings.Builder
+	for _, f := range fields {
+		length := utf8.RuneCountInString(f.Value)
+		if length > qrisMaxFieldLen {
+			return "", fmt.Errorf("%w: tag %s would be %d characters, at most %d fit", ErrInvalidQRIS, f.Tag, length, qrisMaxFieldLen)
+		}
+		fmt.Fprintf(&b, "%s%02d%s", f.Tag, length, f.Value)
+	}
+	return b.String(), nil
+}
+
+// setQRISField replaces the value of tag, or inserts it in ascending tag order
+// when it isn't present yet.
+func setQRISField(fields []qrisField, tag, value string) []qrisField {
+	for i := range fields {
+		if fields[i].Tag == tag {
+			fields[i].Value = value
+			return fields
+		}
+	}
+	for i := range fields {
+		if fields[i].Tag > tag {
+			fields = append(fields[:i], append([]qrisField{{Tag: tag, Value: value}}, fields[i:]...)...)
+			return fields
+		}
+	}
+	return append(fields, qrisField{Tag: tag, Value: value})
+}
+
+// QRISChecksum computes the CRC-16/CCITT-FALSE checksum QRIS uses for tag 63,
+// over the UTF-8 bytes of data.
+func QRISChecksum(data string) string {
+	crc := uint16(0xFFFF)
+	for i := 0; i < len(data); i++ {
+		crc ^= uint16(data[i]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return fmt.Sprintf("%04X", crc)
+}
+
+// ValidateQRIS checks that payload is well-formed TLV ending in a matching CRC.
+func ValidateQRIS(payload string) error {
+	fields, err := parseQRISFields(payload)
+	if err != nil {
+		return err
+	}
+	if len(fields) == 0 || fields[len(fields)-1].Tag != qrisTagCRC {
+		return fmt.Errorf("%w: missing CRC", ErrInvalidQRIS)
+	}
+	crc := fields[len(fields)-1].Value
+	if expected := QRISChecksum(payload[:len(payload)-len(crc)]); !strings.EqualFold(crc, expected) {
+		return fmt.Errorf("%w: CRC mismatch (got %s, expected %s)", ErrInvalidQRIS, crc, expected)
+	}
+	return nil
+}
+
+// GenerateDynamicQRIS turns a merchant's static QRIS payload into a dynamic one
+// carrying the given amount and reference label, with a recomputed CRC.
+func GenerateDynamicQRIS(staticPayload string, amount models.Money, reference string) (string, error) {
+	staticPayload = strings.TrimSpace(staticPayload)
+	if err := ValidateQRIS(staticPayload); err != nil {
+		return "", err
+	}
+	if amount <= 0 {
+		return "", fmt.Errorf("%w: amount must be positive", ErrInvalidQRIS)
+	}
+	// Rupiah amounts are written without decimals unless they have a fraction
+	amountStr := amount.String()
+	if len(amountStr) > qrisMaxAmountLen {
+		return "", fmt.Errorf("%w: amount %s is too long", ErrInvalidQRIS, amountStr)
+	}
+	if len(reference) > qrisMaxRefLen {
+		return "", fmt.Errorf("%w: reference %q exceeds %d characters", ErrInvalidQRIS, reference, qrisMaxRefLen)
+	}
+
+	fields, _ := parseQRISFields(staticPayload)
+	fields = fields[:len(fields)-1] // drop CRC, recomputed below
+
+	fields = setQRISField(fields, qrisTagPointOfInitiation, qrisDynamic)
+	fields = setQRISField(fields, qrisTagAmount, amountStr)
+
+	if reference != "" {
+		var additional []qrisField
+		for _, f := range fields {
+			if f.Tag == qrisTagAdditionalData {
+				parsed, err := parseQRISFields(f.Value)
+				if err != nil {
+					return "", err
+				}
+				additional = parsed
+			}
+		}
+		additional = setQRISField(additional, qrisSubTagReferenceLabel, reference)
+		encoded, err := encodeQRISFields(additional)
+		if err != nil {
+			return "", err
+		}
+		fields = setQRISField(fields, qrisTagAdditionalData, encoded)
+	}
+
+	payload, err := encodeQRISFields(fields)
+	if err != nil {
+		return "", err
+	}
+	payload += qrisTagCRC + "04"
+	return payload + QRISChecksum(payload), nil
+}
+
+// RenderQRISPNG encodes a QRIS payload as a PNG QR code image.
+func RenderQRISPNG(payload string) ([]byte, error) {
+	return qrcode.Encode(payload, qrcode.Medium, QRISImageSize)
+}
+
+// SaveQRISImage renders payload and writes it under uploads/qris, returning the
+// public URL of the image.
+func SaveQRISImage(payload, name string) (string, error) {
+	png, err := RenderQRISPNG(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to render QRIS image: %w", err)
+	}
+
+	dir := filepath.Join("uploads", "qris")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create QRIS directory: %w", err)
+	}
+
+	filename := name + ".png"
+	if err := os.WriteFile(filepath.Join(dir, filename), png, 0644); err != nil {
+		return "", fmt.Errorf("failed to save QRIS image: %w", err)
+	}
+
+	return fmt.Sprintf("/uploads/qris/%s", filename), nil
+}
+
+// RemoveQRISImage deletes an image written by SaveQRISImage, e.g. when the
+// donation it was made for is not saved after all.
+func RemoveQRISImage(name string) {
+	path := filepath.Join("uploads", "qris", name+".png")
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.Printf("failed to remove QRIS image %s: %v", path, err)
+	}
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"masjid-baiturrahim-backend/internal/models"
+)
+
+// The merchant-presented example from the EMVCo QR specification, with the
+// spec's own CRC. It is already dynamic and has a template with Chinese
+// characters, whose lengths count characters rather than bytes.
+const emvcoSamplePayload = "00020101021229300012D156000000000510A93FO3230Q31280012D15600000001030812345678520441115802CN5914BEST TRANSPORT6007BEIJING64200002ZH0104最佳运输0202北京540523.7253031565502016233030412340603***0708A60086670902ME91320016A0112233449988770708123456786304A13A"
+
+// The EMVCo sample made static: tag 01 is 11 and tag 54 is removed. The CRCs
+// of this and the other derived payloads were computed with Python's
+// binascii.crc_hqx(data, 0xFFFF), not with the code under test.
+const staticQRISPayload = "00020101021129300012D156000000000510A93FO3230Q31280012D15600000001030812345678520441115802CN5914BEST TRANSPORT6007BEIJING64200002ZH0104最佳运输0202北京53031565502016233030412340603***0708A60086670902ME91320016A01122334499887707081234567863043E69"
+
+// The static sample with additional data (tag 62) of 89 characters, which
+// leaves no room for a reference label.
+const longAdditionalDataPayload = "00020101021129300012D156000000000510A93FO3230Q31280012D15600000001030812345678520441115802CN5914BEST TRANSPORT6007BEIJING64200002ZH0104最佳运输0202北京53031565502016289030412340877INFAQ PEMBANGUNAN DAN PEMELIHARAAN MASJID BAITURRAHIM SERTA KEGIATAN RAMADHAN91320016A01122334499887707081234567863043D39"
+
+func TestQRISChecksum(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"CRC-16/CCITT-FALSE check value", "123456789", "29B1"},
+		{"EMVCo sample", emvcoSamplePayload[:len(emvcoSamplePayload)-4], "A13A"},
+		{"static EMVCo sample", staticQRISPayload[:len(staticQRISPayload)-4], "3E69"},
+	}
+	for _, tt := range tests {
+		if got := QRISChecksum(tt.data); got != tt.want {
+			t.Errorf("%s: QRISChecksum = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateQRIS(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		valid   bool
+	}{
+		{"EMVCo sample", emvcoSamplePayload, true},
+		{"static EMVCo sample", staticQRISPayload, true},
+		{"long additional data", longAdditionalDataPayload, true},
+		{"lowercase CRC", emvcoSamplePayload[:len(emvcoSamplePayload)-4] + "a13a", true},
+		{"wrong CRC", staticQRISPayload[:len(staticQRISPayload)-4] + "3E6A", false},
+		{"edited merchant name", strings.Replace(emvcoSamplePayload, "BEST TRANSPORT", "BEST TRANSPORX", 1), false},
+		{"missing CRC", staticQRISPayload[:len(staticQRISPayload)-8], false},
+		{"truncated", staticQRISPayload[:20], false},
+		{"empty", "", false},
+	}
+	for _, tt := range tests {
+		err := ValidateQRIS(tt.payload)
+		if tt.valid && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidQRIS) {
+			t.Errorf("%s: got %v, want ErrInvalidQRIS", tt.name, err)
+		}
+	}
+}
+
+func TestParseQRISFields(t *testing.T) {
+	fields, err := parseQRISFields(emvcoSamplePayload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"01": "12",
+		"59": "BEST TRANSPORT",
+		"64": "0002ZH0104最佳运输0202北京",
+		"54": "23.72",
+		"63": "A13A",
+	}
+	for _, f := range fields {
+		if v, ok := want[f.Tag]; ok && v != f.Value {
+			t.Errorf("tag %s = %q, want %q", f.Tag, f.Value, v)
+		}
+	}
+	if got, err := encodeQRISFields(fields); err != nil || got != emvcoSamplePayload {
+		t.Errorf("re-encoding changed the payload (%v):\n got %s\nwant %s", err, got, emvcoSamplePayload)
+	}
+}
+
+func TestEncodeQRISFieldsLength(t *testing.T) {
+	if got, err := encodeQRISFields([]qrisField{{"08", strings.Repeat("x", 99)}}); err != nil || got[:4] != "0899" {
+		t.Errorf("99 characters: got %.8s…, %v", got, err)
+	}
+	if _, err := encodeQRISFields([]qrisField{{"08", strings.Repeat("x", 100)}}); !errors.Is(err, ErrInvalidQRIS) {
+		t.Errorf("100 characters: got %v, want ErrInvalidQRIS", err)
+	}
+	if _, err := encodeQRISFields([]qrisField{{"59", strings.Repeat("运", 100)}}); !errors.Is(err, ErrInvalidQRIS) {
+		t.Errorf("100 characters of 3 bytes: got %v, want ErrInvalidQRIS", err)
+	}
+}
+
+func TestGenerateDynamicQRIS(t *testing.T) {
+	tests := []struct {
+		name      string
+		static    string
+		amount    models.Money
+		reference string
+		want      string
+	}{
+		{
+			// Tag 01 becomes 12, tag 54 goes before the first higher tag
+			// and the reference label joins tag 62's own sub-fields in order
+			name:      "static sample with reference",
+			static:    staticQRISPayload,
+			amount:    models.NewMoney(100037),
+			reference: "DON-20261019-ABC12",
+			want:      "00020101021229300012D156000000000510A93FO3230Q31280012D156000000010308123456785204411154061000375802CN5914BEST TRANSPORT6007BEIJING64200002ZH0104最佳运输0202北京53031565502016255030412340518DON-20261019-ABC120603***0708A60086670902ME91320016A0112233449988770708123456786304C725",
+		},
+		{
+			// Tag 54 is rewritten in place and tag 62 is left alone
+			name:   "EMVCo sample with new amount",
+			static: emvcoSamplePayload,
+			amount: models.NewMoney(50000),
+			want:   "00020101021229300012D156000000000510A93FO3230Q31280012D15600000001030812345678520441115802CN5914BEST TRANSPORT6007BEIJING64200002ZH0104最佳运输0202北京54055000053031565502016233030412340603***0708A60086670902ME91320016A0112233449988770708123456786304A4F1",
+		},
+	}
+	for _, tt := range tests {
+		got, err := GenerateDynamicQRIS(tt.static, tt.amount, tt.reference)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s:\n got %s\nwant %s", tt.name, got, tt.want)
+		}
+		if err := ValidateQRIS(got); err != nil {
+			t.Errorf("%s: generated payload is invalid: %v", tt.name, err)
+		}
+	}
+}
+
+func TestGenerateDynamicQRISRejects(t *testing.T) {
+	tests := []struct {
+		name      string
+		static    string
+		amount    models.Money
+		reference string
+	}{
+		{"invalid static payload", staticQRISPayload[:len(staticQRISPayload)-1] + "5", models.NewMoney(10000), ""},
+		{"zero amount", staticQRISPayload, 0, ""},
+		{"amount too long", staticQRISPayload, models.NewMoney(10000000000000), ""},
+		{"reference too long", staticQRISPayload, models.NewMoney(10000), "DON-20261019-ABCDEFGHIJKLMNOP"},
+		{"no room for the reference", longAdditionalDataPayload, models.NewMoney(10000), "DON-20261019-ABC12"},
+	}
+	for _, tt := range tests {
+		if _, err := GenerateDynamicQRIS(tt.static, tt.amount, tt.reference); !errors.Is(err, ErrInvalidQRIS) {
+			t.Errorf("%s: got %v, want ErrInvalidQRIS", tt.name, err)
+		}
+	}
+}
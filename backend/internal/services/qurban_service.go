@@ -0,0 +1,354 @@
+package services
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/skip2/go-qrcode"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	qurbanLockKey = 7203 // pg advisory lock serialising share allocation
+
+	MaxCouponBatch = 2000
+)
+
+var (
+	ErrQurbanTypeInvalid  = errors.New("name, species and a positive price are required")
+	ErrQurbanShares       = errors.New("goats and sheep have 1 share; cows and camels up to 7")
+	ErrQurbanTypeInactive = errors.New("this qurban type is not open for registration")
+	ErrQurbanSoldOut      = errors.New("all animals of this type are taken")
+	ErrQurbanStatus       = errors.New("invalid animal status change")
+	ErrCouponRedeemed     = errors.New("coupon has already been redeemed")
+	ErrCouponCount        = fmt.Errorf("coupon count must be between 1 and %d", MaxCouponBatch)
+)
+
+// activeShareCondition limits shares to those still holding their slot.
+const activeShareCondition = `EXISTS (SELECT 1 FROM donations WHERE donations.id = qurban_shares.donation_id AND donations.status IN ('pending', 'confirmed'))`
+
+func ValidateQurbanType(t *models.QurbanAnimalType) error {
+	if t.Name == "" || t.Price <= 0 {
+		return ErrQurbanTypeInvalid
+	}
+	switch t.Species {
+	case models.QurbanGoat, models.QurbanSheep:
+		if t.SharesPerAnimal != 1 {
+			return ErrQurbanShares
+		}
+	case models.QurbanCow, models.QurbanCamel:
+		if t.SharesPerAnimal < 1 || t.SharesPerAnimal > models.MaxQurbanShares {
+			return ErrQurbanShares
+		}
+	default:
+		return ErrQurbanTypeInvalid
+	}
+	return ValidateDonationAmount(t.Price)
+}
+
+var qurbanTagPrefix = map[models.QurbanSpecies]string{
+	models.QurbanGoat:  "K",
+	models.QurbanSheep: "D",
+	models.QurbanCow:   "S",
+	models.QurbanCamel: "U",
+}
+
+// nextQurbanTag numbers animals per species and year: S-001, S-002, ...
+func nextQurbanTag(tx *gorm.DB, year int, species models.QurbanSpecies) (string, error) {
+	var count int64
+	if err := tx.Model(&models.QurbanAnimal{}).
+		Joins("JOIN qurban_animal_types ON qurban_animal_types.id = qurban_animals.type_id").
+		Where("qurban_animals.year = ? AND qurban_animal_types.species = ?", year, species).
+		Count(&count).Error; err != nil {
+		return "", err
+	}
+
+	for n := count + 1; ; n++ {
+		tag := fmt.Sprintf("%s-%03d", qurbanTagPrefix[species], n)
+		var exists int64
+		if err := tx.Model(&models.QurbanAnimal{}).Where("year = ? AND tag_number = ?", year, tag).Count(&exists).Error; err != nil {
+			return "", err
+		}
+		if exists == 0 {
+			return tag, nil
+		}
+	}
+}
+
+// allocateQurbanShare finds the first waiting animal of the type with a free
+// share, buying a new one (within the quota) when all are full.
+func allocateQurbanShare(tx *gorm.DB, animalType *models.QurbanAnimalType) (*models.QurbanAnimal, int, error) {
+	var animals []models.QurbanAnimal
+	if err := tx.Where("type_id = ? AND status = ?", animalType.ID, models.QurbanAnimalWaiting).
+		Order("tag_number ASC").
+		Find(&animals).Error; err != nil {
+		return nil, 0, err
+	}
+
+	for i := range animals {
+		var taken []int
+		if err := tx.Model(&models.QurbanShare{}).
+			Where("animal_id = ?", animals[i].ID).
+			Where(activeShareCondition).
+			Pluck("share_number", &taken).Error; err != nil {
+			return nil, 0, err
+		}
+		used := make(map[int]bool, len(taken))
+		for _, n := range taken {
+			used[n] = true
+		}
+		for n := 1; n <= animalType.SharesPerAnimal; n++ {
+			if !used[n] {
+				return &animals[i], n, nil
+			}
+		}
+	}
+
+	if animalType.Quota > 0 {
+		var count int64
+		if err := tx.Model(&models.QurbanAnimal{}).Where("type_id = ?", animalType.ID).Count(&count).Error; err != nil {
+			return nil, 0, err
+		}
+		if count >= int64(animalType.Quota) {
+			return nil, 0, ErrQurbanSoldOut
+		}
+	}
+
+	tag, err := nextQurbanTag(tx, animalType.Year, animalType.Species)
+	if err != nil {
+		return nil, 0, err
+	}
+	animal := models.QurbanAnimal{
+		TypeID:    animalType.ID,
+		Year:      animalType.Year,
+		TagNumber: tag,
+		Status:    models.QurbanAnimalWaiting,
+	}
+	if err := tx.Create(&animal).Error; err != nil {
+		return nil, 0, err
+	}
+	return &animal, 1, nil
+}
+
+// RegisterQurbanShare allocates a share of the given type to a shohibul and
+// creates the pending qurban donation that pays for it. donation carries the
+// payer's details and access token hash; amount and category are set here.
+func RegisterQurbanShare(db *gorm.DB, typeID uuid.UUID, share *models.QurbanShare, donation *models.Donation, useUniqueCode bool) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var animalType models.QurbanAnimalType
+		if err := tx.Clauses(clause.Locking{Strength: "SHARE"}).First(&animalType, "id = ?", typeID).Error; err != nil {
+			return err
+		}
+		if !animalType.IsActive {
+			return ErrQurbanTypeInactive
+		}
+
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", qurbanLockKey).Error; err != nil {
+			return err
+		}
+		animal, shareNumber, err := allocateQurbanShare(tx, &animalType)
+		if err != nil {
+			return err
+		}
+
+		donation.DonationCode = GenerateDonationCode()
+		donation.Amount = animalType.Price
+		donation.TransferAmount = animalType.Price
+		donation.Category = models.DonationCategoryQurban
+		donation.Status = models.DonationStatusPending
+		if donation.DonorName == "" {
+			donation.DonorName = share.ShohibulName
+		}
+		donation.Notes = fmt.Sprintf("Qurban %s %d, %s no. %d", animalType.Name, animalType.Year, animal.TagNumber, shareNumber)
+		if useUniqueCode {
+			code, err := AssignUniqueCode(tx, donation.Amount)
+			if err != nil {
+				return err
+			}
+			donation.UniqueCode = code
+			donation.TransferAmount = donation.Amount + models.NewMoney(int64(code))
+		}
+		if err := AttachDonor(tx, donation); err != nil {
+			return err
+		}
+		if err := tx.Create(donation).Error; err != nil {
+			return err
+		}
+
+		share.AnimalID = animal.ID
+		share.ShareNumber = shareNumber
+		share.DonationID = donation.ID
+		if err := tx.Omit("Animal", "Donation").Create(share).Error; err != nil {
+			return err
+		}
+		share.Animal = animal
+		share.Donation = donation
+		return nil
+	})
+}
+
+type QurbanTypeAvailability struct {
+	models.QurbanAnimalType
+	Animals         int64  `json:"animals"`
+	SharesTaken     int64  `json:"shares_taken"`
+	SharesPaid      int64  `json:"shares_paid"`
+	SharesAvailable *int64 `json:"shares_available"` // null when there is no quota
+}
+
+// GetQurbanAvailability lists the types of a year with their share counts.
+func GetQurbanAvailability(db *gorm.DB, year int, activeOnly bool) ([]QurbanTypeAvailability, error) {
+	query := db.Where("year = ?", year)
+	if activeOnly {
+		query = query.Where("is_active = ?", true)
+	}
+	var types []models.QurbanAnimalType
+	if err := query.Order("species ASC, price ASC").Find(&types).Error; err != nil {
+		return nil, err
+	}
+
+	var counts []struct {
+		TypeID      uuid.UUID
+		Animals     int64
+		SharesTaken int64
+		SharesPaid  int64
+	}
+	if err := db.Table("qurban_animals").
+		Select(`qurban_animals.type_id, COUNT(DISTINCT qurban_animals.id) as animals,
+			COUNT(donations.id) FILTER (WHERE donations.status IN ('pending', 'confirmed')) as shares_taken,
+			COUNT(donations.id) FILTER (WHERE donations.status = 'confirmed') as shares_paid`).
+		Joins("LEFT JOIN qurban_shares ON qurban_shares.animal_id = qurban_animals.id").
+		Joins("LEFT JOIN donations ON donations.id = qurban_shares.donation_id").
+		Where("qurban_animals.year = ?", year).
+		Group("qurban_animals.type_id").
+		Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+
+	result := make([]QurbanTypeAvailability, len(types))
+	for i, t := range types {
+		result[i] = QurbanTypeAvailability{QurbanAnimalType: t}
+		for _, row := range counts {
+			if row.TypeID == t.ID {
+				result[i].Animals = row.Animals
+				result[i].SharesTaken = row.SharesTaken
+				result[i].SharesPaid = row.SharesPaid
+			}
+		}
+		if t.Quota > 0 {
+			available := int64(t.Quota*t.SharesPerAnimal) - result[i].SharesTaken
+			if available < 0 {
+				available = 0
+			}
+			result[i].SharesAvailable = &available
+		}
+	}
+	return result, nil
+}
+
+// SetQurbanAnimalStatus moves an animal forward from waiting to slaughtered
+// to distributed.
+func SetQurbanAnimalStatus(db *gorm.DB, id uuid.UUID, status models.QurbanAnimalStatus) (*models.QurbanAnimal, error) {
+	var animal models.QurbanAnimal
+	if err := db.First(&animal, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{"status": status}
+	switch {
+	case animal.Status == models.QurbanAnimalWaiting && status == models.QurbanAnimalSlaughtered:
+		now := time.Now()
+		updates["slaughtered_at"] = now
+		animal.SlaughteredAt = &now
+	case animal.Status == models.QurbanAnimalSlaughtered && status == models.QurbanAnimalDistributed:
+	default:
+		return nil, fmt.Errorf("%w: %s to %s", ErrQurbanStatus, animal.Status, status)
+	}
+
+	if err := db.Model(&animal).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	animal.Status = status
+	return &animal, nil
+}
+
+// Coupon codes avoid characters that are easily confused when read aloud or
+// typed from paper (0/O, 1/I/L).
+const couponAlphabet = "23456789ABCDEFGHJKMNPQRSTUVWXYZ"
+
+func generateCouponCode(year int) (string, error) {
+	b := make([]byte, 6)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(couponAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		b[i] = couponAlphabet[n.Int64()]
+	}
+	return fmt.Sprintf("QRB%02d-%s", year%100, b), nil
+}
+
+// GenerateQurbanCoupons issues count coupons for a year, or one per name when
+// recipient names are given.
+func GenerateQurbanCoupons(db *gorm.DB, year, count int, recipients []string) ([]models.QurbanCoupon, error) {
+	if len(recipients) > 0 {
+		count = len(recipients)
+	}
+	if count < 1 || count > MaxCouponBatch {
+		return nil, ErrCouponCount
+	}
+
+	coupons := make([]models.QurbanCoupon, count)
+	seen := make(map[string]bool, count)
+	for i := 0; i < count; {
+		code, err := generateCouponCode(year)
+		if err != nil {
+			return nil, err
+		}
+		if seen[code] {
+			continue
+		}
+		seen[code] = true
+		coupons[i] = models.QurbanCoupon{Year: year, Code: code}
+		if len(recipients) > 0 {
+			coupons[i].RecipientName = recipients[i]
+		}
+		i++
+	}
+
+	if err := db.CreateInBatches(&coupons, 500).Error; err != nil {
+		return nil, err
+	}
+	return coupons, nil
+}
+
+// RedeemQurbanCoupon marks a coupon as exchanged for meat. A coupon can only
+// be redeemed once, even when two volunteers scan it at the same moment.
+func RedeemQurbanCoupon(db *gorm.DB, code string, actorID uuid.UUID) (*models.QurbanCoupon, error) {
+	now := time.Now()
+	result := db.Model(&models.QurbanCoupon{}).
+		Where("code = ? AND redeemed_at IS NULL", code).
+		Updates(map[string]interface{}{"redeemed_at": now, "redeemed_by": actorID})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	var coupon models.QurbanCoupon
+	if err := db.First(&coupon, "code = ?", code).Error; err != nil {
+		return nil, err
+	}
+	if result.RowsAffected == 0 {
+		return &coupon, ErrCouponRedeemed
+	}
+	return &coupon, nil
+}
+
+// RenderCouponQR encodes a coupon code as a PNG QR code for printing.
+func RenderCouponQR(code string) ([]byte, error) {
+	return qrcode.Encode(code, qrcode.Medium, 256)
+}
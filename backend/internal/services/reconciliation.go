@@ -0,0 +1,524 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// A transfer is matched against donations created up to three days before
+// the statement date (donors often pay a day or two later) and during that day.
+const (
+	MatchWindowBefore = 3 * 24 * time.Hour
+	MatchWindowAfter  = 24 * time.Hour
+)
+
+var (
+	ErrUnsupportedBank       = errors.New("unsupported bank statement format")
+	ErrStatementHeader       = errors.New("could not find the statement header row")
+	ErrMutationReviewed      = errors.New("mutation has already been reviewed")
+	ErrMutationNotCredit     = errors.New("only credit mutations can confirm a donation")
+	ErrDonationNotCandidate  = errors.New("donation is not a candidate for this mutation")
+	ErrMutationNeedsDonation = errors.New("a donation must be chosen for this mutation")
+	ErrMutationAmountDiffers = errors.New("mutation amount differs from the donation's transfer amount")
+)
+
+// statementFormat describes a bank's CSV export by the header names of its
+// columns (lowercase). Banks either use one amount column followed by CR or
+// DB (BCA) or separate debit and credit columns.
+type statementFormat struct {
+	date        []string
+	description []string
+	amount      []string
+	debit       []string
+	credit      []string
+	balance     []string
+	dateLayouts []string
+}
+
+var statementFormats = map[models.BankCode]statementFormat{
+	models.BankBCA: {
+		date:        []string{"tanggal transaksi", "tanggal"},
+		description: []string{"keterangan"},
+		amount:      []string{"jumlah"},
+		balance:     []string{"saldo"},
+		dateLayouts: []string{"02/01/2006", "02/01/06", "02/01"},
+	},
+	models.BankBSI: {
+		date:        []string{"tanggal transaksi", "tanggal", "tgl transaksi"},
+		description: []string{"keterangan", "deskripsi", "uraian"},
+		debit:       []string{"debet", "debit", "mutasi debet"},
+		credit:      []string{"kredit", "credit", "mutasi kredit"},
+		balance:     []string{"saldo"},
+		dateLayouts: []string{"02/01/2006", "02-01-2006", "2006-01-02", "02/01/2006 15:04:05", "02-01-2006 15:04:05"},
+	},
+	models.BankMandiri: {
+		date:        []string{"date", "posting date", "tanggal"},
+		description: []string{"description", "remarks", "keterangan"},
+		debit:       []string{"debit"},
+		credit:      []string{"credit", "kredit"},
+		balance:     []string{"balance", "saldo"},
+		dateLayouts: []string{"02/01/06", "02/01/2006", "02 Jan 2006", "2006-01-02", "02/01/2006 15:04:05"},
+	},
+}
+
+func IsSupportedBank(bank models.BankCode) bool {
+	_, ok := statementFormats[bank]
+	return ok
+}
+
+type statementColumns struct {
+	date, amount, debit, credit, balance int
+	description                          []int
+}
+
+func findColumns(header []string, f statementFormat) (statementColumns, bool) {
+	cols := statementColumns{date: -1, amount: -1, debit: -1, credit: -1, balance: -1}
+	matches := func(cell string, aliases []string) bool {
+		for _, alias := range aliases {
+			if cell == alias {
+				return true
+			}
+		}
+		return false
+	}
+
+	for i, cell := range header {
+		cell = strings.ToLower(strings.TrimSpace(cell))
+		switch {
+		case cols.date < 0 && matches(cell, f.date):
+			cols.date = i
+		case matches(cell, f.description):
+			cols.description = append(cols.description, i)
+		case cols.amount < 0 && matches(cell, f.amount):
+			cols.amount = i
+		case cols.debit < 0 && matches(cell, f.debit):
+			cols.debit = i
+		case cols.credit < 0 && matches(cell, f.credit):
+			cols.credit = i
+		case cols.balance < 0 && matches(cell, f.balance):
+			cols.balance = i
+		}
+	}
+
+	ok := cols.date >= 0 && (cols.amount >= 0 || cols.credit >= 0)
+	return cols, ok
+}
+
+// ParseStatementNumber parses amounts written either as 1,000,000.00 or
+// 1.000.000,00. A lone separator followed by exactly three digits is read as
+// a thousands separator.
+func ParseStatementNumber(raw string) (models.Money, error) {
+	s := strings.TrimSpace(raw)
+	s = strings.TrimPrefix(s, "Rp")
+	s = strings.TrimPrefix(s, "IDR")
+	s = strings.ReplaceAll(s, " ", "")
+	if s == "" || s == "-" {
+		return 0, nil
+	}
+
+	negative := false
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		negative = true
+		s = s[1 : len(s)-1]
+	}
+	if strings.HasPrefix(s, "-") {
+		negative = true
+		s = s[1:]
+	}
+
+	lastDot := strings.LastIndex(s, ".")
+	lastComma := strings.LastIndex(s, ",")
+	var decimalSep byte
+	if i := max(lastDot, lastComma); i >= 0 {
+		if (lastDot >= 0 && lastComma >= 0) || len(s)-i-1 != 3 {
+			decimalSep = s[i]
+		}
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch ch := s[i]; {
+		case ch == decimalSep:
+			b.WriteByte('.')
+		case ch == '.' || ch == ',':
+		default:
+			b.WriteByte(ch)
+		}
+	}
+
+	value, err := models.ParseMoney(b.String())
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", raw)
+	}
+	if negative {
+		value = -value
+	}
+	return value, nil
+}
+
+func parseStatementDate(raw string, layouts []string, now time.Time) (time.Time, bool) {
+	raw = strings.TrimPrefix(strings.TrimSpace(raw), "'")
+	for _, layout := range layouts {
+		t, err := time.Parse(layout, raw)
+		if err != nil {
+			continue
+		}
+		// Layouts without a year (BCA's dd/mm) belong to the latest past occurrence
+		if t.Year() == 0 {
+			t = time.Date(now.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+			if t.After(now) {
+				t = t.AddDate(-1, 0, 0)
+			}
+		}
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), true
+	}
+	return time.Time{}, false
+}
+
+func sniffDelimiter(data string) rune {
+	firstLines := data
+	if len(firstLines) > 2048 {
+		firstLines = firstLines[:2048]
+	}
+	if strings.Count(firstLines, ";") > strings.Count(firstLines, ",") {
+		return ';'
+	}
+	return ','
+}
+
+// ParseBankStatement reads a CSV statement export of the given bank. Account
+// information above the header and summary lines below the transactions are
+// skipped.
+func ParseBankStatement(bank models.BankCode, r io.Reader) ([]models.BankMutation, error) {
+	format, ok := statementFormats[bank]
+	if !ok {
+		return nil, ErrUnsupportedBank
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	content := strings.TrimPrefix(string(data), "\ufeff")
+
+	reader := csv.NewReader(strings.NewReader(content))
+	reader.Comma = sniffDelimiter(content)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV: %w", err)
+	}
+
+	headerRow := -1
+	var cols statementColumns
+	for i, record := range records {
+		if cols, ok = findColumns(record, format); ok {
+			headerRow = i
+			break
+		}
+	}
+	if headerRow < 0 {
+		return nil, ErrStatementHeader
+	}
+
+	cell := func(record []string, i int) string {
+		if i < 0 || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	now := time.Now()
+	occurrences := make(map[string]int)
+	var mutations []models.BankMutation
+	for _, record := range records[headerRow+1:] {
+		date, ok := parseStatementDate(cell(record, cols.date), format.dateLayouts, now)
+		if !ok {
+			continue
+		}
+
+		var descriptions []string
+		for _, i := range cols.description {
+			if d := cell(record, i); d != "" {
+				descriptions = append(descriptions, d)
+			}
+		}
+
+		m := models.BankMutation{
+			Source:          models.MutationSourceStatement,
+			Bank:            bank,
+			TransactionDate: date,
+			Description:     strings.Join(descriptions, " "),
+		}
+
+		if cols.amount >= 0 {
+			// The CR/DB marker follows the amount, in the same cell or, in
+			// KlikBCA's export, in the unnamed column next to it
+			raw := strings.ToUpper(cell(record, cols.amount))
+			if marker := strings.ToUpper(cell(record, cols.amount+1)); marker == "CR" || marker == "DB" {
+				raw += marker
+			}
+			m.Type = models.MutationCredit
+			if strings.HasSuffix(raw, "DB") {
+				m.Type = models.MutationDebit
+			}
+			raw = strings.TrimSuffix(strings.TrimSuffix(raw, "CR"), "DB")
+			if m.Amount, err = ParseStatementNumber(raw); err != nil {
+				return nil, err
+			}
+		} else {
+			credit, err := ParseStatementNumber(cell(record, cols.credit))
+			if err != nil {
+				return nil, err
+			}
+			debit, err := ParseStatementNumber(cell(record, cols.debit))
+			if err != nil {
+				return nil, err
+			}
+			m.Type, m.Amount = models.MutationCredit, credit
+			if credit == 0 {
+				m.Type, m.Amount = models.MutationDebit, debit
+			}
+		}
+		if m.Amount < 0 {
+			m.Amount = -m.Amount
+		}
+		if m.Amount == 0 {
+			continue
+		}
+
+		if raw := cell(record, cols.balance); raw != "" {
+			if balance, err := ParseStatementNumber(strings.TrimSuffix(strings.ToUpper(raw), "CR")); err == nil {
+				m.Balance = &balance
+			}
+		}
+
+		// Identical rows within one file are distinct transfers; re-importing the
+		// same file yields the same fingerprints and is skipped.
+		key := strings.Join([]string{
+			string(bank), date.Format("2006-01-02"), m.Description, string(m.Type),
+			m.Amount.String(), cell(record, cols.balance),
+		}, "|")
+		occurrences[key]++
+		sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%d", key, occurrences[key])))
+		m.Fingerprint = hex.EncodeToString(sum[:])
+
+		mutations = append(mutations, m)
+	}
+
+	return mutations, nil
+}
+
+// FindMatchingDonations returns pending donations awaiting exactly amount that
+// were created inside the matching window around at and aren't already
+// claimed by another mutation.
+func FindMatchingDonations(db *gorm.DB, amount models.Money, at time.Time) ([]models.Donation, error) {
+	var donations []models.Donation
+	err := db.Where("status = ? AND transfer_amount = ?", models.DonationStatusPending, amount).
+		Where("created_at >= ? AND created_at < ?", at.Add(-MatchWindowBefore), at.Add(MatchWindowAfter)).
+		Where("id NOT IN (?)", db.Model(&models.BankMutation{}).
+			Select("donation_id").
+			Where("donation_id IS NOT NULL AND match_status IN ?", []models.MutationMatchStatus{models.MutationMatched, models.MutationConfirmed})).
+		Order("created_at ASC").
+		Find(&donations).Error
+	return donations, err
+}
+
+// MatchMutation proposes a donation for a credit mutation: a single candidate
+// is marked matched, several go to the review queue as ambiguous. Pushed
+// mutations carry the time of the transfer, statements only its date.
+func MatchMutation(db *gorm.DB, m *models.BankMutation) error {
+	m.MatchStatus = models.MutationUnmatched
+	m.DonationID = nil
+	m.CandidateIDs = nil
+	if m.Type != models.MutationCredit {
+		return nil
+	}
+
+	at := m.TransactionDate
+	if m.TransactionTime != nil {
+		at = *m.TransactionTime
+	}
+	candidates, err := FindMatchingDonations(db, m.Amount, at)
+	if err != nil {
+		return err
+	}
+
+	switch len(candidates) {
+	case 0:
+	case 1:
+		m.MatchStatus = models.MutationMatched
+		m.DonationID = &candidates[0].ID
+	default:
+		m.MatchStatus = models.MutationAmbiguous
+		for _, d := range candidates {
+			m.CandidateIDs = append(m.CandidateIDs, d.ID)
+		}
+	}
+	return nil
+}
+
+// ImportBankStatement parses and stores a statement, skipping rows that were
+// imported before, and proposes matches for new credits.
+func ImportBankStatement(db *gorm.DB, bank models.BankCode, fileName string, r io.Reader, importedBy uuid.UUID) (*models.BankStatementImport, error) {
+	mutations, err := ParseBankStatement(bank, r)
+	if err != nil {
+		return nil, err
+	}
+
+	imp := &models.BankStatementImport{
+		Bank:       bank,
+		FileName:   fileName,
+		RowCount:   len(mutations),
+		ImportedBy: importedBy,
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(imp).Error; err != nil {
+			return err
+		}
+
+		for i := range mutations {
+			m := &mutations[i]
+			m.ImportID = &imp.ID
+			if err := MatchMutation(tx, m); err != nil {
+				return err
+			}
+
+			result := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "fingerprint"}}, DoNothing: true}).Create(m)
+			if result.Error != nil {
+				return result.Error
+			}
+			switch {
+			case result.RowsAffected == 0:
+				imp.DuplicateCount++
+			case m.MatchStatus == models.MutationMatched:
+				imp.MatchedCount++
+			case m.MatchStatus == models.MutationAmbiguous:
+				imp.AmbiguousCount++
+			}
+		}
+
+		return tx.Save(imp).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return imp, nil
+}
+
+// ConfirmMutation confirms the donation paid by a mutation. donationID picks
+// one of the candidates of an ambiguous mutation, or any pending donation for
+// an unmatched one; for a matched mutation it may be nil to accept the
+// proposal. A credit that isn't exactly the donation's transfer amount is only
+// accepted with overrideReason, which is kept on the mutation. Donations
+// above the approval threshold aren't confirmed yet: the returned approval
+// confirms the donation and the mutation once a treasurer approves it.
+func ConfirmMutation(db *gorm.DB, mutationID uuid.UUID, donationID *uuid.UUID, overrideReason string, actorID uuid.UUID) (*models.BankMutation, *models.Approval, error) {
+	overrideReason = strings.TrimSpace(overrideReason)
+	var mutation models.BankMutation
+	var approval *models.Approval
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&mutation, "id = ?", mutationID).Error; err != nil {
+			return err
+		}
+		if mutation.MatchStatus == models.MutationConfirmed || mutation.MatchStatus == models.MutationIgnored {
+			return ErrMutationReviewed
+		}
+		if mutation.Type != models.MutationCredit {
+			return ErrMutationNotCredit
+		}
+
+		target := mutation.DonationID
+		if donationID != nil {
+			allowed := mutation.DonationID != nil && *mutation.DonationID == *donationID
+			for _, id := range mutation.CandidateIDs {
+				allowed = allowed || id == *donationID
+			}
+			// Unmatched credits may be assigned to any pending donation by hand
+			if !allowed && mutation.MatchStatus != models.MutationUnmatched {
+				return ErrDonationNotCandidate
+			}
+			target = donationID
+		}
+		if target == nil {
+			return ErrMutationNeedsDonation
+		}
+
+		var donation models.Donation
+		if err := tx.First(&donation, "id = ?", *target).Error; err != nil {
+			return err
+		}
+		reason := fmt.Sprintf("Mutasi %s %s: %s", strings.ToUpper(string(mutation.Bank)), mutation.TransactionDate.Format("02/01/2006"), mutation.Description)
+		if mutation.Amount != donation.TransferAmount {
+			if overrideReason == "" {
+				return fmt.Errorf("%w (%s received, %s expected); give a reason to accept it",
+					ErrMutationAmountDiffers, mutation.Amount.Format(), donation.TransferAmount.Format())
+			}
+			mutation.ReviewNote = overrideReason
+			reason += fmt.Sprintf(" (diterima %s, seharusnya %s: %s)", mutation.Amount.Format(), donation.TransferAmount.Format(), overrideReason)
+		}
+
+		if NeedsApproval(tx, donation.Amount) {
+			mutation.MatchStatus = models.MutationMatched
+			mutation.DonationID = target
+			mutation.CandidateIDs = nil
+			if err := tx.Save(&mutation).Error; err != nil {
+				return err
+			}
+			var err error
+			approval, err = requestDonationConfirmation(tx, *target, actorID, reason, &mutation.ID)
+			return err
+		}
+
+		if _, err := TransitionDonation(tx, *target, models.DonationStatusConfirmed, actorID, reason); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		mutation.MatchStatus = models.MutationConfirmed
+		mutation.DonationID = target
+		mutation.ReviewedBy = &actorID
+		mutation.ReviewedAt = &now
+		return tx.Save(&mutation).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &mutation, approval, nil
+}
+
+// IgnoreMutation takes a mutation out of the review queue, e.g. for transfers
+// that aren't donations.
+func IgnoreMutation(db *gorm.DB, mutationID uuid.UUID, actorID uuid.UUID) (*models.BankMutation, error) {
+	var mutation models.BankMutation
+	if err := db.First(&mutation, "id = ?", mutationID).Error; err != nil {
+		return nil, err
+	}
+	if mutation.MatchStatus == models.MutationConfirmed {
+		return nil, ErrMutationReviewed
+	}
+
+	now := time.Now()
+	mutation.MatchStatus = models.MutationIgnored
+	mutation.ReviewedBy = &actorID
+	mutation.ReviewedAt = &now
+	if err := db.Save(&mutation).Error; err != nil {
+		return nil, err
+	}
+	return &mutation, nil
+}
@@ -0,0 +1,276 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+	"masjid-baiturrahim-backend/internal/testutil"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func TestParseStatementNumber(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    models.Money
+		wantErr bool
+	}{
+		{"1.000.000,00", models.NewMoney(1000000), false},
+		{"1,000,000.00", models.NewMoney(1000000), false},
+		{"150037.00", models.NewMoney(150037), false},
+		{"150.037", models.NewMoney(150037), false}, // a lone separator before three digits groups thousands
+		{"150,037", models.NewMoney(150037), false},
+		{"2.500,50", models.NewMoney(2500) + 50, false},
+		{"2,500.5", models.NewMoney(2500) + 50, false},
+		{"Rp 1.500.000", models.NewMoney(1500000), false},
+		{"IDR1,500,000.00", models.NewMoney(1500000), false},
+		{"-75,000.00", -models.NewMoney(75000), false},
+		{"(2.500,00)", -models.NewMoney(2500), false},
+		{"", 0, false},
+		{"-", 0, false},
+		{"12a", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseStatementNumber(tt.raw)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseStatementNumber(%q) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseStatementNumber(%q) = %s, want %s", tt.raw, got.Format(), tt.want.Format())
+		}
+	}
+}
+
+// Statements in the layout each bank's internet banking exports, with the
+// account details above the transactions and the totals below them.
+const (
+	bcaStatement = `No. rekening : 0123456789
+Nama : MASJID BAITURRAHIM
+Periode : 01/10/2026 - 19/10/2026
+Kode Mata Uang : Rp
+
+Tanggal Transaksi,Keterangan,Cabang,Jumlah,,Saldo
+'01/10,TRSF E-BANKING CR 0110/FTSCY/WS95051 150037.00 INFAQ HAMBA ALLAH,'0000,"150,037.00",CR,"25,150,037.00"
+'02/10,BIAYA ADM,'0000,"10,000.00",DB,"25,140,037.00"
+
+Saldo Awal,"25,000,000.00"
+Mutasi Kredit,"150,037.00",1
+Mutasi Debet,"10,000.00",1
+Saldo Akhir,"25,140,037.00"
+`
+	bcaStatementInline = `Tanggal Transaksi,Keterangan,Cabang,Jumlah,Saldo
+'01/10,SETORAN TUNAI,'0000,"500,000.00 CR","25,500,000.00"
+'02/10,TARIKAN ATM,'0000,"200,000.00 DB","25,300,000.00"
+`
+	bsiStatement = `Nomor Rekening;7123456789
+Nama;MASJID BAITURRAHIM
+Periode;01-10-2026 s.d. 19-10-2026
+
+Tanggal Transaksi;Keterangan;Debet;Kredit;Saldo
+01-10-2026 09:15:32;TRANSFER DARI FULAN BIN FULAN ZAKAT MAAL;0,00;2.500.037,00;27.500.037,00
+02-10-2026 14:02:11;BIAYA ADMINISTRASI;12.500,00;0,00;27.487.537,00
+`
+	mandiriStatement = `Account No,Date,Val. Date,Transaction Code,Description,Description,Reference No.,Debit,Credit
+1230001234567,01/10/26,01/10/26,1234,TRANSFER DARI,HAMBA ALLAH WAKAF,99102601,.00,"1,000,037.00"
+1230001234567,02/10/26,02/10/26,8888,BIAYA ADM,,99102602,"7,500.00",.00
+`
+)
+
+func TestParseBankStatement(t *testing.T) {
+	type row struct {
+		month, day  int
+		kind        models.MutationType
+		amount      models.Money
+		description string
+	}
+	tests := []struct {
+		name       string
+		bank       models.BankCode
+		data       string
+		hasBalance bool
+		want       []row
+	}{
+		{"BCA", models.BankBCA, bcaStatement, true, []row{
+			{10, 1, models.MutationCredit, models.NewMoney(150037), "TRSF E-BANKING CR 0110/FTSCY/WS95051 150037.00 INFAQ HAMBA ALLAH"},
+			{10, 2, models.MutationDebit, models.NewMoney(10000), "BIAYA ADM"},
+		}},
+		{"BCA with the marker in the amount", models.BankBCA, bcaStatementInline, true, []row{
+			{10, 1, models.MutationCredit, models.NewMoney(500000), "SETORAN TUNAI"},
+			{10, 2, models.MutationDebit, models.NewMoney(200000), "TARIKAN ATM"},
+		}},
+		{"BSI", models.BankBSI, bsiStatement, true, []row{
+			{10, 1, models.MutationCredit, models.NewMoney(2500037), "TRANSFER DARI FULAN BIN FULAN ZAKAT MAAL"},
+			{10, 2, models.MutationDebit, models.NewMoney(12500), "BIAYA ADMINISTRASI"},
+		}},
+		{"Mandiri", models.BankMandiri, mandiriStatement, false, []row{
+			{10, 1, models.MutationCredit, models.NewMoney(1000037), "TRANSFER DARI HAMBA ALLAH WAKAF"},
+			{10, 2, models.MutationDebit, models.NewMoney(7500), "BIAYA ADM"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mutations, err := ParseBankStatement(tt.bank, strings.NewReader(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(mutations) != len(tt.want) {
+				t.Fatalf("%d mutations, want %d", len(mutations), len(tt.want))
+			}
+			for i, want := range tt.want {
+				m := mutations[i]
+				if int(m.TransactionDate.Month()) != want.month || m.TransactionDate.Day() != want.day {
+					t.Errorf("row %d: date = %s, want %02d/%02d", i, m.TransactionDate.Format("2006-01-02"), want.day, want.month)
+				}
+				if m.Type != want.kind || m.Amount != want.amount {
+					t.Errorf("row %d: %s %s, want %s %s", i, m.Type, m.Amount.Format(), want.kind, want.amount.Format())
+				}
+				if m.Description != want.description {
+					t.Errorf("row %d: description = %q, want %q", i, m.Description, want.description)
+				}
+				if m.Bank != tt.bank || (m.Balance != nil) != tt.hasBalance || m.Fingerprint == "" {
+					t.Errorf("row %d: bank %s, balance %v, fingerprint %q", i, m.Bank, m.Balance, m.Fingerprint)
+				}
+			}
+		})
+	}
+
+	if _, err := ParseBankStatement(models.BankCode("bri"), strings.NewReader(bsiStatement)); err != ErrUnsupportedBank {
+		t.Errorf("unsupported bank: err = %v, want %v", err, ErrUnsupportedBank)
+	}
+	if _, err := ParseBankStatement(models.BankBSI, strings.NewReader(mandiriStatement)); err != ErrStatementHeader {
+		t.Errorf("another bank's statement: err = %v, want %v", err, ErrStatementHeader)
+	}
+}
+
+func TestStatementFingerprints(t *testing.T) {
+	// Two equal transfers on the same day are two mutations, with different
+	// fingerprints
+	data := bsiStatement + "03-10-2026 08:00:00;TRANSFER DARI HAMBA ALLAH;0,00;100.000,00;27.587.537,00\n" +
+		"03-10-2026 08:00:00;TRANSFER DARI HAMBA ALLAH;0,00;100.000,00;27.587.537,00\n"
+
+	first, err := ParseBankStatement(models.BankBSI, strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := ParseBankStatement(models.BankBSI, strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]bool)
+	for i := range first {
+		if first[i].Fingerprint != second[i].Fingerprint {
+			t.Errorf("row %d: fingerprint changed between two parses", i)
+		}
+		if seen[first[i].Fingerprint] {
+			t.Errorf("row %d: fingerprint repeated within the statement", i)
+		}
+		seen[first[i].Fingerprint] = true
+	}
+
+	db := testutil.NewDB(t)
+	importer := testutil.NewUser(t, db, models.RoleTreasurer, "")
+	imp, err := ImportBankStatement(db, models.BankBSI, "oktober.csv", strings.NewReader(data), importer.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imp.RowCount != 4 || imp.DuplicateCount != 0 {
+		t.Errorf("first import: %d rows, %d duplicates, want 4 and 0", imp.RowCount, imp.DuplicateCount)
+	}
+	again, err := ImportBankStatement(db, models.BankBSI, "oktober (1).csv", strings.NewReader(data), importer.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.DuplicateCount != 4 {
+		t.Errorf("second import: %d duplicates, want 4", again.DuplicateCount)
+	}
+	var stored int64
+	db.Model(&models.BankMutation{}).Count(&stored)
+	if stored != 4 {
+		t.Errorf("%d mutations stored, want 4", stored)
+	}
+}
+
+func pendingDonation(t *testing.T, db *gorm.DB, code string, transfer models.Money) models.Donation {
+	t.Helper()
+	donation := models.Donation{
+		DonationCode:   code,
+		DonorName:      "Hamba Allah",
+		Amount:         transfer,
+		TransferAmount: transfer,
+		Category:       models.DonationCategoryInfaq,
+		Status:         models.DonationStatusPending,
+	}
+	if err := db.Create(&donation).Error; err != nil {
+		t.Fatal(err)
+	}
+	return donation
+}
+
+func TestMatchMutation(t *testing.T) {
+	db := testutil.NewDB(t)
+	single := pendingDonation(t, db, "DON-MATCH-1", models.NewMoney(150037))
+	first := pendingDonation(t, db, "DON-MATCH-2", models.NewMoney(250000))
+	second := pendingDonation(t, db, "DON-MATCH-3", models.NewMoney(250000))
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	tests := []struct {
+		name       string
+		mutation   models.BankMutation
+		want       models.MutationMatchStatus
+		donation   *uuid.UUID
+		candidates []uuid.UUID
+	}{
+		{"one pending donation", models.BankMutation{Type: models.MutationCredit, Amount: models.NewMoney(150037), TransactionDate: today},
+			models.MutationMatched, &single.ID, nil},
+		{"two equal pending donations", models.BankMutation{Type: models.MutationCredit, Amount: models.NewMoney(250000), TransactionDate: today},
+			models.MutationAmbiguous, nil, []uuid.UUID{first.ID, second.ID}},
+		{"no donation for the amount", models.BankMutation{Type: models.MutationCredit, Amount: models.NewMoney(99000), TransactionDate: today},
+			models.MutationUnmatched, nil, nil},
+		{"outside the window", models.BankMutation{Type: models.MutationCredit, Amount: models.NewMoney(150037), TransactionDate: today.AddDate(0, 0, 5)},
+			models.MutationUnmatched, nil, nil},
+		{"debit", models.BankMutation{Type: models.MutationDebit, Amount: models.NewMoney(150037), TransactionDate: today},
+			models.MutationUnmatched, nil, nil},
+	}
+	for _, tt := range tests {
+		m := tt.mutation
+		if err := MatchMutation(db, &m); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if m.MatchStatus != tt.want {
+			t.Errorf("%s: status = %s, want %s", tt.name, m.MatchStatus, tt.want)
+		}
+		if (m.DonationID == nil) != (tt.donation == nil) || (m.DonationID != nil && *m.DonationID != *tt.donation) {
+			t.Errorf("%s: donation = %v, want %v", tt.name, m.DonationID, tt.donation)
+		}
+		if len(m.CandidateIDs) != len(tt.candidates) {
+			t.Errorf("%s: candidates = %v, want %v", tt.name, m.CandidateIDs, tt.candidates)
+			continue
+		}
+		for i, id := range tt.candidates {
+			if m.CandidateIDs[i] != id {
+				t.Errorf("%s: candidates = %v, want %v", tt.name, m.CandidateIDs, tt.candidates)
+				break
+			}
+		}
+	}
+
+	// A donation already claimed by a matched mutation isn't offered again
+	claimed := models.BankMutation{
+		Source: models.MutationSourceStatement, Bank: models.BankBSI, Type: models.MutationCredit,
+		Amount: models.NewMoney(150037), TransactionDate: today, Fingerprint: "claimed",
+		MatchStatus: models.MutationMatched, DonationID: &single.ID,
+	}
+	if err := db.Create(&claimed).Error; err != nil {
+		t.Fatal(err)
+	}
+	m := models.BankMutation{Type: models.MutationCredit, Amount: models.NewMoney(150037), TransactionDate: today}
+	if err := MatchMutation(db, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.MatchStatus != models.MutationUnmatched {
+		t.Errorf("claimed donation: status = %s, want unmatched", m.MatchStatus)
+	}
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+	"masjid-baiturrahim-backend/internal/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const RefreshTokenTTL = 7 * 24 * time.Hour
+
+// Reasons recorded on revoked sessions
+const (
+	RevokeLogout         = "logout"
+	RevokeLogoutAll      = "logout_all"
+	RevokeRefreshReuse   = "refresh_token_reuse"
+	RevokeUserInactive   = "user_inactive"
+	RevokePasswordChange = "password_changed"
+	RevokePasswordReset  = "password_reset"
+)
+
+var (
+	ErrRefreshTokenInvalid = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used; the session has been revoked")
+	ErrSessionRevoked      = errors.New("session has been revoked")
+)
+
+func issueRefreshToken(tx *gorm.DB, sessionID uuid.UUID) (string, error) {
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+	err = tx.Create(&models.RefreshToken{
+		SessionID: sessionID,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(RefreshTokenTTL),
+	}).Error
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// StartSession records a login and returns its first refresh token.
+func StartSession(db *gorm.DB, userID uuid.UUID, userAgent, ip string) (*models.AuthSession, string, error) {
+	if len(userAgent) > 500 {
+		userAgent = userAgent[:500]
+	}
+	session := &models.AuthSession{
+		UserID:     userID,
+		UserAgent:  userAgent,
+		IPAddress:  ip,
+		LastUsedAt: time.Now(),
+	}
+
+	var token string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(session).Error; err != nil {
+			return err
+		}
+		var err error
+		token, err = issueRefreshToken(tx, session.ID)
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return session, token, nil
+}
+
+// RotateRefreshToken exchanges a refresh token for the next one of its
+// session. Each token works once: presenting a used token means it was
+// copied, so the whole session is revoked and whoever holds the current
+// token has to log in again.
+func RotateRefreshToken(db *gorm.DB, token string) (*models.AuthSession, string, error) {
+	var session models.AuthSession
+	var next string
+	reused := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var current models.RefreshToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&current, "token_hash = ?", utils.HashToken(token)).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRefreshTokenInvalid
+			}
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&session, "id = ?", current.SessionID).Error; err != nil {
+			return err
+		}
+		if session.RevokedAt != nil {
+			return ErrSessionRevoked
+		}
+
+		now := time.Now()
+		if current.UsedAt != nil {
+			reused = true
+			log.Printf("Refresh token reuse in session %s of user %s, revoking the session", session.ID, session.UserID)
+			return revokeSessions(tx.Where("id = ?", session.ID), RevokeRefreshReuse)
+		}
+		if now.After(current.ExpiresAt) {
+			return ErrRefreshTokenInvalid
+		}
+
+		if err := tx.Model(&current).Update("used_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&session).Update("last_used_at", now).Error; err != nil {
+			return err
+		}
+		var err error
+		next, err = issueRefreshToken(tx, session.ID)
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if reused {
+		return nil, "", ErrRefreshTokenReused
+	}
+	return &session, next, nil
+}
+
+func revokeSessions(query *gorm.DB, reason string) error {
+	return query.Model(&models.AuthSession{}).
+		Where("revoked_at IS NULL").
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoke_reason": reason}).Error
+}
+
+// RevokeSession ends one session; its refresh and access tokens stop working.
+func RevokeSession(db *gorm.DB, sessionID uuid.UUID, reason string) error {
+	return revokeSessions(db.Where("id = ?", sessionID), reason)
+}
+
+// RevokeUserSessions ends every session of a user.
+func RevokeUserSessions(db *gorm.DB, userID uuid.UUID, reason string) error {
+	return revokeSessions(db.Where("user_id = ?", userID), reason)
+}
+
+// SessionActive reports whether access tokens of a session are still valid.
+func SessionActive(db *gorm.DB, sessionID uuid.UUID) bool {
+	var count int64
+	db.Model(&models.AuthSession{}).Where("id = ? AND revoked_at IS NULL", sessionID).Count(&count)
+	return count > 0
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+	"masjid-baiturrahim-backend/internal/testutil"
+	"masjid-baiturrahim-backend/internal/utils"
+)
+
+func TestRotateRefreshToken(t *testing.T) {
+	db := testutil.NewDB(t)
+	user := testutil.NewUser(t, db, models.RoleEditor, "")
+
+	session, first, err := StartSession(db, user.ID, "test", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, otherToken, err := StartSession(db, user.ID, "another device", "127.0.0.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, second, err := RotateRefreshToken(db, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated.ID != session.ID || second == "" || second == first {
+		t.Fatalf("rotation gave session %s and token %q", rotated.ID, second)
+	}
+	third, latest, err := RotateRefreshToken(db, second)
+	if err != nil || third.ID != session.ID {
+		t.Fatalf("rotating the new token: %v", err)
+	}
+
+	// The token given up at the first rotation was copied: the whole session
+	// goes, including the token its rightful holder has now
+	if _, _, err := RotateRefreshToken(db, first); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Errorf("reusing a rotated token: err = %v, want %v", err, ErrRefreshTokenReused)
+	}
+	var revoked models.AuthSession
+	db.First(&revoked, "id = ?", session.ID)
+	if revoked.RevokedAt == nil || revoked.RevokeReason != RevokeRefreshReuse {
+		t.Errorf("session revoked at %v for %q, want revoked for %q", revoked.RevokedAt, revoked.RevokeReason, RevokeRefreshReuse)
+	}
+	for name, token := range map[string]string{"latest token": latest, "rotated token": second, "reused token": first} {
+		if _, _, err := RotateRefreshToken(db, token); !errors.Is(err, ErrSessionRevoked) {
+			t.Errorf("%s after reuse: err = %v, want %v", name, err, ErrSessionRevoked)
+		}
+	}
+	if SessionActive(db, session.ID) {
+		t.Error("access tokens of the revoked session are still accepted")
+	}
+
+	// Other devices keep their session
+	if !SessionActive(db, other.ID) {
+		t.Error("the user's other session was revoked")
+	}
+	if _, _, err := RotateRefreshToken(db, otherToken); err != nil {
+		t.Errorf("other session: %v", err)
+	}
+
+	if _, _, err := RotateRefreshToken(db, "not-a-token"); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Errorf("unknown token: err = %v, want %v", err, ErrRefreshTokenInvalid)
+	}
+
+	_, expired, err := StartSession(db, user.ID, "test", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Model(&models.RefreshToken{}).Where("token_hash = ?", utils.HashToken(expired)).
+		Update("expires_at", time.Now().Add(-time.Minute))
+	if _, _, err := RotateRefreshToken(db, expired); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Errorf("expired token: err = %v, want %v", err, ErrRefreshTokenInvalid)
+	}
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"strconv"
+	"strings"
+	"masjid-baiturrahim-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// GetSetting returns the value stored under key and whether it is set.
+func GetSetting(db *gorm.DB, key string) (string, bool) {
+	var setting models.Setting
+	if err := db.Where("key = ?", key).First(&setting).Error; err != nil {
+		return "", false
+	}
+	return strings.TrimSpace(setting.Value), true
+}
+
+// GetSettingInt returns an integer setting, or def when it is missing or not
+// a number.
+func GetSettingInt(db *gorm.DB, key string, def int64) int64 {
+	value, ok := GetSetting(db, key)
+	if !ok {
+		return def
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+func GetSettingFloat(db *gorm.DB, key string, def float64) float64 {
+	value, ok := GetSetting(db, key)
+	if !ok {
+		return def
+	}
+	f, err := strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+	if err != nil {
+		return def
+	}
+	return f
+}
+
+func GetSettingBool(db *gorm.DB, key string, def bool) bool {
+	value, ok := GetSetting(db, key)
+	if !ok {
+		return def
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return def
+	}
+	return b
+}
+
+// GetSettingMoney reads a rupiah amount such as "1250000" or "45000.50".
+func GetSettingMoney(db *gorm.DB, key string, def models.Money) models.Money {
+	value, ok := GetSetting(db, key)
+	if !ok {
+		return def
+	}
+	m, err := models.ParseMoney(value)
+	if err != nil {
+		return def
+	}
+	return m
+}
@@ -0,0 +1,243 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"masjid-baiturrahim-backend/config"
+	"masjid-baiturrahim-backend/internal/models"
+	"masjid-baiturrahim-backend/internal/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrVirtualAccountBank       = errors.New("virtual accounts are not available for this bank")
+	ErrVirtualAccountNotice     = errors.New("invalid virtual account payment notification")
+	ErrVirtualAccountSignature  = errors.New("invalid virtual account notification signature")
+	ErrVirtualAccountUnknown    = errors.New("no donation has this virtual account")
+	ErrVirtualAccountAmount     = errors.New("paid amount does not match the donation")
+	ErrVirtualAccountNotPending = errors.New("donation is no longer awaiting payment")
+	ErrVirtualAccountExpired    = errors.New("virtual account was paid after it expired")
+)
+
+// VirtualAccountRequest asks a provider for an account number that only
+// accepts this donation's transfer.
+type VirtualAccountRequest struct {
+	DonationCode string
+	Name         string
+	Amount       models.Money
+	BankCode     string // empty picks the provider's default bank
+	ExpiresAt    time.Time
+}
+
+type VirtualAccount struct {
+	Number    string
+	BankCode  string
+	ExpiresAt time.Time
+}
+
+// VirtualAccountPayment is a verified payment notification.
+type VirtualAccountPayment struct {
+	Number    string
+	Amount    models.Money
+	PaidAt    time.Time
+	PaymentID string
+}
+
+// VirtualAccountProvider issues virtual accounts at a payment provider and
+// reads the notifications it sends when one is paid. A real gateway (e.g.
+// Xendit or Midtrans) can be added as another provider.
+type VirtualAccountProvider interface {
+	CreateVirtualAccount(req VirtualAccountRequest) (*VirtualAccount, error)
+	ParsePaymentNotification(header http.Header, body []byte) (*VirtualAccountPayment, error)
+}
+
+// NewVirtualAccountProvider returns the provider selected by VA_PROVIDER, or
+// nil when virtual accounts are disabled.
+func NewVirtualAccountProvider(cfg *config.Config) VirtualAccountProvider {
+	switch cfg.VAProvider {
+	case "":
+		return nil
+	case "fake":
+		if cfg.Environment == "production" {
+			log.Println("Fake virtual account provider is not allowed in production, virtual accounts disabled")
+			return nil
+		}
+		// Anyone could confirm donations with unsigned notifications
+		if cfg.VACallbackSecret == "" {
+			log.Println("VA_CALLBACK_SECRET is not set, virtual accounts disabled")
+			return nil
+		}
+		return FakeVirtualAccountProvider{Secret: cfg.VACallbackSecret}
+	default:
+		log.Printf("Unknown virtual account provider %q, virtual accounts disabled", cfg.VAProvider)
+		return nil
+	}
+}
+
+// FakeVirtualAccountProvider issues made-up account numbers derived from the
+// donation code, so the same donation always gets the same number. Payment
+// notifications are plain JSON signed like the mutation webhook:
+//
+//	{"va_number": "7001212345678901", "amount": "100000", "payment_id": "p-1"}
+type FakeVirtualAccountProvider struct {
+	Secret string // notifications are signed with it; without one none are accepted
+}
+
+// FakeVirtualAccountPrefixes are the company codes the fake provider puts in
+// front of account numbers, per bank.
+var FakeVirtualAccountPrefixes = map[string]string{
+	string(models.BankBCA):     "70012",
+	string(models.BankBSI):     "70013",
+	string(models.BankMandiri): "70014",
+}
+
+func (p FakeVirtualAccountProvider) CreateVirtualAccount(req VirtualAccountRequest) (*VirtualAccount, error) {
+	bank := strings.ToLower(req.BankCode)
+	if bank == "" {
+		bank = string(models.BankBSI)
+	}
+	prefix, ok := FakeVirtualAccountPrefixes[bank]
+	if !ok {
+		return nil, ErrVirtualAccountBank
+	}
+
+	sum := sha256.Sum256([]byte(req.DonationCode))
+	suffix := binary.BigEndian.Uint64(sum[:8]) % 100000000000
+	return &VirtualAccount{
+		Number:    fmt.Sprintf("%s%011d", prefix, suffix),
+		BankCode:  bank,
+		ExpiresAt: req.ExpiresAt,
+	}, nil
+}
+
+// SignNotification returns the signature a fake notification body needs, for
+// simulating payments during development.
+func (p FakeVirtualAccountProvider) SignNotification(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(p.Secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (p FakeVirtualAccountProvider) ParsePaymentNotification(header http.Header, body []byte) (*VirtualAccountPayment, error) {
+	signature := strings.ToLower(strings.TrimSpace(header.Get("X-Callback-Signature")))
+	if p.Secret == "" || !hmac.Equal([]byte(p.SignNotification(body)), []byte(signature)) {
+		return nil, ErrVirtualAccountSignature
+	}
+
+	var notice struct {
+		VANumber  string      `json:"va_number"`
+		Amount    json.Number `json:"amount"`
+		PaidAt    *time.Time  `json:"paid_at"`
+		PaymentID string      `json:"payment_id"`
+	}
+	if err := json.Unmarshal(body, &notice); err != nil {
+		return nil, fmt.Errorf("%w (%s)", ErrVirtualAccountNotice, err.Error())
+	}
+	amount, err := models.ParseMoney(notice.Amount.String())
+	if err != nil || notice.VANumber == "" {
+		return nil, ErrVirtualAccountNotice
+	}
+
+	payment := &VirtualAccountPayment{
+		Number:    notice.VANumber,
+		Amount:    amount,
+		PaidAt:    time.Now(),
+		PaymentID: notice.PaymentID,
+	}
+	if notice.PaidAt != nil {
+		payment.PaidAt = *notice.PaidAt
+	}
+	return payment, nil
+}
+
+// ConfirmVirtualAccountPayment confirms the donation a virtual account was
+// issued for. The provider has already verified the transfer, so no admin is
+// involved. Providers repeat notifications until they are acknowledged; a
+// repeat for a donation that is already confirmed changes nothing.
+//
+// A payment made after the account expired doesn't confirm the donation,
+// which may have expired already. It goes to the reconciliation queue as a
+// bank mutation for an admin to sort out, and ErrVirtualAccountExpired is
+// returned once it is stored.
+func ConfirmVirtualAccountPayment(db *gorm.DB, payment *VirtualAccountPayment) (*models.Donation, error) {
+	var donation models.Donation
+	late := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&donation, "va_number = ?", payment.Number).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrVirtualAccountUnknown
+			}
+			return err
+		}
+		if donation.Status == models.DonationStatusConfirmed {
+			return nil
+		}
+		if donation.VAExpiresAt != nil && payment.PaidAt.After(*donation.VAExpiresAt) {
+			late = true
+			return queueLateVirtualAccountPayment(tx, &donation, payment)
+		}
+		if donation.Status != models.DonationStatusPending {
+			return ErrVirtualAccountNotPending
+		}
+		if payment.Amount != donation.TransferAmount {
+			return fmt.Errorf("%w (paid %s, expected %s)", ErrVirtualAccountAmount, payment.Amount.Format(), donation.TransferAmount.Format())
+		}
+
+		reason := fmt.Sprintf("Dibayar melalui virtual account %s %s", strings.ToUpper(utils.StringValue(donation.VABankCode)), payment.Number)
+		if payment.PaymentID != "" {
+			reason += " (" + payment.PaymentID + ")"
+		}
+		confirmed, err := SystemTransitionDonation(tx, donation.ID, models.DonationStatusConfirmed, reason)
+		if err != nil {
+			return err
+		}
+		donation = *confirmed
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if late {
+		return &donation, ErrVirtualAccountExpired
+	}
+	return &donation, nil
+}
+
+// queueLateVirtualAccountPayment stores a late payment as an unmatched credit
+// in the reconciliation queue. Repeated notifications of the same payment
+// are stored once.
+func queueLateVirtualAccountPayment(tx *gorm.DB, donation *models.Donation, payment *VirtualAccountPayment) error {
+	key := payment.PaymentID
+	if key == "" {
+		key = fmt.Sprintf("%s|%s|%s", payment.Number, payment.Amount.String(), payment.PaidAt.UTC().Format(time.RFC3339))
+	}
+	sum := sha256.Sum256([]byte("va|" + key))
+
+	paidAt := payment.PaidAt
+	mutation := models.BankMutation{
+		Source:          models.MutationSourceVirtualAccount,
+		ExternalID:      payment.PaymentID,
+		Bank:            models.BankCode(utils.StringValue(donation.VABankCode)),
+		Fingerprint:     hex.EncodeToString(sum[:]),
+		TransactionDate: time.Date(paidAt.Year(), paidAt.Month(), paidAt.Day(), 0, 0, 0, 0, time.UTC),
+		TransactionTime: &paidAt,
+		Description: fmt.Sprintf("Pembayaran virtual account %s untuk donasi %s setelah kedaluwarsa (%s)",
+			payment.Number, donation.DonationCode, donation.VAExpiresAt.Format("2006-01-02 15:04")),
+		Type:        models.MutationCredit,
+		Amount:      payment.Amount,
+		MatchStatus: models.MutationUnmatched,
+	}
+	return tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "fingerprint"}}, DoNothing: true}).Create(&mutation).Error
+}
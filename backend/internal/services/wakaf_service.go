@@ -0,0 +1,329 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// wakafYieldCategoryCode is the ledger income category for yields of
+// productive wakaf.
+const wakafYieldCategoryCode = "hasil-wakaf"
+
+var (
+	ErrWakafAssetInvalid     = errors.New("wakaf asset needs a name, a valid type and a quantity of at least 1")
+	ErrWakafLegalStatus      = errors.New("legal status must be pledged, registered or certified")
+	ErrWakafAssetStatus      = errors.New("status must be fundraising, active or inactive")
+	ErrWakafAIWRequired      = errors.New("a registered wakaf needs its Akta Ikrar Wakaf number")
+	ErrWakafCertRequired     = errors.New("a certified wakaf needs its certificate number")
+	ErrWakafNegative         = errors.New("valuation and target amount can't be negative")
+	ErrWakafNotFundraising   = errors.New("this wakaf asset is not accepting cash wakaf")
+	ErrWakafDonationCategory = errors.New("only wakaf donations can fund a wakaf asset")
+	ErrWakafYieldNotProduct  = errors.New("yields can only be recorded for productive wakaf")
+	ErrWakafYieldAmount      = errors.New("yield amount must be positive")
+	ErrWakafYieldAccount     = errors.New("a valid cash book account is required to post the yield")
+)
+
+func ValidateWakafAsset(a *models.WakafAsset) error {
+	if a.Name == "" || !a.Type.Valid() || a.Quantity < 1 {
+		return ErrWakafAssetInvalid
+	}
+	if a.Valuation < 0 || a.TargetAmount < 0 {
+		return ErrWakafNegative
+	}
+	switch a.Status {
+	case models.WakafFundraising, models.WakafActive, models.WakafInactive:
+	default:
+		return ErrWakafAssetStatus
+	}
+
+	// Each legal stage implies the documents of the stages before it
+	switch a.LegalStatus {
+	case models.WakafCertified:
+		if a.CertificateNumber == nil || *a.CertificateNumber == "" {
+			return ErrWakafCertRequired
+		}
+		fallthrough
+	case models.WakafRegistered:
+		if a.AIWNumber == nil || *a.AIWNumber == "" {
+			return ErrWakafAIWRequired
+		}
+	case models.WakafPledged:
+	default:
+		return ErrWakafLegalStatus
+	}
+	return nil
+}
+
+// CheckWakafDonation checks that a donation may fund the given asset: it must
+// be a wakaf donation and, when made by the public, the asset must be
+// raising funds.
+func CheckWakafDonation(db *gorm.DB, category models.DonationCategory, assetID uuid.UUID, public bool) error {
+	if category != models.DonationCategoryWakaf {
+		return ErrWakafDonationCategory
+	}
+
+	var asset models.WakafAsset
+	if err := db.First(&asset, "id = ?", assetID).Error; err != nil {
+		return err
+	}
+	if public && (asset.Status != models.WakafFundraising || asset.HideFromPublic) {
+		return ErrWakafNotFundraising
+	}
+	return nil
+}
+
+// LinkDonationToWakafAsset records which asset a wakaf donation funds, or
+// unlinks it when assetID is nil.
+func LinkDonationToWakafAsset(db *gorm.DB, donationID uuid.UUID, assetID *uuid.UUID) (*models.Donation, error) {
+	var donation models.Donation
+	if err := db.First(&donation, "id = ?", donationID).Error; err != nil {
+		return nil, err
+	}
+	if assetID != nil {
+		if err := CheckWakafDonation(db, donation.Category, *assetID, false); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := db.Model(&donation).Update("wakaf_asset_id", assetID).Error; err != nil {
+		return nil, err
+	}
+	return &donation, nil
+}
+
+type WakafFunding struct {
+	Collected     models.Money `json:"collected"`
+	DonationCount int64        `json:"donation_count"`
+	WakifCount    int64        `json:"wakif_count"`
+	YieldTotal    models.Money `json:"yield_total"`
+}
+
+// GetWakafFunding sums the confirmed cash wakaf and recorded yields of each
+// asset.
+func GetWakafFunding(db *gorm.DB, assetIDs []uuid.UUID) (map[uuid.UUID]WakafFunding, error) {
+	funding := make(map[uuid.UUID]WakafFunding, len(assetIDs))
+	if len(assetIDs) == 0 {
+		return funding, nil
+	}
+
+	var donations []struct {
+		WakafAssetID  uuid.UUID
+		Collected     models.Money
+		DonationCount int64
+		WakifCount    int64
+	}
+	if err := db.Model(&models.Donation{}).
+		Select("wakaf_asset_id, COALESCE(SUM(amount), 0) as collected, COUNT(*) as donation_count, COUNT(DISTINCT "+donorIdentity+") as wakif_count").
+		Where("status = ? AND wakaf_asset_id IN ?", models.DonationStatusConfirmed, assetIDs).
+		Group("wakaf_asset_id").
+		Scan(&donations).Error; err != nil {
+		return nil, err
+	}
+	for _, d := range donations {
+		f := funding[d.WakafAssetID]
+		f.Collected, f.DonationCount, f.WakifCount = d.Collected, d.DonationCount, d.WakifCount
+		funding[d.WakafAssetID] = f
+	}
+
+	var yields []struct {
+		AssetID uuid.UUID
+		Total   models.Money
+	}
+	if err := db.Model(&models.WakafYield{}).
+		Select("asset_id, SUM(amount) as total").
+		Where("asset_id IN ?", assetIDs).
+		Group("asset_id").
+		Scan(&yields).Error; err != nil {
+		return nil, err
+	}
+	for _, y := range yields {
+		f := funding[y.AssetID]
+		f.YieldTotal = y.Total
+		funding[y.AssetID] = f
+	}
+	return funding, nil
+}
+
+// RecordWakafYield records income from a productive asset and, when an
+// account is given, books it in the cash book.
+func RecordWakafYield(db *gorm.DB, yield *models.WakafYield, actorID uuid.UUID) error {
+	if yield.Amount <= 0 {
+		return ErrWakafYieldAmount
+	}
+	if yield.ReceivedAt.IsZero() {
+		yield.ReceivedAt = time.Now()
+	}
+	yield.CreatedBy = actorID
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var asset models.WakafAsset
+		if err := tx.First(&asset, "id = ?", yield.AssetID).Error; err != nil {
+			return err
+		}
+		if !asset.IsProductive {
+			return ErrWakafYieldNotProduct
+		}
+
+		if yield.AccountID != nil {
+			category := models.LedgerCategory{
+				Code:     wakafYieldCategoryCode,
+				Name:     "Hasil Wakaf Produktif",
+				Type:     models.LedgerIncome,
+				IsActive: true,
+			}
+			if err := tx.Where("code = ?", category.Code).FirstOrCreate(&category).Error; err != nil {
+				return err
+			}
+
+			description := fmt.Sprintf("Hasil wakaf %s", asset.Name)
+			if yield.Description != "" {
+				description += ": " + yield.Description
+			}
+			entry := models.LedgerEntry{
+				AccountID:   *yield.AccountID,
+				CategoryID:  category.ID,
+				Type:        models.LedgerIncome,
+				Amount:      yield.Amount,
+				EntryDate:   yield.ReceivedAt,
+				Description: description,
+				Source:      models.LedgerSourceWakafYield,
+				CreatedBy:   &actorID,
+			}
+			if err := ValidateLedgerEntry(tx, &entry); err != nil {
+				return fmt.Errorf("%w (%s)", ErrWakafYieldAccount, err.Error())
+			}
+			if err := tx.Create(&entry).Error; err != nil {
+				return err
+			}
+			yield.LedgerEntryID = &entry.ID
+		}
+
+		return tx.Create(yield).Error
+	})
+}
+
+// DeleteWakafYield removes a yield recorded in error together with its
+// ledger entry.
+func DeleteWakafYield(db *gorm.DB, assetID, yieldID uuid.UUID) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var yield models.WakafYield
+		if err := tx.First(&yield, "id = ? AND asset_id = ?", yieldID, assetID).Error; err != nil {
+			return err
+		}
+		if yield.LedgerEntryID != nil {
+			if err := tx.Delete(&models.LedgerEntry{}, "id = ? AND source = ?", yield.LedgerEntryID, models.LedgerSourceWakafYield).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&yield).Error
+	})
+}
+
+type WakafTypeSummary struct {
+	Type      models.WakafAssetType `json:"type"`
+	Count     int64                 `json:"count"`
+	Quantity  int64                 `json:"quantity"`
+	AreaM2    float64               `json:"area_m2"`
+	Valuation models.Money          `json:"valuation"`
+}
+
+// PublicWakafAsset is what the public sees of an asset: no wakif, documents
+// or document numbers.
+type PublicWakafAsset struct {
+	ID           uuid.UUID               `json:"id"`
+	Name         string                  `json:"name"`
+	Type         models.WakafAssetType   `json:"type"`
+	Description  string                  `json:"description"`
+	Location     string                  `json:"location"`
+	AreaM2       *float64                `json:"area_m2,omitempty"`
+	Quantity     int                     `json:"quantity"`
+	Purpose      string                  `json:"purpose"`
+	LegalStatus  models.WakafLegalStatus `json:"legal_status"`
+	Status       models.WakafAssetStatus `json:"status"`
+	IsProductive bool                    `json:"is_productive"`
+	Valuation    models.Money            `json:"valuation"`
+	TargetAmount models.Money            `json:"target_amount"`
+	Funding      WakafFunding            `json:"funding"`
+}
+
+type WakafSummary struct {
+	AssetCount     int64              `json:"asset_count"`
+	TotalValuation models.Money       `json:"total_valuation"`
+	TotalAreaM2    float64            `json:"total_area_m2"`
+	CashCollected  models.Money       `json:"cash_collected"`
+	YieldTotal     models.Money       `json:"yield_total"`
+	ByType         []WakafTypeSummary `json:"by_type"`
+	Assets         []PublicWakafAsset `json:"assets"`
+}
+
+// GetWakafSummary summarises the wakaf assets shown to the public; hidden
+// and inactive assets are left out.
+func GetWakafSummary(db *gorm.DB) (*WakafSummary, error) {
+	var assets []models.WakafAsset
+	if err := db.Where("hide_from_public = ? AND status <> ?", false, models.WakafInactive).
+		Order("status ASC, name ASC").
+		Find(&assets).Error; err != nil {
+		return nil, err
+	}
+
+	ids := make([]uuid.UUID, len(assets))
+	for i, a := range assets {
+		ids[i] = a.ID
+	}
+	funding, err := GetWakafFunding(db, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &WakafSummary{ByType: []WakafTypeSummary{}, Assets: []PublicWakafAsset{}}
+	byType := make(map[models.WakafAssetType]*WakafTypeSummary)
+	for _, a := range assets {
+		f := funding[a.ID]
+		summary.AssetCount++
+		summary.TotalValuation += a.Valuation
+		summary.CashCollected += f.Collected
+		summary.YieldTotal += f.YieldTotal
+
+		t := byType[a.Type]
+		if t == nil {
+			t = &WakafTypeSummary{Type: a.Type}
+			byType[a.Type] = t
+		}
+		t.Count++
+		t.Quantity += int64(a.Quantity)
+		t.Valuation += a.Valuation
+		if a.AreaM2 != nil {
+			t.AreaM2 += *a.AreaM2
+			summary.TotalAreaM2 += *a.AreaM2
+		}
+
+		summary.Assets = append(summary.Assets, PublicWakafAsset{
+			ID:           a.ID,
+			Name:         a.Name,
+			Type:         a.Type,
+			Description:  a.Description,
+			Location:     a.Location,
+			AreaM2:       a.AreaM2,
+			Quantity:     a.Quantity,
+			Purpose:      a.Purpose,
+			LegalStatus:  a.LegalStatus,
+			Status:       a.Status,
+			IsProductive: a.IsProductive,
+			Valuation:    a.Valuation,
+			TargetAmount: a.TargetAmount,
+			Funding:      f,
+		})
+	}
+
+	for _, assetType := range models.AllWakafAssetTypes {
+		if t := byType[assetType]; t != nil {
+			summary.ByType = append(summary.ByType, *t)
+		}
+	}
+	return summary, nil
+}
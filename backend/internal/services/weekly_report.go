@@ -0,0 +1,119 @@
+package services
+
+import (
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const dateLayout = "2006-01-02"
+
+// ReportFriday returns the Friday on or before date, at midnight.
+func ReportFriday(date time.Time) time.Time {
+	date = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	offset := (int(date.Weekday()) - int(time.Friday) + 7) % 7
+	return date.AddDate(0, 0, -offset)
+}
+
+type CategoryTotal struct {
+	CategoryID uuid.UUID    `json:"category_id"`
+	Code       string       `json:"code"`
+	Name       string       `json:"name"`
+	Total      models.Money `json:"total"`
+}
+
+type WeeklyAccountBalance struct {
+	Name           string                   `json:"name"`
+	Type           models.LedgerAccountType `json:"type"`
+	OpeningBalance models.Money             `json:"opening_balance"`
+	ClosingBalance models.Money             `json:"closing_balance"`
+}
+
+// WeeklyReport is the cash report read out after Friday prayer. It covers the
+// week from the previous Friday up to, but not including, the report Friday;
+// that Friday's own collections go into the next report.
+type WeeklyReport struct {
+	StartDate      string                 `json:"start_date"`
+	EndDate        string                 `json:"end_date"` // last day covered, the Thursday
+	ReportDate     string                 `json:"report_date"`
+	OpeningBalance models.Money           `json:"opening_balance"`
+	Income         []CategoryTotal        `json:"income"`
+	TotalIncome    models.Money           `json:"total_income"`
+	Expenses       []CategoryTotal        `json:"expenses"`
+	TotalExpense   models.Money           `json:"total_expense"`
+	ClosingBalance models.Money           `json:"closing_balance"`
+	Accounts       []WeeklyAccountBalance `json:"accounts"`
+}
+
+// GetWeeklyReport builds the report read on the given Friday from the ledger,
+// which holds confirmed donations as well as recorded income and expenses.
+func GetWeeklyReport(db *gorm.DB, friday time.Time) (*WeeklyReport, error) {
+	friday = ReportFriday(friday)
+	start := friday.AddDate(0, 0, -7)
+	end := friday.AddDate(0, 0, -1)
+
+	dayBeforeStart := start.AddDate(0, 0, -1)
+	opening, err := GetAccountBalances(db, &dayBeforeStart)
+	if err != nil {
+		return nil, err
+	}
+	closing, err := GetAccountBalances(db, &end)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		CategoryID uuid.UUID
+		Code       string
+		Name       string
+		Type       models.LedgerEntryType
+		Total      models.Money
+	}
+	err = db.Model(&models.LedgerEntry{}).
+		Select("ledger_categories.id as category_id, ledger_categories.code, ledger_categories.name, ledger_entries.type, SUM(ledger_entries.amount) as total").
+		Joins("JOIN ledger_categories ON ledger_categories.id = ledger_entries.category_id").
+		Where("ledger_entries.entry_date BETWEEN ? AND ?", start.Format(dateLayout), end.Format(dateLayout)).
+		Group("ledger_categories.id, ledger_categories.code, ledger_categories.name, ledger_entries.type").
+		Order("total DESC").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	report := &WeeklyReport{
+		StartDate:  start.Format(dateLayout),
+		EndDate:    end.Format(dateLayout),
+		ReportDate: friday.Format(dateLayout),
+		Income:     []CategoryTotal{},
+		Expenses:   []CategoryTotal{},
+		Accounts:   make([]WeeklyAccountBalance, len(closing)),
+	}
+	for _, row := range rows {
+		total := CategoryTotal{CategoryID: row.CategoryID, Code: row.Code, Name: row.Name, Total: row.Total}
+		if row.Type == models.LedgerExpense {
+			report.Expenses = append(report.Expenses, total)
+			report.TotalExpense += row.Total
+		} else {
+			report.Income = append(report.Income, total)
+			report.TotalIncome += row.Total
+		}
+	}
+
+	openingByAccount := make(map[uuid.UUID]models.Money, len(opening))
+	for _, account := range opening {
+		openingByAccount[account.ID] = account.Balance
+	}
+	for i, account := range closing {
+		report.Accounts[i] = WeeklyAccountBalance{
+			Name:           account.Name,
+			Type:           account.Type,
+			OpeningBalance: openingByAccount[account.ID],
+			ClosingBalance: account.Balance,
+		}
+		report.OpeningBalance += openingByAccount[account.ID]
+		report.ClosingBalance += account.Balance
+	}
+	return report, nil
+}
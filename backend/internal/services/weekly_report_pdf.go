@@ -0,0 +1,99 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+
+	"github.com/go-pdf/fpdf"
+)
+
+var indonesianMonths = [...]string{
+	"Januari", "Februari", "Maret", "April", "Mei", "Juni",
+	"Juli", "Agustus", "September", "Oktober", "November", "Desember",
+}
+
+// FormatIndonesianDate renders a YYYY-MM-DD date as e.g. "17 Oktober 2026".
+func FormatIndonesianDate(date string) string {
+	t, err := time.Parse(dateLayout, date)
+	if err != nil {
+		return date
+	}
+	return fmt.Sprintf("%d %s %d", t.Day(), indonesianMonths[t.Month()-1], t.Year())
+}
+
+// RenderWeeklyReportPDF lays the weekly report out on a single A4 page for
+// the notice board.
+func RenderWeeklyReportPDF(report *WeeklyReport, mosqueName string) ([]byte, error) {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle("Laporan Kas Mingguan "+report.ReportDate, true)
+	pdf.SetMargins(20, 20, 20)
+	pdf.AddPage()
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	width, _ := pdf.GetPageSize()
+	contentWidth := width - 40
+	amountWidth := 50.0
+
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.CellFormat(contentWidth, 10, tr("LAPORAN KAS MINGGUAN"), "", 1, "C", false, 0, "")
+	if mosqueName != "" {
+		pdf.SetFont("Helvetica", "B", 14)
+		pdf.CellFormat(contentWidth, 8, tr(mosqueName), "", 1, "C", false, 0, "")
+	}
+	pdf.SetFont("Helvetica", "", 11)
+	pdf.CellFormat(contentWidth, 7, tr(fmt.Sprintf("Periode %s - %s",
+		FormatIndonesianDate(report.StartDate), FormatIndonesianDate(report.EndDate))), "", 1, "C", false, 0, "")
+	pdf.Ln(6)
+
+	row := func(label string, amount models.Money, bold bool) {
+		style := ""
+		if bold {
+			style = "B"
+		}
+		pdf.SetFont("Helvetica", style, 12)
+		pdf.CellFormat(contentWidth-amountWidth, 8, tr(label), "B", 0, "L", false, 0, "")
+		pdf.CellFormat(amountWidth, 8, amount.Format(), "B", 1, "R", false, 0, "")
+	}
+	section := func(title string) {
+		pdf.Ln(4)
+		pdf.SetFont("Helvetica", "B", 12)
+		pdf.SetFillColor(230, 240, 230)
+		pdf.CellFormat(contentWidth, 8, tr(title), "", 1, "L", true, 0, "")
+	}
+
+	row(fmt.Sprintf("Saldo awal (%s)", FormatIndonesianDate(report.StartDate)), report.OpeningBalance, true)
+
+	section("Pemasukan")
+	for _, c := range report.Income {
+		row("    "+c.Name, c.Total, false)
+	}
+	row("Jumlah pemasukan", report.TotalIncome, true)
+
+	section("Pengeluaran")
+	for _, c := range report.Expenses {
+		row("    "+c.Name, c.Total, false)
+	}
+	row("Jumlah pengeluaran", report.TotalExpense, true)
+
+	pdf.Ln(4)
+	row(fmt.Sprintf("Saldo akhir (%s)", FormatIndonesianDate(report.EndDate)), report.ClosingBalance, true)
+
+	if len(report.Accounts) > 0 {
+		section("Posisi saldo")
+		for _, a := range report.Accounts {
+			row("    "+a.Name, a.ClosingBalance, false)
+		}
+	}
+
+	pdf.Ln(10)
+	pdf.SetFont("Helvetica", "I", 9)
+	pdf.CellFormat(contentWidth, 6, tr(fmt.Sprintf("Dibacakan pada hari Jumat, %s", FormatIndonesianDate(report.ReportDate))), "", 1, "L", false, 0, "")
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,317 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"masjid-baiturrahim-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Settings the zakat calculators read. The gold price has no default: it
+// changes daily and must be kept up to date by the treasurer.
+const (
+	SettingGoldPricePerGram = "zakat_gold_price_per_gram"
+	SettingFitrahRiceKg     = "zakat_fitrah_rice_kg"
+	SettingFitrahCashAmount = "zakat_fitrah_cash_amount"
+
+	NisabGoldGrams      = 85
+	DefaultFitrahRiceKg = 2.5
+)
+
+var DefaultFitrahCashAmount = models.NewMoney(45000)
+
+var (
+	ErrGoldPriceNotSet    = errors.New("gold price per gram is not configured")
+	ErrZakatNegative      = errors.New("amounts can't be negative")
+	ErrZakatPeopleCount   = errors.New("number of people must be at least 1")
+	ErrZakatPaymentEmpty  = errors.New("payment needs rice or cash")
+	ErrZakatRiceNotFitrah = errors.New("only zakat fitrah can be paid in rice")
+	ErrZakatType          = errors.New("zakat type must be fitrah, maal or profesi")
+	ErrMuzakkiRequired    = errors.New("muzakki_id or a new muzakki with a name is required")
+)
+
+// zakatDue is 2.5% of amount, rounded to the nearest minor unit.
+func zakatDue(amount models.Money) models.Money {
+	return (amount*25 + 500) / 1000
+}
+
+type ZakatRates struct {
+	GoldPricePerGram models.Money `json:"gold_price_per_gram"`
+	NisabGoldGrams   int          `json:"nisab_gold_grams"`
+	NisabMaal        models.Money `json:"nisab_maal"`            // per year
+	NisabProfesi     models.Money `json:"nisab_profesi_monthly"` // per month
+	FitrahRiceKg     float64      `json:"fitrah_rice_kg"`        // per person
+	FitrahCashAmount models.Money `json:"fitrah_cash_amount"`    // per person
+}
+
+// GetZakatRates reads the nisab and fitrah rates from settings. Nisab is zero
+// while the gold price isn't configured.
+func GetZakatRates(db *gorm.DB) ZakatRates {
+	gold := GetSettingMoney(db, SettingGoldPricePerGram, 0)
+	nisab := gold * NisabGoldGrams
+	return ZakatRates{
+		GoldPricePerGram: gold,
+		NisabGoldGrams:   NisabGoldGrams,
+		NisabMaal:        nisab,
+		NisabProfesi:     (nisab + 6) / 12,
+		FitrahRiceKg:     GetSettingFloat(db, SettingFitrahRiceKg, DefaultFitrahRiceKg),
+		FitrahCashAmount: GetSettingMoney(db, SettingFitrahCashAmount, DefaultFitrahCashAmount),
+	}
+}
+
+// ZakatMaalInput lists wealth held for a full lunar year (haul).
+type ZakatMaalInput struct {
+	Savings     models.Money `json:"savings"`
+	Gold        models.Money `json:"gold"` // value of gold and silver
+	Investments models.Money `json:"investments"`
+	Receivables models.Money `json:"receivables"`
+	TradeGoods  models.Money `json:"trade_goods"`
+	Other       models.Money `json:"other"`
+	Debts       models.Money `json:"debts"` // debts falling due
+}
+
+type ZakatMaalResult struct {
+	TotalAssets models.Money `json:"total_assets"`
+	Debts       models.Money `json:"debts"`
+	NetAssets   models.Money `json:"net_assets"`
+	Nisab       models.Money `json:"nisab"`
+	Obligatory  bool         `json:"obligatory"`
+	ZakatAmount models.Money `json:"zakat_amount"`
+}
+
+func CalculateZakatMaal(rates ZakatRates, in ZakatMaalInput) (*ZakatMaalResult, error) {
+	if rates.NisabMaal <= 0 {
+		return nil, ErrGoldPriceNotSet
+	}
+	for _, m := range []models.Money{in.Savings, in.Gold, in.Investments, in.Receivables, in.TradeGoods, in.Other, in.Debts} {
+		if m < 0 {
+			return nil, ErrZakatNegative
+		}
+	}
+
+	result := &ZakatMaalResult{
+		TotalAssets: in.Savings + in.Gold + in.Investments + in.Receivables + in.TradeGoods + in.Other,
+		Debts:       in.Debts,
+		Nisab:       rates.NisabMaal,
+	}
+	result.NetAssets = result.TotalAssets - result.Debts
+	if result.NetAssets >= result.Nisab {
+		result.Obligatory = true
+		result.ZakatAmount = zakatDue(result.NetAssets)
+	}
+	return result, nil
+}
+
+// ZakatProfesiInput is monthly income. Zakat profesi is calculated on income
+// net of debt installments once that reaches the monthly nisab; payers who
+// follow the gross-income (bruto) opinion leave DebtInstallments at zero.
+type ZakatProfesiInput struct {
+	MonthlyIncome    models.Money `json:"monthly_income"`
+	OtherIncome      models.Money `json:"other_income"`
+	DebtInstallments models.Money `json:"debt_installments"`
+}
+
+type ZakatProfesiResult struct {
+	Income       models.Money `json:"income"`
+	Nisab        models.Money `json:"nisab"`
+	Obligatory   bool         `json:"obligatory"`
+	MonthlyZakat models.Money `json:"monthly_zakat"`
+	YearlyZakat  models.Money `json:"yearly_zakat"`
+}
+
+func CalculateZakatProfesi(rates ZakatRates, in ZakatProfesiInput) (*ZakatProfesiResult, error) {
+	if rates.NisabProfesi <= 0 {
+		return nil, ErrGoldPriceNotSet
+	}
+	if in.MonthlyIncome < 0 || in.OtherIncome < 0 || in.DebtInstallments < 0 {
+		return nil, ErrZakatNegative
+	}
+
+	result := &ZakatProfesiResult{
+		Income: in.MonthlyIncome + in.OtherIncome - in.DebtInstallments,
+		Nisab:  rates.NisabProfesi,
+	}
+	if result.Income >= result.Nisab {
+		result.Obligatory = true
+		result.MonthlyZakat = zakatDue(result.Income)
+		result.YearlyZakat = result.MonthlyZakat * 12
+	}
+	return result, nil
+}
+
+type ZakatFitrahResult struct {
+	People        int          `json:"people"`
+	RiceKgPerson  float64      `json:"rice_kg_per_person"`
+	CashPerPerson models.Money `json:"cash_per_person"`
+	TotalRiceKg   float64      `json:"total_rice_kg"`
+	TotalCash     models.Money `json:"total_cash_amount"`
+}
+
+func CalculateZakatFitrah(rates ZakatRates, people int) (*ZakatFitrahResult, error) {
+	if people < 1 {
+		return nil, ErrZakatPeopleCount
+	}
+	return &ZakatFitrahResult{
+		People:        people,
+		RiceKgPerson:  rates.FitrahRiceKg,
+		CashPerPerson: rates.FitrahCashAmount,
+		TotalRiceKg:   math.Round(rates.FitrahRiceKg*float64(people)*100) / 100,
+		TotalCash:     rates.FitrahCashAmount * models.Money(people),
+	}, nil
+}
+
+// RecordZakatPayment saves a payment taken at the zakat counter. Its cash part
+// becomes a confirmed zakat donation, which posts it to the cash book, or a
+// pending one with an approval request when the amount is above the approval
+// threshold. A first-time payer may be given inline in payment.Muzakki and is
+// registered along with the payment.
+func RecordZakatPayment(db *gorm.DB, payment *models.ZakatPayment, receiverID uuid.UUID) (*models.Approval, error) {
+	switch payment.Type {
+	case models.ZakatFitrah, models.ZakatMaal, models.ZakatProfesi:
+	default:
+		return nil, ErrZakatType
+	}
+	if payment.PeopleCount < 1 {
+		return nil, ErrZakatPeopleCount
+	}
+	if payment.RiceKg < 0 || payment.CashAmount < 0 {
+		return nil, ErrZakatNegative
+	}
+	if payment.RiceKg == 0 && payment.CashAmount == 0 {
+		return nil, ErrZakatPaymentEmpty
+	}
+	if payment.RiceKg > 0 && payment.Type != models.ZakatFitrah {
+		return nil, ErrZakatRiceNotFitrah
+	}
+	// The cash part becomes a donation, held to the same bounds as any other
+	if payment.CashAmount > 0 {
+		if err := ValidateDonationAmount(payment.CashAmount); err != nil {
+			return nil, err
+		}
+	}
+	if payment.Year == 0 {
+		payment.Year = time.Now().Year()
+	}
+	payment.ReceivedBy = receiverID
+
+	var approval *models.Approval
+	err := db.Transaction(func(tx *gorm.DB) error {
+		muzakki := payment.Muzakki
+		if payment.MuzakkiID == uuid.Nil {
+			if strings.TrimSpace(muzakki.Name) == "" {
+				return ErrMuzakkiRequired
+			}
+			muzakki.ID = uuid.Nil
+			if err := tx.Create(&muzakki).Error; err != nil {
+				return err
+			}
+			payment.MuzakkiID = muzakki.ID
+		} else if err := tx.First(&muzakki, "id = ?", payment.MuzakkiID).Error; err != nil {
+			return err
+		}
+		payment.Muzakki = muzakki
+
+		if payment.CashAmount > 0 {
+			notes := fmt.Sprintf("Zakat %s", payment.Type)
+			if payment.Type == models.ZakatFitrah {
+				notes = fmt.Sprintf("Zakat fitrah %d jiwa", payment.PeopleCount)
+			}
+			donation := models.Donation{
+				DonationCode:   GenerateDonationCode(),
+				DonorName:      muzakki.Name,
+				DonorEmail:     muzakki.Email,
+				DonorPhone:     muzakki.Phone,
+				Amount:         payment.CashAmount,
+				TransferAmount: payment.CashAmount,
+				Category:       models.DonationCategoryZakat,
+				Notes:          notes,
+				Status:         models.DonationStatusPending,
+			}
+			if err := AttachDonor(tx, &donation); err != nil {
+				return err
+			}
+			if err := tx.Create(&donation).Error; err != nil {
+				return err
+			}
+			// A large amount waits for a treasurer like any other donation
+			reason := "Diterima langsung oleh amil: " + notes
+			if NeedsApproval(tx, payment.CashAmount) {
+				requested, err := RequestDonationConfirmation(tx, donation.ID, receiverID, reason)
+				if err != nil {
+					return err
+				}
+				approval = requested
+			} else if _, err := TransitionDonation(tx, donation.ID, models.DonationStatusConfirmed, receiverID, reason); err != nil {
+				return err
+			}
+			payment.DonationID = &donation.ID
+		}
+
+		return tx.Omit("Muzakki", "Donation", "Receiver").Create(payment).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return approval, nil
+}
+
+type ZakatTypeSummary struct {
+	Type         models.ZakatType `json:"type"`
+	Payments     int64            `json:"payments"`
+	MuzakkiCount int64            `json:"muzakki_count"`
+	PeopleCount  int64            `json:"people_count"`
+	RiceKg       float64          `json:"rice_kg"`
+	CashAmount   models.Money     `json:"cash_amount"`
+}
+
+type ZakatSummary struct {
+	Year       int                `json:"year"`
+	ByType     []ZakatTypeSummary `json:"by_type"`
+	TotalRice  float64            `json:"total_rice_kg"`
+	TotalCash  models.Money       `json:"total_cash_amount"`
+	RicePeople int64              `json:"rice_people"` // fitrah paid in rice only
+	CashPeople int64              `json:"cash_people"` // fitrah paid in cash only
+}
+
+// GetZakatSummary totals the payments received in a year, splitting fitrah
+// into what was paid in rice and in cash.
+func GetZakatSummary(db *gorm.DB, year int) (*ZakatSummary, error) {
+	summary := &ZakatSummary{Year: year, ByType: []ZakatTypeSummary{}}
+
+	err := db.Model(&models.ZakatPayment{}).
+		Select(`type, COUNT(*) as payments, COUNT(DISTINCT muzakki_id) as muzakki_count,
+			COALESCE(SUM(people_count), 0) as people_count, COALESCE(SUM(rice_kg), 0) as rice_kg,
+			COALESCE(SUM(cash_amount), 0) as cash_amount`).
+		Where("year = ?", year).
+		Group("type").
+		Order("type ASC").
+		Scan(&summary.ByType).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range summary.ByType {
+		summary.TotalRice += t.RiceKg
+		summary.TotalCash += t.CashAmount
+	}
+
+	var split struct {
+		RicePeople int64
+		CashPeople int64
+	}
+	err = db.Model(&models.ZakatPayment{}).
+		Select(`COALESCE(SUM(people_count) FILTER (WHERE rice_kg > 0 AND cash_amount = 0), 0) as rice_people,
+			COALESCE(SUM(people_count) FILTER (WHERE rice_kg = 0 AND cash_amount > 0), 0) as cash_people`).
+		Where("year = ? AND type = ?", year, models.ZakatFitrah).
+		Scan(&split).Error
+	if err != nil {
+		return nil, err
+	}
+	summary.RicePeople = split.RicePeople
+	summary.CashPeople = split.CashPeople
+	return summary, nil
+}
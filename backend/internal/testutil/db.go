@@ -0,0 +1,74 @@
+// Package testutil sets up what the handler and service tests share.
+package testutil
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"masjid-baiturrahim-backend/internal/database"
+	"masjid-baiturrahim-backend/internal/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// postgresOnly are the bits of Postgres SQL the models and services use that
+// SQLite doesn't know, with what they become. IDs are generated by the
+// models' BeforeCreate hooks, and SQLite runs one writer at a time, so the
+// advisory locks have nothing to do.
+var postgresOnly = strings.NewReplacer(
+	"DEFAULT gen_random_uuid()", "",
+	"pg_advisory_xact_lock(", "abs(",
+)
+
+// NewDB returns a migrated SQLite database that lives as long as the test.
+func NewDB(t testing.TB) *gorm.DB {
+	t.Helper()
+
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Callback().Raw().Before("gorm:raw").Register("testutil:postgres", func(tx *gorm.DB) {
+		if sql := tx.Statement.SQL.String(); strings.Contains(sql, "gen_random_uuid") || strings.Contains(sql, "pg_advisory") {
+			tx.Statement.SQL.Reset()
+			tx.Statement.SQL.WriteString(postgresOnly.Replace(sql))
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := database.SeedLedgerDefaults(db); err != nil {
+		t.Fatalf("seed ledger: %v", err)
+	}
+	return db
+}
+
+// NewUser adds an active user with role, named after it and a suffix so a
+// test can have several.
+func NewUser(t testing.TB, db *gorm.DB, role models.UserRole, suffix string) models.User {
+	t.Helper()
+	name := string(role) + suffix
+	user := models.User{
+		Username:     name,
+		Email:        name + "@example.com",
+		PasswordHash: "-",
+		FullName:     name,
+		Role:         role,
+		IsActive:     true,
+	}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NormalizePhone turns the many ways Indonesian numbers are written
+// ("0812-3456-789", "62 812 3456 789", "+62812...", "0062812...") into +62
+// form. It returns "" when too few digits remain to be a phone number.
+func NormalizePhone(phone string) string {
+	var digits strings.Builder
+	for _, r := range phone {
+		if unicode.IsDigit(r) {
+			digits.WriteRune(r)
+		}
+	}
+	d := digits.String()
+
+	switch {
+	case strings.HasPrefix(d, "0062"): // dialled from abroad
+		d = d[4:]
+	case strings.HasPrefix(d, "62"):
+		d = d[2:]
+	case strings.HasPrefix(d, "0"):
+		d = d[1:]
+	}
+	d = strings.TrimLeft(d, "0")
+	if len(d) < 8 {
+		return ""
+	}
+	return "+62" + d
+}
+
+// NormalizeEmail lowercases and trims an address for comparison.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// CanonicalEmail reduces an address to the mailbox it delivers to, so
+// "Ahmad.Fauzi+infaq@googlemail.com" and "ahmadfauzi@gmail.com" compare
+// equal. Only Gmail ignores dots; a +tag is dropped everywhere.
+func CanonicalEmail(email string) string {
+	local, domain, ok := strings.Cut(NormalizeEmail(email), "@")
+	if !ok {
+		return NormalizeEmail(email)
+	}
+	local, _, _ = strings.Cut(local, "+")
+	if domain == "googlemail.com" {
+		domain = "gmail.com"
+	}
+	if domain == "gmail.com" {
+		local = strings.ReplaceAll(local, ".", "")
+	}
+	return local + "@" + domain
+}
+
+// NormalizeName reduces a name to lowercase words without honorifics, so
+// "Bpk. H. Ahmad  Fauzi" and "ahmad fauzi" compare equal.
+func NormalizeName(name string) string {
+	honorifics := map[string]bool{
+		"bapak": true, "bpk": true, "pak": true, "ibu": true, "bu": true,
+		"h": true, "hj": true, "haji": true, "hajjah": true,
+		"sdr": true, "sdri": true, "saudara": true, "saudari": true,
+		"dr": true, "ir": true, "ust": true, "ustadz": true, "ustadzah": true,
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	kept := words[:0]
+	for _, w := range words {
+		if !honorifics[w] {
+			kept = append(kept, w)
+		}
+	}
+	return strings.Join(kept, " ")
+}
@@ -0,0 +1,61 @@
+package utils
+
+import "testing"
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"081234567890", "+6281234567890"},
+		{"0812-3456-7890", "+6281234567890"},
+		{"62 812 3456 7890", "+6281234567890"},
+		{"+62 812-3456-7890", "+6281234567890"},
+		{"+62 (0)812 3456 7890", "+6281234567890"},
+		{"0062 812 3456 7890", "+6281234567890"},
+		{"0062812345678", "+62812345678"},
+		{"(021) 7654321", "+62217654321"},
+		{"812 3456 7890", "+6281234567890"},
+		{"0812", ""},
+		{"", ""},
+		{"tidak ada", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizePhone(tt.in); got != tt.want {
+			t.Errorf("NormalizePhone(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCanonicalEmail(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Ahmad.Fauzi@Gmail.com", "ahmadfauzi@gmail.com"},
+		{"ahmadfauzi@googlemail.com", "ahmadfauzi@gmail.com"},
+		{" ahmad.fauzi+infaq@gmail.com ", "ahmadfauzi@gmail.com"},
+		{"ahmad.fauzi@yahoo.co.id", "ahmad.fauzi@yahoo.co.id"},
+		{"ahmad.fauzi+zakat@yahoo.co.id", "ahmad.fauzi@yahoo.co.id"},
+		{"bukan-email", "bukan-email"},
+	}
+	for _, tt := range tests {
+		if got := CanonicalEmail(tt.in); got != tt.want {
+			t.Errorf("CanonicalEmail(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Bpk. H. Ahmad  Fauzi", "ahmad fauzi"},
+		{"Hj. Siti Aminah, S.Pd", "siti aminah s pd"},
+		{"Ustadz Abdul-Somad", "abdul somad"},
+		{"Ibu", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeName(tt.in); got != tt.want {
+			t.Errorf("NormalizeName(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -10,8 +10,8 @@ import (
 type TokenType string
 
 const (
-	TokenTypeAccess  TokenType = "access"
-	TokenTypeRefresh TokenType = "refresh"
+	TokenTypeAccess TokenType = "access"
+	TokenTypeDonor  TokenType = "donor"
 )
 
 type Claims struct {
@@ -19,12 +19,16 @@ type Claims struct {
 	Email    string    `json:"email"`
 	Role     string    `json:"role"`
 	TokenType TokenType `json:"token_type"`
+	SessionID uuid.UUID `json:"sid,omitempty"` // staff login session, see models.AuthSession
 	jwt.RegisteredClaims
 }
 
-func GenerateAccessToken(userID uuid.UUID, email, role, secret string) (string, error) {
+// GenerateAccessToken issues a short-lived token for a staff user, valid as
+// long as its session isn't revoked.
+func GenerateAccessToken(userID, sessionID uuid.UUID, email, role, secret string) (string, error) {
 	claims := Claims{
 		UserID:   userID,
+		SessionID: sessionID,
 		Email:    email,
 		Role:     role,
 		TokenType: TokenTypeAccess,
@@ -39,14 +43,15 @@ func GenerateAccessToken(userID uuid.UUID, email, role, secret string) (string,
 	return token.SignedString([]byte(secret))
 }
 
-func GenerateRefreshToken(userID uuid.UUID, email, role, secret string) (string, error) {
+// GenerateDonorToken issues a token for a donor signed in through a login
+// link. UserID carries the donor's ID; donors have no role.
+func GenerateDonorToken(donorID uuid.UUID, email, secret string) (string, error) {
 	claims := Claims{
-		UserID:   userID,
-		Email:    email,
-		Role:     role,
-		TokenType: TokenTypeRefresh,
+		UserID:    donorID,
+		Email:     email,
+		TokenType: TokenTypeDonor,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(7 * 24 * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 		},
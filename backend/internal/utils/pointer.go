@@ -0,0 +1,10 @@
+package utils
+
+// StringValue returns the string s points to, or "" for a nil pointer, for
+// optional columns that are written out as plain text.
+func StringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,13 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+)
+
+var nonSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Slugify turns a title such as "Renovasi Kubah 2026" into "renovasi-kubah-2026".
+func Slugify(s string) string {
+	return strings.Trim(nonSlugChars.ReplaceAllString(strings.ToLower(s), "-"), "-")
+}